@@ -0,0 +1,92 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"msgcenter/platform/auth"
+	"msgcenter/testkit"
+	"msgcenter/utils/objectid"
+)
+
+// adminRoutes 运维接口, 均需管理员令牌
+var adminRoutes = []struct {
+	method string
+	path   string
+	body   string
+}{
+	{http.MethodPut, "/admin/log/level", `{"module":"sql","level":"debug"}`},
+	{http.MethodGet, "/admin/audit-logs", ""},
+	{http.MethodGet, "/admin/dead-letters", ""},
+}
+
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	env := testkit.NewEnv(t)
+	s := env.NewServer(t)
+
+	tokens := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"未携带令牌", "", http.StatusUnauthorized},
+		{"无效令牌", "bad.token", http.StatusUnauthorized},
+		{"普通用户", env.Token(t, auth.Claims{UserID: objectid.New()}), http.StatusForbidden},
+		{"管理员", env.AdminToken(t), http.StatusOK},
+	}
+	for _, r := range adminRoutes {
+		for _, tt := range tokens {
+			t.Run(r.path+"/"+tt.name, func(t *testing.T) {
+				var body io.Reader
+				if r.body != "" {
+					body = strings.NewReader(r.body)
+				}
+				req := httptest.NewRequest(r.method, r.path, body)
+				if body != nil {
+					req.Header.Set("Content-Type", "application/json")
+				}
+				if tt.token != "" {
+					req.Header.Set("Authorization", "Bearer "+tt.token)
+				}
+				resp, err := s.App.Test(req, 5000)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tt.want {
+					t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+				}
+			})
+		}
+	}
+}
+
+// 所有 /admin 下的路由都不能匿名访问
+func TestAllAdminRoutesRejectAnonymous(t *testing.T) {
+	env := testkit.NewEnv(t)
+	s := env.NewServer(t)
+
+	id := objectid.New().String()
+	checked := 0
+	for _, route := range s.App.GetRoutes(true) {
+		if !strings.HasPrefix(route.Path, "/admin/") {
+			continue
+		}
+		path := strings.ReplaceAll(route.Path, ":id", id)
+		resp, err := s.App.Test(httptest.NewRequest(route.Method, path, nil), 5000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s %s status = %d, want 401", route.Method, route.Path, resp.StatusCode)
+		}
+		checked++
+	}
+	if checked == 0 {
+		t.Fatal("未找到 /admin 路由")
+	}
+}
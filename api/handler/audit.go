@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"msgcenter/api/handler/middleware"
+	"msgcenter/app"
+	"msgcenter/platform/ent/audit"
+	"time"
+)
+
+func InitAudit(router *fiber.App, svc *app.ServiceApp) {
+	router.Get("/admin/audit-logs", middleware.RequireAdmin(svc.Auth), queryAuditLogs(svc)).Name("查询审计日志")
+}
+
+type auditLogQuery struct {
+	Entity   string `query:"entity"`    // 实体类型, 如 User
+	EntityID string `query:"entity_id"` // 实体主键
+	Actor    string `query:"actor"`     // 操作者, 后台任务为 system
+	From     string `query:"from"`      // 起始时间(包含), RFC3339
+	To       string `query:"to"`        // 结束时间(不包含), RFC3339
+	Limit    int    `query:"limit"`
+	Offset   int    `query:"offset"`
+}
+
+func queryAuditLogs(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req auditLogQuery
+		if err := c.QueryParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		if req.Limit < 0 || req.Offset < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "limit 和 offset 不能为负数")
+		}
+
+		if _, err := QueryObjectID(c, "entity_id"); err != nil {
+			return err
+		}
+
+		filter := audit.Filter{
+			Entity:   req.Entity,
+			EntityID: req.EntityID,
+			Actor:    req.Actor,
+			Limit:    req.Limit,
+			Offset:   req.Offset,
+		}
+		var err error
+		if filter.From, err = parseTime(req.From); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "from 时间格式错误, 应为RFC3339")
+		}
+		if filter.To, err = parseTime(req.To); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "to 时间格式错误, 应为RFC3339")
+		}
+
+		logs, err := audit.Query(c.UserContext(), svc.DbClient(), filter)
+		if err != nil {
+			return err
+		}
+		return ok(c, logs)
+	}
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,32 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/auditlog"
+)
+
+func TestHTTPMutationAuditActor(t *testing.T) {
+	e := newConversationEnv(t, 2)
+	id := e.create(t, 0, `{"type":"Group","member_ids":["`+e.users[1].String()+`"]}`)
+	if status, _ := e.do(t, e.tokens[1], http.MethodPost, "/conversations/"+id.String()+"/leave", `{}`); status != http.StatusOK {
+		t.Fatalf("退出会话 status = %d", status)
+	}
+
+	ctx := context.Background()
+	created := e.server.DbClient.AuditLog.Query().
+		Where(auditlog.Entity(gen.TypeConversation), auditlog.EntityID(id.String()), auditlog.OpEQ(auditlog.OpCreate)).
+		OnlyX(ctx)
+	if created.Actor != e.users[0].String() {
+		t.Fatalf("创建会话的操作者 = %q, want %q", created.Actor, e.users[0])
+	}
+	left := e.server.DbClient.AuditLog.Query().
+		Where(auditlog.Entity(gen.TypeConversationMember), auditlog.OpEQ(auditlog.OpDelete)).
+		OnlyX(ctx)
+	if left.Actor != e.users[1].String() {
+		t.Fatalf("退出会话的操作者 = %q, want %q", left.Actor, e.users[1])
+	}
+}
@@ -0,0 +1,457 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"msgcenter/api/handler/middleware"
+	"msgcenter/app"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/conversation"
+	"msgcenter/platform/ent/gen/conversationmember"
+	"msgcenter/platform/ent/gen/message"
+	conversationsvc "msgcenter/service/conversation"
+	"msgcenter/service/unread"
+	"msgcenter/utils/logger"
+	"msgcenter/utils/objectid"
+)
+
+const (
+	defaultMaxMembers = 2000
+	defaultPageLimit  = 50
+	maxPageLimit      = 200
+)
+
+// InitConversation 注册会话接口. 创建者、操作者和成员本人均取自令牌, 不信任请求体中的用户ID
+func InitConversation(router *fiber.App, svc *app.ServiceApp) {
+	authed := middleware.Authenticate(svc.Auth)
+	router.Post("/conversations", authed, createConversation(svc)).Name("创建会话")
+	router.Get("/conversations/:id", authed, getConversation(svc)).Name("查询会话")
+	router.Get("/conversations/:id/members", authed, listConversationMembers(svc)).Name("查询会话成员")
+	router.Get("/conversations/:id/messages", authed, listConversationMessages(svc)).Name("拉取会话消息")
+	router.Post("/conversations/:id/join", authed, joinConversation(svc)).Name("加入群聊")
+	router.Post("/conversations/:id/leave", authed, leaveConversation(svc)).Name("退出群聊")
+	router.Post("/conversations/:id/kick", authed, kickConversationMember(svc)).Name("移出群成员")
+	router.Post("/conversations/:id/mute", authed, muteConversationMember(svc)).Name("禁言群成员")
+	router.Post("/conversations/:id/role", authed, setConversationRole(svc)).Name("设置群成员角色")
+	router.Post("/conversations/:id/read", authed, markConversationRead(svc)).Name("标记会话已读")
+}
+
+// caller 返回令牌中的用户ID, 不含用户的令牌(如管理员令牌)不能代表成员操作会话
+func caller(c *fiber.Ctx) (objectid.ID, error) {
+	claims, _ := middleware.Claims(c)
+	if claims.UserID.IsZero() {
+		return objectid.ID{}, fiber.NewError(fiber.StatusForbidden, "需要用户令牌")
+	}
+	return claims.UserID, nil
+}
+
+// checkMember 校验会话存在且令牌中的用户是成员, 只有成员可以查看会话
+func checkMember(c *fiber.Ctx, client *gen.Client, id objectid.ID) error {
+	userID, err := caller(c)
+	if err != nil {
+		return err
+	}
+	if _, err := conversationsvc.Get(c.UserContext(), client, id); err != nil {
+		return conversationError(err)
+	}
+	if _, err := conversationsvc.Member(c.UserContext(), client, id, userID); err != nil {
+		return conversationError(err)
+	}
+	return nil
+}
+
+type createConversationRequest struct {
+	Type        string        `json:"type"` // Direct / Group
+	Name        string        `json:"name"`
+	MemberIDs   []objectid.ID `json:"member_ids"`
+	MemberLimit int           `json:"member_limit"` // 为 0 时使用配置的上限
+}
+
+func createConversation(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ownerID, err := caller(c)
+		if err != nil {
+			return err
+		}
+		var req createConversationRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		typ := conversation.Type(req.Type)
+		if conversation.TypeValidator(typ) != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "type 只能是 Direct 或 Group")
+		}
+		maxMembers := svc.Config().Conversation.MaxMembers
+		if maxMembers <= 0 {
+			maxMembers = defaultMaxMembers
+		}
+		if req.MemberLimit < 0 || req.MemberLimit > maxMembers {
+			return fiber.NewError(fiber.StatusBadRequest, "member_limit 不能超过 "+strconv.Itoa(maxMembers))
+		}
+		if req.MemberLimit == 0 {
+			req.MemberLimit = maxMembers
+		}
+
+		conv, err := conversationsvc.Create(c.UserContext(), svc.DbClient(), conversationsvc.CreateInput{
+			Type:        typ,
+			Name:        req.Name,
+			OwnerID:     ownerID,
+			MemberIDs:   req.MemberIDs,
+			MemberLimit: req.MemberLimit,
+		})
+		if err != nil {
+			return conversationError(err)
+		}
+		logger.Ctx(c.UserContext()).Info("会话已创建",
+			zap.Stringer("id", conv.ID),
+			zap.String("type", string(conv.Type)),
+			zap.Int("members", conv.MemberCount),
+		)
+		return ok(c, conv)
+	}
+}
+
+func getConversation(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		client := svc.DbClient()
+		if err := checkMember(c, client, id); err != nil {
+			return err
+		}
+		conv, err := conversationsvc.Get(c.UserContext(), client, id)
+		if err != nil {
+			return conversationError(err)
+		}
+		return ok(c, conv)
+	}
+}
+
+type pageQuery struct {
+	Limit  int `query:"limit"`
+	Offset int `query:"offset"`
+}
+
+func listConversationMembers(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		var req pageQuery
+		if err := c.QueryParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		limit, err := pageLimit(req.Limit)
+		if err != nil {
+			return err
+		}
+		if req.Offset < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "offset 不能为负数")
+		}
+		if err := checkMember(c, svc.DbClient(), id); err != nil {
+			return err
+		}
+		members, err := conversationsvc.Members(c.UserContext(), svc.DbClient(), id, limit, req.Offset)
+		if err != nil {
+			return err
+		}
+		return ok(c, members)
+	}
+}
+
+// listConversationMessages 读扩散: 成员按消息ID游标拉取会话消息, 大群的离线消息只能由此获取
+func listConversationMessages(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		after, err := queryCursor(c, "after")
+		if err != nil {
+			return err
+		}
+		limit, err := pageLimit(c.QueryInt("limit"))
+		if err != nil {
+			return err
+		}
+
+		client := svc.DbClient()
+		if err := checkMember(c, client, id); err != nil {
+			return err
+		}
+		messages, err := client.Message.Query().
+			Where(
+				message.ConversationID(id),
+				message.IDGT(after),
+				message.Or(message.ExpireTimeIsNil(), message.ExpireTimeGT(time.Now())),
+			).
+			Order(message.ByID()).
+			Limit(limit).
+			All(c.UserContext())
+		if err != nil {
+			return err
+		}
+		return ok(c, messages)
+	}
+}
+
+// memberRequest 管理群成员的请求, 操作者为令牌中的用户, UserID 为被操作的成员
+type memberRequest struct {
+	OperatorID objectid.ID `json:"-"`
+	UserID     objectid.ID `json:"user_id"`
+	Duration   int         `json:"duration"` // 禁言秒数, 0 表示解除禁言
+	Role       string      `json:"role"`
+}
+
+func parseMemberRequest(c *fiber.Ctx) (objectid.ID, memberRequest, error) {
+	var req memberRequest
+	id, err := ParamObjectID(c, "id")
+	if err != nil {
+		return id, req, err
+	}
+	if req.OperatorID, err = caller(c); err != nil {
+		return id, req, err
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return id, req, fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+	}
+	if req.UserID.IsZero() {
+		return id, req, fiber.NewError(fiber.StatusBadRequest, "user_id 不能为空")
+	}
+	return id, req, nil
+}
+
+func joinConversation(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		userID, err := caller(c)
+		if err != nil {
+			return err
+		}
+		member, err := conversationsvc.Join(c.UserContext(), svc.DbClient(), id, userID)
+		if err != nil {
+			return conversationError(err)
+		}
+		logger.Ctx(c.UserContext()).Info("用户已加入群聊", zap.Stringer("id", id), zap.Stringer("user_id", userID))
+		return ok(c, member)
+	}
+}
+
+func leaveConversation(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		userID, err := caller(c)
+		if err != nil {
+			return err
+		}
+		if err := conversationsvc.Leave(c.UserContext(), svc.DbClient(), id, userID); err != nil {
+			return conversationError(err)
+		}
+		clearUnread(c, svc, id, userID)
+		logger.Ctx(c.UserContext()).Info("用户已退出群聊", zap.Stringer("id", id), zap.Stringer("user_id", userID))
+		return ok(c, nil)
+	}
+}
+
+func kickConversationMember(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, req, err := parseMemberRequest(c)
+		if err != nil {
+			return err
+		}
+		if err := conversationsvc.Kick(c.UserContext(), svc.DbClient(), id, req.OperatorID, req.UserID); err != nil {
+			return conversationError(err)
+		}
+		clearUnread(c, svc, id, req.UserID)
+		logger.Ctx(c.UserContext()).Info("群成员已被移出",
+			zap.Stringer("id", id),
+			zap.Stringer("operator_id", req.OperatorID),
+			zap.Stringer("user_id", req.UserID),
+		)
+		return ok(c, nil)
+	}
+}
+
+func muteConversationMember(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, req, err := parseMemberRequest(c)
+		if err != nil {
+			return err
+		}
+		if req.Duration < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "duration 不能为负数")
+		}
+		var until time.Time
+		if req.Duration > 0 {
+			until = time.Now().Add(time.Duration(req.Duration) * time.Second)
+		}
+		member, err := conversationsvc.Mute(c.UserContext(), svc.DbClient(), id, req.OperatorID, req.UserID, until)
+		if err != nil {
+			return conversationError(err)
+		}
+		logger.Ctx(c.UserContext()).Info("群成员禁言已更新",
+			zap.Stringer("id", id),
+			zap.Stringer("operator_id", req.OperatorID),
+			zap.Stringer("user_id", req.UserID),
+			zap.Int("duration", req.Duration),
+		)
+		return ok(c, member)
+	}
+}
+
+func setConversationRole(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, req, err := parseMemberRequest(c)
+		if err != nil {
+			return err
+		}
+		role := conversationmember.Role(req.Role)
+		if err := conversationsvc.SetRole(c.UserContext(), svc.DbClient(), id, req.OperatorID, req.UserID, role); err != nil {
+			return conversationError(err)
+		}
+		logger.Ctx(c.UserContext()).Info("群成员角色已更新",
+			zap.Stringer("id", id),
+			zap.Stringer("operator_id", req.OperatorID),
+			zap.Stringer("user_id", req.UserID),
+			zap.String("role", req.Role),
+		)
+		return ok(c, nil)
+	}
+}
+
+type readRequest struct {
+	MessageID string `json:"message_id"` // 已读到的消息ID, 为空表示会话最新的消息
+}
+
+type readResponse struct {
+	ConversationID objectid.ID `json:"conversation_id"`
+	UserID         objectid.ID `json:"user_id"`
+	ReadCursor     string      `json:"read_cursor"`
+	ReadTime       *time.Time  `json:"read_time,omitempty"`
+	Unread         int         `json:"unread"`
+}
+
+// markConversationRead 推进成员的已读游标, 重新计算未读数, 并向其他成员推送已读回执
+func markConversationRead(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		userID, err := caller(c)
+		if err != nil {
+			return err
+		}
+		var req readRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		var messageID int64
+		if req.MessageID != "" {
+			messageID, err = strconv.ParseInt(req.MessageID, 10, 64)
+			if err != nil || messageID <= 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "message_id 不是有效的消息ID")
+			}
+		}
+
+		client := svc.DbClient()
+		if _, err := conversationsvc.Get(c.UserContext(), client, id); err != nil {
+			return conversationError(err)
+		}
+		member, advanced, err := conversationsvc.MarkRead(c.UserContext(), client, id, userID, messageID)
+		if err != nil {
+			return conversationError(err)
+		}
+		n, err := unread.Refresh(c.UserContext(), client, svc.RedisClient(), member)
+		if err != nil {
+			return err
+		}
+		if advanced {
+			logger.Ctx(c.UserContext()).Debug("已读游标已更新",
+				zap.Stringer("id", id),
+				zap.Stringer("user_id", userID),
+				zap.Int64("read_cursor", member.ReadCursor),
+			)
+		}
+		return ok(c, readResponse{
+			ConversationID: id,
+			UserID:         userID,
+			ReadCursor:     strconv.FormatInt(member.ReadCursor, 10),
+			ReadTime:       member.ReadTime,
+			Unread:         n,
+		})
+	}
+}
+
+// clearUnread 成员离开会话后删除其未读数, 失败时等待下次对账
+func clearUnread(c *fiber.Ctx, svc *app.ServiceApp, id, userID objectid.ID) {
+	rdb := svc.RedisClient()
+	if rdb == nil {
+		return
+	}
+	if err := unread.Set(c.UserContext(), rdb, userID, id, 0); err != nil {
+		logger.Ctx(c.UserContext()).Warn("清除未读数失败", zap.Stringer("id", id), zap.Stringer("user_id", userID), zap.Error(err))
+	}
+}
+
+// conversationError 将会话服务的错误转换为对应的 HTTP 状态码, 其他错误原样返回
+func conversationError(err error) error {
+	switch {
+	case errors.Is(err, conversationsvc.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, conversationsvc.ErrNotMember),
+		errors.Is(err, conversationsvc.ErrForbidden),
+		errors.Is(err, conversationsvc.ErrMuted):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case errors.Is(err, conversationsvc.ErrAlreadyMember),
+		errors.Is(err, conversationsvc.ErrFull):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, conversationsvc.ErrUserNotFound),
+		errors.Is(err, conversationsvc.ErrOwnerLeave),
+		errors.Is(err, conversationsvc.ErrDirect),
+		errors.Is(err, conversationsvc.ErrDirectMembers),
+		errors.Is(err, conversationsvc.ErrInvalidRole),
+		errors.Is(err, conversationsvc.ErrMissingOwnerID),
+		errors.Is(err, conversationsvc.ErrMessageNotFound):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return err
+}
+
+// pageLimit 校验分页大小, 0 使用默认值
+func pageLimit(limit int) (int, error) {
+	switch {
+	case limit < 0:
+		return 0, fiber.NewError(fiber.StatusBadRequest, "limit 不能为负数")
+	case limit == 0:
+		return defaultPageLimit, nil
+	default:
+		return min(limit, maxPageLimit), nil
+	}
+}
+
+// queryCursor 解析 snowflake ID 游标, 为空时返回 0
+func queryCursor(c *fiber.Ctx, name string) (int64, error) {
+	s := c.Query(name)
+	if s == "" {
+		return 0, nil
+	}
+	cursor, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || cursor < 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, name+" 不是有效的游标")
+	}
+	return cursor, nil
+}
@@ -0,0 +1,154 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"msgcenter/platform/auth"
+	"msgcenter/platform/ent/gen/conversationmember"
+	"msgcenter/server"
+	"msgcenter/testkit"
+	"msgcenter/utils/objectid"
+)
+
+type conversationEnv struct {
+	env    *testkit.Env
+	server *server.Server
+	users  []objectid.ID
+	tokens []string
+}
+
+func newConversationEnv(t *testing.T, n int) *conversationEnv {
+	t.Helper()
+	env := testkit.NewEnv(t)
+	e := &conversationEnv{env: env, server: env.NewServer(t)}
+	for range n {
+		id := e.server.DbClient.User.Create().SetName("u").SaveX(context.Background()).ID
+		e.users = append(e.users, id)
+		e.tokens = append(e.tokens, env.Token(t, auth.Claims{UserID: id}))
+	}
+	return e
+}
+
+// do 发起请求, 返回状态码和响应中的 data
+func (e *conversationEnv) do(t *testing.T, token, method, path, body string) (int, json.RawMessage) {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := e.server.App.Test(req, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Data json.RawMessage `json:"data"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out.Data
+}
+
+func (e *conversationEnv) create(t *testing.T, owner int, body string) objectid.ID {
+	t.Helper()
+	status, data := e.do(t, e.tokens[owner], http.MethodPost, "/conversations", body)
+	if status != http.StatusOK {
+		t.Fatalf("创建会话 status = %d", status)
+	}
+	var conv struct {
+		ID objectid.ID `json:"id"`
+	}
+	if err := json.Unmarshal(data, &conv); err != nil {
+		t.Fatal(err)
+	}
+	return conv.ID
+}
+
+func TestConversationOperatorFromToken(t *testing.T) {
+	e := newConversationEnv(t, 3)
+	owner, member, other := e.users[0], e.users[1], e.users[2]
+	id := e.create(t, 0, `{"type":"Group","member_ids":["`+member.String()+`","`+other.String()+`"]}`)
+	kick := "/conversations/" + id.String() + "/kick"
+
+	if status, _ := e.do(t, "", http.MethodPost, kick, `{"user_id":"`+other.String()+`"}`); status != http.StatusUnauthorized {
+		t.Fatalf("未携带令牌 status = %d, want 401", status)
+	}
+	if status, _ := e.do(t, e.env.AdminToken(t), http.MethodPost, kick, `{"user_id":"`+other.String()+`"}`); status != http.StatusForbidden {
+		t.Fatalf("不含用户的令牌 status = %d, want 403", status)
+	}
+	// 请求体中冒充群主无效, 操作者始终是令牌中的用户
+	body := `{"operator_id":"` + owner.String() + `","user_id":"` + other.String() + `"}`
+	if status, _ := e.do(t, e.tokens[1], http.MethodPost, kick, body); status != http.StatusForbidden {
+		t.Fatalf("普通成员移出成员 status = %d, want 403", status)
+	}
+	if status, _ := e.do(t, e.tokens[0], http.MethodPost, kick, body); status != http.StatusOK {
+		t.Fatalf("群主移出成员 status = %d, want 200", status)
+	}
+
+	n := e.server.DbClient.ConversationMember.Query().
+		Where(conversationmember.ConversationID(id), conversationmember.UserID(other)).
+		CountX(context.Background())
+	if n != 0 {
+		t.Fatal("被移出的成员仍在会话中")
+	}
+}
+
+func TestConversationMemberFromToken(t *testing.T) {
+	e := newConversationEnv(t, 3)
+	id := e.create(t, 0, `{"type":"Group","member_ids":["`+e.users[1].String()+`"]}`)
+	base := "/conversations/" + id.String()
+
+	// 请求体中的 user_id 被忽略, 加入和退出的都是令牌中的用户
+	if status, _ := e.do(t, e.tokens[2], http.MethodPost, base+"/join", `{"user_id":"`+e.users[1].String()+`"}`); status != http.StatusOK {
+		t.Fatalf("加入群聊 status = %d, want 200", status)
+	}
+	if status, _ := e.do(t, e.tokens[2], http.MethodPost, base+"/leave", `{}`); status != http.StatusOK {
+		t.Fatalf("退出群聊 status = %d, want 200", status)
+	}
+	if status, _ := e.do(t, e.tokens[2], http.MethodGet, base+"/messages", ""); status != http.StatusForbidden {
+		t.Fatalf("退出后拉取消息 status = %d, want 403", status)
+	}
+	if status, _ := e.do(t, e.tokens[1], http.MethodGet, base+"/messages?user_id="+e.users[2].String(), ""); status != http.StatusOK {
+		t.Fatalf("成员拉取消息 status = %d, want 200", status)
+	}
+	if status, _ := e.do(t, e.tokens[1], http.MethodPost, base+"/read", `{}`); status != http.StatusOK {
+		t.Fatalf("标记已读 status = %d, want 200", status)
+	}
+}
+
+func TestCreateDirectConversationOnce(t *testing.T) {
+	e := newConversationEnv(t, 2)
+	first := e.create(t, 0, `{"type":"Direct","member_ids":["`+e.users[1].String()+`"]}`)
+	again := e.create(t, 1, `{"type":"Direct","member_ids":["`+e.users[0].String()+`"]}`)
+	if first != again {
+		t.Fatalf("双方发起的单聊 = %s, %s, 应为同一会话", first, again)
+	}
+}
+
+func TestConversationReadsRequireMember(t *testing.T) {
+	e := newConversationEnv(t, 3)
+	id := e.create(t, 0, `{"type":"Group","member_ids":["`+e.users[1].String()+`"]}`)
+	for _, path := range []string{"/conversations/" + id.String(), "/conversations/" + id.String() + "/members"} {
+		if status, _ := e.do(t, "", http.MethodGet, path, ""); status != http.StatusUnauthorized {
+			t.Fatalf("%s 未携带令牌 status = %d, want 401", path, status)
+		}
+		if status, _ := e.do(t, e.tokens[2], http.MethodGet, path, ""); status != http.StatusForbidden {
+			t.Fatalf("%s 非成员 status = %d, want 403", path, status)
+		}
+		if status, _ := e.do(t, e.tokens[1], http.MethodGet, path, ""); status != http.StatusOK {
+			t.Fatalf("%s 成员 status = %d, want 200", path, status)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"msgcenter/api/handler/middleware"
+	"msgcenter/app"
+	"msgcenter/platform/dlq"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/utils/logger"
+	"msgcenter/utils/objectid"
+)
+
+func InitDeadLetter(router *fiber.App, svc *app.ServiceApp) {
+	store := dlq.NewStore(svc.DbClient, svc.Broker)
+	admin := middleware.RequireAdmin(svc.Auth)
+	router.Get("/admin/dead-letters", admin, listDeadLetters(store)).Name("查询死信")
+	router.Get("/admin/dead-letters/:id", admin, getDeadLetter(store)).Name("查询单条死信")
+	router.Post("/admin/dead-letters/replay", admin, replayDeadLetters(store)).Name("批量重放死信")
+	router.Post("/admin/dead-letters/:id/replay", admin, replayDeadLetter(store)).Name("重放死信")
+	router.Delete("/admin/dead-letters", admin, purgeDeadLetters(store)).Name("清理死信")
+	router.Delete("/admin/dead-letters/:id", admin, deleteDeadLetter(store)).Name("删除死信")
+}
+
+type deadLetterFilter struct {
+	Topic        string `query:"topic" json:"topic"`
+	Subscription string `query:"subscription" json:"subscription"`
+	From         string `query:"from" json:"from"` // 起始时间(包含), RFC3339
+	To           string `query:"to" json:"to"`     // 结束时间(不包含), RFC3339
+	Limit        int    `query:"limit" json:"limit"`
+	Offset       int    `query:"offset" json:"offset"`
+}
+
+func (req deadLetterFilter) parse() (dlq.Filter, error) {
+	if req.Limit < 0 || req.Offset < 0 {
+		return dlq.Filter{}, fiber.NewError(fiber.StatusBadRequest, "limit 和 offset 不能为负数")
+	}
+	filter := dlq.Filter{
+		Topic:        req.Topic,
+		Subscription: req.Subscription,
+		Limit:        req.Limit,
+		Offset:       req.Offset,
+	}
+	var err error
+	if filter.From, err = parseTime(req.From); err != nil {
+		return filter, fiber.NewError(fiber.StatusBadRequest, "from 时间格式错误, 应为RFC3339")
+	}
+	if filter.To, err = parseTime(req.To); err != nil {
+		return filter, fiber.NewError(fiber.StatusBadRequest, "to 时间格式错误, 应为RFC3339")
+	}
+	return filter, nil
+}
+
+// empty 没有任何过滤条件, 批量操作时拒绝以免误操作全部死信
+func (req deadLetterFilter) empty() bool {
+	return req.Topic == "" && req.Subscription == "" && req.From == "" && req.To == ""
+}
+
+func listDeadLetters(store *dlq.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req deadLetterFilter
+		if err := c.QueryParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		filter, err := req.parse()
+		if err != nil {
+			return err
+		}
+		letters, err := store.List(c.UserContext(), filter)
+		if err != nil {
+			return err
+		}
+		return ok(c, letters)
+	}
+}
+
+func getDeadLetter(store *dlq.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		dl, err := store.Get(c.UserContext(), id)
+		if err != nil {
+			return deadLetterError(err)
+		}
+		return ok(c, dl)
+	}
+}
+
+func replayDeadLetter(store *dlq.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		dl, err := store.Replay(c.UserContext(), id)
+		if err != nil {
+			return deadLetterError(err)
+		}
+		logger.Ctx(c.UserContext()).Info("死信已重放",
+			zap.Stringer("id", id),
+			zap.String("topic", dl.Topic),
+			zap.String("subscription", dl.Subscription),
+		)
+		return ok(c, dl)
+	}
+}
+
+type replayDeadLettersRequest struct {
+	IDs             []objectid.ID `json:"ids"`              // 指定时忽略过滤条件
+	IncludeReplayed bool          `json:"include_replayed"` // 按条件重放时包含已重放且未再次失败的死信
+	deadLetterFilter
+}
+
+func replayDeadLetters(store *dlq.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req replayDeadLettersRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+
+		var (
+			n   int
+			err error
+		)
+		if len(req.IDs) > 0 {
+			n, err = store.ReplayIDs(c.UserContext(), req.IDs)
+		} else {
+			if req.empty() {
+				return fiber.NewError(fiber.StatusBadRequest, "ids 和过滤条件不能同时为空")
+			}
+			filter, perr := req.parse()
+			if perr != nil {
+				return perr
+			}
+			filter.IncludeReplayed = req.IncludeReplayed
+			n, err = store.ReplayMatching(c.UserContext(), filter)
+		}
+		logger.Ctx(c.UserContext()).Info("死信已批量重放", zap.Int("count", n), zap.Error(err))
+		if err != nil {
+			return deadLetterError(err)
+		}
+		return ok(c, fiber.Map{"replayed": n})
+	}
+}
+
+func deleteDeadLetter(store *dlq.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		if err := store.Delete(c.UserContext(), id); err != nil {
+			return deadLetterError(err)
+		}
+		return ok(c, nil)
+	}
+}
+
+func purgeDeadLetters(store *dlq.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req deadLetterFilter
+		if err := c.QueryParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		if req.empty() {
+			return fiber.NewError(fiber.StatusBadRequest, "清理死信至少需要一个过滤条件")
+		}
+		filter, err := req.parse()
+		if err != nil {
+			return err
+		}
+		n, err := store.Purge(c.UserContext(), filter)
+		if err != nil {
+			return err
+		}
+		logger.Ctx(c.UserContext()).Info("死信已清理",
+			zap.Int("count", n),
+			zap.String("topic", req.Topic),
+			zap.String("subscription", req.Subscription),
+		)
+		return ok(c, fiber.Map{"deleted": n})
+	}
+}
+
+func deadLetterError(err error) error {
+	if gen.IsNotFound(err) {
+		return fiber.NewError(fiber.StatusNotFound, "死信不存在")
+	}
+	return err
+}
@@ -1,11 +1,28 @@
 package handler
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"github.com/gofiber/fiber/v2"
+	"msgcenter/platform/health"
+)
 
-func InitHealth(app *fiber.App) {
-	app.Get("/health", health).Name("consul健康检测")
+func InitHealth(app *fiber.App, checker *health.Checker) {
+	app.Get("/health", livez).Name("存活检测")
+	app.Get("/livez", livez).Name("存活检测")
+	app.Get("/readyz", readyz(checker)).Name("consul就绪检测")
 }
 
-func health(c *fiber.Ctx) error {
-	return c.SendStatus(200)
+// livez 进程存活即返回200, 不检查外部依赖, 避免依赖抖动导致实例被重启
+func livez(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": health.StatusUp})
+}
+
+// readyz 检查数据库、redis、consul等依赖, 任一不可用或正在下线时返回503
+func readyz(checker *health.Checker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := checker.Check(c.UserContext())
+		if !report.Ready() {
+			c.Status(fiber.StatusServiceUnavailable)
+		}
+		return c.JSON(report)
+	}
 }
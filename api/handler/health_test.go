@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"msgcenter/platform/health"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	var down atomic.Bool
+	down.Store(true)
+	checker.Register("db", func(context.Context) error {
+		if down.Load() {
+			return errors.New("down")
+		}
+		return nil
+	})
+	app := fiber.New()
+	InitHealth(app, checker)
+
+	status := func(path string) int {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// 依赖不可用只影响就绪检查, 不影响存活检查
+	if got := status("/livez"); got != http.StatusOK {
+		t.Fatalf("/livez = %d", got)
+	}
+	if got := status("/readyz"); got != http.StatusServiceUnavailable {
+		t.Fatalf("依赖不可用时 /readyz = %d", got)
+	}
+	down.Store(false)
+	if got := status("/readyz"); got != http.StatusOK {
+		t.Fatalf("/readyz = %d", got)
+	}
+}
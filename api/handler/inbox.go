@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"msgcenter/api/handler/middleware"
+	"msgcenter/app"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/inboxentry"
+	"msgcenter/platform/ent/gen/message"
+	"msgcenter/service/device"
+	"msgcenter/utils/objectid"
+)
+
+func InitInbox(router *fiber.App, svc *app.ServiceApp) {
+	router.Get("/devices/:id/inbox", middleware.Authenticate(svc.Auth), listInbox(svc)).Name("拉取设备收件箱")
+}
+
+// checkDevice 只允许拉取令牌中的设备, 或已绑定到令牌中用户的设备
+func checkDevice(c *fiber.Ctx, client *gen.Client, deviceID objectid.ID) error {
+	claims, _ := middleware.Claims(c)
+	if !claims.DeviceID.IsZero() && claims.DeviceID == deviceID {
+		return nil
+	}
+	if claims.UserID.IsZero() {
+		return fiber.NewError(fiber.StatusForbidden, "无权访问该设备")
+	}
+	err := device.CheckBound(c.UserContext(), client, claims.UserID, deviceID)
+	if errors.Is(err, device.ErrNotBound) {
+		return fiber.NewError(fiber.StatusForbidden, "无权访问该设备")
+	}
+	return err
+}
+
+type inboxItem struct {
+	Cursor  string       `json:"cursor"` // 收件箱条目ID, 作为下一次拉取的 after
+	Message *gen.Message `json:"message"`
+}
+
+// listInbox 设备按游标拉取写扩散的离线消息, 大群消息需通过会话拉取
+func listInbox(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		deviceID, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		after, err := queryCursor(c, "after")
+		if err != nil {
+			return err
+		}
+		limit, err := pageLimit(c.QueryInt("limit"))
+		if err != nil {
+			return err
+		}
+
+		client := svc.DbClient()
+		if err := checkDevice(c, client, deviceID); err != nil {
+			return err
+		}
+		entries, err := client.InboxEntry.Query().
+			Where(inboxentry.DeviceID(deviceID), inboxentry.IDGT(after)).
+			Order(inboxentry.ByID()).
+			Limit(limit).
+			All(c.UserContext())
+		if err != nil {
+			return err
+		}
+		ids := make([]int64, len(entries))
+		for i, e := range entries {
+			ids[i] = e.MessageID
+		}
+		messages, err := client.Message.Query().
+			Where(
+				message.IDIn(ids...),
+				message.Or(message.ExpireTimeIsNil(), message.ExpireTimeGT(time.Now())),
+			).
+			All(c.UserContext())
+		if err != nil {
+			return err
+		}
+		byID := make(map[int64]*gen.Message, len(messages))
+		for _, m := range messages {
+			byID[m.ID] = m
+		}
+
+		items := make([]inboxItem, 0, len(entries))
+		for _, e := range entries {
+			// 消息已过期或已被清理时跳过, 游标仍然前进
+			if m, ok := byID[e.MessageID]; ok {
+				items = append(items, inboxItem{Cursor: strconv.FormatInt(e.ID, 10), Message: m})
+			}
+		}
+		next := strconv.FormatInt(after, 10)
+		if len(entries) > 0 {
+			next = strconv.FormatInt(entries[len(entries)-1].ID, 10)
+		}
+		return ok(c, fiber.Map{"items": items, "next": next})
+	}
+}
@@ -0,0 +1,35 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"msgcenter/platform/auth"
+	"msgcenter/utils/objectid"
+)
+
+func TestInboxRequiresOwnDevice(t *testing.T) {
+	e := newConversationEnv(t, 2)
+	ctx := context.Background()
+	d := e.server.DbClient.Device.Create().SetClientDeviceID(objectid.New().String()).SaveX(ctx)
+	e.server.DbClient.UserDeviceRelation.Create().SetUserID(e.users[0]).SetDeviceID(d.ID).SaveX(ctx)
+	path := "/devices/" + d.ID.String() + "/inbox"
+
+	cases := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"未携带令牌", "", http.StatusUnauthorized},
+		{"其他用户", e.tokens[1], http.StatusForbidden},
+		{"其他设备", e.env.Token(t, auth.Claims{DeviceID: objectid.New()}), http.StatusForbidden},
+		{"绑定的用户", e.tokens[0], http.StatusOK},
+		{"令牌中的设备", e.env.Token(t, auth.Claims{DeviceID: d.ID}), http.StatusOK},
+	}
+	for _, tc := range cases {
+		if status, _ := e.do(t, tc.token, http.MethodGet, path, ""); status != tc.want {
+			t.Fatalf("%s status = %d, want %d", tc.name, status, tc.want)
+		}
+	}
+}
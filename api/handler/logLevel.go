@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"msgcenter/api/handler/middleware"
+	"msgcenter/app"
+	"msgcenter/utils/logger"
+)
+
+func InitLogLevel(router *fiber.App, svc *app.ServiceApp) {
+	admin := middleware.RequireAdmin(svc.Auth)
+	router.Get("/admin/log/level", admin, getLogLevel).Name("查询日志级别")
+	router.Put("/admin/log/level", admin, setLogLevel).Name("修改日志级别")
+}
+
+type logLevelRequest struct {
+	Module string `json:"module"` // 子系统名, 为空表示全局
+	Level  string `json:"level"`  // 为空表示清除通过接口设置的级别, 恢复配置文件和consul中的级别
+}
+
+func getLogLevel(c *fiber.Ctx) error {
+	manager := logger.Default()
+	if manager == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "日志系统未初始化")
+	}
+	return ok(c, manager.Levels())
+}
+
+func setLogLevel(c *fiber.Ctx) error {
+	manager := logger.Default()
+	if manager == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "日志系统未初始化")
+	}
+
+	var req logLevelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+	}
+
+	if req.Level == "" {
+		manager.ResetLevel(logger.SourceAPI, req.Module)
+	} else if err := manager.SetLevel(logger.SourceAPI, req.Module, req.Level); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	logger.Ctx(c.UserContext()).Info("日志级别已通过接口修改",
+		zap.String("module", req.Module),
+		zap.String("level", req.Level),
+		zap.String("ip", c.IP()),
+	)
+	return ok(c, manager.Levels())
+}
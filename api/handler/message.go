@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"msgcenter/api/handler/middleware"
+	"msgcenter/app"
+	"msgcenter/service/message"
+	"msgcenter/utils/logger"
+	"msgcenter/utils/objectid"
+)
+
+const (
+	defaultMaxRecipients  = 1000
+	defaultMaxPayloadSize = 64 << 10
+	defaultIdempotencyTTL = 24 * time.Hour
+)
+
+func InitMessage(router *fiber.App, svc *app.ServiceApp) {
+	ttl := svc.Config().Message.IdempotencyTTL
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	router.Post("/messages",
+		middleware.Authenticate(svc.Auth),
+		middleware.Idempotency(svc.RedisClient, ttl),
+		sendMessage(svc),
+	).Name("发送消息")
+}
+
+type sendMessageRequest struct {
+	ConversationID objectid.ID     `json:"conversation_id"`
+	UserIDs        []objectid.ID   `json:"user_ids"`
+	DeviceIDs      []objectid.ID   `json:"device_ids"`
+	ContentType    string          `json:"content_type"`
+	Payload        json.RawMessage `json:"payload"`
+	TTL            int             `json:"ttl"` // 有效期秒数, 过期后不再投递, 0 表示不过期
+}
+
+type sendMessageResponse struct {
+	ID         string     `json:"id"` // snowflake ID 超出 JS 安全整数范围, 以字符串返回
+	CreateTime time.Time  `json:"create_time"`
+	ExpireTime *time.Time `json:"expire_time,omitempty"`
+}
+
+func sendMessage(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cfg := svc.Config().Message
+		maxRecipients, maxPayloadSize := cfg.MaxRecipients, cfg.MaxPayloadSize
+		if maxRecipients <= 0 {
+			maxRecipients = defaultMaxRecipients
+		}
+		if maxPayloadSize <= 0 {
+			maxPayloadSize = defaultMaxPayloadSize
+		}
+
+		var req sendMessageRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		if req.ContentType == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "content_type 不能为空")
+		}
+		if len(req.Payload) == 0 || string(req.Payload) == "null" {
+			return fiber.NewError(fiber.StatusBadRequest, "payload 不能为空")
+		}
+		if len(req.Payload) > maxPayloadSize {
+			return fiber.NewError(fiber.StatusRequestEntityTooLarge,
+				"payload 不能超过 "+strconv.Itoa(maxPayloadSize)+" 字节")
+		}
+		if len(req.UserIDs)+len(req.DeviceIDs) > maxRecipients {
+			return fiber.NewError(fiber.StatusBadRequest,
+				"user_ids 和 device_ids 总数不能超过 "+strconv.Itoa(maxRecipients))
+		}
+		ttl, err := parseTTL(req.TTL)
+		if err != nil {
+			return err
+		}
+		// 发送者取自令牌, 不含用户的管理员令牌发送系统消息
+		claims, _ := middleware.Claims(c)
+		if claims.UserID.IsZero() && !claims.Admin {
+			return fiber.NewError(fiber.StatusForbidden, "需要用户令牌")
+		}
+
+		msg, err := message.Create(c.UserContext(), svc.DbClient(), message.Input{
+			SenderID:       claims.UserID,
+			ConversationID: req.ConversationID,
+			UserIDs:        req.UserIDs,
+			DeviceIDs:      req.DeviceIDs,
+			ContentType:    req.ContentType,
+			Payload:        req.Payload,
+			TTL:            ttl,
+		})
+		switch {
+		case errors.Is(err, message.ErrNoRecipient),
+			errors.Is(err, message.ErrSenderNotFound),
+			errors.Is(err, message.ErrUserNotFound),
+			errors.Is(err, message.ErrDeviceNotFound):
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		case err != nil:
+			return conversationError(err)
+		}
+
+		logger.Ctx(c.UserContext()).Info("消息已创建",
+			zap.Int64("id", msg.ID),
+			zap.Stringer("sender_id", msg.SenderID),
+			zap.String("content_type", msg.ContentType),
+		)
+		return ok(c, sendMessageResponse{
+			ID:         strconv.FormatInt(msg.ID, 10),
+			CreateTime: msg.CreateTime,
+			ExpireTime: msg.ExpireTime,
+		})
+	}
+}
+
+// parseTTL 将以秒为单位的消息有效期转换为 time.Duration
+func parseTTL(ttl int) (time.Duration, error) {
+	if ttl < 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "ttl 不能为负数")
+	}
+	return time.Duration(ttl) * time.Second, nil
+}
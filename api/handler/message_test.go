@@ -0,0 +1,62 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"msgcenter/platform/auth"
+	"msgcenter/utils/objectid"
+)
+
+func TestSendMessageSenderFromToken(t *testing.T) {
+	e := newConversationEnv(t, 3)
+	owner, member, outsider := e.users[0], e.users[1], e.users[2]
+	id := e.create(t, 0, `{"type":"Group","member_ids":["`+member.String()+`"]}`)
+	body := func(sender objectid.ID) string {
+		return `{"sender_id":"` + sender.String() + `","conversation_id":"` + id.String() +
+			`","content_type":"text","payload":{"text":"hi"}}`
+	}
+	senderOf := func(data json.RawMessage) objectid.ID {
+		t.Helper()
+		var resp struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatal(err)
+		}
+		msgID, _ := strconv.ParseInt(resp.ID, 10, 64)
+		return e.server.DbClient.Message.GetX(context.Background(), msgID).SenderID
+	}
+
+	if status, _ := e.do(t, "", http.MethodPost, "/messages", body(owner)); status != http.StatusUnauthorized {
+		t.Fatalf("未携带令牌 status = %d, want 401", status)
+	}
+	// 非成员冒充群主发送无效
+	if status, _ := e.do(t, e.tokens[2], http.MethodPost, "/messages", body(owner)); status != http.StatusForbidden {
+		t.Fatalf("非成员发送 status = %d, want 403", status)
+	}
+	// 只有设备的令牌不能发送系统消息
+	deviceOnly := e.env.Token(t, auth.Claims{DeviceID: objectid.New()})
+	if status, _ := e.do(t, deviceOnly, http.MethodPost, "/messages", body(objectid.ID{})); status != http.StatusForbidden {
+		t.Fatalf("不含用户的非管理员令牌 status = %d, want 403", status)
+	}
+
+	status, data := e.do(t, e.tokens[1], http.MethodPost, "/messages", body(outsider))
+	if status != http.StatusOK {
+		t.Fatalf("成员发送 status = %d, want 200", status)
+	}
+	if sender := senderOf(data); sender != member {
+		t.Fatalf("发送者 = %s, want %s", sender, member)
+	}
+
+	status, data = e.do(t, e.env.AdminToken(t), http.MethodPost, "/messages", body(owner))
+	if status != http.StatusOK {
+		t.Fatalf("管理员发送 status = %d, want 200", status)
+	}
+	if sender := senderOf(data); !sender.IsZero() {
+		t.Fatalf("管理员令牌发送者 = %s, want 系统消息", sender)
+	}
+}
@@ -0,0 +1,11 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func InitMetrics(app *fiber.App) {
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler())).Name("prometheus指标")
+}
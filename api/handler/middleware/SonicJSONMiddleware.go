@@ -28,23 +28,7 @@ func SonicJSONMiddleware() fiber.Handler {
 			c.Locals("jsonBody", jsonData)
 		}
 
-		// 继续处理请求
-		err := c.Next()
-
-		// 处理响应
-		if c.Get(fiber.HeaderContentType) == fiber.MIMEApplicationJSON {
-			// 获取响应数据
-			response := c.Response().Body()
-
-			// 使用 sonic 编码
-			var buf bytes.Buffer
-			if err := sonic.ConfigDefault.NewEncoder(&buf).Encode(response); err != nil {
-				return fiber.NewError(fiber.StatusInternalServerError, "JSON encoding failed")
-			}
-
-			c.Response().SetBodyRaw(buf.Bytes())
-		}
-
-		return err
+		// 继续处理请求, 响应由 fiber.Config.JSONEncoder 使用 sonic 编码
+		return c.Next()
 	}
 }
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"msgcenter/platform/auth"
+	"msgcenter/utils/reqctx"
+)
+
+// LocalsClaims 令牌声明在 fiber.Ctx.Locals 中的键
+const LocalsClaims = "claims"
+
+// Authenticate 校验 Authorization: Bearer 令牌, 通过后将声明写入 Locals
+func Authenticate(signer func() *auth.Signer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, err := verify(signer(), bearer(c))
+		if err != nil {
+			return err
+		}
+		setClaims(c, claims)
+		return c.Next()
+	}
+}
+
+// AuthenticateWebsocket 只用于 WebSocket 升级请求: 浏览器建立连接时无法设置请求头,
+// 没有 Authorization 头时改用 token 查询参数. 查询参数会进入访问日志和代理日志, 其他接口不接受
+func AuthenticateWebsocket(signer func() *auth.Signer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := bearer(c)
+		if token == "" {
+			token = c.Query("token")
+		}
+		claims, err := verify(signer(), token)
+		if err != nil {
+			return err
+		}
+		setClaims(c, claims)
+		return c.Next()
+	}
+}
+
+// RequireAdmin 只允许管理员令牌访问, 用于运维接口
+func RequireAdmin(signer func() *auth.Signer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, err := verify(signer(), bearer(c))
+		if err != nil {
+			return err
+		}
+		if !claims.Admin {
+			return fiber.NewError(fiber.StatusForbidden, "需要管理员权限")
+		}
+		setClaims(c, claims)
+		return c.Next()
+	}
+}
+
+// bearer 返回 Authorization 头中的令牌
+func bearer(c *fiber.Ctx) string {
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+func verify(signer *auth.Signer, token string) (auth.Claims, error) {
+	if signer == nil {
+		return auth.Claims{}, fiber.NewError(fiber.StatusServiceUnavailable, "鉴权未配置")
+	}
+	if token == "" {
+		return auth.Claims{}, fiber.NewError(fiber.StatusUnauthorized, "缺少令牌")
+	}
+	claims, err := signer.Verify(token)
+	if errors.Is(err, auth.ErrExpired) {
+		return claims, fiber.NewError(fiber.StatusUnauthorized, "令牌已过期")
+	} else if err != nil {
+		return claims, fiber.NewError(fiber.StatusUnauthorized, "无效的令牌")
+	}
+	return claims, nil
+}
+
+// setClaims 保存令牌声明, 并将用户和设备写入 UserContext, 供日志、审计和外发消息使用
+func setClaims(c *fiber.Ctx, claims auth.Claims) {
+	c.Locals(LocalsClaims, claims)
+	ctx := reqctx.WithClaims(c.UserContext(), claims.UserID, claims.DeviceID)
+	if claims.Admin {
+		ctx = reqctx.WithAdmin(ctx)
+	}
+	c.SetUserContext(ctx)
+}
+
+// Claims 返回 Authenticate 写入的令牌声明
+func Claims(c *fiber.Ctx) (auth.Claims, bool) {
+	claims, ok := c.Locals(LocalsClaims).(auth.Claims)
+	return claims, ok
+}
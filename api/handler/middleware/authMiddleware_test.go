@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"msgcenter/platform/auth"
+	"msgcenter/utils/objectid"
+	"msgcenter/utils/reqctx"
+)
+
+func TestAuthenticateSetsRequestContext(t *testing.T) {
+	signer, _ := auth.NewSigner("secret")
+	user, device := objectid.New(), objectid.New()
+	token, _ := signer.Sign(auth.Claims{UserID: user, DeviceID: device}, time.Minute)
+
+	var gotUser, gotDevice string
+	app := fiber.New()
+	app.Get("/", Authenticate(func() *auth.Signer { return signer }), func(c *fiber.Ctx) error {
+		gotUser, gotDevice = reqctx.UserID(c.UserContext()), reqctx.DeviceID(c.UserContext())
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	if gotUser != user.String() || gotDevice != device.String() {
+		t.Fatalf("user = %q, device = %q", gotUser, gotDevice)
+	}
+}
+
+func TestAuthenticateWithoutSigner(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", Authenticate(func() *auth.Signer { return nil }), func(c *fiber.Ctx) error { return nil })
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("未配置密钥时 status = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestQueryTokenOnlyForWebsocket(t *testing.T) {
+	signer, _ := auth.NewSigner("secret")
+	token, _ := signer.Sign(auth.Claims{UserID: objectid.New()}, time.Minute)
+	secret := func() *auth.Signer { return signer }
+
+	app := fiber.New()
+	app.Get("/api", Authenticate(secret), func(c *fiber.Ctx) error { return nil })
+	app.Get("/ws", AuthenticateWebsocket(secret), func(c *fiber.Ctx) error { return nil })
+
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"/api?token=" + token, http.StatusUnauthorized},
+		{"/ws?token=" + token, http.StatusOK},
+		{"/ws", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Fatalf("%s status = %d, want %d", tc.path, resp.StatusCode, tc.want)
+		}
+	}
+}
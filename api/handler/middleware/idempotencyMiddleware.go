@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/metrics"
+	"msgcenter/utils/logger"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	idempotencyKeyMaxLen = 255
+	idempotencyPrefix    = "idempotency:"
+)
+
+// idempotencyLockTTL 请求处理中的占位有效期, 处理期间每隔 1/3 有效期续期一次,
+// 实例崩溃后停止续期, 到期即可重试
+var idempotencyLockTTL = 30 * time.Second
+
+// idempotencyRecord 保存在 redis 中的请求记录, Status 为 0 表示仍在处理
+type idempotencyRecord struct {
+	Token       string `json:"token,omitempty"`
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// releaseScript 只删除自己写入的占位记录
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// renewScript 只为自己写入的占位记录续期
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// Idempotency 按 Idempotency-Key 请求头去重: 窗口内相同键和相同请求体的重试直接返回首次的响应,
+// 首次请求仍在处理时返回 409, 相同键用于不同请求时返回 422.
+// 只保存成功处理的响应, 处理出错或 5xx 时释放键以便客户端重试. 未携带请求头时不做处理.
+// 键按调用方和实际请求路径隔离, 需放在 Authenticate 之后
+func Idempotency(client func() *redis.Client, ttl time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderIdempotencyKey)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > idempotencyKeyMaxLen {
+			return fiber.NewError(fiber.StatusBadRequest, "Idempotency-Key 过长")
+		}
+		rdb := client()
+		if rdb == nil {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "redis未初始化")
+		}
+
+		ctx := c.UserContext()
+		route := c.Route().Path
+		redisKey := idempotencyPrefix + idempotencyCaller(c) + ":" + c.Method() + " " + c.Path() + ":" + key
+		sum := sha256.Sum256(c.Body())
+		fingerprint := hex.EncodeToString(sum[:])
+
+		lock := idempotencyRecord{Token: newToken(), Fingerprint: fingerprint}
+		lockValue, _ := sonic.MarshalString(lock)
+		acquired, err := rdb.SetNX(ctx, redisKey, lockValue, idempotencyLockTTL).Result()
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			return replay(c, rdb, redisKey, fingerprint, route)
+		}
+
+		stop := keepLock(ctx, rdb, redisKey, lockValue)
+		err = c.Next()
+		stop()
+		status := c.Response().StatusCode()
+		if err != nil || status >= fiber.StatusInternalServerError {
+			// 请求已结束, 使用独立的 ctx 释放, 避免客户端断开后键一直占用到过期
+			if rerr := releaseScript.Run(context.WithoutCancel(ctx), rdb, []string{redisKey}, lockValue).Err(); rerr != nil {
+				logger.Ctx(ctx).Warn("释放Idempotency-Key失败", zap.String("key", key), zap.Error(rerr))
+			}
+			return err
+		}
+
+		record := idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: utils.CopyString(string(c.Response().Header.ContentType())),
+			Body:        utils.CopyBytes(c.Response().Body()),
+		}
+		value, _ := sonic.MarshalString(record)
+		if err := rdb.Set(context.WithoutCancel(ctx), redisKey, value, ttl).Err(); err != nil {
+			// 响应已生成, 保存失败只影响之后的重放
+			logger.Ctx(ctx).Warn("保存幂等响应失败", zap.String("key", key), zap.Error(err))
+		}
+		return nil
+	}
+}
+
+// idempotencyCaller 返回令牌中的调用方, 不同调用方使用相同的 Idempotency-Key 互不影响
+func idempotencyCaller(c *fiber.Ctx) string {
+	claims, ok := Claims(c)
+	switch {
+	case !ok:
+		return "anonymous"
+	case !claims.UserID.IsZero():
+		return claims.UserID.String()
+	case claims.Admin:
+		return "admin"
+	default:
+		return "anonymous"
+	}
+}
+
+// keepLock 在请求处理期间定期续期占位记录, 处理时间超过有效期时其他请求仍返回 409.
+// 返回的函数停止续期
+func keepLock(ctx context.Context, rdb *redis.Client, redisKey, lockValue string) func() {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(idempotencyLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := renewScript.Run(ctx, rdb, []string{redisKey}, lockValue, idempotencyLockTTL.Milliseconds()).Err()
+			if err != nil && ctx.Err() == nil {
+				logger.Ctx(ctx).Warn("Idempotency-Key续期失败", zap.String("key", redisKey), zap.Error(err))
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// replay 键已存在时返回首次请求的响应
+func replay(c *fiber.Ctx, rdb *redis.Client, redisKey, fingerprint, route string) error {
+	value, err := rdb.Get(c.UserContext(), redisKey).Result()
+	if errors.Is(err, redis.Nil) {
+		// 首次请求恰好失败释放了键
+		metrics.HTTPIdempotency.WithLabelValues(route, "conflict").Inc()
+		return fiber.NewError(fiber.StatusConflict, "相同 Idempotency-Key 的请求正在处理, 请稍后重试")
+	}
+	if err != nil {
+		return err
+	}
+
+	var record idempotencyRecord
+	if err := sonic.UnmarshalString(value, &record); err != nil {
+		return err
+	}
+	if record.Fingerprint != fingerprint {
+		metrics.HTTPIdempotency.WithLabelValues(route, "mismatch").Inc()
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "Idempotency-Key 已用于不同的请求")
+	}
+	if record.Status == 0 {
+		metrics.HTTPIdempotency.WithLabelValues(route, "conflict").Inc()
+		return fiber.NewError(fiber.StatusConflict, "相同 Idempotency-Key 的请求正在处理, 请稍后重试")
+	}
+
+	metrics.HTTPIdempotency.WithLabelValues(route, "replayed").Inc()
+	c.Set(HeaderIdempotentReplayed, "true")
+	if record.ContentType != "" {
+		c.Set(fiber.HeaderContentType, record.ContentType)
+	}
+	return c.Status(record.Status).Send(record.Body)
+}
+
+func newToken() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+
+	"msgcenter/platform/auth"
+	"msgcenter/utils/objectid"
+)
+
+type idempotencyEnv struct {
+	app    *fiber.App
+	mr     *miniredis.Miniredis
+	signer *auth.Signer
+	calls  atomic.Int32
+	// block 非空时第一次处理等待其关闭后再返回
+	block chan struct{}
+	// status 处理函数返回的状态码
+	status atomic.Int32
+}
+
+func newIdempotencyEnv(t *testing.T) *idempotencyEnv {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	signer, _ := auth.NewSigner("secret")
+	env := &idempotencyEnv{app: fiber.New(), mr: mr, signer: signer}
+	env.status.Store(fiber.StatusCreated)
+	idempotency := Idempotency(func() *redis.Client { return rdb }, time.Hour)
+	handler := func(c *fiber.Ctx) error {
+		n := env.calls.Add(1)
+		if env.block != nil && n == 1 {
+			<-env.block
+		}
+		return c.Status(int(env.status.Load())).JSON(fiber.Map{"call": n})
+	}
+	env.app.Post("/items", idempotency, handler)
+	env.app.Post("/topics/:name/publish", Authenticate(func() *auth.Signer { return signer }), idempotency, handler)
+	return env
+}
+
+func (e *idempotencyEnv) post(t *testing.T, key, body string) (*http.Response, string) {
+	t.Helper()
+	return e.postAs(t, "/items", "", key, body)
+}
+
+// postAs 携带令牌向指定路径发起请求
+func (e *idempotencyEnv) postAs(t *testing.T, path, token, key, body string) (*http.Response, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := e.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	env := newIdempotencyEnv(t)
+
+	first, body := env.post(t, "k1", `{"a":1}`)
+	if first.StatusCode != fiber.StatusCreated || first.Header.Get(HeaderIdempotentReplayed) != "" {
+		t.Fatalf("首次请求 status = %d, replayed = %q", first.StatusCode, first.Header.Get(HeaderIdempotentReplayed))
+	}
+	again, againBody := env.post(t, "k1", `{"a":1}`)
+	if again.StatusCode != fiber.StatusCreated || againBody != body || again.Header.Get(HeaderIdempotentReplayed) != "true" {
+		t.Fatalf("重放 status = %d, body = %s, want %s", again.StatusCode, againBody, body)
+	}
+	if ct := again.Header.Get(fiber.HeaderContentType); !strings.HasPrefix(ct, fiber.MIMEApplicationJSON) {
+		t.Fatalf("重放 Content-Type = %q", ct)
+	}
+	if n := env.calls.Load(); n != 1 {
+		t.Fatalf("处理次数 = %d, want 1", n)
+	}
+
+	// 未携带请求头和使用其他键时正常处理
+	env.post(t, "", `{"a":1}`)
+	env.post(t, "k2", `{"a":1}`)
+	if n := env.calls.Load(); n != 3 {
+		t.Fatalf("处理次数 = %d, want 3", n)
+	}
+}
+
+func TestIdempotencyRejectsDifferentBody(t *testing.T) {
+	env := newIdempotencyEnv(t)
+	env.post(t, "k", `{"a":1}`)
+	if resp, _ := env.post(t, "k", `{"a":2}`); resp.StatusCode != fiber.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", resp.StatusCode)
+	}
+	if resp, _ := env.post(t, strings.Repeat("k", idempotencyKeyMaxLen+1), `{}`); resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("键过长 status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestIdempotencyReleasesOnServerError(t *testing.T) {
+	env := newIdempotencyEnv(t)
+	env.status.Store(fiber.StatusInternalServerError)
+	if resp, _ := env.post(t, "k", `{}`); resp.StatusCode != fiber.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", resp.StatusCode)
+	}
+
+	// 5xx 不保存响应, 重试时重新处理
+	env.status.Store(fiber.StatusCreated)
+	if resp, _ := env.post(t, "k", `{}`); resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("重试 status = %d, want 201", resp.StatusCode)
+	}
+	if n := env.calls.Load(); n != 2 {
+		t.Fatalf("处理次数 = %d, want 2", n)
+	}
+}
+
+func TestIdempotencyConflictWhileProcessing(t *testing.T) {
+	old := idempotencyLockTTL
+	idempotencyLockTTL = 300 * time.Millisecond
+	t.Cleanup(func() { idempotencyLockTTL = old })
+
+	env := newIdempotencyEnv(t)
+	env.block = make(chan struct{})
+	done := make(chan int, 1)
+	go func() {
+		resp, _ := env.post(t, "k", `{}`)
+		done <- resp.StatusCode
+	}()
+	for env.calls.Load() == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 处理时间远超占位有效期, 续期后键仍被占用
+	for range 5 {
+		env.mr.FastForward(200 * time.Millisecond)
+		time.Sleep(150 * time.Millisecond)
+		if resp, _ := env.post(t, "k", `{}`); resp.StatusCode != fiber.StatusConflict {
+			t.Fatalf("处理中 status = %d, want 409", resp.StatusCode)
+		}
+	}
+
+	close(env.block)
+	if status := <-done; status != fiber.StatusCreated {
+		t.Fatalf("首次请求 status = %d, want 201", status)
+	}
+	if resp, _ := env.post(t, "k", `{}`); resp.StatusCode != fiber.StatusCreated || resp.Header.Get(HeaderIdempotentReplayed) != "true" {
+		t.Fatalf("完成后 status = %d, 应重放首次响应", resp.StatusCode)
+	}
+	if n := env.calls.Load(); n != 1 {
+		t.Fatalf("处理次数 = %d, want 1", n)
+	}
+}
+
+func TestIdempotencyScopedByPathAndCaller(t *testing.T) {
+	env := newIdempotencyEnv(t)
+	alice, _ := env.signer.Sign(auth.Claims{UserID: objectid.New()}, time.Minute)
+	bob, _ := env.signer.Sign(auth.Claims{UserID: objectid.New()}, time.Minute)
+
+	env.postAs(t, "/topics/alpha/publish", alice, "k1", `{"a":1}`)
+	// 相同路由模板下的不同路径参数, 以及不同调用方使用相同的键, 都是不同的请求
+	for _, tc := range []struct{ path, token string }{
+		{"/topics/beta/publish", alice},
+		{"/topics/alpha/publish", bob},
+	} {
+		resp, _ := env.postAs(t, tc.path, tc.token, "k1", `{"a":1}`)
+		if resp.StatusCode != fiber.StatusCreated || resp.Header.Get(HeaderIdempotentReplayed) != "" {
+			t.Fatalf("%s status = %d, replayed = %q", tc.path, resp.StatusCode, resp.Header.Get(HeaderIdempotentReplayed))
+		}
+	}
+	resp, _ := env.postAs(t, "/topics/alpha/publish", alice, "k1", `{"a":1}`)
+	if resp.Header.Get(HeaderIdempotentReplayed) != "true" {
+		t.Fatal("同一调用方重试相同路径未重放")
+	}
+	if n := env.calls.Load(); n != 3 {
+		t.Fatalf("处理次数 = %d, want 3", n)
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"msgcenter/platform/metrics"
+	"strconv"
+	"time"
+)
+
+func InitMetrics(app *fiber.App) {
+	app.Use(Metrics())
+}
+
+// Metrics 统计HTTP请求耗时, 按路由模板而非实际路径分组以控制指标基数,
+// fiber 返回的字符串会随请求复用, 作为标签保存前需要复制
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		err := c.Next()
+
+		// 返回的错误尚未经过 ErrorHandler, 需按错误推断状态码
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var e *fiber.Error
+			if errors.As(err, &e) {
+				status = e.Code
+			}
+		}
+
+		metrics.HTTPRequestDuration.
+			WithLabelValues(utils.CopyString(c.Method()), c.Route().Path, strconv.Itoa(status)).
+			Observe(time.Since(start).Seconds())
+		return err
+	}
+}
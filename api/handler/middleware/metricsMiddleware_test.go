@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"msgcenter/platform/metrics"
+)
+
+func TestMetricsLabelsByRouteTemplate(t *testing.T) {
+	app := fiber.New()
+	app.Use(Metrics())
+	app.Get("/items/:id", func(c *fiber.Ctx) error {
+		if c.Params("id") == "missing" {
+			return fiber.ErrNotFound
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	before := testutil.CollectAndCount(metrics.HTTPRequestDuration)
+	for _, path := range []string{"/items/1", "/items/2", "/items/missing"} {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// 不同 id 归入同一路由模板, 返回错误时按错误的状态码统计
+	if got := testutil.CollectAndCount(metrics.HTTPRequestDuration) - before; got != 2 {
+		t.Fatalf("新增序列数 = %d, want 2", got)
+	}
+	ok := metrics.HTTPRequestDuration.WithLabelValues("GET", "/items/:id", "200")
+	notFound := metrics.HTTPRequestDuration.WithLabelValues("GET", "/items/:id", "404")
+	if n := histogramCount(t, ok); n != 2 {
+		t.Fatalf("200 请求数 = %d, want 2", n)
+	}
+	if n := histogramCount(t, notFound); n != 1 {
+		t.Fatalf("404 请求数 = %d, want 1", n)
+	}
+	if v := testutil.ToFloat64(metrics.HTTPRequestsInFlight); v != 0 {
+		t.Fatalf("处理中的请求数 = %v, want 0", v)
+	}
+}
+
+func histogramCount(t *testing.T, o prometheus.Observer) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := o.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
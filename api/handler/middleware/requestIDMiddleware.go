@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"msgcenter/utils/reqctx"
+)
+
+// LocalsRequestID 请求ID在 fiber.Ctx.Locals 中的键
+const LocalsRequestID = "requestid"
+
+func InitRequestID(app *fiber.App) {
+	app.Use(RequestID())
+}
+
+// RequestID 沿用客户端传入的 X-Request-ID, 不合法或缺失时生成新的请求ID,
+// 并写入响应头和 UserContext 供后续日志、数据库和消息使用
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// fiber 返回的字符串会随请求复用, 请求ID会被异步任务持有, 需要复制
+		id := utils.CopyString(c.Get(reqctx.HeaderRequestID))
+		if !reqctx.ValidRequestID(id) {
+			id = reqctx.NewRequestID()
+		}
+
+		c.Set(reqctx.HeaderRequestID, id)
+		c.Locals(LocalsRequestID, id)
+		c.SetUserContext(reqctx.WithRequestID(c.UserContext(), id))
+
+		return c.Next()
+	}
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
+	"msgcenter/utils/logger"
 	"time"
 )
 
@@ -11,6 +12,9 @@ func InitZapLogger(app *fiber.App) {
 }
 
 func ZapLogger() fiber.Handler {
+	// 访问日志量大, 使用采样日志避免刷屏
+	accessLogger := logger.Sampled(logger.HTTP)
+
 	return func(c *fiber.Ctx) error {
 		start := time.Now()
 
@@ -18,7 +22,7 @@ func ZapLogger() fiber.Handler {
 		err := c.Next()
 
 		// 记录日志
-		zap.L().Info("🫡🫡🫡🫡HTTP Request---",
+		logger.With(c.UserContext(), accessLogger).Info("🫡🫡🫡🫡HTTP Request---",
 			zap.String("method", c.Method()),
 			zap.String("path", c.Path()),
 			zap.Int("status", c.Response().StatusCode()),
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"msgcenter/utils/objectid"
+)
+
+// ParamObjectID 解析路径参数中的 ObjectId, 格式错误时返回 400
+func ParamObjectID(c *fiber.Ctx, name string) (objectid.ID, error) {
+	id, err := objectid.Parse(c.Params(name))
+	if err != nil {
+		return objectid.Nil, fiber.NewError(fiber.StatusBadRequest, name+" 不是有效的ObjectId")
+	}
+	return id, nil
+}
+
+// QueryObjectID 解析查询参数中的 ObjectId, 参数为空时返回 objectid.Nil, 格式错误时返回 400
+func QueryObjectID(c *fiber.Ctx, name string) (objectid.ID, error) {
+	s := c.Query(name)
+	if s == "" {
+		return objectid.Nil, nil
+	}
+	id, err := objectid.Parse(s)
+	if err != nil {
+		return objectid.Nil, fiber.NewError(fiber.StatusBadRequest, name+" 不是有效的ObjectId")
+	}
+	return id, nil
+}
@@ -0,0 +1,51 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"msgcenter/api/handler"
+	"msgcenter/utils/objectid"
+)
+
+func TestObjectIDParams(t *testing.T) {
+	app := fiber.New()
+	app.Get("/items/:id", func(c *fiber.Ctx) error {
+		id, err := handler.ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		owner, err := handler.QueryObjectID(c, "owner")
+		if err != nil {
+			return err
+		}
+		return c.SendString(id.String() + "," + owner.String())
+	})
+
+	id, owner := objectid.New(), objectid.New()
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"合法", "/items/" + id.String() + "?owner=" + owner.String(), http.StatusOK},
+		{"查询参数为空", "/items/" + id.String(), http.StatusOK},
+		{"路径参数非法", "/items/123", http.StatusBadRequest},
+		{"查询参数非法", "/items/" + id.String() + "?owner=abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package handler
+
+import "github.com/gofiber/fiber/v2"
+
+// ok 返回统一格式的成功响应
+func ok(c *fiber.Ctx, data interface{}) error {
+	return c.JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"msg":  "success",
+		"data": data,
+	})
+}
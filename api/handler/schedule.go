@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"msgcenter/api/handler/middleware"
+	"msgcenter/app"
+	"msgcenter/platform/ent/gen/scheduledmessage"
+	"msgcenter/service/message"
+	"msgcenter/service/schedule"
+	"msgcenter/utils/logger"
+	"msgcenter/utils/objectid"
+)
+
+func InitSchedule(router *fiber.App, svc *app.ServiceApp) {
+	ttl := svc.Config().Message.IdempotencyTTL
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	authed := middleware.Authenticate(svc.Auth)
+	router.Post("/scheduled-messages",
+		authed,
+		middleware.Idempotency(svc.RedisClient, ttl),
+		createScheduledMessage(svc),
+	).Name("创建定时消息")
+	router.Get("/scheduled-messages", authed, listScheduledMessages(svc)).Name("查询定时消息列表")
+	router.Get("/scheduled-messages/:id", authed, getScheduledMessage(svc)).Name("查询定时消息")
+	router.Post("/scheduled-messages/:id/cancel", authed, cancelScheduledMessage(svc)).Name("取消定时消息")
+	router.Post("/scheduled-messages/:id/reschedule", authed, rescheduleMessage(svc)).Name("修改定时消息发送时间")
+}
+
+// scheduleOwner 返回只能操作其定时消息的用户, 管理员可以操作所有定时消息, 返回零值
+func scheduleOwner(c *fiber.Ctx) (objectid.ID, error) {
+	if claims, _ := middleware.Claims(c); claims.Admin {
+		return objectid.ID{}, nil
+	}
+	return caller(c)
+}
+
+type createScheduledMessageRequest struct {
+	ConversationID objectid.ID     `json:"conversation_id"`
+	UserIDs        []objectid.ID   `json:"user_ids"`
+	DeviceIDs      []objectid.ID   `json:"device_ids"`
+	Topic          string          `json:"topic"`  // 按主题广播, 不能与以上接收方同时指定
+	Target         string          `json:"target"` // 主题广播的定向表达式
+	ContentType    string          `json:"content_type"`
+	Payload        json.RawMessage `json:"payload"`
+	TTL            int             `json:"ttl"`          // 有效期秒数, 从实际发送时开始计算
+	DeliverTime    time.Time       `json:"deliver_time"` // RFC3339
+}
+
+func createScheduledMessage(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cfg := svc.Config()
+		maxRecipients, maxPayloadSize := cfg.Message.MaxRecipients, cfg.Message.MaxPayloadSize
+		if maxRecipients <= 0 {
+			maxRecipients = defaultMaxRecipients
+		}
+		if maxPayloadSize <= 0 {
+			maxPayloadSize = defaultMaxPayloadSize
+		}
+
+		var req createScheduledMessageRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		if req.DeliverTime.IsZero() {
+			return fiber.NewError(fiber.StatusBadRequest, "deliver_time 不能为空")
+		}
+		if req.ContentType == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "content_type 不能为空")
+		}
+		if len(req.Payload) == 0 || string(req.Payload) == "null" {
+			return fiber.NewError(fiber.StatusBadRequest, "payload 不能为空")
+		}
+		if len(req.Payload) > maxPayloadSize {
+			return fiber.NewError(fiber.StatusRequestEntityTooLarge,
+				"payload 不能超过 "+strconv.Itoa(maxPayloadSize)+" 字节")
+		}
+		if len(req.UserIDs)+len(req.DeviceIDs) > maxRecipients {
+			return fiber.NewError(fiber.StatusBadRequest,
+				"user_ids 和 device_ids 总数不能超过 "+strconv.Itoa(maxRecipients))
+		}
+		if req.Topic != "" && !topicName.MatchString(req.Topic) {
+			return fiber.NewError(fiber.StatusBadRequest, "topic 不是有效的主题名称")
+		}
+		if req.Topic == "" && req.Target != "" {
+			return fiber.NewError(fiber.StatusBadRequest, "target 只能用于主题广播")
+		}
+		ttl, err := parseTTL(req.TTL)
+		if err != nil {
+			return err
+		}
+		// 发送者取自令牌, 不含用户的管理员令牌发送系统消息; 与直接发布一样, 只有管理员可以定时广播
+		claims, _ := middleware.Claims(c)
+		if claims.UserID.IsZero() && !claims.Admin {
+			return fiber.NewError(fiber.StatusForbidden, "需要用户令牌")
+		}
+		if req.Topic != "" && !claims.Admin {
+			return fiber.NewError(fiber.StatusForbidden, "需要管理员权限")
+		}
+
+		sm, err := schedule.Create(c.UserContext(), svc.DbClient(), svc.RedisClient(), cfg.Schedule.MaxDelay, schedule.Input{
+			Input: message.Input{
+				SenderID:       claims.UserID,
+				ConversationID: req.ConversationID,
+				UserIDs:        req.UserIDs,
+				DeviceIDs:      req.DeviceIDs,
+				ContentType:    req.ContentType,
+				Payload:        req.Payload,
+				TTL:            ttl,
+			},
+			Topic:       req.Topic,
+			Target:      req.Target,
+			DeliverTime: req.DeliverTime,
+		})
+		if err != nil {
+			return scheduleError(err)
+		}
+		logger.Ctx(c.UserContext()).Info("定时消息已创建",
+			zap.Stringer("id", sm.ID),
+			zap.Stringer("sender_id", sm.SenderID),
+			zap.String("topic", sm.Topic),
+			zap.Time("deliver_time", sm.DeliverTime),
+		)
+		return ok(c, sm)
+	}
+}
+
+type scheduledMessageQuery struct {
+	Status string `query:"status"` // Pending, Sent, Canceled, Failed
+	Limit  int    `query:"limit"`
+	Offset int    `query:"offset"`
+}
+
+func listScheduledMessages(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req scheduledMessageQuery
+		if err := c.QueryParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		limit, err := pageLimit(req.Limit)
+		if err != nil {
+			return err
+		}
+		if req.Offset < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "offset 不能为负数")
+		}
+		status := scheduledmessage.Status(req.Status)
+		if status != "" && scheduledmessage.StatusValidator(status) != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "status 只能为 Pending、Sent、Canceled 或 Failed")
+		}
+		// 普通用户只能查询自己的定时消息, 管理员可按 sender_id 过滤
+		senderID, err := scheduleOwner(c)
+		if err != nil {
+			return err
+		}
+		if senderID.IsZero() {
+			if senderID, err = QueryObjectID(c, "sender_id"); err != nil {
+				return err
+			}
+		}
+
+		sms, err := schedule.List(c.UserContext(), svc.DbClient(), schedule.Filter{
+			SenderID: senderID,
+			Status:   status,
+			Limit:    limit,
+			Offset:   req.Offset,
+		})
+		if err != nil {
+			return err
+		}
+		return ok(c, sms)
+	}
+}
+
+func getScheduledMessage(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		owner, err := scheduleOwner(c)
+		if err != nil {
+			return err
+		}
+		sm, err := schedule.Get(c.UserContext(), svc.DbClient(), owner, id)
+		if err != nil {
+			return scheduleError(err)
+		}
+		return ok(c, sm)
+	}
+}
+
+func cancelScheduledMessage(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		owner, err := scheduleOwner(c)
+		if err != nil {
+			return err
+		}
+		sm, err := schedule.Cancel(c.UserContext(), svc.DbClient(), svc.RedisClient(), owner, id)
+		if err != nil {
+			return scheduleError(err)
+		}
+		logger.Ctx(c.UserContext()).Info("定时消息已取消", zap.Stringer("id", sm.ID))
+		return ok(c, sm)
+	}
+}
+
+type rescheduleRequest struct {
+	DeliverTime time.Time `json:"deliver_time"` // RFC3339
+}
+
+func rescheduleMessage(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		owner, err := scheduleOwner(c)
+		if err != nil {
+			return err
+		}
+		var req rescheduleRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		if req.DeliverTime.IsZero() {
+			return fiber.NewError(fiber.StatusBadRequest, "deliver_time 不能为空")
+		}
+		sm, err := schedule.Reschedule(c.UserContext(), svc.DbClient(), svc.RedisClient(),
+			svc.Config().Schedule.MaxDelay, owner, id, req.DeliverTime)
+		if err != nil {
+			return scheduleError(err)
+		}
+		logger.Ctx(c.UserContext()).Info("定时消息已改期",
+			zap.Stringer("id", sm.ID),
+			zap.Time("deliver_time", sm.DeliverTime),
+		)
+		return ok(c, sm)
+	}
+}
+
+// scheduleError 将定时消息及其校验接收方时的错误转换为对应的 HTTP 状态码
+func scheduleError(err error) error {
+	switch {
+	case errors.Is(err, schedule.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, schedule.ErrNotPending):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, schedule.ErrPastTime),
+		errors.Is(err, schedule.ErrTooFar),
+		errors.Is(err, schedule.ErrRecipient),
+		errors.Is(err, message.ErrNoRecipient),
+		errors.Is(err, message.ErrSenderNotFound),
+		errors.Is(err, message.ErrUserNotFound),
+		errors.Is(err, message.ErrDeviceNotFound):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	// 主题不存在等按主题服务的规则转换, 其余按会话服务的规则转换
+	if e := topicError(err); e != err {
+		return e
+	}
+	return conversationError(err)
+}
@@ -0,0 +1,81 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"msgcenter/utils/objectid"
+)
+
+func TestScheduledMessagesOwnedBySender(t *testing.T) {
+	e := newConversationEnv(t, 2)
+	deliver := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	// 请求体中冒充其他发送者无效
+	body := `{"sender_id":"` + e.users[1].String() + `","user_ids":["` + e.users[1].String() +
+		`"],"content_type":"text","payload":{"text":"hi"},"deliver_time":"` + deliver + `"}`
+
+	if status, _ := e.do(t, "", http.MethodPost, "/scheduled-messages", body); status != http.StatusUnauthorized {
+		t.Fatalf("未携带令牌 status = %d, want 401", status)
+	}
+	status, data := e.do(t, e.tokens[0], http.MethodPost, "/scheduled-messages", body)
+	if status != http.StatusOK {
+		t.Fatalf("创建定时消息 status = %d, want 200", status)
+	}
+	var sm struct {
+		ID       objectid.ID `json:"id"`
+		SenderID objectid.ID `json:"sender_id"`
+	}
+	if err := json.Unmarshal(data, &sm); err != nil {
+		t.Fatal(err)
+	}
+	if sm.SenderID != e.users[0] {
+		t.Fatalf("发送者 = %s, want %s", sm.SenderID, e.users[0])
+	}
+	topic := `{"topic":"news","content_type":"text","payload":{"text":"hi"},"deliver_time":"` + deliver + `"}`
+	if status, _ := e.do(t, e.tokens[0], http.MethodPost, "/scheduled-messages", topic); status != http.StatusForbidden {
+		t.Fatalf("普通用户定时广播 status = %d, want 403", status)
+	}
+
+	// 其他用户看不到也不能修改
+	base := "/scheduled-messages/" + sm.ID.String()
+	reschedule := `{"deliver_time":"` + time.Now().Add(2*time.Hour).UTC().Format(time.RFC3339) + `"}`
+	for _, r := range []struct{ method, path, body string }{
+		{http.MethodGet, base, ""},
+		{http.MethodPost, base + "/reschedule", reschedule},
+		{http.MethodPost, base + "/cancel", ""},
+	} {
+		if status, _ := e.do(t, e.tokens[1], r.method, r.path, r.body); status != http.StatusNotFound {
+			t.Fatalf("其他用户 %s %s status = %d, want 404", r.method, r.path, status)
+		}
+	}
+	count := func(token, query string) int {
+		t.Helper()
+		status, data := e.do(t, token, http.MethodGet, "/scheduled-messages"+query, "")
+		if status != http.StatusOK {
+			t.Fatalf("查询列表 status = %d", status)
+		}
+		var list []json.RawMessage
+		if err := json.Unmarshal(data, &list); err != nil {
+			t.Fatal(err)
+		}
+		return len(list)
+	}
+	if n := count(e.tokens[1], "?sender_id="+e.users[0].String()); n != 0 {
+		t.Fatalf("其他用户按 sender_id 查询到 %d 条", n)
+	}
+	if n := count(e.tokens[0], ""); n != 1 {
+		t.Fatalf("发送者查询到 %d 条, want 1", n)
+	}
+	if n := count(e.env.AdminToken(t), "?sender_id="+e.users[0].String()); n != 1 {
+		t.Fatalf("管理员查询到 %d 条, want 1", n)
+	}
+
+	if status, _ := e.do(t, e.tokens[0], http.MethodPost, base+"/reschedule", reschedule); status != http.StatusOK {
+		t.Fatalf("发送者改期 status = %d, want 200", status)
+	}
+	if status, _ := e.do(t, e.env.AdminToken(t), http.MethodPost, base+"/cancel", ""); status != http.StatusOK {
+		t.Fatalf("管理员取消 status = %d, want 200", status)
+	}
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"msgcenter/api/handler/middleware"
+	"msgcenter/app"
+	"msgcenter/service/signal"
+	"msgcenter/utils/objectid"
+)
+
+func InitSignal(router *fiber.App, svc *app.ServiceApp) {
+	router.Post("/conversations/:id/signals", middleware.Authenticate(svc.Auth), sendSignal(svc)).Name("发送会话信号")
+}
+
+func signalOptions(svc *app.ServiceApp) signal.Options {
+	cfg := svc.Config()
+	return signal.Options{
+		Throttle:   cfg.Signal.Throttle,
+		TTL:        cfg.Signal.TTL,
+		MaxMembers: cfg.Conversation.FanoutThreshold,
+	}
+}
+
+type signalRequest struct {
+	Kind   string `json:"kind"` // typing / recording / viewing
+	Active bool   `json:"active"`
+}
+
+type signalResponse struct {
+	Sent bool `json:"sent"` // false 表示被节流或会话成员过多而丢弃
+}
+
+// sendSignal 发送正在输入等临时信号, 只推送给在线成员, 不保存. 与长连接上行一样, 发送者为令牌中的用户和设备
+func sendSignal(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		userID, err := caller(c)
+		if err != nil {
+			return err
+		}
+		var req signalRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		var deviceID string
+		if claims, _ := middleware.Claims(c); !claims.DeviceID.IsZero() {
+			deviceID = claims.DeviceID.String()
+		}
+		rdb := svc.RedisClient()
+		if rdb == nil {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "redis 未就绪")
+		}
+		sent, err := signal.Send(c.UserContext(), svc.DbClient(), rdb, signalOptions(svc), signal.Signal{
+			Kind:           signal.Kind(req.Kind),
+			ConversationID: id,
+			UserID:         userID,
+			DeviceID:       deviceID,
+			Active:         req.Active,
+		})
+		if errors.Is(err, signal.ErrInvalidKind) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			return conversationError(err)
+		}
+		return ok(c, signalResponse{Sent: sent})
+	}
+}
+
+// signalFrame 设备通过长连接上行的信号帧
+type signalFrame struct {
+	Type           string      `json:"type"` // 固定为 signal
+	ConversationID objectid.ID `json:"conversation_id"`
+	Kind           string      `json:"kind"`
+	Active         bool        `json:"active"`
+}
+
+// ReceiveFrame 处理设备通过长连接上行的帧, 目前只支持信号帧, 发送者为连接登记的用户和设备
+func ReceiveFrame(ctx context.Context, svc *app.ServiceApp, userID, deviceID string, data []byte) error {
+	var frame signalFrame
+	if err := sonic.Unmarshal(data, &frame); err != nil {
+		return err
+	}
+	if frame.Type != "signal" {
+		return errors.New("不支持的帧类型 " + frame.Type)
+	}
+	sender, err := objectid.Parse(userID)
+	if err != nil {
+		return errors.New("连接未登记用户, 不能发送信号")
+	}
+	rdb := svc.RedisClient()
+	if rdb == nil {
+		return errors.New("redis 未就绪")
+	}
+	_, err = signal.Send(ctx, svc.DbClient(), rdb, signalOptions(svc), signal.Signal{
+		Kind:           signal.Kind(frame.Kind),
+		ConversationID: frame.ConversationID,
+		UserID:         sender,
+		DeviceID:       deviceID,
+		Active:         frame.Active,
+	})
+	return err
+}
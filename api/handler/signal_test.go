@@ -0,0 +1,36 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestSignalSenderFromToken(t *testing.T) {
+	e := newConversationEnv(t, 3)
+	id := e.create(t, 0, `{"type":"Group","member_ids":["`+e.users[1].String()+`"]}`)
+	path := "/conversations/" + id.String() + "/signals"
+	// 请求体中冒充成员无效, 发送者始终是令牌中的用户
+	body := `{"user_id":"` + e.users[0].String() + `","kind":"typing","active":true}`
+
+	if status, _ := e.do(t, "", http.MethodPost, path, body); status != http.StatusUnauthorized {
+		t.Fatalf("未携带令牌 status = %d, want 401", status)
+	}
+	if status, _ := e.do(t, e.tokens[2], http.MethodPost, path, body); status != http.StatusForbidden {
+		t.Fatalf("非成员发送 status = %d, want 403", status)
+	}
+	status, data := e.do(t, e.tokens[1], http.MethodPost, path, body)
+	if status != http.StatusOK {
+		t.Fatalf("成员发送 status = %d, want 200", status)
+	}
+	var resp struct {
+		Sent bool `json:"sent"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil || !resp.Sent {
+		t.Fatalf("成员发送 = %s, err = %v", data, err)
+	}
+	// 节流按令牌中的用户计算, 群主不受成员发送的影响
+	if _, data := e.do(t, e.tokens[0], http.MethodPost, path, body); string(data) != `{"sent":true}` {
+		t.Fatalf("群主发送 = %s", data)
+	}
+}
@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"msgcenter/api/handler/middleware"
+	"msgcenter/app"
+	"msgcenter/service/topic"
+	"msgcenter/utils/logger"
+	"msgcenter/utils/objectid"
+)
+
+// topicName 主题名称只允许字母、数字和 _.-
+var topicName = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+func InitTopic(router *fiber.App, svc *app.ServiceApp) {
+	ttl := svc.Config().Message.IdempotencyTTL
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	// 创建主题和发布广播只允许管理员, 订阅者取自令牌
+	authed, admin := middleware.Authenticate(svc.Auth), middleware.RequireAdmin(svc.Auth)
+	router.Post("/topics", admin, createTopic(svc)).Name("创建主题")
+	router.Get("/topics/:name", authed, getTopic(svc)).Name("查询主题")
+	router.Post("/topics/:name/subscribe", authed, subscribeTopic(svc)).Name("订阅主题")
+	router.Post("/topics/:name/unsubscribe", authed, unsubscribeTopic(svc)).Name("取消订阅主题")
+	router.Post("/topics/:name/publish",
+		admin,
+		middleware.Idempotency(svc.RedisClient, ttl),
+		publishTopic(svc),
+	).Name("发布主题广播")
+	router.Get("/broadcasts/:id", authed, getBroadcast(svc)).Name("查询广播")
+}
+
+type createTopicRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func createTopic(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req createTopicRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		if !topicName.MatchString(req.Name) {
+			return fiber.NewError(fiber.StatusBadRequest, "name 只能包含字母、数字和 _.-, 长度 1-64")
+		}
+		if len(req.Description) > 255 {
+			return fiber.NewError(fiber.StatusBadRequest, "description 不能超过 255 字节")
+		}
+		t, err := topic.Create(c.UserContext(), svc.DbClient(), req.Name, req.Description)
+		if err != nil {
+			return topicError(err)
+		}
+		logger.Ctx(c.UserContext()).Info("主题已创建", zap.Stringer("id", t.ID), zap.String("name", t.Name))
+		return ok(c, t)
+	}
+}
+
+// paramTopicName 校验路径中的主题名称
+func paramTopicName(c *fiber.Ctx) (string, error) {
+	name := c.Params("name")
+	if !topicName.MatchString(name) {
+		return "", fiber.NewError(fiber.StatusBadRequest, "name 不是有效的主题名称")
+	}
+	return name, nil
+}
+
+func getTopic(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		name, err := paramTopicName(c)
+		if err != nil {
+			return err
+		}
+		t, err := topic.Get(c.UserContext(), svc.DbClient(), name)
+		if err != nil {
+			return topicError(err)
+		}
+		return ok(c, t)
+	}
+}
+
+type subscribeRequest struct {
+	Device bool `json:"device"` // 只订阅令牌中的设备, 默认订阅令牌中的用户
+}
+
+// parseSubscribeRequest 订阅者取自令牌, 只能为自己或自己的设备订阅
+func parseSubscribeRequest(c *fiber.Ctx) (string, topic.Subscriber, error) {
+	name, err := paramTopicName(c)
+	if err != nil {
+		return "", topic.Subscriber{}, err
+	}
+	var req subscribeRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return "", topic.Subscriber{}, fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+	}
+	claims, _ := middleware.Claims(c)
+	if req.Device {
+		if claims.DeviceID.IsZero() {
+			return "", topic.Subscriber{}, fiber.NewError(fiber.StatusForbidden, "令牌未绑定设备")
+		}
+		return name, topic.Subscriber{DeviceID: claims.DeviceID}, nil
+	}
+	if claims.UserID.IsZero() {
+		return "", topic.Subscriber{}, fiber.NewError(fiber.StatusForbidden, "需要用户令牌")
+	}
+	return name, topic.Subscriber{UserID: claims.UserID}, nil
+}
+
+func subscribeTopic(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		name, sub, err := parseSubscribeRequest(c)
+		if err != nil {
+			return err
+		}
+		s, err := topic.Subscribe(c.UserContext(), svc.DbClient(), name, sub)
+		if err != nil {
+			return topicError(err)
+		}
+		logger.Ctx(c.UserContext()).Info("已订阅主题",
+			zap.String("name", name),
+			zap.String("subscriber_type", string(s.SubscriberType)),
+			zap.Stringer("subscriber_id", s.SubscriberID),
+		)
+		return ok(c, s)
+	}
+}
+
+func unsubscribeTopic(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		name, sub, err := parseSubscribeRequest(c)
+		if err != nil {
+			return err
+		}
+		if err := topic.Unsubscribe(c.UserContext(), svc.DbClient(), name, sub); err != nil {
+			return topicError(err)
+		}
+		logger.Ctx(c.UserContext()).Info("已取消订阅主题",
+			zap.String("name", name),
+			zap.Stringer("user_id", sub.UserID),
+			zap.Stringer("device_id", sub.DeviceID),
+		)
+		return ok(c, nil)
+	}
+}
+
+type publishTopicRequest struct {
+	ContentType string          `json:"content_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Target      string          `json:"target"` // 定向表达式, 如 device_type == 'Mobile' && tag == 'beta'
+	TTL         int             `json:"ttl"`    // 有效期秒数, 过期后停止扩散, 0 表示不过期
+}
+
+type publishTopicResponse struct {
+	BroadcastID objectid.ID `json:"broadcast_id"`
+	MessageID   string      `json:"message_id"`
+	CreateTime  time.Time   `json:"create_time"`
+}
+
+func publishTopic(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		name, err := paramTopicName(c)
+		if err != nil {
+			return err
+		}
+		maxPayloadSize := svc.Config().Message.MaxPayloadSize
+		if maxPayloadSize <= 0 {
+			maxPayloadSize = defaultMaxPayloadSize
+		}
+
+		var req publishTopicRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "请求参数错误")
+		}
+		if req.ContentType == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "content_type 不能为空")
+		}
+		if len(req.Payload) == 0 || string(req.Payload) == "null" {
+			return fiber.NewError(fiber.StatusBadRequest, "payload 不能为空")
+		}
+		if len(req.Payload) > maxPayloadSize {
+			return fiber.NewError(fiber.StatusRequestEntityTooLarge,
+				"payload 不能超过 "+strconv.Itoa(maxPayloadSize)+" 字节")
+		}
+		ttl, err := parseTTL(req.TTL)
+		if err != nil {
+			return err
+		}
+
+		// 发送者取自令牌, 不含用户的管理员令牌发布系统消息
+		claims, _ := middleware.Claims(c)
+		b, err := topic.Publish(c.UserContext(), svc.DbClient(), name, topic.PublishInput{
+			SenderID:    claims.UserID,
+			ContentType: req.ContentType,
+			Payload:     req.Payload,
+			Target:      req.Target,
+			TTL:         ttl,
+		})
+		if err != nil {
+			return topicError(err)
+		}
+		logger.Ctx(c.UserContext()).Info("主题广播已创建",
+			zap.String("name", name),
+			zap.Stringer("broadcast_id", b.ID),
+			zap.Int64("message_id", b.MessageID),
+			zap.String("target", b.Target),
+		)
+		return ok(c, publishTopicResponse{
+			BroadcastID: b.ID,
+			MessageID:   strconv.FormatInt(b.MessageID, 10),
+			CreateTime:  b.CreateTime,
+		})
+	}
+}
+
+func getBroadcast(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := ParamObjectID(c, "id")
+		if err != nil {
+			return err
+		}
+		b, err := topic.GetBroadcast(c.UserContext(), svc.DbClient(), id)
+		if err != nil {
+			return topicError(err)
+		}
+		return ok(c, b)
+	}
+}
+
+// topicError 将主题服务的错误转换为对应的 HTTP 状态码, 其他错误原样返回
+func topicError(err error) error {
+	switch {
+	case errors.Is(err, topic.ErrNotFound),
+		errors.Is(err, topic.ErrBroadcastNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, topic.ErrExists),
+		errors.Is(err, topic.ErrAlreadySubscribed):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, topic.ErrSubscriber),
+		errors.Is(err, topic.ErrUserNotFound),
+		errors.Is(err, topic.ErrDeviceNotFound),
+		errors.Is(err, topic.ErrSenderNotFound),
+		errors.Is(err, topic.ErrNotSubscribed),
+		errors.Is(err, topic.ErrInvalidTarget):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"msgcenter/platform/auth"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/auditlog"
+	"msgcenter/platform/ent/gen/topicsubscription"
+	"msgcenter/utils/objectid"
+)
+
+func TestTopicRoutesAuth(t *testing.T) {
+	e := newConversationEnv(t, 2)
+	admin := e.env.AdminToken(t)
+	publish := `{"content_type":"text","payload":{"text":"hi"}}`
+
+	if status, _ := e.do(t, "", http.MethodGet, "/topics/news", ""); status != http.StatusUnauthorized {
+		t.Fatalf("未携带令牌 status = %d, want 401", status)
+	}
+	if status, _ := e.do(t, e.tokens[0], http.MethodPost, "/topics", `{"name":"news"}`); status != http.StatusForbidden {
+		t.Fatalf("普通用户创建主题 status = %d, want 403", status)
+	}
+	if status, _ := e.do(t, admin, http.MethodPost, "/topics", `{"name":"news"}`); status != http.StatusOK {
+		t.Fatalf("管理员创建主题 status = %d, want 200", status)
+	}
+	if status, _ := e.do(t, e.tokens[0], http.MethodPost, "/topics/news/publish", publish); status != http.StatusForbidden {
+		t.Fatalf("普通用户发布广播 status = %d, want 403", status)
+	}
+	status, data := e.do(t, admin, http.MethodPost, "/topics/news/publish", publish)
+	if status != http.StatusOK {
+		t.Fatalf("管理员发布广播 status = %d, want 200", status)
+	}
+	var b struct {
+		BroadcastID objectid.ID `json:"broadcast_id"`
+	}
+	if err := json.Unmarshal(data, &b); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := e.do(t, e.tokens[1], http.MethodGet, "/broadcasts/"+b.BroadcastID.String(), ""); status != http.StatusOK {
+		t.Fatalf("查询广播 status = %d, want 200", status)
+	}
+}
+
+func TestTopicSubscriberFromToken(t *testing.T) {
+	e := newConversationEnv(t, 2)
+	if status, _ := e.do(t, e.env.AdminToken(t), http.MethodPost, "/topics", `{"name":"news"}`); status != http.StatusOK {
+		t.Fatalf("创建主题 status = %d", status)
+	}
+	ctx := context.Background()
+	d := e.server.DbClient.Device.Create().SetClientDeviceID(objectid.New().String()).SaveX(ctx)
+	deviceToken := e.env.Token(t, auth.Claims{UserID: e.users[1], DeviceID: d.ID})
+
+	// 请求体中指定其他用户无效, 订阅者始终是令牌中的用户
+	if status, _ := e.do(t, e.tokens[0], http.MethodPost, "/topics/news/subscribe", `{"user_id":"`+e.users[1].String()+`"}`); status != http.StatusOK {
+		t.Fatalf("订阅主题 status = %d, want 200", status)
+	}
+	if status, _ := e.do(t, deviceToken, http.MethodPost, "/topics/news/subscribe", `{"device":true}`); status != http.StatusOK {
+		t.Fatalf("设备订阅主题 status = %d, want 200", status)
+	}
+	if status, _ := e.do(t, e.tokens[0], http.MethodPost, "/topics/news/subscribe", `{"device":true}`); status != http.StatusForbidden {
+		t.Fatalf("令牌未绑定设备时订阅设备 status = %d, want 403", status)
+	}
+
+	subs := e.server.DbClient.TopicSubscription.Query().Order(topicsubscription.ByID()).AllX(ctx)
+	if len(subs) != 2 || subs[0].SubscriberID != e.users[0] || subs[1].SubscriberID != d.ID {
+		t.Fatalf("订阅 = %+v", subs)
+	}
+	// 审计日志记录发起请求的用户
+	entry := e.server.DbClient.AuditLog.Query().
+		Where(auditlog.Entity(gen.TypeTopicSubscription), auditlog.EntityID(subs[0].ID.String())).
+		OnlyX(ctx)
+	if entry.Actor != e.users[0].String() {
+		t.Fatalf("订阅的审计操作者 = %q, want %q", entry.Actor, e.users[0])
+	}
+
+	if status, _ := e.do(t, e.tokens[1], http.MethodPost, "/topics/news/unsubscribe", ``); status != http.StatusBadRequest {
+		t.Fatalf("取消未订阅的主题 status = %d, want 400", status)
+	}
+	if status, _ := e.do(t, e.tokens[0], http.MethodPost, "/topics/news/unsubscribe", ``); status != http.StatusOK {
+		t.Fatalf("取消订阅 status = %d, want 200", status)
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"msgcenter/api/handler/middleware"
+	"msgcenter/app"
+	"msgcenter/service/unread"
+	"msgcenter/utils/objectid"
+)
+
+func InitUnread(router *fiber.App, svc *app.ServiceApp) {
+	router.Get("/users/:id/unread", middleware.Authenticate(svc.Auth), getUnread(svc)).Name("查询未读数")
+}
+
+type unreadResponse struct {
+	Total         int                 `json:"total"` // 角标数, 各会话未读数之和
+	Conversations map[objectid.ID]int `json:"conversations"`
+}
+
+func getUnread(svc *app.ServiceApp) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := unreadUser(c)
+		if err != nil {
+			return err
+		}
+		counts, err := unread.Counts(c.UserContext(), svc.DbClient(), svc.RedisClient(), userID,
+			svc.Config().Conversation.UnreadSync)
+		if err != nil {
+			return err
+		}
+		total := 0
+		for _, n := range counts {
+			total += n
+		}
+		return ok(c, unreadResponse{Total: total, Conversations: counts})
+	}
+}
+
+// unreadUser 返回要查询的用户, id 为 me 时取令牌中的用户; 只有管理员可以查询其他用户
+func unreadUser(c *fiber.Ctx) (objectid.ID, error) {
+	claims, _ := middleware.Claims(c)
+	if c.Params("id") == "me" {
+		if claims.UserID.IsZero() {
+			return objectid.ID{}, fiber.NewError(fiber.StatusForbidden, "需要用户令牌")
+		}
+		return claims.UserID, nil
+	}
+	userID, err := ParamObjectID(c, "id")
+	if err != nil {
+		return objectid.ID{}, err
+	}
+	if userID != claims.UserID && !claims.Admin {
+		return objectid.ID{}, fiber.NewError(fiber.StatusForbidden, "只能查询自己的未读数")
+	}
+	return userID, nil
+}
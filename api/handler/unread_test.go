@@ -0,0 +1,28 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestUnreadOnlyForCaller(t *testing.T) {
+	e := newConversationEnv(t, 2)
+	own, other := "/users/"+e.users[0].String()+"/unread", "/users/"+e.users[1].String()+"/unread"
+
+	cases := []struct {
+		name, token, path string
+		want              int
+	}{
+		{"未携带令牌", "", own, http.StatusUnauthorized},
+		{"查询其他用户", e.tokens[0], other, http.StatusForbidden},
+		{"查询自己", e.tokens[0], own, http.StatusOK},
+		{"me", e.tokens[0], "/users/me/unread", http.StatusOK},
+		{"管理员令牌的 me", e.env.AdminToken(t), "/users/me/unread", http.StatusForbidden},
+		{"管理员查询用户", e.env.AdminToken(t), other, http.StatusOK},
+	}
+	for _, tc := range cases {
+		if status, _ := e.do(t, tc.token, http.MethodGet, tc.path, ""); status != tc.want {
+			t.Fatalf("%s status = %d, want %d", tc.name, status, tc.want)
+		}
+	}
+}
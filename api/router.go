@@ -4,10 +4,26 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"msgcenter/api/handler"
 	"msgcenter/api/handler/middleware"
+	"msgcenter/app"
 )
 
-func InitRouter(app *fiber.App) {
-	middleware.InitZapLogger(app)
-	middleware.InitSonic(app)
-	handler.InitHealth(app)
+func InitRouter(router *fiber.App, svc *app.ServiceApp) {
+	middleware.InitRequestID(router)
+	middleware.InitTracing(router)
+	middleware.InitMetrics(router)
+	middleware.InitZapLogger(router)
+	middleware.InitSonic(router)
+	handler.InitHealth(router, svc.Health())
+	handler.InitMetrics(router)
+	handler.InitLogLevel(router, svc)
+	handler.InitAudit(router, svc)
+	handler.InitDeadLetter(router, svc)
+	handler.InitMessage(router, svc)
+	handler.InitConversation(router, svc)
+	handler.InitInbox(router, svc)
+	handler.InitTopic(router, svc)
+	handler.InitUnread(router, svc)
+	handler.InitSignal(router, svc)
+	handler.InitSchedule(router, svc)
+	InitWebsocket(router, svc)
 }
@@ -1 +1,76 @@
 package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"msgcenter/api/handler"
+	"msgcenter/api/handler/middleware"
+	"msgcenter/app"
+	"msgcenter/platform/auth"
+	"msgcenter/platform/ws"
+	"msgcenter/service/device"
+	"msgcenter/utils/logger"
+	"msgcenter/utils/reqctx"
+)
+
+// InitWebsocket 设备长连接. 用户和设备取自令牌声明, 令牌通过 token 查询参数或 Authorization 头传入,
+// 建立连接前校验设备已绑定到该用户
+func InitWebsocket(router *fiber.App, svc *app.ServiceApp) {
+	router.Use("/ws", func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		return c.Next()
+	}, middleware.AuthenticateWebsocket(svc.Auth), func(c *fiber.Ctx) error {
+		claims, _ := middleware.Claims(c)
+		if claims.UserID.IsZero() || claims.DeviceID.IsZero() {
+			return fiber.NewError(fiber.StatusForbidden, "令牌未绑定用户和设备")
+		}
+		err := device.CheckBound(c.UserContext(), svc.DbClient(), claims.UserID, claims.DeviceID)
+		if errors.Is(err, device.ErrNotBound) {
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		} else if err != nil {
+			return err
+		}
+		return c.Next()
+	})
+	router.Get("/ws", websocket.New(func(socket *websocket.Conn) {
+		serveWebsocket(svc, socket)
+	})).Name("设备长连接")
+}
+
+func serveWebsocket(svc *app.ServiceApp, socket *websocket.Conn) {
+	hub := svc.Hub()
+	claims, _ := socket.Locals(middleware.LocalsClaims).(auth.Claims)
+	conn := ws.NewConn(claims.UserID.String(), claims.DeviceID.String(), socket)
+	// 连接内的上行帧沿用升级请求的请求ID和令牌中的用户、设备
+	ctx := reqctx.WithClaims(context.Background(), claims.UserID, claims.DeviceID)
+	if id, ok := socket.Locals(middleware.LocalsRequestID).(string); ok {
+		ctx = reqctx.WithRequestID(ctx, id)
+	}
+	log := logger.With(ctx, logger.Named(logger.WS))
+
+	if !hub.Register(conn) {
+		return
+	}
+	defer hub.Unregister(conn)
+	go conn.WritePump()
+
+	for {
+		_, data, err := socket.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Warn("连接异常断开", zap.Error(err))
+			}
+			return
+		}
+		// 上行帧只有临时信号, 失败时丢弃, 不影响连接
+		if err := handler.ReceiveFrame(ctx, svc, conn.UserID, conn.DeviceID, data); err != nil {
+			log.Debug("上行帧处理失败", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package api_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+
+	"msgcenter/platform/auth"
+	"msgcenter/server"
+	"msgcenter/testkit"
+	"msgcenter/utils/objectid"
+)
+
+// freeAddr 返回一个空闲的本地端口, 长连接测试需要真实监听
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func dial(addr, token string) (*websocket.Conn, int, error) {
+	url := "ws://" + addr + "/ws"
+	if token != "" {
+		url += "?token=" + token
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	return conn, status, err
+}
+
+// bind 创建用户和设备并建立关联
+func bind(t *testing.T, s *server.Server) (user, device objectid.ID) {
+	t.Helper()
+	ctx := context.Background()
+	u, err := s.DbClient.User.Create().SetName("ws").Save(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := s.DbClient.Device.Create().SetClientDeviceID(objectid.New().String()[8:]).Save(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DbClient.UserDeviceRelation.Create().SetUserID(u.ID).SetDeviceID(d.ID).Save(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return u.ID, d.ID
+}
+
+func TestWebsocketAuthentication(t *testing.T) {
+	env := testkit.NewEnv(t)
+	env.Config.IP = freeAddr(t)
+	s := env.NewServer(t)
+	addr := env.Config.IP
+
+	user, device := bind(t, s)
+	_, other := bind(t, s)
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"未携带令牌", "", http.StatusUnauthorized},
+		{"伪造令牌", "forged.token", http.StatusUnauthorized},
+		{"令牌未绑定设备", env.Token(t, auth.Claims{UserID: user}), http.StatusForbidden},
+		{"其他用户的设备", env.Token(t, auth.Claims{UserID: user, DeviceID: other}), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, status, err := dial(addr, tt.token)
+			if err == nil {
+				conn.Close()
+				t.Fatal("应拒绝建立连接")
+			}
+			if status != tt.want {
+				t.Fatalf("status = %d, want %d", status, tt.want)
+			}
+		})
+	}
+
+	conn, _, err := dial(addr, env.Token(t, auth.Claims{UserID: user, DeviceID: device}))
+	if err != nil {
+		t.Fatalf("建立连接失败: %v", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !s.Hub.Online(device.String()) {
+		if time.Now().After(deadline) {
+			t.Fatal("设备应登记在线")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := s.Hub.Devices(user.String()); len(got) != 1 || got[0] != device.String() {
+		t.Fatalf("Devices = %v", got)
+	}
+}
@@ -3,35 +3,163 @@ package app
 import (
 	"github.com/redis/go-redis/v9"
 	"msgcenter/config"
+	"msgcenter/platform/auth"
 	"msgcenter/platform/consul"
 	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/health"
+	"msgcenter/platform/mq"
+	"msgcenter/platform/ws"
 	"sync"
 )
 
-var (
-	instance *ServiceApp
-	once     sync.Once
-)
-
+// ServiceApp 业务依赖容器, 由 server 创建后注入路由和业务服务.
+// 客户端通过访问方法获取, 配置热更新替换连接后业务代码即可读到新客户端, 测试时可直接注入假实现
 type ServiceApp struct {
-	LocalConfig *config.Config
-	DbClient    *gen.Client
-	Consul      *consul.Client
-	RedisClient *redis.Client
-}
-
-func GetService(localConfig *config.Config, dbClient *gen.Client, consul *consul.Client, redisClient *redis.Client) *ServiceApp {
-	once.Do(func() {
-		instance = &ServiceApp{
-			LocalConfig: localConfig,
-			DbClient:    dbClient,
-			Consul:      consul,
-			RedisClient: redisClient,
-		}
-	})
-	return instance
-}
-
-func SERVICE() *ServiceApp {
-	return instance
+	mu          sync.RWMutex
+	localConfig *config.Config
+	dbClient    *gen.Client
+	consul      *consul.Client
+	redisClient *redis.Client
+	hub         *ws.Hub
+	health      *health.Checker
+	broker      mq.Broker
+	auth        *auth.Signer
+}
+
+// Option 创建 ServiceApp 时注入依赖
+type Option func(*ServiceApp)
+
+func WithConfig(cfg *config.Config) Option {
+	return func(a *ServiceApp) { a.localConfig = cfg }
+}
+
+func WithDbClient(client *gen.Client) Option {
+	return func(a *ServiceApp) { a.dbClient = client }
+}
+
+func WithConsul(client *consul.Client) Option {
+	return func(a *ServiceApp) { a.consul = client }
+}
+
+func WithRedisClient(client *redis.Client) Option {
+	return func(a *ServiceApp) { a.redisClient = client }
+}
+
+func WithHub(hub *ws.Hub) Option {
+	return func(a *ServiceApp) { a.hub = hub }
+}
+
+func WithHealth(checker *health.Checker) Option {
+	return func(a *ServiceApp) { a.health = checker }
+}
+
+func WithBroker(broker mq.Broker) Option {
+	return func(a *ServiceApp) { a.broker = broker }
+}
+
+func WithAuth(signer *auth.Signer) Option {
+	return func(a *ServiceApp) { a.auth = signer }
+}
+
+func New(opts ...Option) *ServiceApp {
+	a := &ServiceApp{}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+func (a *ServiceApp) Config() *config.Config {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.localConfig
+}
+
+func (a *ServiceApp) DbClient() *gen.Client {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.dbClient
+}
+
+func (a *ServiceApp) Consul() *consul.Client {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.consul
+}
+
+func (a *ServiceApp) RedisClient() *redis.Client {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.redisClient
+}
+
+func (a *ServiceApp) Hub() *ws.Hub {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.hub
+}
+
+func (a *ServiceApp) Health() *health.Checker {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.health
+}
+
+func (a *ServiceApp) Broker() mq.Broker {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.broker
+}
+
+// Auth 返回令牌签名器, consul 中未配置密钥时为nil
+func (a *ServiceApp) Auth() *auth.Signer {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.auth
+}
+
+// SetConfig 本地配置热更新后替换
+func (a *ServiceApp) SetConfig(cfg *config.Config) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.localConfig = cfg
+}
+
+// SetDbClient 数据库配置变更重建连接后替换
+func (a *ServiceApp) SetDbClient(client *gen.Client) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.dbClient = client
+}
+
+func (a *ServiceApp) SetConsul(client *consul.Client) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.consul = client
+}
+
+// SetRedisClient redis配置变更重建连接后替换
+func (a *ServiceApp) SetRedisClient(client *redis.Client) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.redisClient = client
+}
+
+func (a *ServiceApp) SetHub(hub *ws.Hub) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.hub = hub
+}
+
+func (a *ServiceApp) SetBroker(broker mq.Broker) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.broker = broker
+}
+
+// SetAuth 令牌密钥轮换后替换
+func (a *ServiceApp) SetAuth(signer *auth.Signer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.auth = signer
 }
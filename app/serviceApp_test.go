@@ -0,0 +1,52 @@
+package app
+
+import (
+	"sync"
+	"testing"
+
+	"msgcenter/config"
+	"msgcenter/platform/auth"
+	"msgcenter/platform/mq"
+)
+
+func TestOptionsInjectDependencies(t *testing.T) {
+	cfg := &config.Config{IP: "127.0.0.1:0"}
+	broker := mq.NewMemory()
+	a := New(WithConfig(cfg), WithBroker(broker))
+
+	if a.Config() != cfg {
+		t.Fatal("Config 应返回注入的配置")
+	}
+	if a.Broker() != broker {
+		t.Fatal("Broker 应返回注入的消息队列")
+	}
+	// 未注入的依赖为nil, 由调用方判断是否就绪
+	if a.DbClient() != nil || a.RedisClient() != nil || a.Auth() != nil {
+		t.Fatal("未注入的依赖应为nil")
+	}
+}
+
+func TestSettersReplaceConcurrently(t *testing.T) {
+	a := New(WithConfig(&config.Config{IP: "old"}))
+	signer, _ := auth.NewSigner("secret")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			a.SetConfig(&config.Config{IP: "new"})
+			a.SetAuth(signer)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = a.Config()
+			_ = a.Auth()
+		}()
+	}
+	wg.Wait()
+
+	if a.Config().IP != "new" || a.Auth() != signer {
+		t.Fatal("Set 之后应读到替换后的依赖")
+	}
+}
@@ -34,19 +34,139 @@ type ConsulConfig struct {
 }
 
 type LogConfig struct {
-	Debug      bool   `yaml:"debug"`
-	Dir        string `yaml:"log_dir"`
-	MaxSize    int    `yaml:"log_max_size"`
-	MaxBackups int    `yaml:"log_max_backups"`
-	MaxAge     int    `yaml:"log_max_age"`
-	Compress   bool   `yaml:"log_compress"`
+	Debug      bool              `yaml:"debug"`
+	Dir        string            `yaml:"log_dir"`
+	MaxSize    int               `yaml:"log_max_size"`
+	MaxBackups int               `yaml:"log_max_backups"`
+	MaxAge     int               `yaml:"log_max_age"`
+	Compress   bool              `yaml:"log_compress"`
+	Modules    map[string]string `yaml:"modules"` // 子系统日志级别, 如 consul: debug
+	Sampling   LogSamplingConfig `yaml:"sampling"`
+}
+
+// LogSamplingConfig 高频日志(如HTTP访问日志)采样配置
+type LogSamplingConfig struct {
+	Tick       time.Duration `yaml:"tick"`
+	First      int           `yaml:"first"`
+	Thereafter int           `yaml:"thereafter"`
+}
+
+// TraceConfig 链路追踪配置
+type TraceConfig struct {
+	Exporter    string   `yaml:"exporter"`     // none / otlp / stdout / file, 默认 none 不导出
+	Endpoint    string   `yaml:"endpoint"`     // OTLP HTTP 地址, 如 127.0.0.1:4318
+	Insecure    bool     `yaml:"insecure"`     // OTLP 是否使用明文HTTP
+	File        string   `yaml:"file"`         // file 导出器的输出文件
+	SampleRatio *float64 `yaml:"sample_ratio"` // 采样率 [0, 1], 0 表示不采样, 未配置时全部采样
+}
+
+// ShutdownConfig 优雅下线配置
+type ShutdownConfig struct {
+	Timeout    time.Duration `yaml:"timeout"`     // 下线总超时
+	DrainDelay time.Duration `yaml:"drain_delay"` // consul注销后等待流量切走的时间, 未配置时为5s, 负数表示不等待
+}
+
+// StartupConfig 组件启动超时与重试配置
+type StartupConfig struct {
+	Timeout    time.Duration `yaml:"timeout"`     // 单个组件单次启动超时
+	Retries    int           `yaml:"retries"`     // 启动失败后的重试次数
+	Backoff    time.Duration `yaml:"backoff"`     // 首次重试间隔, 之后按指数增长
+	MaxBackoff time.Duration `yaml:"max_backoff"` // 重试间隔上限
+}
+
+// MigrateConfig 数据库迁移配置
+type MigrateConfig struct {
+	Auto bool `yaml:"auto"` // 连接数据库后自动执行未执行的迁移, 仅建议开发环境开启
+}
+
+// NodeConfig 节点ID配置, 用于生成 objectid 和 snowflake ID, 同时运行的实例必须互不相同
+type NodeConfig struct {
+	ID       *int          `yaml:"id"`        // 固定节点ID, 范围 [0, 1023]; 不配置时从 consul 租约分配
+	LeaseTTL time.Duration `yaml:"lease_ttl"` // consul 租约的 session TTL
+	// LayoutCutover 覆盖 objectid 毫秒布局的启用时间, 所有实例需配置相同的值且在该时间前完成升级
+	LayoutCutover time.Time `yaml:"layout_cutover"`
+}
+
+// OutboxConfig 事务发件箱发布配置
+type OutboxConfig struct {
+	Interval  time.Duration `yaml:"interval"`   // 没有积压时的轮询间隔
+	BatchSize int           `yaml:"batch_size"` // 每批发布的事件数
+	Retention time.Duration `yaml:"retention"`  // 已发布事件的保留时间, 0 表示不清理
+	LeaderTTL time.Duration `yaml:"leader_ttl"` // leader 选举的 session TTL
+	// MaxAttempts 单个事件的最大发布次数, 超过后标记失败并跳过, 0 使用默认值
+	MaxAttempts int `yaml:"max_attempts"`
+	// CommitGrace 只发布写入超过该时长的事件, 减少长事务晚提交导致的同键乱序, 0 表示不等待
+	CommitGrace time.Duration `yaml:"commit_grace"`
+}
+
+// MessageConfig 发送消息接口配置
+type MessageConfig struct {
+	MaxRecipients  int           `yaml:"max_recipients"`   // 单条消息直接指定的用户和设备总数上限
+	MaxPayloadSize int           `yaml:"max_payload_size"` // 消息内容的最大字节数
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`  // Idempotency-Key 的重放窗口
+}
+
+// ConversationConfig 会话配置
+type ConversationConfig struct {
+	MaxMembers      int           `yaml:"max_members"`      // 群成员上限, 创建群时指定的上限不能超过该值
+	FanoutThreshold int           `yaml:"fanout_threshold"` // 成员数超过该值的群不写设备收件箱, 由设备按会话拉取消息
+	UnreadSync      time.Duration `yaml:"unread_sync"`      // redis 中的未读数与数据库对账的间隔
+}
+
+// TopicConfig 主题广播配置
+type TopicConfig struct {
+	FanoutRate int `yaml:"fanout_rate"` // 所有实例合计每秒扩散的设备数上限, 0 表示不限速
+	BatchSize  int `yaml:"batch_size"`  // 每批处理的订阅数
+}
+
+// SignalConfig 正在输入等临时信号配置, 成员数超过 conversation.fanout_threshold 的群不转发信号
+type SignalConfig struct {
+	Throttle time.Duration `yaml:"throttle"` // 同一用户在同一会话的同类信号的最小间隔
+	TTL      time.Duration `yaml:"ttl"`      // 信号有效期, 接收端超时未收到新信号时自动清除状态
+}
+
+// ScheduleConfig 定时消息配置
+type ScheduleConfig struct {
+	Interval     time.Duration `yaml:"interval"`      // 检查到期消息的间隔
+	BatchSize    int           `yaml:"batch_size"`    // 每次发送的到期消息数
+	SyncInterval time.Duration `yaml:"sync_interval"` // 从数据库重建 redis 到期索引的间隔
+	Horizon      time.Duration `yaml:"horizon"`       // 重建索引时加载的未来时间窗口
+	MaxDelay     time.Duration `yaml:"max_delay"`     // 最多可提前多久定时
+	LeaderTTL    time.Duration `yaml:"leader_ttl"`    // leader 选举的 session TTL
+	MaxAttempts  int           `yaml:"max_attempts"`  // 单条消息的最大发送次数, 超过后标记失败
+	Backoff      time.Duration `yaml:"backoff"`       // 首次失败后的重试间隔, 之后按指数增长
+	MaxBackoff   time.Duration `yaml:"max_backoff"`   // 重试间隔上限
+}
+
+// RetentionConfig 消息清理任务配置, 保留策略本身在 consul 的 datacenter/retention 中
+type RetentionConfig struct {
+	LeaderTTL time.Duration `yaml:"leader_ttl"` // leader 选举的 session TTL
+}
+
+// SoftDeleteConfig 软删除记录清理配置
+type SoftDeleteConfig struct {
+	Retention     time.Duration `yaml:"retention"`      // 软删除记录保留时间, 超过后物理删除, 0 表示不清理
+	PurgeInterval time.Duration `yaml:"purge_interval"` // 清理间隔
 }
 
 type Config struct {
-	Consul ConsulConfig `yaml:"consul"`
-	IP     string       `yaml:"ip"`
-	Env    string       `yaml:"env"`
-	Log    LogConfig    `yaml:"log"`
+	Consul       ConsulConfig       `yaml:"consul"`
+	IP           string             `yaml:"ip"`
+	Env          string             `yaml:"env"`
+	Log          LogConfig          `yaml:"log"`
+	Trace        TraceConfig        `yaml:"trace"`
+	Migrate      MigrateConfig      `yaml:"migrate"`
+	Node         NodeConfig         `yaml:"node"`
+	Outbox       OutboxConfig       `yaml:"outbox"`
+	Message      MessageConfig      `yaml:"message"`
+	Conversation ConversationConfig `yaml:"conversation"`
+	Topic        TopicConfig        `yaml:"topic"`
+	Signal       SignalConfig       `yaml:"signal"`
+	Schedule     ScheduleConfig     `yaml:"schedule"`
+	Retention    RetentionConfig    `yaml:"retention"`
+	SoftDelete   SoftDeleteConfig   `yaml:"soft_delete"`
+	Startup      StartupConfig      `yaml:"startup"`
+	Shutdown     ShutdownConfig     `yaml:"shutdown"`
 }
 
 func Init() error {
@@ -119,14 +239,14 @@ func initWatcher(configPath string) error {
 	return nil
 }
 
+// watchLoop 监听配置文件变化. 重新加载和回调都在本 goroutine 中串行执行,
+// 连续多次修改按发生顺序生效, 不会出现旧配置覆盖新配置
 func watchLoop(configPath string) {
 	defer watcher.Close()
 
-	// 防抖动定时器
-	var (
-		timer     *time.Timer
-		lastEvent time.Time
-	)
+	// 防抖动: 500ms 内的事件合并为一次重新加载
+	var timer *time.Timer
+	reload := make(chan struct{}, 1)
 
 	for {
 		select {
@@ -145,22 +265,19 @@ func watchLoop(configPath string) {
 				continue
 			}
 
-			// 防抖动处理（500ms内的事件合并）
-			now := time.Now()
 			if timer != nil {
 				timer.Stop()
 			}
-
-			// 忽略1秒内的重复事件
-			if now.Sub(lastEvent) < time.Second {
-				continue
-			}
-
 			timer = time.AfterFunc(500*time.Millisecond, func() {
-				reloadConfig(configPath)
-				lastEvent = time.Now()
+				select {
+				case reload <- struct{}{}:
+				default:
+				}
 			})
 
+		case <-reload:
+			reloadConfig(configPath)
+
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				return
@@ -185,11 +302,12 @@ func reloadConfig(configPath string) {
 	configMutex.Lock()
 	oldConfig := GlobalConfig
 	GlobalConfig = newCfg
+	callbacks := append([]func(*Config, *Config){}, reloadCallbacks...)
 	configMutex.Unlock()
 
-	// 执行回调函数
-	for _, cb := range reloadCallbacks {
-		go cb(oldConfig, newCfg)
+	// 按注册顺序串行执行回调, 回调返回前不会处理下一次变化
+	for _, cb := range callbacks {
+		cb(oldConfig, newCfg)
 	}
 }
 
@@ -203,8 +321,10 @@ func validateConfig(cfg *Config) error {
 	return nil
 }
 
-// 注册热加载回调函数
+// 注册热加载回调函数, 回调在配置监听 goroutine 中串行执行, 不应长时间阻塞
 func RegisterReloadCallback(cb func(old, new *Config)) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
 	reloadCallbacks = append(reloadCallbacks, cb)
 }
 
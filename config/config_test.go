@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testYAML = `consul:
+  host: http://127.0.0.1:8500
+  service:
+    name: msg-center
+ip: 127.0.0.1:%d
+`
+
+// useConfigDir 在临时目录中写入 app.yaml 并切换工作目录, 测试结束后恢复
+func useConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	configMutex.Lock()
+	saved := reloadCallbacks
+	reloadCallbacks = nil
+	configMutex.Unlock()
+	t.Cleanup(func() {
+		configMutex.Lock()
+		reloadCallbacks = saved
+		configMutex.Unlock()
+	})
+	return filepath.Join(dir, "app.yaml")
+}
+
+func writeConfig(t *testing.T, path string, port int) {
+	t.Helper()
+	data := []byte(fmt.Sprintf(testYAML, port))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadRunsCallbacksSeriallyInOrder(t *testing.T) {
+	path := useConfigDir(t)
+	writeConfig(t, path, 8080)
+	GlobalConfig, _, _ = loadConfig()
+
+	var calls []string
+	RegisterReloadCallback(func(old, new *Config) {
+		calls = append(calls, "first "+old.IP+" -> "+new.IP)
+		time.Sleep(10 * time.Millisecond)
+	})
+	RegisterReloadCallback(func(old, new *Config) {
+		calls = append(calls, "second "+new.IP)
+	})
+
+	writeConfig(t, path, 8081)
+	reloadConfig(path)
+	writeConfig(t, path, 8082)
+	reloadConfig(path)
+
+	// reloadConfig 返回时回调已全部执行完, 且按注册顺序和变更顺序执行
+	want := []string{
+		"first 127.0.0.1:8080 -> 127.0.0.1:8081",
+		"second 127.0.0.1:8081",
+		"first 127.0.0.1:8081 -> 127.0.0.1:8082",
+		"second 127.0.0.1:8082",
+	}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("calls = %q, want %q", calls, want)
+	}
+	if got := Get().IP; got != "127.0.0.1:8082" {
+		t.Fatalf("GlobalConfig.IP = %s", got)
+	}
+}
+
+func TestReloadSkipsInvalidConfig(t *testing.T) {
+	path := useConfigDir(t)
+	writeConfig(t, path, 8080)
+	GlobalConfig, _, _ = loadConfig()
+
+	called := false
+	RegisterReloadCallback(func(old, new *Config) { called = true })
+
+	if err := os.WriteFile(path, []byte("ip: 127.0.0.1:9000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reloadConfig(path)
+	if called {
+		t.Fatal("缺少 consul 配置时不应触发回调")
+	}
+	if got := Get().IP; got != "127.0.0.1:8080" {
+		t.Fatalf("无效配置不应生效, IP = %s", got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := &Config{IP: "a", Log: LogConfig{Debug: true}}
+	new := &Config{IP: "b", Log: LogConfig{Debug: true, Modules: map[string]string{"sql": "debug"}}}
+	changes := Diff(old, new)
+	want := []string{"ip: a -> b", "log.modules: map[] -> map[sql:debug]"}
+	if !slices.Equal(changes, want) {
+		t.Fatalf("Diff = %q, want %q", changes, want)
+	}
+	if len(Diff(old, old)) != 0 {
+		t.Fatal("相同配置不应有变化")
+	}
+}
+
+func TestDiffTime(t *testing.T) {
+	at := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	old := &Config{Node: NodeConfig{LayoutCutover: at}}
+	new := &Config{Node: NodeConfig{LayoutCutover: at.AddDate(0, 1, 0)}}
+	if changes := Diff(old, new); len(changes) != 1 || !strings.HasPrefix(changes[0], "node.layout_cutover: ") {
+		t.Fatalf("Diff = %q", changes)
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// timeType time.Time 按值比较, 不展开其未导出字段
+var timeType = reflect.TypeOf(time.Time{})
+
+// Diff 比较新旧配置, 返回发生变化的字段, 格式为 "log.debug: true -> false"
+func Diff(old, new *Config) []string {
+	changes := make([]string, 0)
+	if old == nil || new == nil {
+		return changes
+	}
+	diffValue("", reflect.ValueOf(*old), reflect.ValueOf(*new), &changes)
+	return changes
+}
+
+func diffValue(path string, oldVal, newVal reflect.Value, changes *[]string) {
+	if oldVal.Kind() == reflect.Struct && oldVal.Type() != timeType {
+		t := oldVal.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "" {
+				name = strings.ToLower(t.Field(i).Name)
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			diffValue(name, oldVal.Field(i), newVal.Field(i), changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(oldVal.Interface(), newVal.Interface()) {
+		*changes = append(*changes, fmt.Sprintf("%s: %v -> %v", path, oldVal.Interface(), newVal.Interface()))
+	}
+}
@@ -1,9 +1,24 @@
 package main
 
 import (
+	"context"
+	"log/slog"
 	"msgcenter/server"
+	"os"
 )
 
 func main() {
-	server.GetServer().Start()
+	// msgcenter migrate <命令>: 执行数据库迁移后退出
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := server.GetServer().Migrate(context.Background(), os.Args[2:]); err != nil {
+			slog.Error("数据库迁移失败", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := server.GetServer().Start(); err != nil {
+		slog.Error("服务异常退出", "error", err)
+		os.Exit(1)
+	}
 }
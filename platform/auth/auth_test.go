@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"msgcenter/utils/objectid"
+)
+
+func TestSignVerify(t *testing.T) {
+	s, err := NewSigner("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Claims{UserID: objectid.New(), DeviceID: objectid.New(), Admin: true}
+	token, err := s.Sign(want, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != want.UserID || got.DeviceID != want.DeviceID || !got.Admin {
+		t.Fatalf("Verify = %+v, want %+v", got, want)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	s, _ := NewSigner("secret")
+	other, _ := NewSigner("other")
+	token, _ := s.Sign(Claims{UserID: objectid.New()}, time.Minute)
+	expired, _ := s.Sign(Claims{UserID: objectid.New()}, -time.Second)
+
+	// 篡改声明后签名不再匹配
+	body, sig, _ := strings.Cut(token, ".")
+	forged := body[:len(body)-1] + "A." + sig
+
+	tests := []struct {
+		name   string
+		signer *Signer
+		token  string
+		want   error
+	}{
+		{"其他密钥签发", other, token, ErrInvalidToken},
+		{"篡改声明", s, forged, ErrInvalidToken},
+		{"缺少签名", s, body, ErrInvalidToken},
+		{"非base64", s, "!!.!!", ErrInvalidToken},
+		{"已过期", s, expired, ErrExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.signer.Verify(tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSignerRequiresSecret(t *testing.T) {
+	if _, err := NewSigner(""); !errors.Is(err, ErrNoSecret) {
+		t.Fatalf("err = %v, want ErrNoSecret", err)
+	}
+}
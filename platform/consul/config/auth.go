@@ -0,0 +1,6 @@
+package config
+
+// Auth 令牌签名配置, secret 修改后旧令牌全部失效
+type Auth struct {
+	Secret string `json:"secret"`
+}
@@ -0,0 +1,7 @@
+package config
+
+// Log 运行时日志级别配置, modules 为各子系统的级别覆盖
+type Log struct {
+	Level   string            `json:"level"`
+	Modules map[string]string `json:"modules"`
+}
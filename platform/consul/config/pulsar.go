@@ -0,0 +1,10 @@
+package config
+
+// Pulsar 消息队列连接配置, 超时单位为秒
+type Pulsar struct {
+	URL               string `json:"url"`
+	Tenant            string `json:"tenant"`
+	Namespace         string `json:"namespace"`
+	OperationTimeout  int    `json:"operation_timeout"`
+	ConnectionTimeout int    `json:"connection_timeout"`
+}
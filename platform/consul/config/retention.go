@@ -0,0 +1,17 @@
+package config
+
+// Retention 消息保留策略, 时间单位为秒. conversations 以会话ID覆盖默认策略,
+// 已过有效期(ttl)的消息按默认策略的 action 处理
+type Retention struct {
+	Interval      int                        `json:"interval"`
+	BatchSize     int                        `json:"batch_size"`
+	ArchiveDir    string                     `json:"archive_dir"`
+	Default       RetentionPolicy            `json:"default"`
+	Conversations map[string]RetentionPolicy `json:"conversations"`
+}
+
+// RetentionPolicy 超过 max_age 的消息按 action(delete/archive) 清理, max_age 为 0 表示永久保留
+type RetentionPolicy struct {
+	MaxAge int    `json:"max_age"`
+	Action string `json:"action"`
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/bytedance/sonic"
 	"msgcenter/platform/consul/config"
+	"msgcenter/platform/metrics"
 	"sync"
 	"time"
 
@@ -19,9 +20,13 @@ type WatchConfig struct {
 }
 
 const (
-	Sqldb  = "datacenter/sqldb"
-	Redis  = "datacenter/redis"
-	Banner = "datacenter/banner"
+	Sqldb     = "datacenter/sqldb"
+	Redis     = "datacenter/redis"
+	Banner    = "datacenter/banner"
+	Log       = "datacenter/log"
+	Pulsar    = "datacenter/pulsar"
+	Retention = "datacenter/retention"
+	Auth      = "datacenter/auth"
 )
 
 type Client struct {
@@ -161,6 +166,7 @@ func (c *Client) StartDynamicWatch(cfg WatchConfig) {
 		if _, exists := c.serviceWatchers[service]; !exists {
 			ctx, cancel := context.WithCancel(c.watchCtx)
 			c.serviceWatchers[service] = cancel
+			c.activeWatchers.Add(1)
 			go c.watchService(ctx, service)
 		}
 	}
@@ -181,6 +187,7 @@ func (c *Client) StartDynamicWatch(cfg WatchConfig) {
 		if _, exists := c.keyWatchers[key]; !exists {
 			ctx, cancel := context.WithCancel(c.watchCtx)
 			c.keyWatchers[key] = cancel
+			c.activeWatchers.Add(1)
 			go c.watchKey(ctx, key)
 		}
 	}
@@ -199,7 +206,6 @@ func (c *Client) StartDynamicWatch(cfg WatchConfig) {
 
 // 服务发现监控
 func (c *Client) watchService(ctx context.Context, serviceName string) {
-	c.activeWatchers.Add(1)
 	defer c.activeWatchers.Done()
 
 	c.logger.Debug("开始监控服务",
@@ -221,28 +227,40 @@ func (c *Client) watchService(ctx context.Context, serviceName string) {
 				serviceName,
 				"",
 				true,
-				&api.QueryOptions{
+				(&api.QueryOptions{
 					WaitIndex: lastIndex,
 					WaitTime:  10 * time.Second,
-				},
+				}).WithContext(ctx),
 			)
 
 			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
 				c.logger.Warn("服务监控错误",
 					zap.String("service", serviceName),
 					zap.Error(err),
 					zap.Int("retryCount", retryCount),
 				)
 				retryCount++
-				time.Sleep(time.Duration(retryCount) * time.Second)
+				metrics.ConsulWatchErrors.WithLabelValues("service", serviceName).Inc()
+				metrics.ConsulWatchRetries.WithLabelValues("service", serviceName).Set(float64(retryCount))
+				if !sleepCtx(ctx, time.Duration(retryCount)*time.Second) {
+					return
+				}
 				continue
 			}
-			retryCount = 0
+			if retryCount > 0 {
+				retryCount = 0
+				metrics.ConsulWatchRetries.WithLabelValues("service", serviceName).Set(0)
+			}
 
 			if meta.LastIndex != lastIndex {
 				c.updateServiceCache(serviceName, entries)
 				lastIndex = meta.LastIndex
+				c.mu.Lock()
 				c.serviceLastIndex[serviceName] = lastIndex
+				c.mu.Unlock()
 			}
 		}
 	}
@@ -267,7 +285,6 @@ func (c *Client) updateServiceCache(serviceName string, entries []*api.ServiceEn
 
 // 配置监控
 func (c *Client) watchKey(ctx context.Context, key string) {
-	c.activeWatchers.Add(1)
 	defer c.activeWatchers.Done()
 
 	c.logger.Debug("开始监控配置键",
@@ -285,22 +302,32 @@ func (c *Client) watchKey(ctx context.Context, key string) {
 			)
 			return
 		default:
-			kv, meta, err := c.client.KV().Get(key, &api.QueryOptions{
+			kv, meta, err := c.client.KV().Get(key, (&api.QueryOptions{
 				WaitIndex: lastIndex,
 				WaitTime:  10 * time.Second,
-			})
+			}).WithContext(ctx))
 
 			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
 				c.logger.Warn("配置监控错误",
 					zap.String("key", key),
 					zap.Error(err),
 					zap.Int("retryCount", retryCount),
 				)
 				retryCount++
-				time.Sleep(time.Duration(retryCount) * time.Second)
+				metrics.ConsulWatchErrors.WithLabelValues("key", key).Inc()
+				metrics.ConsulWatchRetries.WithLabelValues("key", key).Set(float64(retryCount))
+				if !sleepCtx(ctx, time.Duration(retryCount)*time.Second) {
+					return
+				}
 				continue
 			}
-			retryCount = 0
+			if retryCount > 0 {
+				retryCount = 0
+				metrics.ConsulWatchRetries.WithLabelValues("key", key).Set(0)
+			}
 
 			if meta.LastIndex != lastIndex {
 				c.updateKeyCache(key, kv)
@@ -419,14 +446,24 @@ func (c *Client) RefreshConnection(newAddress string) error {
 	return nil
 }
 
-func (c *Client) Close() {
+// StopWatch 停止所有监控并等待监控协程退出
+func (c *Client) StopWatch() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.watchCancel()
+	c.serviceWatchers = make(map[string]context.CancelFunc)
+	c.keyWatchers = make(map[string]context.CancelFunc)
+	c.mu.Unlock()
 
+	// 监控协程退出前可能需要获取锁更新缓存, 不能持锁等待
+	c.activeWatchers.Wait()
+}
+
+func (c *Client) Close() {
 	c.logger.Info("关闭Consul客户端")
+	c.StopWatch()
 
-	c.watchCancel()
-	c.activeWatchers.Wait()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	for serviceID := range c.services {
 		if err := c.client.Agent().ServiceDeregister(serviceID); err != nil {
@@ -441,6 +478,22 @@ func (c *Client) Close() {
 // 公共访问接口
 // -------------------------------------------------------------------
 
+// Ping 查询集群leader, 用于就绪检查
+func (c *Client) Ping(ctx context.Context) error {
+	c.mu.RLock()
+	client := c.client
+	c.mu.RUnlock()
+
+	leader, err := client.Status().LeaderWithQueryOptions((&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if leader == "" {
+		return fmt.Errorf("consul集群无leader")
+	}
+	return nil
+}
+
 func (c *Client) GetServiceInstances(serviceName string) []*api.ServiceEntry {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -487,6 +540,16 @@ func contains(slice []string, s string) bool {
 	return false
 }
 
+// sleepCtx 等待指定时间, ctx 取消时返回false
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 func keys[K comparable, V any](m map[K]V) []K {
 	keys := make([]K, 0, len(m))
 	for k := range m {
@@ -495,47 +558,110 @@ func keys[K comparable, V any](m map[K]V) []K {
 	return keys
 }
 
-func (c *Client) GetSqldb() config.SqlDb {
+func (c *Client) GetSqldb() (config.SqlDb, error) {
 	var sqldb config.SqlDb
 	err := sonic.Unmarshal(c.GetConfigValue(Sqldb), &sqldb)
 	if err != nil {
 		c.logger.Error("解析SQL配置失败",
 			zap.Error(err),
 		)
-		panic(err)
+		return sqldb, fmt.Errorf("解析SQL配置失败: %w", err)
 	}
 	c.logger.Info("获取SQL配置成功",
 		zap.Any("config", sqldb),
 	)
-	return sqldb
+	return sqldb, nil
 }
 
-func (c *Client) GetRedis() config.Redis {
+func (c *Client) GetRedis() (config.Redis, error) {
 	var redis config.Redis
 	err := sonic.Unmarshal(c.GetConfigValue(Redis), &redis)
 	if err != nil {
 		c.logger.Error("解析Redis配置失败",
 			zap.Error(err),
 		)
-		panic(err)
+		return redis, fmt.Errorf("解析Redis配置失败: %w", err)
 	}
 	c.logger.Info("获取Redis配置成功",
 		zap.Any("config", redis),
 	)
-	return redis
+	return redis, nil
 }
 
-func (c *Client) GetBanner() config.Banner {
+func (c *Client) GetPulsar() (config.Pulsar, error) {
+	var pulsar config.Pulsar
+	value := c.GetConfigValue(Pulsar)
+	if value == nil {
+		return pulsar, fmt.Errorf("配置键不存在: %s", Pulsar)
+	}
+	if err := sonic.Unmarshal(value, &pulsar); err != nil {
+		c.logger.Error("解析Pulsar配置失败",
+			zap.Error(err),
+		)
+		return pulsar, fmt.Errorf("解析Pulsar配置失败: %w", err)
+	}
+	c.logger.Info("获取Pulsar配置成功",
+		zap.Any("config", pulsar),
+	)
+	return pulsar, nil
+}
+
+func (c *Client) GetBanner() (config.Banner, error) {
 	var banner config.Banner
 	err := sonic.Unmarshal(c.GetConfigValue(Banner), &banner)
 	if err != nil {
 		c.logger.Error("解析Banner配置失败",
 			zap.Error(err),
 		)
-		panic(err)
+		return banner, fmt.Errorf("解析Banner配置失败: %w", err)
 	}
 	c.logger.Info("获取Banner成功",
 		zap.Any("banner", banner),
 	)
-	return banner
+	return banner, nil
+}
+
+func (c *Client) GetLog() (config.Log, error) {
+	var log config.Log
+	value := c.GetConfigValue(Log)
+	if value == nil {
+		return log, fmt.Errorf("配置键不存在: %s", Log)
+	}
+	if err := sonic.Unmarshal(value, &log); err != nil {
+		c.logger.Error("解析日志配置失败",
+			zap.Error(err),
+		)
+		return log, err
+	}
+	return log, nil
+}
+
+func (c *Client) GetRetention() (config.Retention, error) {
+	var retention config.Retention
+	value := c.GetConfigValue(Retention)
+	if value == nil {
+		return retention, fmt.Errorf("配置键不存在: %s", Retention)
+	}
+	if err := sonic.Unmarshal(value, &retention); err != nil {
+		c.logger.Error("解析消息保留配置失败",
+			zap.Error(err),
+		)
+		return retention, fmt.Errorf("解析消息保留配置失败: %w", err)
+	}
+	return retention, nil
+}
+
+func (c *Client) GetAuth() (config.Auth, error) {
+	var auth config.Auth
+	value := c.GetConfigValue(Auth)
+	if value == nil {
+		return auth, fmt.Errorf("配置键不存在: %s", Auth)
+	}
+	if err := sonic.Unmarshal(value, &auth); err != nil {
+		c.logger.Error("解析鉴权配置失败",
+			zap.Error(err),
+		)
+		return auth, fmt.Errorf("解析鉴权配置失败: %w", err)
+	}
+	return auth, nil
 }
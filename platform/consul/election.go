@@ -0,0 +1,76 @@
+package consul
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/metrics"
+)
+
+const (
+	// electionWait 等待锁的阻塞查询时长, 决定非 leader 实例下线时的最长等待
+	electionWait  = time.Second
+	electionRetry = 5 * time.Second
+)
+
+// Elect 竞选 key 对应的 leader, 当选后执行 fn; 失去 leader 身份或 fn 返回时取消 fn 的 ctx 并重新竞选.
+// 阻塞直到 ctx 取消, 退出前释放锁以便其他实例立即接管
+func (c *Client) Elect(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context)) {
+	log := c.logger.With(zap.String("election", key))
+	for ctx.Err() == nil {
+		lock, err := c.api().LockOpts(&api.LockOptions{
+			Key:          key,
+			SessionName:  "leader " + key,
+			SessionTTL:   ttl.String(),
+			LockWaitTime: electionWait,
+		})
+		if err != nil {
+			log.Warn("创建选举锁失败", zap.Error(err))
+			sleepCtx(ctx, electionRetry)
+			continue
+		}
+
+		lost, err := lock.Lock(ctx.Done())
+		if err != nil {
+			log.Warn("竞选leader失败", zap.Error(err))
+			sleepCtx(ctx, electionRetry)
+			continue
+		}
+		if lost == nil {
+			return
+		}
+
+		log.Info("当选leader")
+		metrics.ConsulLeader.WithLabelValues(key).Set(1)
+		leaderCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			fn(leaderCtx)
+		}()
+
+		returned := false
+		select {
+		case <-lost:
+			log.Warn("失去leader身份")
+		case <-done:
+			returned = true
+		case <-ctx.Done():
+		}
+		cancel()
+		<-done
+		metrics.ConsulLeader.WithLabelValues(key).Set(0)
+
+		if err := lock.Unlock(); err != nil && err != api.ErrLockNotHeld {
+			log.Warn("释放选举锁失败", zap.Error(err))
+		}
+		log.Info("已退出leader")
+		// fn 自行退出通常是出错, 稍后再竞选避免反复切换
+		if returned {
+			sleepCtx(ctx, electionRetry)
+		}
+	}
+}
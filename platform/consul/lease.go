@@ -0,0 +1,199 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"go.uber.org/zap"
+)
+
+// NodeLease 通过 consul session 占用的节点ID, session 有效期间其他实例无法占用同一ID
+type NodeLease struct {
+	client   *Client
+	prefix   string
+	max      int
+	ttl      time.Duration
+	onChange func(id int) error
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	mu      sync.Mutex
+	id      int
+	session string
+}
+
+// AcquireNode 在 prefix 下按 0..max 依次尝试占用节点ID, 返回第一个占用成功的租约.
+// 租约在后台续期, 失效后优先重新占用同一ID; 该ID已被其他实例占用时改占其他空闲ID,
+// 并调用 onChange 通知调用方切换节点, 避免与新的占用者生成重复ID. 调用 Release 释放
+func (c *Client) AcquireNode(ctx context.Context, prefix string, max int, ttl time.Duration, onChange func(id int) error) (*NodeLease, error) {
+	lease := &NodeLease{
+		client:   c,
+		prefix:   prefix,
+		max:      max,
+		ttl:      ttl,
+		onChange: onChange,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	id, err := lease.acquireAny(ctx, -1)
+	if err != nil {
+		return nil, err
+	}
+	c.logger.Info("节点ID租约已获取", zap.Int("node", id), zap.String("key", lease.key(id)))
+	go lease.keepAlive()
+	return lease, nil
+}
+
+// ID 当前占用的节点ID
+func (l *NodeLease) ID() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.id
+}
+
+func (l *NodeLease) key(id int) string {
+	return l.prefix + strconv.Itoa(id)
+}
+
+// acquireAny 优先占用 prefer, 失败时按 0..max 依次尝试其他ID
+func (l *NodeLease) acquireAny(ctx context.Context, prefer int) (int, error) {
+	if prefer >= 0 {
+		ok, err := l.acquire(ctx, prefer)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return prefer, nil
+		}
+	}
+	for id := 0; id <= l.max; id++ {
+		if id == prefer {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		ok, err := l.acquire(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("节点ID已全部被占用: %s[0-%d]", l.prefix, l.max)
+}
+
+// acquire 创建 session 并尝试占用 id 对应的键, 占用失败时销毁 session
+func (l *NodeLease) acquire(ctx context.Context, id int) (bool, error) {
+	client := l.client.api()
+	wo := (&api.WriteOptions{}).WithContext(ctx)
+	key := l.key(id)
+
+	session, _, err := client.Session().Create(&api.SessionEntry{
+		Name:     "node-lease " + key,
+		TTL:      l.ttl.String(),
+		Behavior: api.SessionBehaviorRelease,
+	}, wo)
+	if err != nil {
+		return false, fmt.Errorf("创建consul session失败: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	ok, _, err := client.KV().Acquire(&api.KVPair{
+		Key:     key,
+		Value:   []byte(hostname),
+		Session: session,
+	}, wo)
+	if err != nil || !ok {
+		_, _ = client.Session().Destroy(session, nil)
+		if err != nil {
+			return false, fmt.Errorf("占用节点ID失败: %w", err)
+		}
+		return false, nil
+	}
+	l.mu.Lock()
+	l.id, l.session = id, session
+	l.mu.Unlock()
+	return true, nil
+}
+
+// keepAlive 续期 session, 失效后重新占用节点ID
+func (l *NodeLease) keepAlive() {
+	defer close(l.done)
+
+	for {
+		l.mu.Lock()
+		id, session := l.id, l.session
+		l.mu.Unlock()
+
+		// stop 关闭时 RenewPeriodic 销毁 session 并返回
+		err := l.client.api().Session().RenewPeriodic(l.ttl.String(), session, nil, l.stop)
+		select {
+		case <-l.stop:
+			return
+		default:
+		}
+		l.client.logger.Error("节点ID租约失效, 尝试重新占用",
+			zap.Int("node", id),
+			zap.Error(err),
+		)
+
+		for {
+			next, err := l.acquireAny(context.Background(), id)
+			if err == nil {
+				l.moved(id, next)
+				break
+			}
+			l.client.logger.Warn("重新占用节点ID失败", zap.Int("node", id), zap.Error(err))
+			select {
+			case <-l.stop:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+// moved 记录重新占用的结果, 节点ID变化时通知调用方
+func (l *NodeLease) moved(prev, next int) {
+	if next == prev {
+		l.client.logger.Info("节点ID租约已重新获取", zap.Int("node", next))
+		return
+	}
+	l.client.logger.Warn("原节点ID已被其他实例占用, 切换节点ID",
+		zap.Int("from", prev),
+		zap.Int("to", next),
+	)
+	if l.onChange == nil {
+		return
+	}
+	if err := l.onChange(next); err != nil {
+		l.client.logger.Error("切换节点ID失败", zap.Int("node", next), zap.Error(err))
+	}
+}
+
+// Release 停止续期并销毁 session, 节点ID可被其他实例占用. 可重复调用
+func (l *NodeLease) Release(ctx context.Context) error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	select {
+	case <-l.done:
+		l.client.logger.Info("节点ID租约已释放", zap.Int("node", l.ID()))
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// api 返回当前的 consul api 客户端, 地址刷新后会被替换
+func (c *Client) api() *api.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client
+}
@@ -0,0 +1,115 @@
+package consul_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"msgcenter/platform/consul"
+	"msgcenter/testkit"
+)
+
+const leasePrefix = "service/test/nodes/"
+
+func newLeaseClient(t *testing.T) (*testkit.Consul, *consul.Client) {
+	t.Helper()
+	fake := testkit.NewConsul(t)
+	client, err := consul.NewClient(fake.Addr(), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, client
+}
+
+func acquireNode(t *testing.T, client *consul.Client, onChange func(int) error) *consul.NodeLease {
+	t.Helper()
+	lease, err := client.AcquireNode(context.Background(), leasePrefix, 3, 200*time.Millisecond, onChange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lease.Release(context.Background()) })
+	return lease
+}
+
+func TestAcquireNodeSkipsTaken(t *testing.T) {
+	_, client := newLeaseClient(t)
+	a := acquireNode(t, client, nil)
+	b := acquireNode(t, client, nil)
+	if a.ID() != 0 || b.ID() != 1 {
+		t.Fatalf("节点ID = %d, %d, want 0, 1", a.ID(), b.ID())
+	}
+
+	// 释放后ID可被再次占用
+	if err := a.Release(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if c := acquireNode(t, client, nil); c.ID() != 0 {
+		t.Fatalf("释放后节点ID = %d, want 0", c.ID())
+	}
+}
+
+func TestAcquireNodeExhausted(t *testing.T) {
+	_, client := newLeaseClient(t)
+	for range 4 {
+		acquireNode(t, client, nil)
+	}
+	if _, err := client.AcquireNode(context.Background(), leasePrefix, 3, time.Second, nil); err == nil {
+		t.Fatal("节点ID全部被占用时应返回错误")
+	}
+}
+
+func TestLeaseReacquiresSameNode(t *testing.T) {
+	fake, client := newLeaseClient(t)
+	changed := make(chan int, 1)
+	lease := acquireNode(t, client, func(id int) error { changed <- id; return nil })
+
+	session := fake.LockHolder(leasePrefix + "0")
+	fake.InvalidateSession(session)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if holder := fake.LockHolder(leasePrefix + "0"); holder != "" && holder != session {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("租约失效后未重新占用原节点ID")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if lease.ID() != 0 {
+		t.Fatalf("节点ID = %d, want 0", lease.ID())
+	}
+	select {
+	case id := <-changed:
+		t.Fatalf("重新占用原ID不应通知切换, got %d", id)
+	default:
+	}
+}
+
+func TestLeaseMovesWhenNodeTaken(t *testing.T) {
+	fake, client := newLeaseClient(t)
+	changed := make(chan int, 1)
+	lease := acquireNode(t, client, func(id int) error { changed <- id; return nil })
+	other := acquireNode(t, client, nil)
+
+	// 租约失效期间原ID被其他实例占用
+	fake.InvalidateSession(fake.LockHolder(leasePrefix + "0"))
+	taker := acquireNode(t, client, nil)
+	if taker.ID() != 0 {
+		t.Fatalf("其他实例占用的节点ID = %d, want 0", taker.ID())
+	}
+
+	select {
+	case id := <-changed:
+		if id != 2 {
+			t.Fatalf("切换后的节点ID = %d, want 2", id)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("原ID被占用后未切换节点ID")
+	}
+	if lease.ID() != 2 || other.ID() != 1 {
+		t.Fatalf("节点ID = %d, %d, want 2, 1", lease.ID(), other.ID())
+	}
+}
@@ -0,0 +1,196 @@
+package dlq
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/deadletter"
+	"msgcenter/platform/ent/gen/predicate"
+	"msgcenter/platform/mq"
+	"msgcenter/utils/objectid"
+	"msgcenter/utils/reqctx"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// HeaderDeadLetterID 重放消息携带的死信ID, 再次失败时更新原死信而不是新增
+const HeaderDeadLetterID = "dlq-id"
+
+// Filter 死信查询条件, 零值字段不参与过滤
+type Filter struct {
+	Topic        string
+	Subscription string
+	From         time.Time // 包含
+	To           time.Time // 不包含
+	// IncludeReplayed 批量重放时包含已重放且未再次失败的死信, 默认跳过以免重复重放
+	IncludeReplayed bool
+	Limit           int
+	Offset          int
+}
+
+func (f Filter) predicates() []predicate.DeadLetter {
+	var ps []predicate.DeadLetter
+	if f.Topic != "" {
+		ps = append(ps, deadletter.Topic(f.Topic))
+	}
+	if f.Subscription != "" {
+		ps = append(ps, deadletter.Subscription(f.Subscription))
+	}
+	if !f.From.IsZero() {
+		ps = append(ps, deadletter.CreateTimeGTE(f.From))
+	}
+	if !f.To.IsZero() {
+		ps = append(ps, deadletter.CreateTimeLT(f.To))
+	}
+	return ps
+}
+
+func (f Filter) limit() int {
+	if f.Limit <= 0 {
+		return defaultLimit
+	}
+	return min(f.Limit, maxLimit)
+}
+
+// Store 将死信保存到数据库, 并支持重放到原订阅
+type Store struct {
+	client func() *gen.Client
+	broker func() mq.Broker
+}
+
+// NewStore client 和 broker 每次调用时获取, 配置热更新后使用新连接
+func NewStore(client func() *gen.Client, broker func() mq.Broker) *Store {
+	return &Store{client: client, broker: broker}
+}
+
+// DeadLetter 实现 mq.DeadLetterSink, 重放的消息再次失败时更新原死信并清空重放时间
+func (s *Store) DeadLetter(ctx context.Context, dl mq.DeadLetter) error {
+	msg := dl.Message
+	client := s.client()
+	if raw := msg.Properties[HeaderDeadLetterID]; raw != "" {
+		if id, err := objectid.Parse(raw); err == nil {
+			err := client.DeadLetter.UpdateOneID(id).
+				SetReason(dl.Reason.Error()).
+				SetPermanent(mq.IsPermanent(dl.Reason)).
+				SetAttempts(len(dl.History)).
+				SetHistory(dl.History).
+				ClearReplayTime().
+				Exec(ctx)
+			// 原死信已删除时新增
+			if !gen.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	// 失败记录单独保存, 不重复记录在消息头中
+	props := maps.Clone(msg.Properties)
+	delete(props, mq.HeaderAttempt)
+	delete(props, mq.HeaderHistory)
+	delete(props, HeaderDeadLetterID)
+	return client.DeadLetter.Create().
+		SetTopic(msg.Topic).
+		SetSubscription(dl.Subscription).
+		SetMessageID(msg.ID).
+		SetKey(msg.Key).
+		SetPayload(msg.Payload).
+		SetProperties(props).
+		SetReason(dl.Reason.Error()).
+		SetPermanent(mq.IsPermanent(dl.Reason)).
+		SetAttempts(len(dl.History)).
+		SetHistory(dl.History).
+		Exec(ctx)
+}
+
+// List 按时间倒序查询死信
+func (s *Store) List(ctx context.Context, f Filter) ([]*gen.DeadLetter, error) {
+	return s.client().DeadLetter.Query().
+		Where(f.predicates()...).
+		Order(gen.Desc(deadletter.FieldCreateTime), gen.Desc(deadletter.FieldID)).
+		Limit(f.limit()).
+		Offset(f.Offset).
+		All(ctx)
+}
+
+// Get 查询单条死信, 不存在时返回 *gen.NotFoundError
+func (s *Store) Get(ctx context.Context, id objectid.ID) (*gen.DeadLetter, error) {
+	return s.client().DeadLetter.Get(ctx, id)
+}
+
+// Replay 将死信重新发布到原订阅的重试主题, 只有该订阅会再次处理.
+// 重放的消息重新计算处理次数, 死信记录保留并累加重放次数, 再次失败时更新该记录
+func (s *Store) Replay(ctx context.Context, id objectid.ID) (*gen.DeadLetter, error) {
+	client, broker := s.client(), s.broker()
+	if broker == nil {
+		return nil, fmt.Errorf("消息队列未初始化")
+	}
+	dl, err := client.DeadLetter.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	props := maps.Clone(dl.Properties)
+	if props == nil {
+		props = make(map[string]string, 1)
+	}
+	props[HeaderDeadLetterID] = id.String()
+	if _, err := broker.Publish(reqctx.FromMetadata(ctx, dl.Properties), &mq.Message{
+		Topic:      mq.RetryTopic(dl.Topic, dl.Subscription),
+		Key:        dl.Key,
+		Payload:    dl.Payload,
+		Properties: props,
+	}); err != nil {
+		return nil, fmt.Errorf("重放死信 %s 失败: %w", id, err)
+	}
+	return client.DeadLetter.UpdateOneID(id).
+		AddReplayCount(1).
+		SetReplayTime(time.Now()).
+		Save(ctx)
+}
+
+// ReplayMatching 按条件批量重放, 每次最多 maxLimit 条, 遇到失败时停止并返回已重放的数量.
+// 默认跳过已重放且未再次失败的死信, 重复调用不会重复重放
+func (s *Store) ReplayMatching(ctx context.Context, f Filter) (int, error) {
+	ps := f.predicates()
+	if !f.IncludeReplayed {
+		ps = append(ps, deadletter.ReplayTimeIsNil())
+	}
+	ids, err := s.client().DeadLetter.Query().
+		Where(ps...).
+		Order(gen.Asc(deadletter.FieldCreateTime), gen.Asc(deadletter.FieldID)).
+		Limit(f.limit()).
+		Offset(f.Offset).
+		IDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return s.ReplayIDs(ctx, ids)
+}
+
+// ReplayIDs 按顺序重放, 遇到失败时停止并返回已重放的数量
+func (s *Store) ReplayIDs(ctx context.Context, ids []objectid.ID) (int, error) {
+	for i, id := range ids {
+		if _, err := s.Replay(ctx, id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// Delete 删除单条死信
+func (s *Store) Delete(ctx context.Context, id objectid.ID) error {
+	return s.client().DeadLetter.DeleteOneID(id).Exec(ctx)
+}
+
+// Purge 按条件删除死信, 忽略分页参数, 返回删除的数量
+func (s *Store) Purge(ctx context.Context, f Filter) (int, error) {
+	return s.client().DeadLetter.Delete().
+		Where(f.predicates()...).
+		Exec(ctx)
+}
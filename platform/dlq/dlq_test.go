@@ -0,0 +1,183 @@
+package dlq_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"msgcenter/platform/dlq"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/mq"
+	"msgcenter/testkit"
+)
+
+type dlqEnv struct {
+	client *gen.Client
+	broker *mq.Memory
+	store  *dlq.Store
+}
+
+func newDLQEnv(t *testing.T) *dlqEnv {
+	t.Helper()
+	client := testkit.NewEntClient(t)
+	broker := mq.NewMemory()
+	t.Cleanup(func() { _ = broker.Close() })
+	return &dlqEnv{
+		client: client,
+		broker: broker,
+		store:  dlq.NewStore(func() *gen.Client { return client }, func() mq.Broker { return broker }),
+	}
+}
+
+func (e *dlqEnv) deadLetter(t *testing.T, topic string, props map[string]string) {
+	t.Helper()
+	err := e.store.DeadLetter(context.Background(), mq.DeadLetter{
+		Message:      &mq.Message{Topic: topic, ID: "m", Payload: []byte("p"), Properties: props},
+		Subscription: "s",
+		Reason:       mq.Permanent(errors.New("boom")),
+		History:      []mq.Attempt{{Time: time.Now(), Error: "boom"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayMatchingSkipsReplayed(t *testing.T) {
+	ctx := context.Background()
+	env := newDLQEnv(t)
+	env.deadLetter(t, "t", nil)
+	env.deadLetter(t, "t", nil)
+	c, _ := env.broker.Subscribe(ctx, mq.SubscribeOptions{Topic: mq.RetryTopic("t", "s"), Subscription: "s"})
+	defer c.Close()
+
+	if n, err := env.store.ReplayMatching(ctx, dlq.Filter{Topic: "t"}); err != nil || n != 2 {
+		t.Fatalf("ReplayMatching = %d, %v, want 2", n, err)
+	}
+	// 已重放的死信不再重复重放
+	if n, err := env.store.ReplayMatching(ctx, dlq.Filter{Topic: "t"}); err != nil || n != 0 {
+		t.Fatalf("再次 ReplayMatching = %d, %v, want 0", n, err)
+	}
+	if n, err := env.store.ReplayMatching(ctx, dlq.Filter{Topic: "t", IncludeReplayed: true}); err != nil || n != 2 {
+		t.Fatalf("IncludeReplayed ReplayMatching = %d, %v, want 2", n, err)
+	}
+
+	for _, dl := range env.client.DeadLetter.Query().AllX(ctx) {
+		if dl.ReplayCount != 2 || dl.ReplayTime == nil {
+			t.Fatalf("死信 %s replay_count = %d, replay_time = %v", dl.ID, dl.ReplayCount, dl.ReplayTime)
+		}
+	}
+	if n := env.broker.Backlog(mq.RetryTopic("t", "s"), "s"); n != 4 {
+		t.Fatalf("重试主题积压 = %d, want 4", n)
+	}
+}
+
+func TestReplayCarriesDeadLetterID(t *testing.T) {
+	ctx := context.Background()
+	env := newDLQEnv(t)
+	env.deadLetter(t, "t", map[string]string{"k": "v"})
+	c, _ := env.broker.Subscribe(ctx, mq.SubscribeOptions{Topic: mq.RetryTopic("t", "s"), Subscription: "s"})
+	defer c.Close()
+
+	dl := env.client.DeadLetter.Query().OnlyX(ctx)
+	if _, ok := dl.Properties[dlq.HeaderDeadLetterID]; ok {
+		t.Fatal("死信保存的消息头不应包含死信ID")
+	}
+	if _, err := env.store.Replay(ctx, dl.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	msg, err := c.Receive(rctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Properties[dlq.HeaderDeadLetterID] != dl.ID.String() || msg.Properties["k"] != "v" {
+		t.Fatalf("重放消息头 = %v", msg.Properties)
+	}
+}
+
+func TestDeadLetterFallsBackWhenOriginalDeleted(t *testing.T) {
+	ctx := context.Background()
+	env := newDLQEnv(t)
+	env.deadLetter(t, "t", nil)
+	dl := env.client.DeadLetter.Query().OnlyX(ctx)
+	if err := env.store.Delete(ctx, dl.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	env.deadLetter(t, "t", map[string]string{dlq.HeaderDeadLetterID: dl.ID.String()})
+	if n := env.client.DeadLetter.Query().CountX(ctx); n != 1 {
+		t.Fatalf("原死信已删除时应新增, 数量 = %d", n)
+	}
+}
+
+// 重放后再次失败的消息更新原死信, 不新增记录
+func TestReplayedMessageFailsAgain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env := newDLQEnv(t)
+
+	// 预先创建持久订阅, worker 启动前发布的消息不会丢失
+	for _, topic := range []string{"t", mq.RetryTopic("t", "s")} {
+		c, err := env.broker.Subscribe(ctx, mq.SubscribeOptions{Topic: topic, Subscription: "s"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = c.Close()
+	}
+	if _, err := env.broker.Publish(ctx, &mq.Message{Topic: "t", Payload: []byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls atomic.Int32
+	w := &mq.Worker{
+		Broker:       env.broker,
+		Subscription: mq.SubscribeOptions{Topic: "t", Subscription: "s"},
+		Handler: func(context.Context, *mq.Message) error {
+			calls.Add(1)
+			return mq.Permanent(errors.New("bad payload"))
+		},
+		DeadLetter: env.store,
+		Logger:     zap.NewNop(),
+	}
+	done := make(chan struct{})
+	go func() { _ = w.Run(ctx); close(done) }()
+	defer func() { cancel(); <-done }()
+
+	waitFor := func(cond func() bool, msg string) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal(msg)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor(func() bool { return env.client.DeadLetter.Query().CountX(ctx) == 1 }, "消息未写入死信")
+
+	dl := env.client.DeadLetter.Query().OnlyX(ctx)
+	if _, err := env.store.Replay(ctx, dl.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(func() bool {
+		got := env.client.DeadLetter.GetX(ctx, dl.ID)
+		return got.ReplayCount == 1 && got.ReplayTime == nil
+	}, "重放后再次失败未更新原死信")
+
+	if n := env.client.DeadLetter.Query().CountX(ctx); n != 1 {
+		t.Fatalf("死信数量 = %d, want 1", n)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("处理次数 = %d, want 2", calls.Load())
+	}
+	// 再次失败的死信可以再次按条件重放
+	if n, err := env.store.ReplayMatching(ctx, dlq.Filter{Topic: "t"}); err != nil || n != 1 {
+		t.Fatalf("ReplayMatching = %d, %v, want 1", n, err)
+	}
+}
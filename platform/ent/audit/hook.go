@@ -0,0 +1,237 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"entgo.io/ent"
+	"github.com/bytedance/sonic"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/auditlog"
+	"msgcenter/platform/ent/gen/conversation"
+	"msgcenter/platform/ent/gen/conversationmember"
+	"msgcenter/platform/ent/gen/device"
+	"msgcenter/platform/ent/gen/scheduledmessage"
+	"msgcenter/platform/ent/gen/topic"
+	"msgcenter/platform/ent/gen/topicsubscription"
+	"msgcenter/platform/ent/gen/user"
+	"msgcenter/platform/ent/gen/userdevicerelation"
+	"msgcenter/platform/ent/softdelete"
+	"msgcenter/utils/objectid"
+	"msgcenter/utils/reqctx"
+)
+
+const redacted = "******"
+
+// mutation 审计用到的生成代码方法, 所有 ObjectId 主键的实体均满足
+type mutation interface {
+	ent.Mutation
+	Client() *gen.Client
+	ID() (objectid.ID, bool)
+	IDs(ctx context.Context) ([]objectid.ID, error)
+}
+
+// Hook 在变更后写入审计日志, 更新和删除记录变更前后有差异的字段.
+// 审计日志与变更使用同一个客户端, 在事务中时一同提交或回滚
+func Hook(sensitive ...string) ent.Hook {
+	secret := make(map[string]bool, len(sensitive))
+	for _, name := range sensitive {
+		secret[name] = true
+	}
+
+	return func(next ent.Mutator) ent.Mutator {
+		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+			if skipped(ctx) {
+				return next.Mutate(ctx, m)
+			}
+			mx, ok := m.(mutation)
+			if !ok {
+				return nil, fmt.Errorf("审计不支持的mutation类型 %T", m)
+			}
+
+			// 变更前的状态, 包含已软删除的记录
+			loadCtx := softdelete.WithDeleted(ctx)
+			var (
+				ids    []objectid.ID
+				before map[string]map[string]any
+			)
+			if !m.Op().Is(ent.OpCreate) {
+				var err error
+				if ids, err = mx.IDs(loadCtx); err != nil {
+					return nil, err
+				}
+				if len(ids) == 0 {
+					return next.Mutate(ctx, m)
+				}
+				if before, err = load(loadCtx, mx.Client(), m.Type(), ids); err != nil {
+					return nil, err
+				}
+			}
+
+			v, err := next.Mutate(ctx, m)
+			if err != nil {
+				return v, err
+			}
+
+			var entries []*gen.AuditLogCreate
+			newEntry := func(entityID string, op auditlog.Op, b, a map[string]any) *gen.AuditLogCreate {
+				return mx.Client().AuditLog.Create().
+					SetActor(actor(ctx)).
+					SetEntity(m.Type()).
+					SetEntityID(entityID).
+					SetOp(op).
+					SetBefore(redact(b, secret)).
+					SetAfter(redact(a, secret)).
+					SetRequestID(reqctx.RequestID(ctx))
+			}
+
+			switch {
+			case m.Op().Is(ent.OpCreate):
+				after := toMap(v)
+				id := fmt.Sprint(after["id"])
+				if oid, ok := mx.ID(); ok {
+					id = oid.String()
+				}
+				entries = append(entries, newEntry(id, auditlog.OpCreate, nil, after))
+			case m.Op().Is(ent.OpDelete | ent.OpDeleteOne):
+				for _, oid := range ids {
+					id := oid.String()
+					entries = append(entries, newEntry(id, auditlog.OpDelete, before[id], nil))
+				}
+			default:
+				after, err := load(loadCtx, mx.Client(), m.Type(), ids)
+				if err != nil {
+					return nil, err
+				}
+				for _, oid := range ids {
+					id := oid.String()
+					b, a := diff(before[id], after[id])
+					if len(a) == 0 {
+						continue
+					}
+					op := auditlog.OpUpdate
+					// 软删除以更新操作执行, 按删除记录
+					if deleted, _ := a[softdelete.FieldDeleteFlag].(bool); deleted {
+						op = auditlog.OpDelete
+					}
+					entries = append(entries, newEntry(id, op, b, a))
+				}
+			}
+
+			if len(entries) > 0 {
+				if err := mx.Client().AuditLog.CreateBulk(entries...).Exec(ctx); err != nil {
+					return nil, fmt.Errorf("写入审计日志失败: %w", err)
+				}
+			}
+			return v, nil
+		})
+	}
+}
+
+// actor 返回变更的操作者: 令牌中的用户, 其次是管理员令牌, 都没有时为系统
+func actor(ctx context.Context) string {
+	if id := reqctx.UserID(ctx); id != "" {
+		return id
+	}
+	if reqctx.Admin(ctx) {
+		return ActorAdmin
+	}
+	return ActorSystem
+}
+
+// load 按主键加载实体当前的字段值, 新增需要审计的实体时在此添加
+func load(ctx context.Context, client *gen.Client, typ string, ids []objectid.ID) (map[string]map[string]any, error) {
+	var (
+		rows any
+		err  error
+	)
+	switch typ {
+	case gen.TypeUser:
+		rows, err = client.User.Query().Where(user.IDIn(ids...)).All(ctx)
+	case gen.TypeDevice:
+		rows, err = client.Device.Query().Where(device.IDIn(ids...)).All(ctx)
+	case gen.TypeUserDeviceRelation:
+		rows, err = client.UserDeviceRelation.Query().Where(userdevicerelation.IDIn(ids...)).All(ctx)
+	case gen.TypeConversation:
+		rows, err = client.Conversation.Query().Where(conversation.IDIn(ids...)).All(ctx)
+	case gen.TypeConversationMember:
+		rows, err = client.ConversationMember.Query().Where(conversationmember.IDIn(ids...)).All(ctx)
+	case gen.TypeTopic:
+		rows, err = client.Topic.Query().Where(topic.IDIn(ids...)).All(ctx)
+	case gen.TypeTopicSubscription:
+		rows, err = client.TopicSubscription.Query().Where(topicsubscription.IDIn(ids...)).All(ctx)
+	case gen.TypeScheduledMessage:
+		rows, err = client.ScheduledMessage.Query().Where(scheduledmessage.IDIn(ids...)).All(ctx)
+	default:
+		return nil, fmt.Errorf("审计未支持的实体类型 %s", typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rv := reflect.ValueOf(rows)
+	out := make(map[string]map[string]any, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		fields := toMap(rv.Index(i).Interface())
+		out[fmt.Sprint(fields["id"])] = fields
+	}
+	return out, nil
+}
+
+// toMap 按json标签将实体转为字段名到值的映射, 不包含关联
+func toMap(v any) map[string]any {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	rt := rv.Type()
+	fields := make(map[string]any, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "" || name == "-" || name == "edges" {
+			continue
+		}
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				fields[name] = nil
+				continue
+			}
+			fv = fv.Elem()
+		}
+		fields[name] = fv.Interface()
+	}
+	return fields
+}
+
+// diff 返回前后不同的字段
+func diff(before, after map[string]any) (map[string]any, map[string]any) {
+	b := make(map[string]any)
+	a := make(map[string]any)
+	for name, av := range after {
+		bv := before[name]
+		bj, _ := sonic.Marshal(bv)
+		aj, _ := sonic.Marshal(av)
+		if string(bj) != string(aj) {
+			b[name] = bv
+			a[name] = av
+		}
+	}
+	return b, a
+}
+
+func redact(fields map[string]any, secret map[string]bool) map[string]any {
+	if fields == nil {
+		return nil
+	}
+	for name := range fields {
+		if secret[name] {
+			fields[name] = redacted
+		}
+	}
+	return fields
+}
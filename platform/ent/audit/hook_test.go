@@ -0,0 +1,94 @@
+package audit_test
+
+import (
+	"context"
+	"testing"
+
+	"msgcenter/platform/ent/audit"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/auditlog"
+	"msgcenter/platform/ent/gen/topicsubscription"
+	"msgcenter/testkit"
+	"msgcenter/utils/objectid"
+	"msgcenter/utils/reqctx"
+)
+
+func logsOf(t *testing.T, client *gen.Client, entity, id string) []*gen.AuditLog {
+	t.Helper()
+	logs, err := client.AuditLog.Query().
+		Where(auditlog.Entity(entity), auditlog.EntityID(id)).
+		Order(gen.Asc(auditlog.FieldID)).
+		All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logs
+}
+
+func TestActorFromContext(t *testing.T) {
+	client := testkit.NewEntClient(t)
+	uid := objectid.New()
+	ctx := reqctx.WithRequestID(reqctx.WithClaims(context.Background(), uid, objectid.ID{}), "req-1")
+
+	u := client.User.Create().SetName("alice").SetPassword("secret").SaveX(ctx)
+	client.User.UpdateOneID(u.ID).SetName("bob").ExecX(context.Background())
+
+	logs := logsOf(t, client, gen.TypeUser, u.ID.String())
+	if len(logs) != 2 {
+		t.Fatalf("审计日志数量 = %d, want 2", len(logs))
+	}
+	if logs[0].Actor != uid.String() || logs[0].RequestID != "req-1" || logs[0].Op != auditlog.OpCreate {
+		t.Fatalf("创建日志 = %+v", logs[0])
+	}
+	if logs[0].After["password"] == "secret" {
+		t.Fatal("敏感字段未脱敏")
+	}
+	// 无调用方的变更记为系统操作, 只记录变化的字段
+	if logs[1].Actor != audit.ActorSystem || logs[1].Op != auditlog.OpUpdate {
+		t.Fatalf("更新日志 = %+v", logs[1])
+	}
+	if logs[1].Before["name"] != "alice" || logs[1].After["name"] != "bob" || len(logs[1].After) != 1 {
+		t.Fatalf("更新日志 before = %v, after = %v", logs[1].Before, logs[1].After)
+	}
+}
+
+func TestAdminActor(t *testing.T) {
+	client := testkit.NewEntClient(t)
+	ctx := reqctx.WithAdmin(context.Background())
+
+	u := client.User.Create().SetName("alice").SaveX(ctx)
+	logs := logsOf(t, client, gen.TypeUser, u.ID.String())
+	if len(logs) != 1 || logs[0].Actor != audit.ActorAdmin {
+		t.Fatalf("管理员令牌的变更 = %+v, want actor %q", logs, audit.ActorAdmin)
+	}
+}
+
+func TestTopicSubscriptionAudited(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	topic := client.Topic.Create().SetName("news").SaveX(ctx)
+	sub := client.TopicSubscription.Create().
+		SetTopicID(topic.ID).
+		SetSubscriberType(topicsubscription.SubscriberTypeUser).
+		SetSubscriberID(objectid.New()).
+		SaveX(ctx)
+	if _, err := client.TopicSubscription.Delete().Where(topicsubscription.ID(sub.ID)).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := logsOf(t, client, gen.TypeTopicSubscription, sub.ID.String())
+	if len(logs) != 2 || logs[0].Op != auditlog.OpCreate || logs[1].Op != auditlog.OpDelete {
+		t.Fatalf("订阅审计日志 = %+v", logs)
+	}
+	if logs[1].Before["topic_id"] != topic.ID.String() {
+		t.Fatalf("删除日志 before = %v", logs[1].Before)
+	}
+}
+
+func TestWithoutAudit(t *testing.T) {
+	client := testkit.NewEntClient(t)
+	u := client.User.Create().SetName("carol").SaveX(audit.WithoutAudit(context.Background()))
+	if logs := logsOf(t, client, gen.TypeUser, u.ID.String()); len(logs) != 0 {
+		t.Fatalf("WithoutAudit 下仍写入审计日志: %+v", logs)
+	}
+}
@@ -0,0 +1,38 @@
+package audit
+
+import (
+	"context"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/mixin"
+)
+
+// 审计日志中没有请求用户时记录的操作者
+const (
+	ActorSystem = "system" // 后台任务等非请求发起的变更
+	ActorAdmin  = "admin"  // 不含用户的管理员令牌
+)
+
+type skipKey struct{}
+
+// WithoutAudit 返回不记录审计日志的 ctx, 用于清理任务等批量维护操作
+func WithoutAudit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipKey{}, true)
+}
+
+func skipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipKey{}).(bool)
+	return skip
+}
+
+// Mixin 为实体的每次变更写入审计日志, Sensitive 中的字段在日志中脱敏.
+// 与 softdelete.Mixin 同时使用时需放在其后, 软删除会以更新操作记录.
+// 只用于 ObjectId 主键的业务实体; 消息、收件箱、outbox 事件和死信等高频且只追加的数据不审计
+type Mixin struct {
+	mixin.Schema
+	Sensitive []string
+}
+
+func (m Mixin) Hooks() []ent.Hook {
+	return []ent.Hook{Hook(m.Sensitive...)}
+}
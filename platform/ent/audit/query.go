@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/auditlog"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// Filter 审计日志查询条件, 零值字段不参与过滤
+type Filter struct {
+	Entity   string
+	EntityID string
+	Actor    string
+	From     time.Time // 包含
+	To       time.Time // 不包含
+	Limit    int
+	Offset   int
+}
+
+// Query 按时间倒序查询审计日志
+func Query(ctx context.Context, client *gen.Client, f Filter) ([]*gen.AuditLog, error) {
+	q := client.AuditLog.Query()
+	if f.Entity != "" {
+		q.Where(auditlog.Entity(f.Entity))
+	}
+	if f.EntityID != "" {
+		q.Where(auditlog.EntityID(f.EntityID))
+	}
+	if f.Actor != "" {
+		q.Where(auditlog.Actor(f.Actor))
+	}
+	if !f.From.IsZero() {
+		q.Where(auditlog.CreateTimeGTE(f.From))
+	}
+	if !f.To.IsZero() {
+		q.Where(auditlog.CreateTimeLT(f.To))
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return q.
+		Order(gen.Desc(auditlog.FieldCreateTime), gen.Desc(auditlog.FieldID)).
+		Limit(limit).
+		Offset(f.Offset).
+		All(ctx)
+}
@@ -0,0 +1,141 @@
+package driver
+
+import (
+	"context"
+	stdsql "database/sql"
+	"fmt"
+	"msgcenter/platform/tracing"
+	"msgcenter/utils/logger"
+	"msgcenter/utils/reqctx"
+	"strings"
+	"time"
+
+	"entgo.io/ent/dialect"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Driver 包装ent驱动, 将请求ID以SQL注释的形式带入Postgres, 记录查询日志并创建span
+type Driver struct {
+	dialect.Driver
+	logger *zap.Logger
+}
+
+func New(drv dialect.Driver, logger *zap.Logger) *Driver {
+	return &Driver{Driver: drv, logger: logger}
+}
+
+func (d *Driver) Exec(ctx context.Context, query string, args, v any) error {
+	return execWith(ctx, d.Driver, d.logger, query, args, v)
+}
+
+func (d *Driver) Query(ctx context.Context, query string, args, v any) error {
+	return queryWith(ctx, d.Driver, d.logger, query, args, v)
+}
+
+func (d *Driver) Tx(ctx context.Context) (dialect.Tx, error) {
+	tx, err := d.Driver.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, ctx: ctx, logger: d.logger}, nil
+}
+
+// BeginTx 供 gen.Client.BeginTx 使用
+func (d *Driver) BeginTx(ctx context.Context, opts *stdsql.TxOptions) (dialect.Tx, error) {
+	drv, ok := d.Driver.(interface {
+		BeginTx(context.Context, *stdsql.TxOptions) (dialect.Tx, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("driver.BeginTx is not supported")
+	}
+	tx, err := drv.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, ctx: ctx, logger: d.logger}, nil
+}
+
+// Tx 包装事务, 事务内语句沿用开启事务时的请求上下文
+type Tx struct {
+	dialect.Tx
+	ctx    context.Context
+	logger *zap.Logger
+}
+
+func (t *Tx) Exec(ctx context.Context, query string, args, v any) error {
+	return execWith(ctx, t.Tx, t.logger, query, args, v)
+}
+
+func (t *Tx) Query(ctx context.Context, query string, args, v any) error {
+	return queryWith(ctx, t.Tx, t.logger, query, args, v)
+}
+
+func (t *Tx) Commit() error {
+	err := t.Tx.Commit()
+	logger.With(t.ctx, t.logger).Debug("事务提交", zap.Error(err))
+	return err
+}
+
+func (t *Tx) Rollback() error {
+	err := t.Tx.Rollback()
+	logger.With(t.ctx, t.logger).Debug("事务回滚", zap.Error(err))
+	return err
+}
+
+func execWith(ctx context.Context, eq dialect.ExecQuerier, l *zap.Logger, query string, args, v any) error {
+	ctx, span := startSpan(ctx, "exec", query)
+	start := time.Now()
+	err := eq.Exec(ctx, annotate(ctx, query), args, v)
+	logQuery(ctx, l, "exec", query, start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func queryWith(ctx context.Context, eq dialect.ExecQuerier, l *zap.Logger, query string, args, v any) error {
+	ctx, span := startSpan(ctx, "query", query)
+	start := time.Now()
+	err := eq.Query(ctx, annotate(ctx, query), args, v)
+	logQuery(ctx, l, "query", query, start, err)
+	tracing.End(span, err)
+	return err
+}
+
+func startSpan(ctx context.Context, op, query string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "sql."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBQueryText(strings.TrimSpace(query)),
+		),
+	)
+}
+
+// annotate 在语句末尾追加请求ID注释, 可在 pg_stat_activity 和慢查询日志中看到
+func annotate(ctx context.Context, query string) string {
+	id := reqctx.RequestID(ctx)
+	if id == "" || !reqctx.ValidRequestID(id) {
+		return query
+	}
+	return query + " /* request_id='" + id + "' */"
+}
+
+func logQuery(ctx context.Context, l *zap.Logger, op, query string, start time.Time, err error) {
+	if err != nil {
+		logger.With(ctx, l).Warn("SQL执行失败",
+			zap.String("op", op),
+			zap.String("query", strings.TrimSpace(query)),
+			zap.Duration("latency", time.Since(start)),
+			zap.Error(err),
+		)
+		return
+	}
+	if l.Core().Enabled(zap.DebugLevel) {
+		logger.With(ctx, l).Debug("SQL执行",
+			zap.String("op", op),
+			zap.String("query", strings.TrimSpace(query)),
+			zap.Duration("latency", time.Since(start)),
+		)
+	}
+}
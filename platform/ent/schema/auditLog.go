@@ -0,0 +1,61 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// AuditLog 定义审计日志表结构, 由 audit.Mixin 的hook写入
+type AuditLog struct {
+	ent.Schema
+}
+
+// Mixin of the AuditLog.
+func (AuditLog) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		ObjectIDMixin{},
+	}
+}
+
+// Fields of the AuditLog.
+func (AuditLog) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("actor").
+			MaxLen(64).
+			Immutable(),
+		field.String("entity").
+			MaxLen(64).
+			Immutable(),
+		field.String("entity_id").
+			MaxLen(64).
+			Immutable(),
+		field.Enum("op").
+			Values("create", "update", "delete").
+			Immutable(),
+		field.JSON("before", map[string]any{}).
+			Optional().
+			Immutable(),
+		field.JSON("after", map[string]any{}).
+			Optional().
+			Immutable(),
+		field.String("request_id").
+			MaxLen(64).
+			Optional().
+			Immutable(),
+		field.Time("create_time").
+			Default(time.Now).
+			Immutable(),
+	}
+}
+
+// Indexes of the AuditLog.
+func (AuditLog) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("entity", "entity_id", "create_time"),
+		index.Fields("actor", "create_time"),
+		index.Fields("create_time"),
+	}
+}
@@ -0,0 +1,69 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+
+	"msgcenter/utils/objectid"
+)
+
+// Broadcast 定义主题广播任务表结构, 按订阅主键分批扩散并记录进度, 中断后从 cursor 继续
+type Broadcast struct {
+	ent.Schema
+}
+
+// Mixin of the Broadcast.
+func (Broadcast) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		ObjectIDMixin{},
+	}
+}
+
+// Fields of the Broadcast.
+func (Broadcast) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("topic_id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Immutable(),
+		field.Int64("message_id").
+			Immutable(),
+		// 定向表达式, 为空表示全部订阅者
+		field.Text("target").
+			Optional().
+			Immutable(),
+		field.Enum("status").
+			NamedValues(
+				"Pending", "Pending",
+				"Running", "Running",
+				"Done", "Done",
+				"Failed", "Failed",
+			).
+			Default("Pending"),
+		// 已处理的最后一条订阅
+		field.String("cursor").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Optional(),
+		field.Int("device_count").
+			Default(0),
+		field.Text("error").
+			Optional(),
+		field.Time("create_time").
+			Default(time.Now).
+			Immutable(),
+		field.Time("finish_time").
+			Optional().
+			Nillable(),
+	}
+}
+
+// Indexes of the Broadcast.
+func (Broadcast) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("topic_id", "create_time"),
+	}
+}
@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+
+	"msgcenter/platform/ent/audit"
+)
+
+// Conversation 定义会话表结构, 单聊固定两名成员, 群聊成员数不超过 member_limit
+type Conversation struct {
+	ent.Schema
+}
+
+// Mixin of the Conversation.
+func (Conversation) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		ObjectIDMixin{},
+		audit.Mixin{},
+	}
+}
+
+// Fields of the Conversation.
+func (Conversation) Fields() []ent.Field {
+	return []ent.Field{
+		field.Enum("type").
+			NamedValues(
+				"Direct", "Direct",
+				"Group", "Group",
+			).
+			Immutable(),
+		field.String("name").
+			MaxLen(64).
+			Optional(),
+		// 单聊双方用户ID排序后以冒号拼接, 唯一约束保证两名用户之间只有一个单聊; 群聊为空
+		field.String("direct_key").
+			MaxLen(49).
+			Optional().
+			Nillable().
+			Unique().
+			Immutable(),
+		field.Int("member_limit"),
+		// 随成员加入和退出在同一事务中更新, 用于人数限制和选择扩散方式
+		field.Int("member_count").
+			Default(0),
+		field.Time("create_time").
+			Default(time.Now).
+			Immutable(),
+	}
+}
+
+// Edges of the Conversation.
+func (Conversation) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("members", ConversationMember.Type),
+	}
+}
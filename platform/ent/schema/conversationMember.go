@@ -0,0 +1,81 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+
+	"msgcenter/platform/ent/audit"
+	"msgcenter/utils/objectid"
+)
+
+// ConversationMember 定义会话成员表结构, 每个群有且只有一个 Owner
+type ConversationMember struct {
+	ent.Schema
+}
+
+// Mixin of the ConversationMember.
+func (ConversationMember) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		ObjectIDMixin{},
+		audit.Mixin{},
+	}
+}
+
+// Fields of the ConversationMember.
+func (ConversationMember) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("conversation_id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Immutable(),
+		field.String("user_id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Immutable(),
+		field.Enum("role").
+			NamedValues(
+				"Owner", "Owner",
+				"Admin", "Admin",
+				"Member", "Member",
+			).
+			Default("Member"),
+		// 禁言截止时间, 为空或已过期表示未禁言
+		field.Time("muted_until").
+			Optional().
+			Nillable(),
+		field.Time("join_time").
+			Default(time.Now).
+			Immutable(),
+		// 已读的最后一条消息ID, 之后的消息计为未读
+		field.Int64("read_cursor").
+			Default(0),
+		field.Time("read_time").
+			Optional().
+			Nillable(),
+	}
+}
+
+// Edges of the ConversationMember.
+func (ConversationMember) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("conversation", Conversation.Type).
+			Ref("members").
+			Field("conversation_id").
+			Required().
+			Unique().
+			Immutable(),
+	}
+}
+
+// Indexes of the ConversationMember.
+func (ConversationMember) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("conversation_id", "user_id").
+			Unique(),
+		index.Fields("user_id"),
+	}
+}
@@ -0,0 +1,72 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+
+	"msgcenter/platform/mq"
+)
+
+// DeadLetter 定义死信表结构, 保存超过重试次数或永久失败的消息
+type DeadLetter struct {
+	ent.Schema
+}
+
+// Mixin of the DeadLetter.
+func (DeadLetter) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		ObjectIDMixin{},
+	}
+}
+
+// Fields of the DeadLetter.
+func (DeadLetter) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("topic").
+			MaxLen(255).
+			Immutable(),
+		field.String("subscription").
+			MaxLen(255).
+			Immutable(),
+		// 消息队列中的消息ID
+		field.String("message_id").
+			MaxLen(255).
+			Immutable(),
+		field.String("key").
+			MaxLen(255).
+			Optional().
+			Immutable(),
+		field.Bytes("payload").
+			Immutable(),
+		field.JSON("properties", map[string]string{}).
+			Optional().
+			Immutable(),
+		// 以下字段在重放后再次失败时更新
+		field.Text("reason"),
+		field.Bool("permanent").
+			Default(false),
+		field.Int("attempts"),
+		field.JSON("history", []mq.Attempt{}).
+			Optional(),
+		field.Time("create_time").
+			Default(time.Now).
+			Immutable(),
+		field.Int("replay_count").
+			Default(0),
+		// 最近一次重放时间, 重放后再次失败时清空
+		field.Time("replay_time").
+			Optional().
+			Nillable(),
+	}
+}
+
+// Indexes of the DeadLetter.
+func (DeadLetter) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("topic", "subscription", "create_time"),
+		index.Fields("create_time"),
+	}
+}
@@ -6,6 +6,10 @@ import (
 	"entgo.io/ent"
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
+
+	"msgcenter/platform/ent/audit"
+	"msgcenter/platform/ent/softdelete"
+	"msgcenter/utils/objectid"
 )
 
 // Device 定义设备表结构
@@ -13,11 +17,18 @@ type Device struct {
 	ent.Schema
 }
 
+// Mixin of the Device.
+func (Device) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		ObjectIDMixin{},
+		softdelete.Mixin{},
+		audit.Mixin{},
+	}
+}
+
 // Fields of the Device.
 func (Device) Fields() []ent.Field {
 	return []ent.Field{
-		field.String("id").
-			MaxLen(32),
 		field.String("client_device_id").
 			MaxLen(32).
 			Unique(),
@@ -25,10 +36,9 @@ func (Device) Fields() []ent.Field {
 			Default(time.Now),
 		field.Bool("actived").
 			Default(true),
-		field.Bool("delete_flag").
-			Default(false),
 		field.String("curr_user_id").
-			MaxLen(32).
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
 			Optional(),
 		field.Enum("device_type").
 			NamedValues(
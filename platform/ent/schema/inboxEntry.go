@@ -0,0 +1,51 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+
+	"msgcenter/utils/objectid"
+	"msgcenter/utils/snowflake"
+)
+
+// InboxEntry 定义设备收件箱表结构. 写扩散时每个接收设备一行, 设备按主键顺序拉取离线消息;
+// 超过扩散阈值的大群不写收件箱, 由设备按会话读取消息
+type InboxEntry struct {
+	ent.Schema
+}
+
+// Fields of the InboxEntry.
+func (InboxEntry) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			DefaultFunc(snowflake.Next).
+			Immutable(),
+		field.String("device_id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Immutable(),
+		field.Int64("message_id").
+			Immutable(),
+		field.String("conversation_id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Optional().
+			Immutable(),
+		field.Time("create_time").
+			Default(time.Now).
+			Immutable(),
+	}
+}
+
+// Indexes of the InboxEntry.
+func (InboxEntry) Indexes() []ent.Index {
+	return []ent.Index{
+		// 重复消费时跳过已写入的设备
+		index.Fields("message_id", "device_id").
+			Unique(),
+		index.Fields("device_id", "id"),
+	}
+}
@@ -0,0 +1,68 @@
+package schema
+
+import (
+	"encoding/json"
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+
+	"msgcenter/utils/objectid"
+	"msgcenter/utils/snowflake"
+)
+
+// Message 定义消息表结构, 主键为 snowflake ID, 按主键排序即按发送时间排序.
+// 消息创建后不可修改, 量大且由保留策略批量清理, 不记录审计日志
+type Message struct {
+	ent.Schema
+}
+
+// Fields of the Message.
+func (Message) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			DefaultFunc(snowflake.Next).
+			Immutable(),
+		// 系统消息没有发送者
+		field.String("sender_id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Optional().
+			Immutable(),
+		// 接收方: 会话、用户和设备可同时指定, 投递时合并去重
+		field.String("conversation_id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Optional().
+			Immutable(),
+		field.JSON("user_ids", []objectid.ID{}).
+			Optional().
+			Immutable(),
+		field.JSON("device_ids", []objectid.ID{}).
+			Optional().
+			Immutable(),
+		field.String("content_type").
+			MaxLen(128).
+			Immutable(),
+		field.JSON("payload", json.RawMessage{}).
+			Immutable(),
+		field.Time("create_time").
+			Default(time.Now).
+			Immutable(),
+		// 为空表示不过期; 过期后不再投递和返回, 由保留策略清理
+		field.Time("expire_time").
+			Optional().
+			Nillable().
+			Immutable(),
+	}
+}
+
+// Indexes of the Message.
+func (Message) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("sender_id", "create_time"),
+		index.Fields("conversation_id", "id"),
+		index.Fields("expire_time"),
+	}
+}
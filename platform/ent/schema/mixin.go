@@ -0,0 +1,30 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/mixin"
+
+	"msgcenter/utils/objectid"
+)
+
+// objectIDType ObjectId 在数据库中的列类型
+var objectIDType = map[string]string{
+	dialect.Postgres: "varchar(24)",
+}
+
+// ObjectIDMixin 使用 ObjectId 作为主键, 创建时自动生成
+type ObjectIDMixin struct {
+	mixin.Schema
+}
+
+func (ObjectIDMixin) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			DefaultFunc(objectid.New).
+			Immutable(),
+	}
+}
@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+
+	"msgcenter/utils/snowflake"
+)
+
+// OutboxEvent 定义事务发件箱表结构, 与业务数据在同一事务中写入, 由 outbox.Relay 按主键顺序发布到消息队列
+type OutboxEvent struct {
+	ent.Schema
+}
+
+// Fields of the OutboxEvent.
+func (OutboxEvent) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			DefaultFunc(snowflake.Next).
+			Immutable(),
+		field.String("topic").
+			MaxLen(255).
+			Immutable(),
+		field.String("key").
+			MaxLen(255).
+			Optional().
+			Immutable(),
+		field.Bytes("payload").
+			Immutable(),
+		field.JSON("headers", map[string]string{}).
+			Optional().
+			Immutable(),
+		// 产生事件的实体, 如 Message
+		field.String("aggregate").
+			MaxLen(64).
+			Immutable(),
+		field.String("aggregate_id").
+			MaxLen(64).
+			Immutable(),
+		field.Time("deliver_at").
+			Optional().
+			Nillable().
+			Immutable(),
+		field.Int("attempts").
+			Default(0),
+		field.Text("last_error").
+			Optional(),
+		field.Time("create_time").
+			Default(time.Now).
+			Immutable(),
+		field.Time("sent_time").
+			Optional().
+			Nillable(),
+		// 超过最大发布次数后标记, 不再发布; 清空该字段和 attempts 可手动重新发布
+		field.Time("failed_time").
+			Optional().
+			Nillable(),
+	}
+}
+
+// Indexes of the OutboxEvent.
+func (OutboxEvent) Indexes() []ent.Index {
+	return []ent.Index{
+		// 只索引待发布的事件, 发布或标记失败后自动移出索引
+		index.Fields("id").
+			StorageKey("outboxevent_pending").
+			Annotations(entsql.IndexWhere("sent_time IS NULL AND failed_time IS NULL")),
+		index.Fields("sent_time"),
+	}
+}
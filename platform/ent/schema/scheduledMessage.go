@@ -0,0 +1,107 @@
+package schema
+
+import (
+	"encoding/json"
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+
+	"msgcenter/platform/ent/audit"
+	"msgcenter/utils/objectid"
+)
+
+// ScheduledMessage 定义定时消息表结构, 是定时发送的唯一数据源, redis 中的有序集合只作为到期索引.
+// 到期后按普通消息或主题广播发送, 并记录生成的消息
+type ScheduledMessage struct {
+	ent.Schema
+}
+
+// Mixin of the ScheduledMessage.
+func (ScheduledMessage) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		ObjectIDMixin{},
+		audit.Mixin{},
+	}
+}
+
+// Fields of the ScheduledMessage.
+func (ScheduledMessage) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("sender_id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Optional().
+			Immutable(),
+		// 接收方与 Message 相同; 指定 topic 时改为主题广播, target 为定向表达式
+		field.String("conversation_id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Optional().
+			Immutable(),
+		field.JSON("user_ids", []objectid.ID{}).
+			Optional().
+			Immutable(),
+		field.JSON("device_ids", []objectid.ID{}).
+			Optional().
+			Immutable(),
+		field.String("topic").
+			MaxLen(64).
+			Optional().
+			Immutable(),
+		field.Text("target").
+			Optional().
+			Immutable(),
+		field.String("content_type").
+			MaxLen(128).
+			Immutable(),
+		field.JSON("payload", json.RawMessage{}).
+			Immutable(),
+		// 消息有效期秒数, 从实际发送时开始计算, 0 表示不过期
+		field.Int("ttl").
+			Default(0).
+			NonNegative().
+			Immutable(),
+		field.Time("deliver_time"),
+		field.Enum("status").
+			NamedValues(
+				"Pending", "Pending",
+				"Sent", "Sent",
+				"Canceled", "Canceled",
+				"Failed", "Failed",
+			).
+			Default("Pending"),
+		field.Int64("message_id").
+			Optional().
+			Nillable(),
+		field.String("broadcast_id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Optional(),
+		field.Text("error").
+			Optional(),
+		// 发送失败的次数, 达到上限后标记为失败
+		field.Int("attempts").
+			Default(0).
+			NonNegative(),
+		// 发送失败后的下次重试时间, 早于该时间不再尝试
+		field.Time("retry_time").
+			Optional().
+			Nillable(),
+		field.Time("create_time").
+			Default(time.Now).
+			Immutable(),
+		field.Time("sent_time").
+			Optional().
+			Nillable(),
+	}
+}
+
+// Indexes of the ScheduledMessage.
+func (ScheduledMessage) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("status", "deliver_time"),
+		index.Fields("sender_id", "create_time"),
+	}
+}
@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+
+	"msgcenter/platform/ent/audit"
+)
+
+// Topic 定义广播主题表结构, 用户和设备订阅主题后接收发布到主题的公告
+type Topic struct {
+	ent.Schema
+}
+
+// Mixin of the Topic.
+func (Topic) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		ObjectIDMixin{},
+		audit.Mixin{},
+	}
+}
+
+// Fields of the Topic.
+func (Topic) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("name").
+			MaxLen(64).
+			Unique().
+			Immutable(),
+		field.String("description").
+			MaxLen(255).
+			Optional(),
+		field.Time("create_time").
+			Default(time.Now).
+			Immutable(),
+	}
+}
+
+// Edges of the Topic.
+func (Topic) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("subscriptions", TopicSubscription.Type),
+	}
+}
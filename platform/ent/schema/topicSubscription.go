@@ -0,0 +1,70 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+
+	"msgcenter/platform/ent/audit"
+	"msgcenter/utils/objectid"
+)
+
+// TopicSubscription 定义主题订阅表结构, 订阅者为用户(其全部设备)或单个设备
+type TopicSubscription struct {
+	ent.Schema
+}
+
+// Mixin of the TopicSubscription.
+func (TopicSubscription) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		ObjectIDMixin{},
+		audit.Mixin{},
+	}
+}
+
+// Fields of the TopicSubscription.
+func (TopicSubscription) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("topic_id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Immutable(),
+		field.Enum("subscriber_type").
+			NamedValues(
+				"User", "User",
+				"Device", "Device",
+			).
+			Immutable(),
+		field.String("subscriber_id").
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType).
+			Immutable(),
+		field.Time("create_time").
+			Default(time.Now).
+			Immutable(),
+	}
+}
+
+// Edges of the TopicSubscription.
+func (TopicSubscription) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("topic", Topic.Type).
+			Ref("subscriptions").
+			Field("topic_id").
+			Required().
+			Unique().
+			Immutable(),
+	}
+}
+
+// Indexes of the TopicSubscription.
+func (TopicSubscription) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("topic_id", "subscriber_type", "subscriber_id").
+			Unique(),
+		index.Fields("subscriber_id"),
+	}
+}
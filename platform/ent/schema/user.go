@@ -5,6 +5,9 @@ import (
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
 	"entgo.io/ent/schema/index"
+
+	"msgcenter/platform/ent/audit"
+	"msgcenter/platform/ent/softdelete"
 )
 
 // User 定义用户表结构
@@ -12,17 +15,23 @@ type User struct {
 	ent.Schema
 }
 
+// Mixin of the User.
+func (User) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		ObjectIDMixin{},
+		softdelete.Mixin{},
+		audit.Mixin{Sensitive: []string{"password"}},
+	}
+}
+
 // Fields of the User.
 func (User) Fields() []ent.Field {
 	return []ent.Field{
-		field.String("id").
-			MaxLen(32),
 		field.String("name").
 			MaxLen(32),
 		field.String("password").
 			MaxLen(32).
 			Optional(),
-		field.Bool("delete_flag"),
 		field.Enum("gender").
 			NamedValues(
 				"Male", "M",
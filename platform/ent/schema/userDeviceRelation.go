@@ -4,6 +4,9 @@ import (
 	"entgo.io/ent"
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
+
+	"msgcenter/platform/ent/audit"
+	"msgcenter/utils/objectid"
 )
 
 // UserDeviceRelation 定义用户设备关联表结构
@@ -11,15 +14,23 @@ type UserDeviceRelation struct {
 	ent.Schema
 }
 
+// Mixin of the UserDeviceRelation.
+func (UserDeviceRelation) Mixin() []ent.Mixin {
+	return []ent.Mixin{
+		ObjectIDMixin{},
+		audit.Mixin{},
+	}
+}
+
 // Fields of the UserDeviceRelation.
 func (UserDeviceRelation) Fields() []ent.Field {
 	return []ent.Field{
-		field.String("id").
-			MaxLen(32),
 		field.String("user_id").
-			MaxLen(32),
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType),
 		field.String("device_id").
-			MaxLen(32),
+			GoType(objectid.ID{}).
+			SchemaType(objectIDType),
 		field.String("tag").
 			MaxLen(32).
 			Optional(),
@@ -0,0 +1,100 @@
+package softdelete
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/sql"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"entgo.io/ent/schema/mixin"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/hook"
+	"msgcenter/platform/ent/gen/intercept"
+)
+
+const (
+	FieldDeleteFlag = "delete_flag"
+	FieldDeleteTime = "delete_time"
+)
+
+type withDeletedKey struct{}
+
+// WithDeleted 返回不过滤软删除记录的 ctx, 在此 ctx 下删除操作为物理删除
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withDeletedKey{}, true)
+}
+
+func withDeleted(ctx context.Context) bool {
+	skip, _ := ctx.Value(withDeletedKey{}).(bool)
+	return skip
+}
+
+// Mixin 软删除: 查询默认隐藏 delete_flag 为 true 的记录, 删除改为更新 delete_flag 和 delete_time
+type Mixin struct {
+	mixin.Schema
+}
+
+func (Mixin) Fields() []ent.Field {
+	return []ent.Field{
+		field.Bool(FieldDeleteFlag).
+			Default(false),
+		field.Time(FieldDeleteTime).
+			Optional().
+			Nillable(),
+	}
+}
+
+// Indexes 供清理任务按删除时间查找过期记录
+func (Mixin) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields(FieldDeleteFlag, FieldDeleteTime),
+	}
+}
+
+func (m Mixin) Interceptors() []ent.Interceptor {
+	return []ent.Interceptor{
+		intercept.TraverseFunc(func(ctx context.Context, q intercept.Query) error {
+			if withDeleted(ctx) {
+				return nil
+			}
+			m.where(q)
+			return nil
+		}),
+	}
+}
+
+func (m Mixin) Hooks() []ent.Hook {
+	return []ent.Hook{
+		hook.On(func(next ent.Mutator) ent.Mutator {
+			return ent.MutateFunc(func(ctx context.Context, mut ent.Mutation) (ent.Value, error) {
+				if withDeleted(ctx) {
+					return next.Mutate(ctx, mut)
+				}
+				mx, ok := mut.(interface {
+					SetOp(ent.Op)
+					Client() *gen.Client
+					SetDeleteFlag(bool)
+					SetDeleteTime(time.Time)
+					WhereP(...func(*sql.Selector))
+				})
+				if !ok {
+					return nil, fmt.Errorf("软删除不支持的mutation类型 %T", mut)
+				}
+				// 已删除的记录不再更新删除时间, 保证清理任务按首次删除时间计算
+				m.where(mx)
+				mx.SetOp(ent.OpUpdate)
+				mx.SetDeleteFlag(true)
+				mx.SetDeleteTime(time.Now())
+				return mx.Client().Mutate(ctx, mut)
+			})
+		}, ent.OpDeleteOne|ent.OpDelete),
+	}
+}
+
+func (Mixin) where(w interface{ WhereP(...func(*sql.Selector)) }) {
+	w.WhereP(sql.FieldEQ(FieldDeleteFlag, false))
+}
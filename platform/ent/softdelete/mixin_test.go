@@ -0,0 +1,59 @@
+package softdelete_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"msgcenter/platform/ent/gen/user"
+	"msgcenter/platform/ent/softdelete"
+	"msgcenter/testkit"
+)
+
+func TestDeleteMarksAndHides(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	u, err := client.User.Create().SetName("alice").Save(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.User.DeleteOneID(u.ID).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 默认查询隐藏已删除记录, WithDeleted 下仍可查到
+	if n := client.User.Query().CountX(ctx); n != 0 {
+		t.Fatalf("默认查询数量 = %d, want 0", n)
+	}
+	deleted, err := client.User.Get(softdelete.WithDeleted(ctx), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deleted.DeleteFlag || deleted.DeleteTime == nil {
+		t.Fatalf("删除后 delete_flag = %v, delete_time = %v", deleted.DeleteFlag, deleted.DeleteTime)
+	}
+
+	// 再次删除不更新删除时间
+	first := *deleted.DeleteTime
+	time.Sleep(5 * time.Millisecond)
+	if _, err := client.User.Delete().Where(user.ID(u.ID)).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	again := client.User.GetX(softdelete.WithDeleted(ctx), u.ID)
+	if !again.DeleteTime.Equal(first) {
+		t.Fatalf("重复删除不应更新删除时间: %v -> %v", first, again.DeleteTime)
+	}
+}
+
+func TestDeleteWithDeletedIsPhysical(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	u := client.User.Create().SetName("bob").SaveX(ctx)
+
+	if err := client.User.DeleteOneID(u.ID).Exec(softdelete.WithDeleted(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	if n := client.User.Query().CountX(softdelete.WithDeleted(ctx)); n != 0 {
+		t.Fatalf("物理删除后记录数 = %d, want 0", n)
+	}
+}
@@ -0,0 +1,134 @@
+package purge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/audit"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/device"
+	"msgcenter/platform/ent/gen/user"
+	"msgcenter/platform/ent/gen/userdevicerelation"
+	"msgcenter/platform/ent/softdelete"
+	"msgcenter/platform/metrics"
+)
+
+// Purger 定期物理删除软删除超过保留期的记录
+type Purger struct {
+	client    func() *gen.Client
+	retention time.Duration
+	interval  time.Duration
+	logger    *zap.Logger
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// New client 每次清理时调用, 数据库配置热更新后使用新连接
+func New(client func() *gen.Client, retention, interval time.Duration, logger *zap.Logger) *Purger {
+	return &Purger{
+		client:    client,
+		retention: retention,
+		interval:  interval,
+		logger:    logger,
+	}
+}
+
+// Start 启动后台清理
+func (p *Purger) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := p.Purge(ctx); err != nil && ctx.Err() == nil {
+					p.logger.Warn("清理软删除记录失败", zap.Error(err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台清理并等待进行中的清理结束
+func (p *Purger) Stop(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Purge 在一个事务中物理删除保留期之前软删除的记录, 先删除引用它们的关联记录
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-p.retention)
+	// 删除时已记录审计日志, 清理不再逐条记录
+	ctx = audit.WithoutAudit(softdelete.WithDeleted(ctx))
+
+	tx, err := p.client().Tx(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	relations, err := tx.UserDeviceRelation.Delete().
+		Where(userdevicerelation.Or(
+			userdevicerelation.HasUserWith(user.DeleteFlag(true), user.DeleteTimeLT(cutoff)),
+			userdevicerelation.HasDeviceWith(device.DeleteFlag(true), device.DeleteTimeLT(cutoff)),
+		)).
+		Exec(ctx)
+	if err != nil {
+		return 0, rollback(tx, fmt.Errorf("删除用户设备关联失败: %w", err))
+	}
+	devices, err := tx.Device.Delete().
+		Where(device.DeleteFlag(true), device.DeleteTimeLT(cutoff)).
+		Exec(ctx)
+	if err != nil {
+		return 0, rollback(tx, fmt.Errorf("删除设备失败: %w", err))
+	}
+	users, err := tx.User.Delete().
+		Where(user.DeleteFlag(true), user.DeleteTimeLT(cutoff)).
+		Exec(ctx)
+	if err != nil {
+		return 0, rollback(tx, fmt.Errorf("删除用户失败: %w", err))
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	metrics.SoftDeletePurged.WithLabelValues(userdevicerelation.Table).Add(float64(relations))
+	metrics.SoftDeletePurged.WithLabelValues(device.Table).Add(float64(devices))
+	metrics.SoftDeletePurged.WithLabelValues(user.Table).Add(float64(users))
+
+	total := relations + devices + users
+	if total > 0 {
+		p.logger.Info("已清理软删除记录",
+			zap.Int("users", users),
+			zap.Int("devices", devices),
+			zap.Int("relations", relations),
+			zap.Time("cutoff", cutoff),
+		)
+	}
+	return total, nil
+}
+
+func rollback(tx *gen.Tx, err error) error {
+	if rerr := tx.Rollback(); rerr != nil {
+		return fmt.Errorf("%w: 回滚失败: %v", err, rerr)
+	}
+	return err
+}
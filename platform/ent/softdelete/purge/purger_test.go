@@ -0,0 +1,55 @@
+package purge_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/softdelete"
+	"msgcenter/platform/ent/softdelete/purge"
+	"msgcenter/testkit"
+)
+
+func TestPurgeRemovesExpiredRecords(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+
+	old := client.User.Create().SetName("old").SaveX(ctx)
+	recent := client.User.Create().SetName("recent").SaveX(ctx)
+	live := client.User.Create().SetName("live").SaveX(ctx)
+	d := client.Device.Create().SetClientDeviceID("device-old").SaveX(ctx)
+	client.UserDeviceRelation.Create().SetUserID(old.ID).SetDeviceID(d.ID).SaveX(ctx)
+
+	client.User.DeleteOneID(old.ID).ExecX(ctx)
+	client.User.DeleteOneID(recent.ID).ExecX(ctx)
+	// 将 old 的删除时间改到保留期之前
+	client.User.UpdateOneID(old.ID).
+		SetDeleteTime(time.Now().Add(-2 * time.Hour)).
+		ExecX(softdelete.WithDeleted(ctx))
+
+	p := purge.New(func() *gen.Client { return client }, time.Hour, time.Hour, zap.NewNop())
+	n, err := p.Purge(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// old 及其设备关联被物理删除, recent 仍在保留期内, live 未删除
+	if n != 2 {
+		t.Fatalf("清理数量 = %d, want 2", n)
+	}
+	all := softdelete.WithDeleted(ctx)
+	if client.User.Query().CountX(all) != 2 {
+		t.Fatal("应只剩 recent 和 live")
+	}
+	if _, err := client.User.Get(all, recent.ID); err != nil {
+		t.Fatalf("保留期内的记录不应清理: %v", err)
+	}
+	if _, err := client.User.Get(ctx, live.ID); err != nil {
+		t.Fatal(err)
+	}
+	if client.UserDeviceRelation.Query().CountX(all) != 0 {
+		t.Fatal("关联记录应被清理")
+	}
+}
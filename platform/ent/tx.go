@@ -0,0 +1,29 @@
+package ent
+
+import (
+	"context"
+	"fmt"
+
+	"msgcenter/platform/ent/gen"
+)
+
+// WithTx 在事务中执行 fn, fn 返回错误或 panic 时回滚
+func WithTx(ctx context.Context, client *gen.Client, fn func(tx *gen.Tx) error) error {
+	tx, err := client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			_ = tx.Rollback()
+			panic(v)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			return fmt.Errorf("%w: 回滚失败: %v", err, rerr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,63 @@
+package ent_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"msgcenter/platform/ent"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/testkit"
+)
+
+func createUser(ctx context.Context, tx *gen.Tx) error {
+	return tx.User.Create().SetName("u").Exec(ctx)
+}
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+
+	if err := ent.WithTx(ctx, client, func(tx *gen.Tx) error { return createUser(ctx, tx) }); err != nil {
+		t.Fatal(err)
+	}
+	if n := client.User.Query().CountX(ctx); n != 1 {
+		t.Fatalf("提交后用户数 = %d, want 1", n)
+	}
+
+	boom := errors.New("boom")
+	err := ent.WithTx(ctx, client, func(tx *gen.Tx) error {
+		if err := createUser(ctx, tx); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("err = %v, want boom", err)
+	}
+	if n := client.User.Query().CountX(ctx); n != 1 {
+		t.Fatalf("返回错误后应回滚, 用户数 = %d", n)
+	}
+}
+
+func TestWithTxRollsBackOnPanic(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+
+	func() {
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Fatalf("recover = %v, 应继续抛出原 panic", v)
+			}
+		}()
+		_ = ent.WithTx(ctx, client, func(tx *gen.Tx) error {
+			if err := createUser(ctx, tx); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+	if n := client.User.Query().CountX(ctx); n != 0 {
+		t.Fatalf("panic 后应回滚, 用户数 = %d", n)
+	}
+}
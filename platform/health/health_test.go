@@ -0,0 +1,65 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckAggregatesResults(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.Register("db", func(context.Context) error { return nil })
+	c.Register("redis", func(context.Context) error { return errors.New("connection refused") })
+
+	report := c.Check(context.Background())
+	if report.Ready() || report.Status != StatusDown {
+		t.Fatalf("任一依赖不可用时应未就绪, status = %s", report.Status)
+	}
+	if r := report.Checks["db"]; r.Status != StatusUp || r.Error != "" {
+		t.Fatalf("db = %+v", r)
+	}
+	if r := report.Checks["redis"]; r.Status != StatusDown || r.Error != "connection refused" {
+		t.Fatalf("redis = %+v", r)
+	}
+
+	c.Register("redis", func(context.Context) error { return nil })
+	if report := c.Check(context.Background()); !report.Ready() {
+		t.Fatalf("同名检查应被覆盖, status = %s", report.Status)
+	}
+}
+
+func TestCheckTimesOutSlowDependency(t *testing.T) {
+	c := NewChecker(20 * time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+	// 忽略 ctx 的检查也不能拖住就绪检查
+	c.Register("slow", func(context.Context) error {
+		<-release
+		return nil
+	})
+
+	start := time.Now()
+	report := c.Check(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("检查耗时 %v, 应在超时后返回", elapsed)
+	}
+	if r := report.Checks["slow"]; r.Status != StatusDown || r.Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("slow = %+v", r)
+	}
+}
+
+func TestDrainingOverridesStatus(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.Register("db", func(context.Context) error { return nil })
+	c.SetDraining(true)
+
+	report := c.Check(context.Background())
+	if report.Ready() || report.Status != StatusDraining {
+		t.Fatalf("排空期间 status = %s, want draining", report.Status)
+	}
+	c.SetDraining(false)
+	if !c.Check(context.Background()).Ready() {
+		t.Fatal("取消排空后应就绪")
+	}
+}
@@ -0,0 +1,94 @@
+package lifecycle
+
+import (
+	"context"
+	"time"
+)
+
+// Component 可由 Registry 统一启动、停止和检查的组件
+type Component interface {
+	Name() string
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+	Health(ctx context.Context) error
+}
+
+// Dependent 可选接口, 声明需要先于本组件启动的组件
+type Dependent interface {
+	DependsOn() []string
+}
+
+// Configurable 可选接口, 为组件单独指定启动超时与重试策略
+type Configurable interface {
+	StartOptions() StartOptions
+}
+
+// StartOptions 启动超时与重试策略, 重试间隔按指数退避增长
+type StartOptions struct {
+	Timeout    time.Duration // 单次启动超时
+	Retries    int           // 失败后的重试次数
+	Backoff    time.Duration // 首次重试间隔
+	MaxBackoff time.Duration // 重试间隔上限
+}
+
+func (o StartOptions) withDefaults() StartOptions {
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.Retries < 0 {
+		o.Retries = 0
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = time.Second
+	}
+	if o.MaxBackoff < o.Backoff {
+		o.MaxBackoff = o.Backoff
+	}
+	return o
+}
+
+// Func 通过函数字段快速定义组件, 未设置的函数视为空操作
+type Func struct {
+	ComponentName string
+	Deps          []string
+	Options       *StartOptions
+	OnStart       func(ctx context.Context) error
+	OnStop        func(ctx context.Context) error
+	OnHealth      func(ctx context.Context) error
+}
+
+func (f *Func) Name() string {
+	return f.ComponentName
+}
+
+func (f *Func) DependsOn() []string {
+	return f.Deps
+}
+
+func (f *Func) StartOptions() StartOptions {
+	if f.Options == nil {
+		return StartOptions{}
+	}
+	return *f.Options
+}
+
+func (f *Func) Start(ctx context.Context) error {
+	if f.OnStart == nil {
+		return nil
+	}
+	return f.OnStart(ctx)
+}
+
+func (f *Func) Stop(ctx context.Context) error {
+	if f.OnStop == nil {
+		return nil
+	}
+	return f.OnStop(ctx)
+}
+
+func (f *Func) Health(ctx context.Context) error {
+	if f.OnHealth == nil {
+		return nil
+	}
+	return f.OnHealth(ctx)
+}
@@ -0,0 +1,278 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Registry 按依赖顺序启动组件, 按相反顺序停止
+type Registry struct {
+	mu         sync.Mutex
+	components []Component
+	index      map[string]int
+	started    []Component
+	running    map[string]bool
+	opts       StartOptions
+	logger     *zap.Logger
+}
+
+func NewRegistry(opts StartOptions, logger *zap.Logger) *Registry {
+	return &Registry{
+		index:   make(map[string]int),
+		running: make(map[string]bool),
+		opts:    opts,
+		logger:  logger,
+	}
+}
+
+// Register 注册组件, 同名组件会替换已注册的组件, 便于测试时替换为假实现
+func (r *Registry) Register(c Component) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if i, ok := r.index[c.Name()]; ok {
+		r.components[i] = c
+		return
+	}
+	r.index[c.Name()] = len(r.components)
+	r.components = append(r.components, c)
+}
+
+// Get 按名称获取组件
+func (r *Registry) Get(name string) (Component, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, ok := r.index[name]
+	if !ok {
+		return nil, false
+	}
+	return r.components[i], true
+}
+
+// Start 按依赖顺序启动所有组件, 任一组件重试后仍失败时停止已启动的组件并返回错误
+func (r *Registry) Start(ctx context.Context) error {
+	r.mu.Lock()
+	ordered, err := r.sort()
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range ordered {
+		if err := r.start(ctx, c); err != nil {
+			stopCtx, cancel := context.WithTimeout(context.Background(), r.opts.withDefaults().Timeout)
+			_ = r.Stop(stopCtx)
+			cancel()
+			return fmt.Errorf("组件 %s 启动失败: %w", c.Name(), err)
+		}
+
+		r.mu.Lock()
+		r.started = append(r.started, c)
+		r.running[c.Name()] = true
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+func (r *Registry) start(ctx context.Context, c Component) error {
+	opts := r.opts
+	if cfg, ok := c.(Configurable); ok {
+		opts = mergeOptions(opts, cfg.StartOptions())
+	}
+	opts = opts.withDefaults()
+
+	backoff := opts.Backoff
+	var err error
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		start := time.Now()
+		if err = startWithTimeout(ctx, c, opts.Timeout); err == nil {
+			r.logger.Info("组件已启动",
+				zap.String("component", c.Name()),
+				zap.Duration("latency", time.Since(start)),
+			)
+			return nil
+		}
+
+		if attempt == opts.Retries || errors.Is(err, errAbandoned) {
+			break
+		}
+		r.logger.Warn("组件启动失败, 准备重试",
+			zap.String("component", c.Name()),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+		if backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+	return err
+}
+
+// errAbandoned 启动超时后组件仍未返回, 无法确定其状态, 不再重试
+var errAbandoned = errors.New("组件未响应取消")
+
+// startWithTimeout 启动超时后取消 ctx, 并再等待最多一个超时周期让本次 Start 返回,
+// 保证同一组件不会有两次 Start 并发执行. 超时后才启动成功的组件会被停止, 以便重试从干净状态开始
+func startWithTimeout(ctx context.Context, c Component, timeout time.Duration) error {
+	startCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Start(startCtx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-startCtx.Done():
+	}
+	timeoutErr := fmt.Errorf("启动超时: %w", startCtx.Err())
+
+	wait := time.NewTimer(timeout)
+	defer wait.Stop()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return timeoutErr
+		}
+		stopCtx, stopCancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer stopCancel()
+		if err := c.Stop(stopCtx); err != nil {
+			return fmt.Errorf("%w, 回滚失败: %w: %w", timeoutErr, errAbandoned, err)
+		}
+		return timeoutErr
+	case <-wait.C:
+		return fmt.Errorf("%w: %w", timeoutErr, errAbandoned)
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", timeoutErr, ctx.Err())
+	}
+}
+
+// Stop 按启动的相反顺序停止已启动的组件, 返回所有停止错误
+func (r *Registry) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	started := r.started
+	r.started = nil
+	r.running = make(map[string]bool)
+	r.mu.Unlock()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		if err := c.Stop(ctx); err != nil {
+			r.logger.Warn("组件停止失败",
+				zap.String("component", c.Name()),
+				zap.Error(err),
+			)
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name(), err))
+			continue
+		}
+		r.logger.Info("组件已停止", zap.String("component", c.Name()))
+	}
+	return errors.Join(errs...)
+}
+
+// Health 检查组件状态, 未启动的组件视为不健康
+func (r *Registry) Health(ctx context.Context, name string) error {
+	r.mu.Lock()
+	i, ok := r.index[name]
+	running := r.running[name]
+	var c Component
+	if ok {
+		c = r.components[i]
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("组件 %s 未注册", name)
+	}
+	if !running {
+		return fmt.Errorf("组件 %s 未启动", name)
+	}
+	return c.Health(ctx)
+}
+
+// Names 返回已注册组件的名称
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, len(r.components))
+	for i, c := range r.components {
+		names[i] = c.Name()
+	}
+	return names
+}
+
+// sort 拓扑排序, 无依赖关系的组件保持注册顺序
+func (r *Registry) sort() ([]Component, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(r.components))
+	ordered := make([]Component, 0, len(r.components))
+
+	var visit func(c Component, path []string) error
+	visit = func(c Component, path []string) error {
+		switch state[c.Name()] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("组件存在循环依赖: %v", append(path, c.Name()))
+		}
+		state[c.Name()] = visiting
+
+		if d, ok := c.(Dependent); ok {
+			for _, dep := range d.DependsOn() {
+				i, exists := r.index[dep]
+				if !exists {
+					return fmt.Errorf("组件 %s 依赖的 %s 未注册", c.Name(), dep)
+				}
+				if err := visit(r.components[i], append(path, c.Name())); err != nil {
+					return err
+				}
+			}
+		}
+
+		state[c.Name()] = visited
+		ordered = append(ordered, c)
+		return nil
+	}
+
+	for _, c := range r.components {
+		if err := visit(c, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+func mergeOptions(base, override StartOptions) StartOptions {
+	if override.Timeout > 0 {
+		base.Timeout = override.Timeout
+	}
+	if override.Retries > 0 {
+		base.Retries = override.Retries
+	}
+	if override.Backoff > 0 {
+		base.Backoff = override.Backoff
+	}
+	if override.MaxBackoff > 0 {
+		base.MaxBackoff = override.MaxBackoff
+	}
+	return base
+}
@@ -0,0 +1,157 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestRegistry(opts StartOptions) *Registry {
+	return NewRegistry(opts, zap.NewNop())
+}
+
+func TestStartOrderAndReverseStop(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	record := func(e string) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}
+	comp := func(name string, deps ...string) *Func {
+		return &Func{
+			ComponentName: name,
+			Deps:          deps,
+			OnStart:       func(context.Context) error { record("start " + name); return nil },
+			OnStop:        func(context.Context) error { record("stop " + name); return nil },
+		}
+	}
+
+	r := newTestRegistry(StartOptions{})
+	r.Register(comp("http", "db", "redis"))
+	r.Register(comp("db", "consul"))
+	r.Register(comp("redis", "consul"))
+	r.Register(comp("consul"))
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"start consul", "start db", "start redis", "start http",
+		"stop http", "stop redis", "stop db", "stop consul",
+	}
+	if !slices.Equal(events, want) {
+		t.Fatalf("events = %q, want %q", events, want)
+	}
+}
+
+func TestStartDetectsCycleAndMissingDeps(t *testing.T) {
+	r := newTestRegistry(StartOptions{})
+	r.Register(&Func{ComponentName: "a", Deps: []string{"b"}})
+	r.Register(&Func{ComponentName: "b", Deps: []string{"a"}})
+	if err := r.Start(context.Background()); err == nil {
+		t.Fatal("循环依赖应返回错误")
+	}
+
+	r = newTestRegistry(StartOptions{})
+	r.Register(&Func{ComponentName: "a", Deps: []string{"missing"}})
+	if err := r.Start(context.Background()); err == nil {
+		t.Fatal("依赖未注册应返回错误")
+	}
+}
+
+func TestStartFailureStopsStartedComponents(t *testing.T) {
+	var stopped atomic.Bool
+	r := newTestRegistry(StartOptions{Retries: 2, Backoff: time.Millisecond})
+	var attempts atomic.Int32
+	r.Register(&Func{
+		ComponentName: "db",
+		OnStop:        func(context.Context) error { stopped.Store(true); return nil },
+	})
+	r.Register(&Func{
+		ComponentName: "http",
+		Deps:          []string{"db"},
+		OnStart: func(context.Context) error {
+			attempts.Add(1)
+			return errors.New("listen failed")
+		},
+	})
+
+	if err := r.Start(context.Background()); err == nil {
+		t.Fatal("启动失败应返回错误")
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Fatalf("启动次数 = %d, want 3", n)
+	}
+	if !stopped.Load() {
+		t.Fatal("已启动的组件应被停止")
+	}
+}
+
+// 超时的 Start 返回前不会开始重试, 超时后才完成的启动会被停止
+func TestStartTimeoutWaitsForAbandonedStart(t *testing.T) {
+	var running, maxRunning, attempts, stops atomic.Int32
+	r := newTestRegistry(StartOptions{Timeout: 20 * time.Millisecond, Retries: 1, Backoff: time.Millisecond})
+	r.Register(&Func{
+		ComponentName: "slow",
+		OnStart: func(ctx context.Context) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			if n > maxRunning.Load() {
+				maxRunning.Store(n)
+			}
+			if attempts.Add(1) == 1 {
+				// 第一次启动忽略取消, 超时后才成功返回
+				time.Sleep(30 * time.Millisecond)
+				return nil
+			}
+			return nil
+		},
+		OnStop: func(context.Context) error { stops.Add(1); return nil },
+	})
+
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := maxRunning.Load(); n != 1 {
+		t.Fatalf("同时执行的 Start 数 = %d, want 1", n)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Fatalf("启动次数 = %d, want 2", n)
+	}
+	if n := stops.Load(); n != 1 {
+		t.Fatalf("超时后完成的启动应被停止一次, stops = %d", n)
+	}
+}
+
+func TestStartTimeoutGivesUpOnStuckComponent(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	var attempts atomic.Int32
+	r := newTestRegistry(StartOptions{Timeout: 10 * time.Millisecond, Retries: 3, Backoff: time.Millisecond})
+	r.Register(&Func{
+		ComponentName: "stuck",
+		OnStart: func(context.Context) error {
+			attempts.Add(1)
+			<-release
+			return nil
+		},
+	})
+
+	err := r.Start(context.Background())
+	if !errors.Is(err, errAbandoned) {
+		t.Fatalf("err = %v, want errAbandoned", err)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Fatalf("组件未响应取消时不应重试, 启动次数 = %d", n)
+	}
+}
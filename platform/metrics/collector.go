@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// dbStatsCollector 采集连接池状态, 通过函数获取当前连接, 配置热更新后自动采集新连接池
+type dbStatsCollector struct {
+	db func() *sql.DB
+
+	maxOpen      *prometheus.Desc
+	open         *prometheus.Desc
+	inUse        *prometheus.Desc
+	idle         *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+	maxIdle      *prometheus.Desc
+	maxLifetime  *prometheus.Desc
+}
+
+func NewDBStatsCollector(db func() *sql.DB) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db", name), help, nil, nil)
+	}
+	return &dbStatsCollector{
+		db:           db,
+		maxOpen:      desc("max_open_connections", "最大连接数"),
+		open:         desc("open_connections", "当前连接数"),
+		inUse:        desc("in_use_connections", "使用中的连接数"),
+		idle:         desc("idle_connections", "空闲连接数"),
+		waitCount:    desc("wait_count_total", "等待连接的总次数"),
+		waitDuration: desc("wait_duration_seconds_total", "等待连接的总耗时"),
+		maxIdle:      desc("max_idle_closed_total", "因超出最大空闲数关闭的连接数"),
+		maxLifetime:  desc("max_lifetime_closed_total", "因超出最大存活时间关闭的连接数"),
+	}
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdle
+	ch <- c.maxLifetime
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	db := c.db()
+	if db == nil {
+		return
+	}
+	stats := db.Stats()
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxIdle, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetime, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+}
+
+// redisPoolCollector 采集go-redis连接池状态
+type redisPoolCollector struct {
+	client func() *redis.Client
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+func NewRedisPoolCollector(client func() *redis.Client) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_pool", name), help, nil, nil)
+	}
+	return &redisPoolCollector{
+		client:     client,
+		hits:       desc("hits_total", "从连接池获取到空闲连接的次数"),
+		misses:     desc("misses_total", "连接池无空闲连接的次数"),
+		timeouts:   desc("timeouts_total", "等待连接超时的次数"),
+		totalConns: desc("total_connections", "连接总数"),
+		idleConns:  desc("idle_connections", "空闲连接数"),
+		staleConns: desc("stale_connections_total", "被移除的失效连接数"),
+	}
+}
+
+func (c *redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.staleConns
+}
+
+func (c *redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	client := c.client()
+	if client == nil {
+		return
+	}
+	stats := client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDBStatsCollectorFollowsCurrentDB(t *testing.T) {
+	var db *sql.DB
+	c := NewDBStatsCollector(func() *sql.DB { return db })
+
+	// 数据库未初始化时不输出指标
+	if n := testutil.CollectAndCount(c); n != 0 {
+		t.Fatalf("未初始化时指标数 = %d, want 0", n)
+	}
+
+	var err error
+	db, err = sql.Open("sqlite3", "file:metrics?mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	if n := testutil.CollectAndCount(c); n != 8 {
+		t.Fatalf("指标数 = %d, want 8", n)
+	}
+	if n := testutil.CollectAndCount(c, "msgcenter_db_max_open_connections"); n != 1 {
+		t.Fatalf("max_open_connections 指标数 = %d", n)
+	}
+}
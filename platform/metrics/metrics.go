@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "msgcenter"
+
+// HTTP
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP请求耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "正在处理的HTTP请求数",
+	})
+
+	HTTPIdempotency = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "idempotency_hits_total",
+		Help:      "Idempotency-Key 命中已有记录的次数, result 为 replayed/conflict/mismatch",
+	}, []string{"route", "result"})
+)
+
+// 多级缓存
+var (
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "多级缓存查询次数, tier 为 local/redis, result 为 hit/miss",
+	}, []string{"tier", "result"})
+
+	CacheFetches = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "fetches_total",
+		Help:      "缓存未命中后回源加载次数",
+	})
+)
+
+// Consul
+var (
+	ConsulWatchErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consul",
+		Name:      "watch_errors_total",
+		Help:      "Consul监控请求失败次数, kind 为 service/key",
+	}, []string{"kind", "name"})
+
+	ConsulWatchRetries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "consul",
+		Name:      "watch_retries",
+		Help:      "Consul监控当前连续重试次数",
+	}, []string{"kind", "name"})
+
+	ConsulLeader = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "consul",
+		Name:      "leader",
+		Help:      "本实例是否为选举的leader, 1 为是",
+	}, []string{"election"})
+)
+
+// WebSocket
+var (
+	WSConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "connections",
+		Help:      "当前WebSocket连接数",
+	})
+)
+
+// 软删除
+var (
+	SoftDeletePurged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "soft_delete",
+		Name:      "purged_total",
+		Help:      "超过保留期被物理删除的记录数",
+	}, []string{"table"})
+)
+
+// 消息队列
+var (
+	MQRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mq",
+		Name:      "retries_total",
+		Help:      "消息处理失败后重新发布到重试主题的次数",
+	}, []string{"topic", "subscription"})
+
+	MQDeadLetters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mq",
+		Name:      "dead_letters_total",
+		Help:      "进入死信的消息数, permanent 表示是否为永久失败",
+	}, []string{"topic", "subscription", "permanent"})
+)
+
+// 事务发件箱
+var (
+	OutboxPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "pending",
+		Help:      "待发布的事件数",
+	})
+
+	OutboxLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "lag_seconds",
+		Help:      "最早一条待发布事件已等待的时间",
+	})
+
+	OutboxPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "published_total",
+		Help:      "已发布的事件数",
+	}, []string{"topic"})
+
+	OutboxPublishErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "publish_errors_total",
+		Help:      "事件发布失败次数",
+	}, []string{"topic"})
+
+	OutboxFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "failed_total",
+		Help:      "超过最大发布次数被标记失败的事件数",
+	}, []string{"topic"})
+
+	OutboxPublishDelay = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "publish_delay_seconds",
+		Help:      "事件从写入到发布的耗时",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
+
+// 消息投递
+var (
+	DeliveryInboxWritten = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "delivery",
+		Name:      "inbox_written_total",
+		Help:      "写入设备收件箱的条数, kind 为 direct/conversation",
+	}, []string{"kind"})
+
+	DeliveryReadFanout = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "delivery",
+		Name:      "read_fanout_total",
+		Help:      "成员数超过阈值、不写收件箱的会话消息数",
+	})
+
+	DeliveryPushed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "delivery",
+		Name:      "pushed_total",
+		Help:      "推送到本实例在线设备的消息数, result 为 sent/dropped(发送队列已满)",
+	}, []string{"result"})
+
+	Signals = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "delivery",
+		Name:      "signals_total",
+		Help:      "临时信号数, result 为 published/throttled/dropped(群成员过多)/expired(转发前已过期)",
+	}, []string{"result"})
+
+	ScheduledSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "delivery",
+		Name:      "scheduled_total",
+		Help:      "到期处理的定时消息数, result 为 Sent/Failed",
+	}, []string{"result"})
+
+	ScheduledDelay = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "delivery",
+		Name:      "scheduled_delay_seconds",
+		Help:      "定时消息实际发送时间与计划时间的差",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	DeliveryBroadcastDevices = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "delivery",
+		Name:      "broadcast_devices_total",
+		Help:      "主题广播扩散到的设备数",
+	})
+
+	DeliveryBroadcasts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "delivery",
+		Name:      "broadcasts_total",
+		Help:      "处理完成的主题广播数, status 为 Done/Failed",
+	}, []string{"status"})
+
+	DeliveryExpired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "delivery",
+		Name:      "expired_total",
+		Help:      "投递前已过期而跳过的消息数, stage 为 inbox/push/broadcast",
+	}, []string{"stage"})
+)
+
+// 消息保留
+var (
+	RetentionMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "retention",
+		Name:      "messages_total",
+		Help:      "按保留策略清理的消息数, reason 为 expired/retention, action 为 delete/archive",
+	}, []string{"reason", "action"})
+
+	RetentionErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "retention",
+		Name:      "errors_total",
+		Help:      "消息清理失败次数",
+	})
+)
@@ -0,0 +1,366 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	atlasmigrate "ariga.io/atlas/sql/migrate"
+	"entgo.io/ent/dialect"
+	"go.uber.org/zap"
+)
+
+// Dir 迁移文件目录(相对仓库根目录), 供 diff/hash 命令写入
+const Dir = "platform/migrate/migrations"
+
+// lockKey postgres advisory lock 的键, 保证同一时间只有一个实例执行迁移
+const lockKey = 7_311_027_036
+
+//go:embed migrations/*.sql migrations/atlas.sum
+var files embed.FS
+
+// fileName golang-migrate 格式的文件名: 000001_init.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的升级和回滚脚本
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status 迁移版本的执行状态
+type Status struct {
+	Version   uint64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrations 校验并返回内置的迁移脚本
+func Migrations() ([]Migration, error) {
+	sub, err := fs.Sub(files, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	if err := Verify(sub); err != nil {
+		return nil, err
+	}
+	return Load(sub)
+}
+
+// Verify 校验迁移文件与 atlas.sum 一致. 已发布的迁移被修改, 或修改后未执行 hash 时返回错误,
+// 避免不同实例执行内容不同的同一版本
+func Verify(fsys fs.FS) error {
+	dir := &atlasmigrate.MemDir{}
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return err
+	}
+	names = append(names, atlasmigrate.HashFileName)
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("缺少 %s, 请执行 msgcenter migrate hash", name)
+		} else if err != nil {
+			return err
+		}
+		if err := dir.WriteFile(name, data); err != nil {
+			return err
+		}
+	}
+	if err := atlasmigrate.Validate(dir); err != nil {
+		return fmt.Errorf("迁移文件校验失败, 已发布的迁移不能修改, 新增迁移后请执行 msgcenter migrate hash: %w", err)
+	}
+	return nil
+}
+
+// Load 读取目录中的迁移脚本, 按版本号升序返回
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的迁移版本 %s: %w", entry.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("迁移版本 %d 存在不同名称的文件: %s, %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("迁移版本 %d 缺少升级脚本", mig.Version)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Runner 执行迁移, 已执行的版本记录在 schema_migrations 表中, 每个版本在独立事务中执行
+type Runner struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+	logger     *zap.Logger
+}
+
+func NewRunner(db *sql.DB, dialectName string, migrations []Migration, logger *zap.Logger) *Runner {
+	return &Runner{
+		db:         db,
+		dialect:    dialectName,
+		migrations: migrations,
+		logger:     logger,
+	}
+}
+
+// Up 执行未执行的迁移, steps 为 0 时全部执行, 返回本次执行的迁移
+func (r *Runner) Up(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range r.migrations {
+			if steps > 0 && len(done) >= steps {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			err := r.exec(ctx, conn, mig.Up,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+				int64(mig.Version), mig.Name, time.Now(),
+			)
+			if err != nil {
+				return fmt.Errorf("执行迁移 %d_%s 失败: %w", mig.Version, mig.Name, err)
+			}
+			r.logger.Info("迁移已执行", zap.Uint64("version", mig.Version), zap.String("name", mig.Name))
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 按版本倒序回滚已执行的迁移, steps 为 0 时回滚一个版本
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	var done []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(r.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := r.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("迁移 %d_%s 没有回滚脚本", mig.Version, mig.Name)
+			}
+			err := r.exec(ctx, conn, mig.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`,
+				int64(mig.Version),
+			)
+			if err != nil {
+				return fmt.Errorf("回滚迁移 %d_%s 失败: %w", mig.Version, mig.Name, err)
+			}
+			r.logger.Info("迁移已回滚", zap.Uint64("version", mig.Version), zap.String("name", mig.Name))
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Force 将 version 及之前的版本标记为已执行而不执行脚本, 用于接管已有的数据库
+func (r *Runner) Force(ctx context.Context, version uint64) error {
+	return r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range r.migrations {
+			if mig.Version > version {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			_, err := conn.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+				int64(mig.Version), mig.Name, time.Now(),
+			)
+			if err != nil {
+				return err
+			}
+			r.logger.Info("迁移已标记为执行", zap.Uint64("version", mig.Version), zap.String("name", mig.Name))
+		}
+		return nil
+	})
+}
+
+// Status 返回所有迁移的执行状态, 数据库中存在但本地没有的版本也会列出
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := r.withConn(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range r.migrations {
+			st := Status{Version: mig.Version, Name: mig.Name}
+			if s, ok := applied[mig.Version]; ok {
+				st.Applied = true
+				st.AppliedAt = s.AppliedAt
+				delete(applied, mig.Version)
+			}
+			statuses = append(statuses, st)
+		}
+		for _, s := range applied {
+			statuses = append(statuses, s)
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+// Pending 返回未执行的迁移数量
+func (r *Runner) Pending(ctx context.Context) (int, error) {
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range statuses {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// exec 在事务中执行迁移脚本并更新版本记录
+func (r *Runner) exec(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Runner) applied(ctx context.Context, conn *sql.Conn) (map[uint64]Status, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[uint64]Status)
+	for rows.Next() {
+		var (
+			version int64
+			s       Status
+		)
+		if err := rows.Scan(&version, &s.Name, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+		s.Version = uint64(version)
+		s.Applied = true
+		applied[s.Version] = s
+	}
+	return applied, rows.Err()
+}
+
+// withConn 在单个连接上确保版本表存在后执行 fn
+func (r *Runner) withConn(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	timeType := "TIMESTAMP"
+	if r.dialect == dialect.Postgres {
+		timeType = "TIMESTAMP WITH TIME ZONE"
+	}
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    BIGINT PRIMARY KEY,
+	name       VARCHAR(255) NOT NULL,
+	applied_at `+timeType+` NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("创建版本表失败: %w", err)
+	}
+	return fn(conn)
+}
+
+// withLock 持有迁移锁执行 fn, postgres 使用会话级 advisory lock, 锁随连接释放; 其他数据库不加锁
+func (r *Runner) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	return r.withConn(ctx, func(conn *sql.Conn) error {
+		if r.dialect != dialect.Postgres {
+			return fn(conn)
+		}
+
+		r.logger.Debug("等待迁移锁")
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+			return fmt.Errorf("获取迁移锁失败: %w", err)
+		}
+		defer func() {
+			// ctx 可能已取消, 解锁使用独立的 ctx
+			unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := conn.ExecContext(unlockCtx, `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+				r.logger.Warn("释放迁移锁失败", zap.Error(err))
+			}
+		}()
+		return fn(conn)
+	})
+}
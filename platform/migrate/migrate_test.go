@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// embedded 复制内置的迁移文件, 便于修改后校验
+func embedded(t *testing.T) fstest.MapFS {
+	t.Helper()
+	sub, err := fs.Sub(files, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := fstest.MapFS{}
+	err = fs.WalkDir(sub, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(sub, path)
+		m[path] = &fstest.MapFile{Data: data}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMigrationsMatchSum(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, mig := range migrations {
+		if mig.Version != uint64(i+1) {
+			t.Fatalf("迁移版本应连续, 第 %d 个为 %d", i, mig.Version)
+		}
+		if mig.Down == "" {
+			t.Errorf("迁移 %d_%s 缺少回滚脚本", mig.Version, mig.Name)
+		}
+	}
+}
+
+func TestVerifyRejectsTamperedMigrations(t *testing.T) {
+	edited := embedded(t)
+	edited["000001_init.up.sql"].Data = append(edited["000001_init.up.sql"].Data, "\n-- edited"...)
+
+	added := embedded(t)
+	added["000099_unhashed.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+
+	missing := embedded(t)
+	delete(missing, "atlas.sum")
+
+	tests := []struct {
+		name string
+		fsys fs.FS
+		want string
+	}{
+		{"修改已发布的迁移", edited, "checksum mismatch"},
+		{"新增迁移未重新计算", added, "checksum mismatch"},
+		{"缺少 atlas.sum", missing, "atlas.sum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Verify err = %v, want 包含 %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRequiresUpScript(t *testing.T) {
+	fsys := fstest.MapFS{"000001_init.down.sql": &fstest.MapFile{Data: []byte("DROP TABLE t;")}}
+	if _, err := Load(fsys); err == nil {
+		t.Fatal("缺少升级脚本应返回错误")
+	}
+}
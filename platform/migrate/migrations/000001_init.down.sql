@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS user_device_relations;
+DROP TABLE IF EXISTS devices;
+DROP TABLE IF EXISTS users;
+DROP TYPE IF EXISTS device_type;
+DROP TYPE IF EXISTS gender;
@@ -0,0 +1,64 @@
+-- 初始表结构, 与原 sql/0001_user.sql 一致. 已按该脚本建库的环境执行 `migrate force 1` 标记后再升级
+CREATE TYPE gender AS ENUM ('M', 'F', 'O');
+
+CREATE TABLE users
+(
+    id           varchar(32)       NOT NULL
+        CONSTRAINT users_pk
+            PRIMARY KEY,
+    name         varchar(32)       NOT NULL,
+    password     varchar(32),
+    delete_flag  boolean           NOT NULL,
+    gender       gender            NOT NULL,
+    phone_number varchar(32),
+    age          integer DEFAULT 0 NOT NULL,
+    email        varchar(64)
+);
+
+CREATE INDEX users_name_index
+    ON users (name);
+
+CREATE INDEX users_email_index
+    ON users (email);
+
+CREATE INDEX users_phone_number_index
+    ON users (phone_number);
+
+CREATE TYPE device_type AS ENUM ('Mobile', 'Desktop', 'Other');
+
+CREATE TABLE devices
+(
+    id               varchar(32)                              NOT NULL
+        CONSTRAINT devices_pk_2
+            PRIMARY KEY,
+    client_device_id varchar(32)                              NOT NULL
+        CONSTRAINT devices_pk
+            UNIQUE,
+    last_active_time timestamp   DEFAULT CURRENT_TIMESTAMP    NOT NULL,
+    actived          boolean     DEFAULT true                 NOT NULL,
+    delete_flag      boolean     DEFAULT false                NOT NULL,
+    curr_user_id     varchar(32)                              NOT NULL
+        CONSTRAINT devices_users_id_fk
+            REFERENCES users
+            ON DELETE SET NULL
+            DEFERRABLE,
+    device_type      device_type DEFAULT 'Other'::device_type NOT NULL
+);
+
+CREATE TABLE user_device_relations
+(
+    id        varchar(32) NOT NULL
+        CONSTRAINT user_device_relations_pk
+            PRIMARY KEY,
+    user_id   varchar(32) NOT NULL
+        CONSTRAINT user_device_relations_users_id_fk
+            REFERENCES users
+            ON DELETE CASCADE
+            DEFERRABLE,
+    device_id varchar(32) NOT NULL
+        CONSTRAINT user_device_relations_devices_id_fk
+            REFERENCES devices
+            ON DELETE RESTRICT
+            DEFERRABLE,
+    tag       varchar(32)
+);
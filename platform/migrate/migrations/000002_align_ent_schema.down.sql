@@ -0,0 +1,21 @@
+-- 回滚前需确保 devices.curr_user_id 没有空值
+ALTER TABLE "user_device_relations" DROP CONSTRAINT "user_device_relations_devices_user_device_relations";
+ALTER TABLE "user_device_relations" DROP CONSTRAINT "user_device_relations_users_user_device_relations";
+ALTER TABLE "devices" DROP CONSTRAINT "devices_users_devices";
+CREATE TYPE gender AS ENUM ('M', 'F', 'O');
+CREATE TYPE device_type AS ENUM ('Mobile', 'Desktop', 'Other');
+ALTER TABLE "user_device_relations" ALTER COLUMN "id" TYPE varchar(32), ALTER COLUMN "user_id" TYPE varchar(32), ALTER COLUMN "device_id" TYPE varchar(32), ALTER COLUMN "tag" TYPE varchar(32);
+ALTER TABLE "user_device_relations" RENAME CONSTRAINT "user_device_relations_pkey" TO "user_device_relations_pk";
+ALTER TABLE "devices" ALTER COLUMN "device_type" DROP DEFAULT;
+ALTER TABLE "devices" ALTER COLUMN "id" TYPE varchar(32), ALTER COLUMN "client_device_id" TYPE varchar(32), ALTER COLUMN "last_active_time" TYPE timestamp, ALTER COLUMN "last_active_time" SET DEFAULT CURRENT_TIMESTAMP, ALTER COLUMN "curr_user_id" TYPE varchar(32), ALTER COLUMN "curr_user_id" SET NOT NULL, ALTER COLUMN "device_type" TYPE device_type USING "device_type"::device_type, ALTER COLUMN "device_type" SET DEFAULT 'Other'::device_type;
+ALTER TABLE "devices" RENAME CONSTRAINT "devices_client_device_id_key" TO "devices_pk";
+ALTER TABLE "devices" RENAME CONSTRAINT "devices_pkey" TO "devices_pk_2";
+ALTER INDEX "user_phone_number" RENAME TO "users_phone_number_index";
+ALTER INDEX "user_email" RENAME TO "users_email_index";
+ALTER INDEX "user_name" RENAME TO "users_name_index";
+ALTER TABLE "users" ALTER COLUMN "gender" DROP DEFAULT;
+ALTER TABLE "users" ALTER COLUMN "id" TYPE varchar(32), ALTER COLUMN "name" TYPE varchar(32), ALTER COLUMN "password" TYPE varchar(32), ALTER COLUMN "gender" TYPE gender USING "gender"::gender, ALTER COLUMN "phone_number" TYPE varchar(32), ALTER COLUMN "age" TYPE integer, ALTER COLUMN "email" TYPE varchar(64);
+ALTER TABLE "users" RENAME CONSTRAINT "users_pkey" TO "users_pk";
+ALTER TABLE "devices" ADD CONSTRAINT "devices_users_id_fk" FOREIGN KEY ("curr_user_id") REFERENCES "users" ("id") ON DELETE SET NULL DEFERRABLE;
+ALTER TABLE "user_device_relations" ADD CONSTRAINT "user_device_relations_users_id_fk" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE DEFERRABLE;
+ALTER TABLE "user_device_relations" ADD CONSTRAINT "user_device_relations_devices_id_fk" FOREIGN KEY ("device_id") REFERENCES "devices" ("id") ON DELETE RESTRICT DEFERRABLE;
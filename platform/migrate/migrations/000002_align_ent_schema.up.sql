@@ -0,0 +1,26 @@
+-- 将初始表结构与 ent schema 对齐, 之后的迁移均由 `migrate diff` 根据 ent schema 生成
+-- Drop foreign keys of "devices" and "user_device_relations" tables
+ALTER TABLE "devices" DROP CONSTRAINT "devices_users_id_fk";
+ALTER TABLE "user_device_relations" DROP CONSTRAINT "user_device_relations_users_id_fk";
+ALTER TABLE "user_device_relations" DROP CONSTRAINT "user_device_relations_devices_id_fk";
+-- Modify "users" table
+ALTER TABLE "users" RENAME CONSTRAINT "users_pk" TO "users_pkey";
+ALTER TABLE "users" ALTER COLUMN "id" TYPE character varying, ALTER COLUMN "name" TYPE character varying, ALTER COLUMN "password" TYPE character varying, ALTER COLUMN "gender" TYPE character varying USING "gender"::text, ALTER COLUMN "gender" SET DEFAULT 'O', ALTER COLUMN "phone_number" TYPE character varying, ALTER COLUMN "age" TYPE bigint, ALTER COLUMN "email" TYPE character varying;
+ALTER INDEX "users_name_index" RENAME TO "user_name";
+ALTER INDEX "users_email_index" RENAME TO "user_email";
+ALTER INDEX "users_phone_number_index" RENAME TO "user_phone_number";
+-- Modify "devices" table, curr_user_id is Optional in ent
+ALTER TABLE "devices" RENAME CONSTRAINT "devices_pk_2" TO "devices_pkey";
+ALTER TABLE "devices" RENAME CONSTRAINT "devices_pk" TO "devices_client_device_id_key";
+ALTER TABLE "devices" ALTER COLUMN "device_type" DROP DEFAULT;
+ALTER TABLE "devices" ALTER COLUMN "id" TYPE character varying, ALTER COLUMN "client_device_id" TYPE character varying, ALTER COLUMN "last_active_time" TYPE timestamptz, ALTER COLUMN "last_active_time" DROP DEFAULT, ALTER COLUMN "curr_user_id" TYPE character varying, ALTER COLUMN "curr_user_id" DROP NOT NULL, ALTER COLUMN "device_type" TYPE character varying USING "device_type"::text, ALTER COLUMN "device_type" SET DEFAULT 'Other';
+-- Modify "user_device_relations" table
+ALTER TABLE "user_device_relations" RENAME CONSTRAINT "user_device_relations_pk" TO "user_device_relations_pkey";
+ALTER TABLE "user_device_relations" ALTER COLUMN "id" TYPE character varying, ALTER COLUMN "user_id" TYPE character varying, ALTER COLUMN "device_id" TYPE character varying, ALTER COLUMN "tag" TYPE character varying;
+-- Drop enum types replaced by varchar
+DROP TYPE "gender";
+DROP TYPE "device_type";
+-- Create foreign keys with ent naming
+ALTER TABLE "devices" ADD CONSTRAINT "devices_users_devices" FOREIGN KEY ("curr_user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE SET NULL;
+ALTER TABLE "user_device_relations" ADD CONSTRAINT "user_device_relations_users_user_device_relations" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION;
+ALTER TABLE "user_device_relations" ADD CONSTRAINT "user_device_relations_devices_user_device_relations" FOREIGN KEY ("device_id") REFERENCES "devices" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION;
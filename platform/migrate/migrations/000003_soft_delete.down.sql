@@ -0,0 +1,8 @@
+-- reverse: create index "device_delete_flag_delete_time" to table: "devices"
+DROP INDEX "device_delete_flag_delete_time";
+-- reverse: modify "devices" table
+ALTER TABLE "devices" DROP COLUMN "delete_time";
+-- reverse: create index "user_delete_flag_delete_time" to table: "users"
+DROP INDEX "user_delete_flag_delete_time";
+-- reverse: modify "users" table
+ALTER TABLE "users" DROP COLUMN "delete_time", ALTER COLUMN "delete_flag" DROP DEFAULT;
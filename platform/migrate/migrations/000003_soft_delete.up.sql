@@ -0,0 +1,8 @@
+-- Modify "users" table
+ALTER TABLE "users" ALTER COLUMN "delete_flag" SET DEFAULT false, ADD COLUMN "delete_time" timestamptz NULL;
+-- Create index "user_delete_flag_delete_time" to table: "users"
+CREATE INDEX "user_delete_flag_delete_time" ON "users" ("delete_flag", "delete_time");
+-- Modify "devices" table
+ALTER TABLE "devices" ADD COLUMN "delete_time" timestamptz NULL;
+-- Create index "device_delete_flag_delete_time" to table: "devices"
+CREATE INDEX "device_delete_flag_delete_time" ON "devices" ("delete_flag", "delete_time");
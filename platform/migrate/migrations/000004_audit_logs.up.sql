@@ -0,0 +1,8 @@
+-- Create "audit_logs" table
+CREATE TABLE "audit_logs" ("id" character varying NOT NULL, "actor" character varying NOT NULL, "entity" character varying NOT NULL, "entity_id" character varying NOT NULL, "op" character varying NOT NULL, "before" jsonb NULL, "after" jsonb NULL, "request_id" character varying NULL, "create_time" timestamptz NOT NULL, PRIMARY KEY ("id"));
+-- Create index "auditlog_entity_entity_id_create_time" to table: "audit_logs"
+CREATE INDEX "auditlog_entity_entity_id_create_time" ON "audit_logs" ("entity", "entity_id", "create_time");
+-- Create index "auditlog_actor_create_time" to table: "audit_logs"
+CREATE INDEX "auditlog_actor_create_time" ON "audit_logs" ("actor", "create_time");
+-- Create index "auditlog_create_time" to table: "audit_logs"
+CREATE INDEX "auditlog_create_time" ON "audit_logs" ("create_time");
@@ -0,0 +1,8 @@
+-- reverse: modify "audit_logs" table
+ALTER TABLE "audit_logs" ALTER COLUMN "id" TYPE character varying;
+-- reverse: modify "user_device_relations" table
+ALTER TABLE "user_device_relations" ALTER COLUMN "id" TYPE character varying, ALTER COLUMN "user_id" TYPE character varying, ALTER COLUMN "device_id" TYPE character varying;
+-- reverse: modify "devices" table
+ALTER TABLE "devices" ALTER COLUMN "id" TYPE character varying, ALTER COLUMN "curr_user_id" TYPE character varying;
+-- reverse: modify "users" table
+ALTER TABLE "users" ALTER COLUMN "id" TYPE character varying;
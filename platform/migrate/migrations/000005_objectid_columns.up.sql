@@ -0,0 +1,8 @@
+-- Modify "users" table
+ALTER TABLE "users" ALTER COLUMN "id" TYPE character varying(24);
+-- Modify "devices" table
+ALTER TABLE "devices" ALTER COLUMN "id" TYPE character varying(24), ALTER COLUMN "curr_user_id" TYPE character varying(24);
+-- Modify "user_device_relations" table
+ALTER TABLE "user_device_relations" ALTER COLUMN "id" TYPE character varying(24), ALTER COLUMN "user_id" TYPE character varying(24), ALTER COLUMN "device_id" TYPE character varying(24);
+-- Modify "audit_logs" table
+ALTER TABLE "audit_logs" ALTER COLUMN "id" TYPE character varying(24);
@@ -0,0 +1,10 @@
+-- reverse: create index "outboxevent_sent_time" to table: "outbox_events"
+DROP INDEX "outboxevent_sent_time";
+-- reverse: create index "outboxevent_pending" to table: "outbox_events"
+DROP INDEX "outboxevent_pending";
+-- reverse: create "outbox_events" table
+DROP TABLE "outbox_events";
+-- reverse: create index "message_sender_id_create_time" to table: "messages"
+DROP INDEX "message_sender_id_create_time";
+-- reverse: create "messages" table
+DROP TABLE "messages";
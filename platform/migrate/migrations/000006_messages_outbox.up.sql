@@ -0,0 +1,10 @@
+-- Create "messages" table
+CREATE TABLE "messages" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "sender_id" character varying(24) NULL, "content_type" character varying NOT NULL, "payload" jsonb NOT NULL, "create_time" timestamptz NOT NULL, PRIMARY KEY ("id"));
+-- Create index "message_sender_id_create_time" to table: "messages"
+CREATE INDEX "message_sender_id_create_time" ON "messages" ("sender_id", "create_time");
+-- Create "outbox_events" table
+CREATE TABLE "outbox_events" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "topic" character varying NOT NULL, "key" character varying NULL, "payload" bytea NOT NULL, "headers" jsonb NULL, "aggregate" character varying NOT NULL, "aggregate_id" character varying NOT NULL, "deliver_at" timestamptz NULL, "attempts" bigint NOT NULL DEFAULT 0, "last_error" text NULL, "create_time" timestamptz NOT NULL, "sent_time" timestamptz NULL, PRIMARY KEY ("id"));
+-- Create index "outboxevent_pending" to table: "outbox_events"
+CREATE INDEX "outboxevent_pending" ON "outbox_events" ("id") WHERE (sent_time IS NULL);
+-- Create index "outboxevent_sent_time" to table: "outbox_events"
+CREATE INDEX "outboxevent_sent_time" ON "outbox_events" ("sent_time");
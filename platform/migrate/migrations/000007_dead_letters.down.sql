@@ -0,0 +1,6 @@
+-- reverse: create index "deadletter_topic_subscription_create_time" to table: "dead_letters"
+DROP INDEX "deadletter_topic_subscription_create_time";
+-- reverse: create index "deadletter_create_time" to table: "dead_letters"
+DROP INDEX "deadletter_create_time";
+-- reverse: create "dead_letters" table
+DROP TABLE "dead_letters";
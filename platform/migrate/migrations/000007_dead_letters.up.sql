@@ -0,0 +1,6 @@
+-- Create "dead_letters" table
+CREATE TABLE "dead_letters" ("id" character varying(24) NOT NULL, "topic" character varying NOT NULL, "subscription" character varying NOT NULL, "message_id" character varying NOT NULL, "key" character varying NULL, "payload" bytea NOT NULL, "properties" jsonb NULL, "reason" text NOT NULL, "permanent" boolean NOT NULL DEFAULT false, "attempts" bigint NOT NULL, "history" jsonb NULL, "create_time" timestamptz NOT NULL, "replay_count" bigint NOT NULL DEFAULT 0, "replay_time" timestamptz NULL, PRIMARY KEY ("id"));
+-- Create index "deadletter_create_time" to table: "dead_letters"
+CREATE INDEX "deadletter_create_time" ON "dead_letters" ("create_time");
+-- Create index "deadletter_topic_subscription_create_time" to table: "dead_letters"
+CREATE INDEX "deadletter_topic_subscription_create_time" ON "dead_letters" ("topic", "subscription", "create_time");
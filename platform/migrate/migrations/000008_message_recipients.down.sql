@@ -0,0 +1,4 @@
+-- reverse: create index "message_conversation_id_id" to table: "messages"
+DROP INDEX "message_conversation_id_id";
+-- reverse: modify "messages" table
+ALTER TABLE "messages" DROP COLUMN "device_ids", DROP COLUMN "user_ids", DROP COLUMN "conversation_id";
@@ -0,0 +1,4 @@
+-- Modify "messages" table
+ALTER TABLE "messages" ADD COLUMN "conversation_id" character varying(24) NULL, ADD COLUMN "user_ids" jsonb NULL, ADD COLUMN "device_ids" jsonb NULL;
+-- Create index "message_conversation_id_id" to table: "messages"
+CREATE INDEX "message_conversation_id_id" ON "messages" ("conversation_id", "id");
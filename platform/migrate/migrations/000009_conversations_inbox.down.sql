@@ -0,0 +1,14 @@
+-- reverse: create index "inboxentry_message_id_device_id" to table: "inbox_entries"
+DROP INDEX "inboxentry_message_id_device_id";
+-- reverse: create index "inboxentry_device_id_id" to table: "inbox_entries"
+DROP INDEX "inboxentry_device_id_id";
+-- reverse: create "inbox_entries" table
+DROP TABLE "inbox_entries";
+-- reverse: create index "conversationmember_user_id" to table: "conversation_members"
+DROP INDEX "conversationmember_user_id";
+-- reverse: create index "conversationmember_conversation_id_user_id" to table: "conversation_members"
+DROP INDEX "conversationmember_conversation_id_user_id";
+-- reverse: create "conversation_members" table
+DROP TABLE "conversation_members";
+-- reverse: create "conversations" table
+DROP TABLE "conversations";
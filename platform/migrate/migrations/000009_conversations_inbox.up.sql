@@ -0,0 +1,14 @@
+-- Create "conversations" table
+CREATE TABLE "conversations" ("id" character varying(24) NOT NULL, "type" character varying NOT NULL, "name" character varying NULL, "member_limit" bigint NOT NULL, "member_count" bigint NOT NULL DEFAULT 0, "create_time" timestamptz NOT NULL, PRIMARY KEY ("id"));
+-- Create "conversation_members" table
+CREATE TABLE "conversation_members" ("id" character varying(24) NOT NULL, "user_id" character varying(24) NOT NULL, "role" character varying NOT NULL DEFAULT 'Member', "muted_until" timestamptz NULL, "join_time" timestamptz NOT NULL, "conversation_id" character varying(24) NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "conversation_members_conversations_members" FOREIGN KEY ("conversation_id") REFERENCES "conversations" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION);
+-- Create index "conversationmember_conversation_id_user_id" to table: "conversation_members"
+CREATE UNIQUE INDEX "conversationmember_conversation_id_user_id" ON "conversation_members" ("conversation_id", "user_id");
+-- Create index "conversationmember_user_id" to table: "conversation_members"
+CREATE INDEX "conversationmember_user_id" ON "conversation_members" ("user_id");
+-- Create "inbox_entries" table
+CREATE TABLE "inbox_entries" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "device_id" character varying(24) NOT NULL, "message_id" bigint NOT NULL, "conversation_id" character varying(24) NULL, "create_time" timestamptz NOT NULL, PRIMARY KEY ("id"));
+-- Create index "inboxentry_device_id_id" to table: "inbox_entries"
+CREATE INDEX "inboxentry_device_id_id" ON "inbox_entries" ("device_id", "id");
+-- Create index "inboxentry_message_id_device_id" to table: "inbox_entries"
+CREATE UNIQUE INDEX "inboxentry_message_id_device_id" ON "inbox_entries" ("message_id", "device_id");
@@ -0,0 +1,14 @@
+-- reverse: create index "topicsubscription_topic_id_subscriber_type_subscriber_id" to table: "topic_subscriptions"
+DROP INDEX "topicsubscription_topic_id_subscriber_type_subscriber_id";
+-- reverse: create index "topicsubscription_subscriber_id" to table: "topic_subscriptions"
+DROP INDEX "topicsubscription_subscriber_id";
+-- reverse: create "topic_subscriptions" table
+DROP TABLE "topic_subscriptions";
+-- reverse: create index "topics_name_key" to table: "topics"
+DROP INDEX "topics_name_key";
+-- reverse: create "topics" table
+DROP TABLE "topics";
+-- reverse: create index "broadcast_topic_id_create_time" to table: "broadcasts"
+DROP INDEX "broadcast_topic_id_create_time";
+-- reverse: create "broadcasts" table
+DROP TABLE "broadcasts";
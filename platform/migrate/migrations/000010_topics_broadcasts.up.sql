@@ -0,0 +1,14 @@
+-- Create "broadcasts" table
+CREATE TABLE "broadcasts" ("id" character varying(24) NOT NULL, "topic_id" character varying(24) NOT NULL, "message_id" bigint NOT NULL, "target" text NULL, "status" character varying NOT NULL DEFAULT 'Pending', "cursor" character varying(24) NULL, "device_count" bigint NOT NULL DEFAULT 0, "error" text NULL, "create_time" timestamptz NOT NULL, "finish_time" timestamptz NULL, PRIMARY KEY ("id"));
+-- Create index "broadcast_topic_id_create_time" to table: "broadcasts"
+CREATE INDEX "broadcast_topic_id_create_time" ON "broadcasts" ("topic_id", "create_time");
+-- Create "topics" table
+CREATE TABLE "topics" ("id" character varying(24) NOT NULL, "name" character varying NOT NULL, "description" character varying NULL, "create_time" timestamptz NOT NULL, PRIMARY KEY ("id"));
+-- Create index "topics_name_key" to table: "topics"
+CREATE UNIQUE INDEX "topics_name_key" ON "topics" ("name");
+-- Create "topic_subscriptions" table
+CREATE TABLE "topic_subscriptions" ("id" character varying(24) NOT NULL, "subscriber_type" character varying NOT NULL, "subscriber_id" character varying(24) NOT NULL, "create_time" timestamptz NOT NULL, "topic_id" character varying(24) NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "topic_subscriptions_topics_subscriptions" FOREIGN KEY ("topic_id") REFERENCES "topics" ("id") ON UPDATE NO ACTION ON DELETE NO ACTION);
+-- Create index "topicsubscription_subscriber_id" to table: "topic_subscriptions"
+CREATE INDEX "topicsubscription_subscriber_id" ON "topic_subscriptions" ("subscriber_id");
+-- Create index "topicsubscription_topic_id_subscriber_type_subscriber_id" to table: "topic_subscriptions"
+CREATE UNIQUE INDEX "topicsubscription_topic_id_subscriber_type_subscriber_id" ON "topic_subscriptions" ("topic_id", "subscriber_type", "subscriber_id");
@@ -0,0 +1,2 @@
+-- reverse: modify "conversation_members" table
+ALTER TABLE "conversation_members" DROP COLUMN "read_time", DROP COLUMN "read_cursor";
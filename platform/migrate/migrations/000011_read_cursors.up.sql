@@ -0,0 +1,2 @@
+-- Modify "conversation_members" table
+ALTER TABLE "conversation_members" ADD COLUMN "read_cursor" bigint NOT NULL DEFAULT 0, ADD COLUMN "read_time" timestamptz NULL;
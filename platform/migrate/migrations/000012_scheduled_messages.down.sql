@@ -0,0 +1,6 @@
+-- reverse: create index "scheduledmessage_status_deliver_time" to table: "scheduled_messages"
+DROP INDEX "scheduledmessage_status_deliver_time";
+-- reverse: create index "scheduledmessage_sender_id_create_time" to table: "scheduled_messages"
+DROP INDEX "scheduledmessage_sender_id_create_time";
+-- reverse: create "scheduled_messages" table
+DROP TABLE "scheduled_messages";
@@ -0,0 +1,6 @@
+-- Create "scheduled_messages" table
+CREATE TABLE "scheduled_messages" ("id" character varying(24) NOT NULL, "sender_id" character varying(24) NULL, "conversation_id" character varying(24) NULL, "user_ids" jsonb NULL, "device_ids" jsonb NULL, "topic" character varying NULL, "target" text NULL, "content_type" character varying NOT NULL, "payload" jsonb NOT NULL, "deliver_time" timestamptz NOT NULL, "status" character varying NOT NULL DEFAULT 'Pending', "message_id" bigint NULL, "broadcast_id" character varying(24) NULL, "error" text NULL, "create_time" timestamptz NOT NULL, "sent_time" timestamptz NULL, PRIMARY KEY ("id"));
+-- Create index "scheduledmessage_sender_id_create_time" to table: "scheduled_messages"
+CREATE INDEX "scheduledmessage_sender_id_create_time" ON "scheduled_messages" ("sender_id", "create_time");
+-- Create index "scheduledmessage_status_deliver_time" to table: "scheduled_messages"
+CREATE INDEX "scheduledmessage_status_deliver_time" ON "scheduled_messages" ("status", "deliver_time");
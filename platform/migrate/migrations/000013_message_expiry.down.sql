@@ -0,0 +1,6 @@
+-- reverse: modify "scheduled_messages" table
+ALTER TABLE "scheduled_messages" DROP COLUMN "ttl";
+-- reverse: create index "message_expire_time" to table: "messages"
+DROP INDEX "message_expire_time";
+-- reverse: modify "messages" table
+ALTER TABLE "messages" DROP COLUMN "expire_time";
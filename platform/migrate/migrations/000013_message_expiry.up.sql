@@ -0,0 +1,6 @@
+-- Modify "messages" table
+ALTER TABLE "messages" ADD COLUMN "expire_time" timestamptz NULL;
+-- Create index "message_expire_time" to table: "messages"
+CREATE INDEX "message_expire_time" ON "messages" ("expire_time");
+-- Modify "scheduled_messages" table
+ALTER TABLE "scheduled_messages" ADD COLUMN "ttl" bigint NOT NULL DEFAULT 0;
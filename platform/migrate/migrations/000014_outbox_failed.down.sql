@@ -0,0 +1,6 @@
+-- reverse: create index "outboxevent_pending" to table: "outbox_events"
+DROP INDEX "outboxevent_pending";
+-- reverse: drop index "outboxevent_pending" from table: "outbox_events"
+CREATE INDEX "outboxevent_pending" ON "outbox_events" ("id") WHERE (sent_time IS NULL);
+-- reverse: modify "outbox_events" table
+ALTER TABLE "outbox_events" DROP COLUMN "failed_time";
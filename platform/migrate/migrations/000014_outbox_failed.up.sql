@@ -0,0 +1,6 @@
+-- Modify "outbox_events" table
+ALTER TABLE "outbox_events" ADD COLUMN "failed_time" timestamptz NULL;
+-- Drop index "outboxevent_pending" from table: "outbox_events"
+DROP INDEX "outboxevent_pending";
+-- Create index "outboxevent_pending" to table: "outbox_events"
+CREATE INDEX "outboxevent_pending" ON "outbox_events" ("id") WHERE ((sent_time IS NULL) AND (failed_time IS NULL));
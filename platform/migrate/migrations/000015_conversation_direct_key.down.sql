@@ -0,0 +1,4 @@
+-- reverse: create index "conversations_direct_key_key" to table: "conversations"
+DROP INDEX "conversations_direct_key_key";
+-- reverse: modify "conversations" table
+ALTER TABLE "conversations" DROP COLUMN "direct_key";
@@ -0,0 +1,6 @@
+-- Modify "conversations" table
+ALTER TABLE "conversations" ADD COLUMN "direct_key" character varying NULL;
+-- Backfill "direct_key" for existing direct conversations, keeping the earliest one per user pair
+WITH "keys" AS (SELECT "m"."conversation_id", string_agg("m"."user_id", ':' ORDER BY "m"."user_id" COLLATE "C") AS "direct_key" FROM "conversation_members" AS "m" JOIN "conversations" AS "c" ON "c"."id" = "m"."conversation_id" WHERE "c"."type" = 'Direct' GROUP BY "m"."conversation_id" HAVING count(*) = 2), "ranked" AS (SELECT "k"."conversation_id", "k"."direct_key", row_number() OVER (PARTITION BY "k"."direct_key" ORDER BY "c"."create_time", "c"."id") AS "rank" FROM "keys" AS "k" JOIN "conversations" AS "c" ON "c"."id" = "k"."conversation_id") UPDATE "conversations" SET "direct_key" = "ranked"."direct_key" FROM "ranked" WHERE "conversations"."id" = "ranked"."conversation_id" AND "ranked"."rank" = 1;
+-- Create index "conversations_direct_key_key" to table: "conversations"
+CREATE UNIQUE INDEX "conversations_direct_key_key" ON "conversations" ("direct_key");
@@ -0,0 +1,2 @@
+-- reverse: modify "scheduled_messages" table
+ALTER TABLE "scheduled_messages" DROP COLUMN "retry_time", DROP COLUMN "attempts";
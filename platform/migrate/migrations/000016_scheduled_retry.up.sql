@@ -0,0 +1,2 @@
+-- Modify "scheduled_messages" table
+ALTER TABLE "scheduled_messages" ADD COLUMN "attempts" bigint NOT NULL DEFAULT 0, ADD COLUMN "retry_time" timestamptz NULL;
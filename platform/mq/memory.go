@@ -0,0 +1,297 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Memory 内存实现的 Broker, 语义与 Pulsar 一致: 订阅创建后才能收到消息,
+// 持久订阅在消费者全部断开后保留, 未确认的消息重新投递. 用于测试和本地开发
+type Memory struct {
+	mu     sync.Mutex
+	closed bool
+	seq    uint64
+	topics map[string]map[string]*memSub
+}
+
+func NewMemory() *Memory {
+	return &Memory{topics: make(map[string]map[string]*memSub)}
+}
+
+func (m *Memory) Publish(ctx context.Context, msg *Message) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return "", ErrClosed
+	}
+
+	m.seq++
+	id := strconv.FormatUint(m.seq, 10)
+	now := time.Now()
+	props := properties(ctx, msg)
+	for _, sub := range m.topics[msg.Topic] {
+		out := &Message{
+			Topic:       msg.Topic,
+			Key:         msg.Key,
+			Payload:     append([]byte(nil), msg.Payload...),
+			Properties:  maps.Clone(props),
+			DeliverAt:   msg.DeliverAt,
+			ID:          id,
+			PublishTime: now,
+		}
+		sub.push(out, out.due())
+	}
+	return id, nil
+}
+
+func (m *Memory) Subscribe(_ context.Context, opts SubscribeOptions) (Consumer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	subs, ok := m.topics[opts.Topic]
+	if !ok {
+		subs = make(map[string]*memSub)
+		m.topics[opts.Topic] = subs
+	}
+	sub, ok := subs[opts.Subscription]
+	if !ok {
+		sub = &memSub{typ: opts.Type, notify: make(chan struct{})}
+		if opts.NonDurable {
+			topic, name := opts.Topic, opts.Subscription
+			sub.remove = func() {
+				m.mu.Lock()
+				defer m.mu.Unlock()
+				if m.topics[topic][name] == sub {
+					delete(m.topics[topic], name)
+				}
+			}
+		}
+		subs[opts.Subscription] = sub
+	}
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if len(sub.consumers) > 0 && sub.typ != opts.Type {
+		return nil, fmt.Errorf("mq: 订阅 %s 已以 %s 类型存在", opts.Subscription, sub.typ)
+	}
+	sub.typ = opts.Type
+
+	nackDelay := opts.NackDelay
+	if nackDelay <= 0 {
+		nackDelay = defaultNackDelay
+	}
+	c := &memConsumer{
+		sub:       sub,
+		nackDelay: nackDelay,
+		inflight:  make(map[string]*Message),
+		done:      make(chan struct{}),
+	}
+	sub.consumers = append(sub.consumers, c)
+	sub.changed()
+	return c, nil
+}
+
+// Close 关闭所有消费者, 之后的发布和订阅返回 ErrClosed
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	var consumers []*memConsumer
+	for _, subs := range m.topics {
+		for _, sub := range subs {
+			sub.mu.Lock()
+			consumers = append(consumers, sub.consumers...)
+			sub.mu.Unlock()
+		}
+	}
+	m.mu.Unlock()
+
+	for _, c := range consumers {
+		_ = c.Close()
+	}
+	return nil
+}
+
+// Backlog 返回订阅中待投递和未确认的消息数量
+func (m *Memory) Backlog(topic, subscription string) int {
+	m.mu.Lock()
+	sub, ok := m.topics[topic][subscription]
+	m.mu.Unlock()
+	if !ok {
+		return 0
+	}
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	n := len(sub.pending)
+	for _, c := range sub.consumers {
+		n += len(c.inflight)
+	}
+	return n
+}
+
+// due 消息的原投递时间
+func (m *Message) due() time.Time {
+	if m.DeliverAt.After(m.PublishTime) {
+		return m.DeliverAt
+	}
+	return m.PublishTime
+}
+
+type memEntry struct {
+	msg *Message
+	due time.Time
+}
+
+type memSub struct {
+	mu        sync.Mutex
+	typ       SubscriptionType
+	pending   []memEntry // 按投递时间排序
+	consumers []*memConsumer
+	notify    chan struct{}
+	remove    func() // 非持久订阅在消费者全部断开后删除, 持久订阅为空
+}
+
+func (s *memSub) push(msg *Message, due time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := sort.Search(len(s.pending), func(i int) bool { return s.pending[i].due.After(due) })
+	s.pending = append(s.pending, memEntry{})
+	copy(s.pending[i+1:], s.pending[i:])
+	s.pending[i] = memEntry{msg: msg, due: due}
+	s.changed()
+}
+
+// changed 唤醒等待中的消费者, 调用方需持有锁
+func (s *memSub) changed() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// active 判断消费者能否接收消息, failover 订阅只有第一个消费者活跃
+func (s *memSub) active(c *memConsumer) bool {
+	return s.typ == Shared || (len(s.consumers) > 0 && s.consumers[0] == c)
+}
+
+type memConsumer struct {
+	sub       *memSub
+	nackDelay time.Duration
+	inflight  map[string]*Message
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *memConsumer) Receive(ctx context.Context) (*Message, error) {
+	s := c.sub
+	for {
+		s.mu.Lock()
+		select {
+		case <-c.done:
+			s.mu.Unlock()
+			return nil, ErrClosed
+		default:
+		}
+		wait := time.Duration(-1)
+		if s.active(c) && len(s.pending) > 0 {
+			head := s.pending[0]
+			if wait = time.Until(head.due); wait <= 0 {
+				s.pending = s.pending[1:]
+				c.inflight[head.msg.ID] = head.msg
+				s.mu.Unlock()
+				return head.msg, nil
+			}
+		}
+		notify := s.notify
+		s.mu.Unlock()
+
+		if err := c.wait(ctx, notify, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait 等待订阅变化或到达下一条消息的投递时间, wait 为负数时不限时
+func (c *memConsumer) wait(ctx context.Context, notify <-chan struct{}, wait time.Duration) error {
+	var timeout <-chan time.Time
+	if wait >= 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-notify:
+		return nil
+	case <-timeout:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return ErrClosed
+	}
+}
+
+func (c *memConsumer) Ack(msg *Message) error {
+	c.sub.mu.Lock()
+	defer c.sub.mu.Unlock()
+	if _, ok := c.inflight[msg.ID]; !ok {
+		return fmt.Errorf("mq: 消息 %s 未在投递中", msg.ID)
+	}
+	delete(c.inflight, msg.ID)
+	return nil
+}
+
+func (c *memConsumer) Nack(msg *Message) {
+	c.sub.mu.Lock()
+	m, ok := c.inflight[msg.ID]
+	delete(c.inflight, msg.ID)
+	c.sub.mu.Unlock()
+	if ok {
+		m.Redeliveries++
+		c.sub.push(m, time.Now().Add(c.nackDelay))
+	}
+}
+
+// Close 断开消费者, 未确认的消息立即重新投递给订阅中的其他消费者
+func (c *memConsumer) Close() error {
+	c.closeOnce.Do(func() {
+		s := c.sub
+		s.mu.Lock()
+		close(c.done)
+		for i, other := range s.consumers {
+			if other == c {
+				s.consumers = append(s.consumers[:i], s.consumers[i+1:]...)
+				break
+			}
+		}
+		inflight := c.inflight
+		c.inflight = make(map[string]*Message)
+		s.changed()
+		drop := s.remove != nil && len(s.consumers) == 0
+		if drop {
+			s.pending = nil
+		}
+		s.mu.Unlock()
+
+		if drop {
+			s.remove()
+			return
+		}
+		// 按原投递时间放回, 接管的消费者先收到这些消息, 与 Pulsar 从确认位置重新投递一致
+		msgs := slices.SortedFunc(maps.Values(inflight), func(a, b *Message) int {
+			return a.due().Compare(b.due())
+		})
+		for _, m := range msgs {
+			m.Redeliveries++
+			s.push(m, m.due())
+		}
+	})
+	return nil
+}
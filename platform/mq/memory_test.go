@@ -0,0 +1,269 @@
+package mq_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"msgcenter/platform/mq"
+	"msgcenter/utils/reqctx"
+)
+
+func subscribe(t *testing.T, b mq.Broker, opts mq.SubscribeOptions) mq.Consumer {
+	t.Helper()
+	if opts.Topic == "" {
+		opts.Topic = "t"
+	}
+	c, err := b.Subscribe(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func publish(t *testing.T, b mq.Broker, msg *mq.Message) string {
+	t.Helper()
+	if msg.Topic == "" {
+		msg.Topic = "t"
+	}
+	id, err := b.Publish(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// receive 在 timeout 内接收一条消息, 超时返回 nil
+func receive(t *testing.T, c mq.Consumer, timeout time.Duration) *mq.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	msg, err := c.Receive(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestMemoryOnlyDeliversAfterSubscribe(t *testing.T) {
+	b := mq.NewMemory()
+	defer b.Close()
+
+	publish(t, b, &mq.Message{Payload: []byte("early")})
+	c := subscribe(t, b, mq.SubscribeOptions{Subscription: "s"})
+	id := publish(t, b, &mq.Message{Payload: []byte("late"), Key: "k"})
+
+	msg := receive(t, c, time.Second)
+	if msg == nil || string(msg.Payload) != "late" || msg.ID != id || msg.Key != "k" {
+		t.Fatalf("收到 %+v, want late", msg)
+	}
+	if err := c.Ack(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ack(msg); err == nil {
+		t.Fatal("重复 Ack 应返回错误")
+	}
+	if n := b.Backlog("t", "s"); n != 0 {
+		t.Fatalf("Backlog = %d, want 0", n)
+	}
+}
+
+func TestMemorySubscriptionsFanOut(t *testing.T) {
+	b := mq.NewMemory()
+	defer b.Close()
+
+	a := subscribe(t, b, mq.SubscribeOptions{Subscription: "a"})
+	c := subscribe(t, b, mq.SubscribeOptions{Subscription: "c"})
+	publish(t, b, &mq.Message{Payload: []byte("x")})
+
+	for _, consumer := range []mq.Consumer{a, c} {
+		if msg := receive(t, consumer, time.Second); msg == nil || string(msg.Payload) != "x" {
+			t.Fatalf("每个订阅都应收到消息, got %+v", msg)
+		}
+	}
+}
+
+func TestMemorySharedSplitsMessages(t *testing.T) {
+	b := mq.NewMemory()
+	defer b.Close()
+
+	c1 := subscribe(t, b, mq.SubscribeOptions{Subscription: "s", Type: mq.Shared})
+	c2 := subscribe(t, b, mq.SubscribeOptions{Subscription: "s", Type: mq.Shared})
+	publish(t, b, &mq.Message{Payload: []byte("1")})
+	publish(t, b, &mq.Message{Payload: []byte("2")})
+
+	m1 := receive(t, c1, time.Second)
+	m2 := receive(t, c2, time.Second)
+	if m1 == nil || m2 == nil || m1.ID == m2.ID {
+		t.Fatalf("共享订阅的消费者应各收到一条不同的消息: %+v, %+v", m1, m2)
+	}
+	if extra := receive(t, c1, 50*time.Millisecond); extra != nil {
+		t.Fatalf("同一消息不应投递两次: %+v", extra)
+	}
+}
+
+func TestMemoryFailoverTakeover(t *testing.T) {
+	b := mq.NewMemory()
+	defer b.Close()
+
+	primary := subscribe(t, b, mq.SubscribeOptions{Subscription: "s", Type: mq.Failover})
+	standby := subscribe(t, b, mq.SubscribeOptions{Subscription: "s", Type: mq.Failover})
+	publish(t, b, &mq.Message{Payload: []byte("1")})
+	publish(t, b, &mq.Message{Payload: []byte("2")})
+
+	if msg := receive(t, standby, 50*time.Millisecond); msg != nil {
+		t.Fatalf("备用消费者不应收到消息: %+v", msg)
+	}
+	first := receive(t, primary, time.Second)
+	if first == nil || string(first.Payload) != "1" {
+		t.Fatalf("活跃消费者应按顺序收到第一条消息, got %+v", first)
+	}
+
+	// 活跃消费者断开, 未确认的消息立即重新投递给接管的消费者
+	_ = primary.Close()
+	got := receive(t, standby, time.Second)
+	if got == nil || got.ID != first.ID || got.Redeliveries != 1 {
+		t.Fatalf("接管后应先收到未确认的消息, got %+v", got)
+	}
+	if next := receive(t, standby, time.Second); next == nil || string(next.Payload) != "2" {
+		t.Fatalf("接管后应继续收到后续消息, got %+v", next)
+	}
+
+	if _, err := b.Subscribe(context.Background(), mq.SubscribeOptions{Topic: "t", Subscription: "s", Type: mq.Shared}); err == nil {
+		t.Fatal("以不同类型订阅已存在的订阅应返回错误")
+	}
+}
+
+func TestMemoryNackRedelivers(t *testing.T) {
+	b := mq.NewMemory()
+	defer b.Close()
+
+	c := subscribe(t, b, mq.SubscribeOptions{Subscription: "s", NackDelay: 100 * time.Millisecond})
+	publish(t, b, &mq.Message{Payload: []byte("x")})
+
+	msg := receive(t, c, time.Second)
+	c.Nack(msg)
+	if again := receive(t, c, 30*time.Millisecond); again != nil {
+		t.Fatalf("nack 延迟内不应重新投递: %+v", again)
+	}
+	again := receive(t, c, time.Second)
+	if again == nil || again.ID != msg.ID || again.Redeliveries != 1 {
+		t.Fatalf("nack 后应重新投递, got %+v", again)
+	}
+}
+
+func TestMemoryDeliverAt(t *testing.T) {
+	b := mq.NewMemory()
+	defer b.Close()
+
+	c := subscribe(t, b, mq.SubscribeOptions{Subscription: "s"})
+	publish(t, b, &mq.Message{Payload: []byte("later"), DeliverAt: time.Now().Add(150 * time.Millisecond)})
+	publish(t, b, &mq.Message{Payload: []byte("now")})
+
+	if msg := receive(t, c, time.Second); msg == nil || string(msg.Payload) != "now" {
+		t.Fatalf("立即投递的消息应先到达, got %+v", msg)
+	}
+	if msg := receive(t, c, 50*time.Millisecond); msg != nil {
+		t.Fatalf("延迟消息提前投递: %+v", msg)
+	}
+	if msg := receive(t, c, time.Second); msg == nil || string(msg.Payload) != "later" {
+		t.Fatalf("延迟消息未投递, got %+v", msg)
+	}
+}
+
+func TestMemoryDurability(t *testing.T) {
+	b := mq.NewMemory()
+	defer b.Close()
+
+	durable, _ := b.Subscribe(context.Background(), mq.SubscribeOptions{Topic: "t", Subscription: "d"})
+	transient, _ := b.Subscribe(context.Background(), mq.SubscribeOptions{Topic: "t", Subscription: "n", NonDurable: true})
+	_ = durable.Close()
+	_ = transient.Close()
+
+	// 持久订阅在消费者断开期间仍累积消息, 非持久订阅已删除
+	publish(t, b, &mq.Message{Payload: []byte("x")})
+	if n := b.Backlog("t", "d"); n != 1 {
+		t.Fatalf("持久订阅 Backlog = %d, want 1", n)
+	}
+	if n := b.Backlog("t", "n"); n != 0 {
+		t.Fatalf("非持久订阅 Backlog = %d, want 0", n)
+	}
+
+	c := subscribe(t, b, mq.SubscribeOptions{Subscription: "d"})
+	if msg := receive(t, c, time.Second); msg == nil {
+		t.Fatal("重新连接持久订阅后应收到断开期间的消息")
+	}
+}
+
+func TestMemoryClose(t *testing.T) {
+	b := mq.NewMemory()
+	c := subscribe(t, b, mq.SubscribeOptions{Subscription: "s"})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- mq.Consume(context.Background(), c, zap.NewNop(), func(context.Context, *mq.Message) error { return nil })
+	}()
+
+	_ = b.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("broker 关闭后 Consume 应返回 nil, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("broker 关闭后 Consume 未退出")
+	}
+	if _, err := b.Publish(context.Background(), &mq.Message{Topic: "t"}); !errors.Is(err, mq.ErrClosed) {
+		t.Fatalf("关闭后 Publish err = %v, want ErrClosed", err)
+	}
+	if _, err := b.Subscribe(context.Background(), mq.SubscribeOptions{Topic: "t", Subscription: "x"}); !errors.Is(err, mq.ErrClosed) {
+		t.Fatalf("关闭后 Subscribe err = %v, want ErrClosed", err)
+	}
+}
+
+func TestConsumeRestoresRequestContext(t *testing.T) {
+	b := mq.NewMemory()
+	defer b.Close()
+	c := subscribe(t, b, mq.SubscribeOptions{Subscription: "s"})
+
+	ctx := reqctx.WithRequestID(context.Background(), "req-1")
+	if _, err := b.Publish(ctx, &mq.Message{Topic: "t", Properties: map[string]string{"k": "v"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	attempts := 0
+	got := make(chan string, 1)
+	go func() {
+		_ = mq.Consume(ctx, c, zap.NewNop(), func(ctx context.Context, msg *mq.Message) error {
+			// 第一次失败 nack, 重新投递后成功
+			if attempts++; attempts == 1 {
+				return errors.New("fail")
+			}
+			got <- reqctx.RequestID(ctx) + "," + msg.Properties["k"]
+			return nil
+		})
+	}()
+
+	select {
+	case v := <-got:
+		if v != "req-1,v" {
+			t.Fatalf("处理消息的上下文 = %q, want req-1,v", v)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("nack 后消息未重新处理")
+	}
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	if n := b.Backlog("t", "s"); n != 0 {
+		t.Fatalf("处理成功后 Backlog = %d, want 0", n)
+	}
+}
@@ -0,0 +1,128 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"msgcenter/utils/logger"
+	"msgcenter/utils/reqctx"
+)
+
+// ErrClosed broker 或消费者已关闭
+var ErrClosed = errors.New("mq: closed")
+
+// SubscriptionType 订阅类型
+type SubscriptionType int
+
+const (
+	// Shared 同一订阅的多个消费者轮流接收消息, 不保证顺序
+	Shared SubscriptionType = iota
+	// Failover 同一订阅只有一个活跃消费者, 活跃消费者断开后由下一个接管
+	Failover
+)
+
+func (t SubscriptionType) String() string {
+	switch t {
+	case Shared:
+		return "shared"
+	case Failover:
+		return "failover"
+	default:
+		return "unknown"
+	}
+}
+
+// Message 发布和消费的消息
+type Message struct {
+	Topic      string
+	Key        string            // 顺序键, 相同键的消息投递到同一分区
+	Payload    []byte            // 消息体
+	Properties map[string]string // 消息头, 发布时自动写入请求上下文
+	DeliverAt  time.Time         // 延迟投递时间, 零值立即投递
+
+	// 以下字段在消费时填充
+	ID           string
+	PublishTime  time.Time
+	Redeliveries int // 重新投递次数
+
+	raw any
+}
+
+// SubscribeOptions 订阅选项
+type SubscribeOptions struct {
+	Topic        string
+	Subscription string
+	Type         SubscriptionType
+	NackDelay    time.Duration // nack 后重新投递的延迟, 0 使用默认值
+	// NonDurable 非持久订阅, 消费者全部断开后订阅删除, 只收到订阅期间发布的消息.
+	// 用于只推送给当前实例在线连接的场景, 实例重启后不会补发离线期间的消息
+	NonDurable bool
+}
+
+// Broker 消息队列, 由 Pulsar 和内存实现
+type Broker interface {
+	// Publish 发布消息, 返回消息ID
+	Publish(ctx context.Context, msg *Message) (string, error)
+	Subscribe(ctx context.Context, opts SubscribeOptions) (Consumer, error)
+	Close() error
+}
+
+// Consumer 订阅的消费者, 消息处理完成后必须 Ack 或 Nack
+type Consumer interface {
+	// Receive 阻塞等待下一条消息
+	Receive(ctx context.Context) (*Message, error)
+	Ack(msg *Message) error
+	// Nack 延迟后重新投递
+	Nack(msg *Message)
+	Close() error
+}
+
+// Handler 处理消息, 返回 nil 时 ack, 返回错误时 nack
+type Handler func(ctx context.Context, msg *Message) error
+
+const defaultNackDelay = time.Second
+
+// Consume 循环接收并处理消息, 直到 ctx 取消或消费者关闭.
+// 处理消息的 ctx 中恢复了发布方的请求上下文
+func Consume(ctx context.Context, c Consumer, log *zap.Logger, handler Handler) error {
+	for {
+		msg, err := c.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		msgCtx := reqctx.FromMetadata(ctx, msg.Properties)
+		if err := handler(msgCtx, msg); err != nil {
+			logger.With(msgCtx, log).Warn("消息处理失败, 稍后重新投递",
+				zap.String("topic", msg.Topic),
+				zap.String("id", msg.ID),
+				zap.Int("redeliveries", msg.Redeliveries),
+				zap.Error(err),
+			)
+			c.Nack(msg)
+			continue
+		}
+		if err := c.Ack(msg); err != nil {
+			logger.With(msgCtx, log).Warn("消息确认失败",
+				zap.String("topic", msg.Topic),
+				zap.String("id", msg.ID),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// properties 合并请求上下文和消息自带的消息头, 消息自带的优先
+func properties(ctx context.Context, msg *Message) map[string]string {
+	props := reqctx.Metadata(ctx)
+	for k, v := range msg.Properties {
+		props[k] = v
+	}
+	return props
+}
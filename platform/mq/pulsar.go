@@ -0,0 +1,191 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"go.uber.org/zap"
+)
+
+// PulsarOptions Pulsar 连接配置
+type PulsarOptions struct {
+	URL               string
+	Tenant            string // 默认 public
+	Namespace         string // 默认 default
+	OperationTimeout  time.Duration
+	ConnectionTimeout time.Duration
+}
+
+// Pulsar 基于 Apache Pulsar 的 Broker, 主题名自动补全为 persistent://tenant/namespace/topic
+type Pulsar struct {
+	client pulsar.Client
+	prefix string
+	logger *zap.Logger
+
+	mu        sync.Mutex
+	closed    bool
+	producers map[string]pulsar.Producer
+}
+
+func NewPulsar(opts PulsarOptions, logger *zap.Logger) (*Pulsar, error) {
+	client, err := pulsar.NewClient(pulsar.ClientOptions{
+		URL:               opts.URL,
+		OperationTimeout:  opts.OperationTimeout,
+		ConnectionTimeout: opts.ConnectionTimeout,
+		Logger:            newPulsarLogger(logger),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建pulsar客户端失败: %w", err)
+	}
+
+	tenant, namespace := opts.Tenant, opts.Namespace
+	if tenant == "" {
+		tenant = "public"
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	return &Pulsar{
+		client:    client,
+		prefix:    fmt.Sprintf("persistent://%s/%s/", tenant, namespace),
+		logger:    logger,
+		producers: make(map[string]pulsar.Producer),
+	}, nil
+}
+
+// producer 按主题复用生产者
+func (p *Pulsar) producer(topic string) (pulsar.Producer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	if producer, ok := p.producers[topic]; ok {
+		return producer, nil
+	}
+
+	producer, err := p.client.CreateProducer(pulsar.ProducerOptions{
+		Topic: p.prefix + topic,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建pulsar生产者失败: %w", err)
+	}
+	p.producers[topic] = producer
+	p.logger.Info("pulsar生产者已创建", zap.String("topic", topic))
+	return producer, nil
+}
+
+func (p *Pulsar) Publish(ctx context.Context, msg *Message) (string, error) {
+	producer, err := p.producer(msg.Topic)
+	if err != nil {
+		return "", err
+	}
+	id, err := producer.Send(ctx, &pulsar.ProducerMessage{
+		Payload:    msg.Payload,
+		Key:        msg.Key,
+		Properties: properties(ctx, msg),
+		DeliverAt:  msg.DeliverAt,
+	})
+	if err != nil {
+		return "", fmt.Errorf("发布消息失败: %w", err)
+	}
+	return id.String(), nil
+}
+
+// Subscribe 创建消费者, 延迟投递只对 Shared 订阅生效
+func (p *Pulsar) Subscribe(_ context.Context, opts SubscribeOptions) (Consumer, error) {
+	subType := pulsar.Shared
+	if opts.Type == Failover {
+		subType = pulsar.Failover
+	}
+	nackDelay := opts.NackDelay
+	if nackDelay <= 0 {
+		nackDelay = defaultNackDelay
+	}
+
+	mode := pulsar.Durable
+	if opts.NonDurable {
+		mode = pulsar.NonDurable
+	}
+
+	consumer, err := p.client.Subscribe(pulsar.ConsumerOptions{
+		Topic:               p.prefix + opts.Topic,
+		SubscriptionName:    opts.Subscription,
+		Type:                subType,
+		SubscriptionMode:    mode,
+		NackRedeliveryDelay: nackDelay,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("订阅 %s/%s 失败: %w", opts.Topic, opts.Subscription, err)
+	}
+	p.logger.Info("pulsar订阅已创建",
+		zap.String("topic", opts.Topic),
+		zap.String("subscription", opts.Subscription),
+		zap.Stringer("type", opts.Type),
+	)
+	return &pulsarConsumer{consumer: consumer, topic: opts.Topic}, nil
+}
+
+// Close 关闭所有生产者和客户端, 消费者需由调用方先关闭
+func (p *Pulsar) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	producers := p.producers
+	p.producers = make(map[string]pulsar.Producer)
+	p.mu.Unlock()
+
+	for _, producer := range producers {
+		producer.Close()
+	}
+	p.client.Close()
+	return nil
+}
+
+type pulsarConsumer struct {
+	consumer pulsar.Consumer
+	topic    string
+}
+
+func (c *pulsarConsumer) Receive(ctx context.Context) (*Message, error) {
+	msg, err := c.consumer.Receive(ctx)
+	if err != nil {
+		var perr *pulsar.Error
+		if errors.As(err, &perr) && perr.Result() == pulsar.ConsumerClosed {
+			return nil, ErrClosed
+		}
+		return nil, err
+	}
+	return &Message{
+		Topic:        c.topic,
+		Key:          msg.Key(),
+		Payload:      msg.Payload(),
+		Properties:   msg.Properties(),
+		ID:           msg.ID().String(),
+		PublishTime:  msg.PublishTime(),
+		Redeliveries: int(msg.RedeliveryCount()),
+		raw:          msg,
+	}, nil
+}
+
+func (c *pulsarConsumer) Ack(msg *Message) error {
+	raw, ok := msg.raw.(pulsar.Message)
+	if !ok {
+		return fmt.Errorf("mq: 消息 %s 不是由该消费者接收", msg.ID)
+	}
+	return c.consumer.Ack(raw)
+}
+
+func (c *pulsarConsumer) Nack(msg *Message) {
+	if raw, ok := msg.raw.(pulsar.Message); ok {
+		c.consumer.Nack(raw)
+	}
+}
+
+func (c *pulsarConsumer) Close() error {
+	c.consumer.Close()
+	return nil
+}
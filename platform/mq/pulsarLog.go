@@ -0,0 +1,40 @@
+package mq
+
+import (
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"go.uber.org/zap"
+)
+
+// pulsarLogger 将 pulsar 客户端日志输出到 zap
+type pulsarLogger struct {
+	s *zap.SugaredLogger
+}
+
+func newPulsarLogger(logger *zap.Logger) log.Logger {
+	return pulsarLogger{s: logger.WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+func (l pulsarLogger) with(fields log.Fields) pulsarLogger {
+	args := make([]any, 0, len(fields)*2)
+	for k, v := range fields {
+		args = append(args, k, v)
+	}
+	return pulsarLogger{s: l.s.With(args...)}
+}
+
+func (l pulsarLogger) SubLogger(fields log.Fields) log.Logger { return l.with(fields) }
+func (l pulsarLogger) WithFields(fields log.Fields) log.Entry { return l.with(fields) }
+func (l pulsarLogger) WithField(name string, value interface{}) log.Entry {
+	return pulsarLogger{s: l.s.With(name, value)}
+}
+func (l pulsarLogger) WithError(err error) log.Entry {
+	return pulsarLogger{s: l.s.With(zap.Error(err))}
+}
+func (l pulsarLogger) Debug(args ...interface{})                 { l.s.Debug(args...) }
+func (l pulsarLogger) Info(args ...interface{})                  { l.s.Info(args...) }
+func (l pulsarLogger) Warn(args ...interface{})                  { l.s.Warn(args...) }
+func (l pulsarLogger) Error(args ...interface{})                 { l.s.Error(args...) }
+func (l pulsarLogger) Debugf(format string, args ...interface{}) { l.s.Debugf(format, args...) }
+func (l pulsarLogger) Infof(format string, args ...interface{})  { l.s.Infof(format, args...) }
+func (l pulsarLogger) Warnf(format string, args ...interface{})  { l.s.Warnf(format, args...) }
+func (l pulsarLogger) Errorf(format string, args ...interface{}) { l.s.Errorf(format, args...) }
@@ -0,0 +1,199 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/metrics"
+	"msgcenter/utils/logger"
+)
+
+// 重试时附加的消息头
+const (
+	HeaderAttempt = "mq-attempt" // 已失败次数
+	HeaderHistory = "mq-history" // 失败记录, []Attempt 的 JSON
+)
+
+// Attempt 一次处理失败的记录
+type Attempt struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+// permanentError 重试也无法成功的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记错误为永久失败, Worker 不再重试直接写入死信
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 判断是否为永久失败
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// DeadLetter 进入死信的消息
+type DeadLetter struct {
+	Message      *Message
+	Subscription string
+	Reason       error
+	History      []Attempt
+}
+
+// DeadLetterSink 保存死信
+type DeadLetterSink interface {
+	DeadLetter(ctx context.Context, dl DeadLetter) error
+}
+
+// RetryPolicy 失败重试策略, 第 n 次重试延迟 Backoff*2^(n-1), 不超过 MaxBackoff
+type RetryPolicy struct {
+	MaxAttempts int // 最多处理次数, 超过后进入死信; 0 表示无限重试
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	if d <= 0 {
+		d = defaultNackDelay
+	}
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return d
+}
+
+// RetryTopic 订阅的重试主题, 失败的消息延迟后重新发布到这里, 只有该订阅会再次收到
+func RetryTopic(topic, subscription string) string {
+	return topic + "-" + subscription + "-retry"
+}
+
+// Worker 消费订阅和它的重试主题. 处理失败的消息带上失败记录重新发布到重试主题,
+// 超过最多处理次数或永久失败时写入死信
+type Worker struct {
+	Broker       Broker
+	Subscription SubscribeOptions
+	Handler      Handler
+	Retry        RetryPolicy
+	DeadLetter   DeadLetterSink // 为空时超过次数的消息只记录日志后丢弃
+	Logger       *zap.Logger
+}
+
+// Run 订阅并消费直到 ctx 取消, 任一订阅出错时一同退出
+func (w *Worker) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	main := w.Subscription
+	// 延迟投递只对 Shared 订阅生效, 重试主题总是使用 Shared
+	retry := main
+	retry.Topic = RetryTopic(main.Topic, main.Subscription)
+	retry.Type = Shared
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, 2)
+	)
+	for i, opts := range []SubscribeOptions{main, retry} {
+		c, err := w.Broker.Subscribe(ctx, opts)
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cancel()
+			defer c.Close()
+			errs[i] = Consume(ctx, c, w.Logger, w.handle)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// handle 处理失败时重新发布或写入死信, 只有这一步失败才 nack 原消息
+func (w *Worker) handle(ctx context.Context, msg *Message) error {
+	err := w.Handler(ctx, msg)
+	if err == nil {
+		return nil
+	}
+
+	attempt, _ := strconv.Atoi(msg.Properties[HeaderAttempt])
+	attempt++
+	var history []Attempt
+	if raw := msg.Properties[HeaderHistory]; raw != "" {
+		_ = sonic.UnmarshalString(raw, &history)
+	}
+	history = append(history, Attempt{Time: time.Now(), Error: err.Error()})
+
+	topic := w.Subscription.Topic
+	sub := w.Subscription.Subscription
+	log := logger.With(ctx, w.Logger).With(
+		zap.String("topic", topic),
+		zap.String("subscription", sub),
+		zap.String("id", msg.ID),
+		zap.Int("attempt", attempt),
+		zap.Error(err),
+	)
+
+	if IsPermanent(err) || (w.Retry.MaxAttempts > 0 && attempt >= w.Retry.MaxAttempts) {
+		metrics.MQDeadLetters.WithLabelValues(topic, sub, strconv.FormatBool(IsPermanent(err))).Inc()
+		if w.DeadLetter == nil {
+			log.Error("消息处理失败, 已丢弃")
+			return nil
+		}
+		// 死信保存原主题, 重放时可按原主题查询
+		dead := *msg
+		dead.Topic = topic
+		if err := w.DeadLetter.DeadLetter(ctx, DeadLetter{
+			Message:      &dead,
+			Subscription: sub,
+			Reason:       err,
+			History:      history,
+		}); err != nil {
+			return fmt.Errorf("写入死信失败: %w", err)
+		}
+		log.Warn("消息处理失败, 已写入死信")
+		return nil
+	}
+
+	props := maps.Clone(msg.Properties)
+	if props == nil {
+		props = make(map[string]string, 2)
+	}
+	props[HeaderAttempt] = strconv.Itoa(attempt)
+	props[HeaderHistory], _ = sonic.MarshalString(history)
+	if _, err := w.Broker.Publish(ctx, &Message{
+		Topic:      RetryTopic(topic, sub),
+		Key:        msg.Key,
+		Payload:    msg.Payload,
+		Properties: props,
+		DeliverAt:  time.Now().Add(w.Retry.delay(attempt)),
+	}); err != nil {
+		return fmt.Errorf("重新发布失败: %w", err)
+	}
+	metrics.MQRetries.WithLabelValues(topic, sub).Inc()
+	log.Info("消息处理失败, 稍后重试")
+	return nil
+}
@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/utils/reqctx"
+)
+
+// Event 待发布到消息队列的事件
+type Event struct {
+	Topic       string
+	Key         string // 顺序键, 相同键的事件投递到同一分区
+	Payload     []byte
+	Aggregate   string // 产生事件的实体类型, 如 Message
+	AggregateID string
+	DeliverAt   time.Time // 延迟投递时间, 零值立即投递
+}
+
+// Add 写入待发布事件, 事件头中记录当前请求上下文.
+// client 应为事务客户端(tx.Client()), 事件与业务数据一同提交或回滚
+func Add(ctx context.Context, client *gen.Client, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	headers := reqctx.Metadata(ctx)
+	builders := make([]*gen.OutboxEventCreate, len(events))
+	for i, ev := range events {
+		b := client.OutboxEvent.Create().
+			SetTopic(ev.Topic).
+			SetKey(ev.Key).
+			SetPayload(ev.Payload).
+			SetHeaders(headers).
+			SetAggregate(ev.Aggregate).
+			SetAggregateID(ev.AggregateID)
+		if !ev.DeliverAt.IsZero() {
+			b.SetDeliverAt(ev.DeliverAt)
+		}
+		builders[i] = b
+	}
+	if err := client.OutboxEvent.CreateBulk(builders...).Exec(ctx); err != nil {
+		return fmt.Errorf("写入发件箱失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/outboxevent"
+	"msgcenter/platform/ent/gen/predicate"
+	"msgcenter/platform/metrics"
+	"msgcenter/platform/mq"
+	"msgcenter/utils/logger"
+	"msgcenter/utils/reqctx"
+)
+
+// 发布到消息队列时附加的消息头, 消费方按事件ID去重
+const (
+	HeaderEventID     = "outbox-event-id"
+	HeaderAggregate   = "outbox-aggregate"
+	HeaderAggregateID = "outbox-aggregate-id"
+)
+
+const (
+	cleanupInterval    = time.Hour
+	defaultMaxAttempts = 10
+)
+
+// Options 发布配置
+type Options struct {
+	Interval    time.Duration // 没有积压时的轮询间隔
+	BatchSize   int           // 每批发布的事件数
+	Retention   time.Duration // 已发布事件的保留时间, 0 表示不清理
+	MaxAttempts int           // 单个事件的最大发布次数, 超过后标记失败并跳过
+	CommitGrace time.Duration // 只发布写入超过该时长的事件, 0 表示不等待
+}
+
+// Relay 将发件箱中的事件按主键顺序发布到消息队列, 保证至少发布一次.
+// 主键在事务提交前生成, 主键顺序不等于提交顺序: 主键较小的长事务晚提交时,
+// 其事件会在主键更大的事件之后发布, 因此同一顺序键的事件只是尽量有序.
+// 配置 CommitGrace 可覆盖常见的提交延迟, 消费方需要严格顺序时应按业务版本号处理.
+// 多实例部署时只能由 leader 运行
+type Relay struct {
+	client func() *gen.Client
+	broker func() mq.Broker
+	opts   Options
+	logger *zap.Logger
+}
+
+// NewRelay client 和 broker 每批发布时调用, 配置热更新后使用新连接
+func NewRelay(client func() *gen.Client, broker func() mq.Broker, opts Options, logger *zap.Logger) *Relay {
+	if opts.Interval <= 0 {
+		opts.Interval = 200 * time.Millisecond
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	return &Relay{
+		client: client,
+		broker: broker,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// Run 循环发布直到 ctx 取消
+func (r *Relay) Run(ctx context.Context) {
+	r.logger.Info("发件箱发布已启动")
+	defer r.logger.Info("发件箱发布已停止")
+
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	var lastCleanup time.Time
+
+	for {
+		n, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Warn("发布发件箱事件失败", zap.Error(err))
+		}
+		if err := r.updateLag(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Warn("统计发件箱积压失败", zap.Error(err))
+		}
+		if r.opts.Retention > 0 && time.Since(lastCleanup) >= cleanupInterval {
+			lastCleanup = time.Now()
+			if err := r.cleanup(ctx); err != nil && ctx.Err() == nil {
+				r.logger.Warn("清理已发布事件失败", zap.Error(err))
+			}
+		}
+
+		// 整批发布成功说明还有积压, 立即继续
+		if err == nil && n == r.opts.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pending 待发布且未标记失败的事件
+func pending() predicate.OutboxEvent {
+	return outboxevent.And(outboxevent.SentTimeIsNil(), outboxevent.FailedTimeIsNil())
+}
+
+// RelayOnce 按主键顺序发布一批待发布事件, 返回发布成功的数量.
+// 遇到发布失败时停止, 后续事件等下一轮重试, 以保证顺序;
+// 发布次数达到 MaxAttempts 的事件标记失败后跳过, 不再阻塞后续事件
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	client, broker := r.client(), r.broker()
+	if client == nil || broker == nil {
+		return 0, fmt.Errorf("数据库或消息队列未初始化")
+	}
+
+	q := client.OutboxEvent.Query().Where(pending())
+	if r.opts.CommitGrace > 0 {
+		q.Where(outboxevent.CreateTimeLT(time.Now().Add(-r.opts.CommitGrace)))
+	}
+	events, err := q.
+		Order(outboxevent.ByID()).
+		Limit(r.opts.BatchSize).
+		All(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, ev := range events {
+		props := maps.Clone(ev.Headers)
+		if props == nil {
+			props = make(map[string]string, 3)
+		}
+		props[HeaderEventID] = strconv.FormatInt(ev.ID, 10)
+		props[HeaderAggregate] = ev.Aggregate
+		props[HeaderAggregateID] = ev.AggregateID
+		msg := &mq.Message{
+			Topic:      ev.Topic,
+			Key:        ev.Key,
+			Payload:    ev.Payload,
+			Properties: props,
+		}
+		if ev.DeliverAt != nil {
+			msg.DeliverAt = *ev.DeliverAt
+		}
+
+		evCtx := reqctx.FromMetadata(ctx, ev.Headers)
+		if _, err := broker.Publish(evCtx, msg); err != nil {
+			metrics.OutboxPublishErrors.WithLabelValues(ev.Topic).Inc()
+			update := client.OutboxEvent.UpdateOneID(ev.ID).
+				AddAttempts(1).
+				SetLastError(err.Error())
+			exhausted := ev.Attempts+1 >= r.opts.MaxAttempts
+			if exhausted {
+				update.SetFailedTime(time.Now())
+			}
+			if uerr := update.Exec(ctx); uerr != nil {
+				logger.With(evCtx, r.logger).Warn("记录事件发布失败出错", zap.Int64("id", ev.ID), zap.Error(uerr))
+			} else if exhausted {
+				metrics.OutboxFailed.WithLabelValues(ev.Topic).Inc()
+				logger.With(evCtx, r.logger).Error("事件超过最大发布次数, 已标记失败",
+					zap.Int64("id", ev.ID),
+					zap.String("topic", ev.Topic),
+					zap.Int("attempts", ev.Attempts+1),
+					zap.Error(err),
+				)
+				continue
+			}
+			return sent, fmt.Errorf("发布事件 %d 到 %s 失败: %w", ev.ID, ev.Topic, err)
+		}
+
+		// 已发布但标记失败时下一轮会重复发布, 由消费方按事件ID去重
+		now := time.Now()
+		if err := client.OutboxEvent.UpdateOneID(ev.ID).
+			AddAttempts(1).
+			SetSentTime(now).
+			Exec(ctx); err != nil {
+			return sent, fmt.Errorf("标记事件 %d 已发布失败: %w", ev.ID, err)
+		}
+		sent++
+		metrics.OutboxPublished.WithLabelValues(ev.Topic).Inc()
+		metrics.OutboxPublishDelay.Observe(now.Sub(ev.CreateTime).Seconds())
+	}
+	return sent, nil
+}
+
+// updateLag 更新待发布数量和最早待发布事件的等待时间
+func (r *Relay) updateLag(ctx context.Context) error {
+	client := r.client()
+	if client == nil {
+		return nil
+	}
+	q := client.OutboxEvent.Query().Where(pending())
+	count, err := q.Clone().Count(ctx)
+	if err != nil {
+		return err
+	}
+	metrics.OutboxPending.Set(float64(count))
+	if count == 0 {
+		metrics.OutboxLag.Set(0)
+		return nil
+	}
+
+	oldest, err := q.Order(outboxevent.ByID()).First(ctx)
+	if err != nil {
+		return err
+	}
+	metrics.OutboxLag.Set(time.Since(oldest.CreateTime).Seconds())
+	return nil
+}
+
+// cleanup 删除超过保留时间的已发布事件
+func (r *Relay) cleanup(ctx context.Context) error {
+	cutoff := time.Now().Add(-r.opts.Retention)
+	n, err := r.client().OutboxEvent.Delete().
+		Where(outboxevent.SentTimeLT(cutoff)).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		r.logger.Info("已清理已发布事件", zap.Int("count", n), zap.Time("cutoff", cutoff))
+	}
+	return nil
+}
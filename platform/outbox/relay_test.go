@@ -0,0 +1,205 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/outboxevent"
+	"msgcenter/platform/mq"
+	"msgcenter/platform/outbox"
+	"msgcenter/testkit"
+)
+
+// flakyBroker 发布到 fail 中的主题时返回错误
+type flakyBroker struct {
+	*mq.Memory
+	fail map[string]bool
+}
+
+func (b *flakyBroker) Publish(ctx context.Context, msg *mq.Message) (string, error) {
+	if b.fail[msg.Topic] {
+		return "", errors.New("broker unavailable")
+	}
+	return b.Memory.Publish(ctx, msg)
+}
+
+type relayEnv struct {
+	client   *gen.Client
+	broker   *flakyBroker
+	consumer mq.Consumer
+}
+
+func newRelayEnv(t *testing.T) *relayEnv {
+	t.Helper()
+	broker := &flakyBroker{Memory: mq.NewMemory(), fail: map[string]bool{}}
+	t.Cleanup(func() { _ = broker.Close() })
+	consumer, err := broker.Subscribe(context.Background(), mq.SubscribeOptions{Topic: "t", Subscription: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &relayEnv{client: testkit.NewEntClient(t), broker: broker, consumer: consumer}
+}
+
+func (e *relayEnv) relay(opts outbox.Options) *outbox.Relay {
+	return outbox.NewRelay(
+		func() *gen.Client { return e.client },
+		func() mq.Broker { return e.broker },
+		opts,
+		zap.NewNop(),
+	)
+}
+
+func (e *relayEnv) add(t *testing.T, events ...outbox.Event) {
+	t.Helper()
+	if err := outbox.Add(context.Background(), e.client, events...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// received 接收当前已投递的全部消息的内容
+func (e *relayEnv) received(t *testing.T) []string {
+	t.Helper()
+	var got []string
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		msg, err := e.consumer.Receive(ctx)
+		cancel()
+		if err != nil {
+			return got
+		}
+		_ = e.consumer.Ack(msg)
+		got = append(got, string(msg.Payload))
+	}
+}
+
+func event(topic, payload string) outbox.Event {
+	return outbox.Event{Topic: topic, Key: "k", Payload: []byte(payload), Aggregate: "Test", AggregateID: payload}
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	ctx := context.Background()
+	env := newRelayEnv(t)
+	env.add(t, event("t", "1"), event("t", "2"), event("t", "3"))
+
+	r := env.relay(outbox.Options{BatchSize: 2})
+	if n, err := r.RelayOnce(ctx); err != nil || n != 2 {
+		t.Fatalf("RelayOnce = %d, %v, want 2", n, err)
+	}
+	if n, err := r.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("RelayOnce = %d, %v, want 1", n, err)
+	}
+	if n, _ := r.RelayOnce(ctx); n != 0 {
+		t.Fatalf("已发布的事件被重复发布: %d", n)
+	}
+
+	got := env.received(t)
+	if len(got) != 3 || got[0] != "1" || got[1] != "2" || got[2] != "3" {
+		t.Fatalf("发布顺序 = %v, want [1 2 3]", got)
+	}
+	for _, ev := range env.client.OutboxEvent.Query().AllX(ctx) {
+		if ev.SentTime == nil || ev.Attempts != 1 {
+			t.Fatalf("事件 %d sent_time = %v, attempts = %d", ev.ID, ev.SentTime, ev.Attempts)
+		}
+	}
+}
+
+func TestRelayStopsOnFailure(t *testing.T) {
+	ctx := context.Background()
+	env := newRelayEnv(t)
+	env.broker.fail["bad"] = true
+	env.add(t, event("t", "1"), event("bad", "2"), event("t", "3"))
+
+	r := env.relay(outbox.Options{MaxAttempts: 5})
+	n, err := r.RelayOnce(ctx)
+	if err == nil || n != 1 {
+		t.Fatalf("RelayOnce = %d, %v, want 1 和错误", n, err)
+	}
+	// 失败事件之后的事件等下一轮, 不越过失败事件发布
+	if got := env.received(t); len(got) != 1 || got[0] != "1" {
+		t.Fatalf("已发布 = %v, want [1]", got)
+	}
+
+	failed := env.client.OutboxEvent.Query().Order(outboxevent.ByID()).AllX(ctx)[1]
+	if failed.Attempts != 1 || failed.LastError == "" || failed.FailedTime != nil {
+		t.Fatalf("失败事件 attempts = %d, last_error = %q, failed_time = %v", failed.Attempts, failed.LastError, failed.FailedTime)
+	}
+
+	// 恢复后按原顺序继续发布
+	delete(env.broker.fail, "bad")
+	env.consumer, _ = env.broker.Subscribe(ctx, mq.SubscribeOptions{Topic: "bad", Subscription: "s"})
+	if n, err := r.RelayOnce(ctx); err != nil || n != 2 {
+		t.Fatalf("恢复后 RelayOnce = %d, %v, want 2", n, err)
+	}
+}
+
+func TestRelayMarksFailedAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	env := newRelayEnv(t)
+	env.broker.fail["bad"] = true
+	env.add(t, event("bad", "1"), event("t", "2"))
+
+	r := env.relay(outbox.Options{MaxAttempts: 3})
+	for i := range 2 {
+		if n, err := r.RelayOnce(ctx); err == nil || n != 0 {
+			t.Fatalf("第 %d 次 RelayOnce = %d, %v, want 0 和错误", i+1, n, err)
+		}
+	}
+	// 第3次失败后标记失败并跳过, 后续事件在同一轮发布
+	if n, err := r.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("RelayOnce = %d, %v, want 1", n, err)
+	}
+	if got := env.received(t); len(got) != 1 || got[0] != "2" {
+		t.Fatalf("已发布 = %v, want [2]", got)
+	}
+
+	events := env.client.OutboxEvent.Query().Order(outboxevent.ByID()).AllX(ctx)
+	if events[0].FailedTime == nil || events[0].Attempts != 3 || events[0].SentTime != nil {
+		t.Fatalf("失败事件 failed_time = %v, attempts = %d", events[0].FailedTime, events[0].Attempts)
+	}
+	if n, err := r.RelayOnce(ctx); err != nil || n != 0 {
+		t.Fatalf("标记失败的事件不应再发布: %d, %v", n, err)
+	}
+}
+
+func TestRelayCommitGrace(t *testing.T) {
+	ctx := context.Background()
+	env := newRelayEnv(t)
+	env.add(t, event("t", "1"))
+
+	r := env.relay(outbox.Options{CommitGrace: 200 * time.Millisecond})
+	if n, _ := r.RelayOnce(ctx); n != 0 {
+		t.Fatalf("等待期内的事件不应发布: %d", n)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if n, err := r.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("RelayOnce = %d, %v, want 1", n, err)
+	}
+}
+
+func TestRelayHeaders(t *testing.T) {
+	ctx := context.Background()
+	env := newRelayEnv(t)
+	env.add(t, event("t", "1"))
+	if _, err := env.relay(outbox.Options{}).RelayOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	ev := env.client.OutboxEvent.Query().OnlyX(ctx)
+	rctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	msg, err := env.consumer.Receive(rctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Properties[outbox.HeaderEventID] != strconv.FormatInt(ev.ID, 10) ||
+		msg.Properties[outbox.HeaderAggregate] != "Test" ||
+		msg.Properties[outbox.HeaderAggregateID] != "1" || msg.Key != "k" {
+		t.Fatalf("消息头 = %v, key = %q", msg.Properties, msg.Key)
+	}
+}
@@ -0,0 +1,88 @@
+package redisx
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// limiterScript GCRA 令牌桶: KEYS[1] 保存理论到达时间(微秒), ARGV[1] 为本次消耗的微秒数,
+// ARGV[2] 为允许的突发窗口. 以 redis 时间为准, 返回需要等待的微秒数
+var limiterScript = redis.NewScript(`
+local now = redis.call("TIME")
+now = tonumber(now[1]) * 1000000 + tonumber(now[2])
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+tat = tat + tonumber(ARGV[1])
+redis.call("SET", KEYS[1], string.format("%.0f", tat), "PX", math.ceil((tat - now) / 1000) + 1000)
+local wait = tat - now - tonumber(ARGV[2])
+if wait < 0 then
+	return 0
+end
+return wait`)
+
+// Limiter 基于 redis 的令牌桶, 使用同一个键的所有实例共享速率, 允许一秒的突发.
+// redis 未配置时退化为进程内限速
+type Limiter struct {
+	rdb  func() *redis.Client
+	key  string
+	rate int // 每秒令牌数
+
+	mu  sync.Mutex
+	tat time.Time // 进程内限速的理论到达时间
+}
+
+func NewLimiter(rdb func() *redis.Client, key string, rate int) *Limiter {
+	return &Limiter{rdb: rdb, key: key, rate: rate}
+}
+
+// Wait 取得 n 个令牌, 不足时等待. rate 不大于 0 时不限速
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	if l.rate <= 0 || n <= 0 {
+		return nil
+	}
+	d, err := l.reserve(ctx, n)
+	if err != nil || d <= 0 {
+		return err
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve 预留 n 个令牌, 返回需要等待的时间
+func (l *Limiter) reserve(ctx context.Context, n int) (time.Duration, error) {
+	cost := time.Duration(n) * time.Second / time.Duration(l.rate)
+	var rdb *redis.Client
+	if l.rdb != nil {
+		rdb = l.rdb()
+	}
+	if rdb == nil {
+		return l.reserveLocal(cost), nil
+	}
+	us, err := limiterScript.Run(ctx, rdb, []string{l.key}, cost.Microseconds(), time.Second.Microseconds()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(us) * time.Microsecond, nil
+}
+
+func (l *Limiter) reserveLocal(cost time.Duration) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.tat.Before(now) {
+		l.tat = now
+	}
+	l.tat = l.tat.Add(cost)
+	return l.tat.Sub(now) - time.Second
+}
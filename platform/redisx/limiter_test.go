@@ -0,0 +1,77 @@
+package redisx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"msgcenter/platform/redisx"
+)
+
+func newRedis(t *testing.T) func() *redis.Client {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return func() *redis.Client { return rdb }
+}
+
+// elapsed 返回 fn 的耗时
+func elapsed(t *testing.T, fn func() error) time.Duration {
+	t.Helper()
+	start := time.Now()
+	if err := fn(); err != nil {
+		t.Fatal(err)
+	}
+	return time.Since(start)
+}
+
+func TestLimiterSharedAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	rdb := newRedis(t)
+	a := redisx.NewLimiter(rdb, "limit", 100)
+	b := redisx.NewLimiter(rdb, "limit", 100)
+
+	// 一秒的突发额度由两个实例共同消耗
+	if d := elapsed(t, func() error { return a.Wait(ctx, 60) }); d > 100*time.Millisecond {
+		t.Fatalf("突发额度内等待了 %v", d)
+	}
+	if d := elapsed(t, func() error { return b.Wait(ctx, 40) }); d > 100*time.Millisecond {
+		t.Fatalf("突发额度内等待了 %v", d)
+	}
+	if d := elapsed(t, func() error { return b.Wait(ctx, 30) }); d < 200*time.Millisecond {
+		t.Fatalf("超出共享额度后只等待了 %v, want 约 300ms", d)
+	}
+
+	// 其他键不受影响
+	other := redisx.NewLimiter(rdb, "other", 100)
+	if d := elapsed(t, func() error { return other.Wait(ctx, 100) }); d > 100*time.Millisecond {
+		t.Fatalf("其他键等待了 %v", d)
+	}
+}
+
+func TestLimiterLocalFallback(t *testing.T) {
+	ctx := context.Background()
+	l := redisx.NewLimiter(func() *redis.Client { return nil }, "limit", 100)
+	if d := elapsed(t, func() error { return l.Wait(ctx, 100) }); d > 100*time.Millisecond {
+		t.Fatalf("突发额度内等待了 %v", d)
+	}
+	if d := elapsed(t, func() error { return l.Wait(ctx, 30) }); d < 200*time.Millisecond {
+		t.Fatalf("超出额度后只等待了 %v, want 约 300ms", d)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l := redisx.NewLimiter(newRedis(t), "limit", 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, 100); err != context.DeadlineExceeded {
+		t.Fatalf("Wait err = %v, want DeadlineExceeded", err)
+	}
+	if err := redisx.NewLimiter(nil, "limit", 0).Wait(ctx, 100); err != nil {
+		t.Fatalf("不限速时 Wait err = %v", err)
+	}
+}
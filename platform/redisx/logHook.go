@@ -0,0 +1,71 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"msgcenter/utils/logger"
+	"net"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// LogHook 记录redis命令日志, 附带调用方上下文中的请求ID
+type LogHook struct {
+	logger *zap.Logger
+}
+
+func NewLogHook(logger *zap.Logger) *LogHook {
+	return &LogHook{logger: logger}
+}
+
+func (h *LogHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil {
+			logger.With(ctx, h.logger).Warn("redis连接失败",
+				zap.String("addr", addr),
+				zap.Error(err),
+			)
+		}
+		return conn, err
+	}
+}
+
+func (h *LogHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.log(ctx, cmd.FullName(), 1, start, err)
+		return err
+	}
+}
+
+func (h *LogHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.log(ctx, "pipeline", len(cmds), start, err)
+		return err
+	}
+}
+
+func (h *LogHook) log(ctx context.Context, name string, count int, start time.Time, err error) {
+	if err != nil && !errors.Is(err, redis.Nil) {
+		logger.With(ctx, h.logger).Warn("redis命令执行失败",
+			zap.String("cmd", name),
+			zap.Int("count", count),
+			zap.Duration("latency", time.Since(start)),
+			zap.Error(err),
+		)
+		return
+	}
+	if h.logger.Core().Enabled(zap.DebugLevel) {
+		logger.With(ctx, h.logger).Debug("redis命令执行",
+			zap.String("cmd", name),
+			zap.Int("count", count),
+			zap.Duration("latency", time.Since(start)),
+		)
+	}
+}
@@ -0,0 +1,67 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"msgcenter/platform/tracing"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingHook 为redis命令创建span
+type TracingHook struct{}
+
+func NewTracingHook() *TracingHook {
+	return &TracingHook{}
+}
+
+func (h *TracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, span := tracing.Start(ctx, "redis.dial", trace.WithSpanKind(trace.SpanKindClient))
+		conn, err := next(ctx, network, addr)
+		tracing.End(span, err)
+		return conn, err
+	}
+}
+
+func (h *TracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := tracing.Start(ctx, "redis."+cmd.FullName(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemRedis,
+				semconv.DBOperationName(cmd.FullName()),
+			),
+		)
+		err := next(ctx, cmd)
+		tracing.End(span, ignoreNil(err))
+		return err
+	}
+}
+
+func (h *TracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := tracing.Start(ctx, "redis.pipeline",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemRedis,
+				attribute.Int("db.redis.pipeline_size", len(cmds)),
+			),
+		)
+		err := next(ctx, cmds)
+		tracing.End(span, ignoreNil(err))
+		return err
+	}
+}
+
+// ignoreNil redis.Nil 表示键不存在, 不作为错误记录
+func ignoreNil(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"msgcenter/config"
+)
+
+func ratio(r float64) *float64 { return &r }
+
+// sampledParent 返回带有已采样的远程父 span 的 context
+func sampledParent() context.Context {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	return trace.ContextWithRemoteSpanContext(context.Background(), sc)
+}
+
+func decide(t *testing.T, r *float64, ctx context.Context) sdktrace.SamplingDecision {
+	t.Helper()
+	sampler, err := newSampler(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sampler.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: ctx,
+		TraceID:       trace.TraceID{2},
+		Name:          "test",
+	}).Decision
+}
+
+func TestSampler(t *testing.T) {
+	tests := []struct {
+		name  string
+		ratio *float64
+		ctx   context.Context
+		want  sdktrace.SamplingDecision
+	}{
+		{"未配置时全部采样", nil, context.Background(), sdktrace.RecordAndSample},
+		{"采样率为1", ratio(1), context.Background(), sdktrace.RecordAndSample},
+		{"采样率为0不采样", ratio(0), context.Background(), sdktrace.Drop},
+		{"采样率为0时忽略上游", ratio(0), sampledParent(), sdktrace.Drop},
+		{"按比例采样时沿用上游", ratio(0.0001), sampledParent(), sdktrace.RecordAndSample},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decide(t, tt.ratio, tt.ctx); got != tt.want {
+				t.Fatalf("decision = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSamplerRejectsOutOfRange(t *testing.T) {
+	for _, r := range []float64{-0.1, 1.5} {
+		if _, err := newSampler(ratio(r)); err == nil {
+			t.Fatalf("采样率 %v 应返回错误", r)
+		}
+	}
+}
+
+func TestInitWithoutExporter(t *testing.T) {
+	for _, exporter := range []string{"", ExporterNone} {
+		shutdown, err := Init(config.TraceConfig{Exporter: exporter}, "msgcenter", "test")
+		if err != nil {
+			t.Fatalf("exporter %q: %v", exporter, err)
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Init(config.TraceConfig{Exporter: "zipkin"}, "msgcenter", "test"); err == nil {
+		t.Fatal("不支持的导出器应返回错误")
+	}
+}
@@ -0,0 +1,301 @@
+package ws
+
+import (
+	"context"
+	"msgcenter/platform/metrics"
+	"msgcenter/platform/tracing"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// sendBufferSize 单个连接的待发送消息缓冲
+const sendBufferSize = 256
+
+// reconnectNotice 实例下线时通知客户端重连到其他实例
+var reconnectNotice = []byte(`{"type":"reconnect","reason":"server_shutdown"}`)
+
+// Conn 单个设备的WebSocket连接
+type Conn struct {
+	UserID   string
+	DeviceID string
+
+	socket    *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewConn(userID, deviceID string, socket *websocket.Conn) *Conn {
+	return &Conn{
+		UserID:   userID,
+		DeviceID: deviceID,
+		socket:   socket,
+		send:     make(chan []byte, sendBufferSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// Send 将消息放入发送队列, 队列已满或连接已关闭时返回false
+func (c *Conn) Send(payload []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- payload:
+		return true
+	default:
+		return false
+	}
+}
+
+// WritePump 串行写出发送队列中的消息, 直到连接关闭
+func (c *Conn) WritePump() {
+	for {
+		select {
+		case payload := <-c.send:
+			if err := c.socket.WriteMessage(websocket.TextMessage, payload); err != nil {
+				c.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Pending 发送队列中尚未写出的消息数
+func (c *Conn) Pending() int {
+	return len(c.send)
+}
+
+// GoAway 发送 1001 关闭帧后关闭连接, 客户端据此重连其他实例
+func (c *Conn) GoAway(reason string) {
+	_ = c.socket.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseGoingAway, reason),
+		time.Now().Add(time.Second),
+	)
+	c.Close()
+}
+
+// Done 连接关闭后返回的channel会被关闭
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Conn) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		_ = c.socket.Close()
+	})
+}
+
+// Hub 管理本实例上所有在线设备的连接
+type Hub struct {
+	mu      sync.RWMutex
+	conns   map[string]*Conn            // deviceID -> 连接
+	users   map[string]map[string]*Conn // userID -> deviceID -> 连接
+	closing bool
+	logger  *zap.Logger
+}
+
+func NewHub(logger *zap.Logger) *Hub {
+	return &Hub{
+		conns:  make(map[string]*Conn),
+		users:  make(map[string]map[string]*Conn),
+		logger: logger,
+	}
+}
+
+// Register 登记设备连接, 同一设备的旧连接会被关闭, 实例下线中返回false
+func (h *Hub) Register(c *Conn) bool {
+	h.mu.Lock()
+	if h.closing {
+		h.mu.Unlock()
+		c.GoAway("server shutdown")
+		return false
+	}
+	old, exists := h.conns[c.DeviceID]
+	h.conns[c.DeviceID] = c
+	if exists {
+		h.removeUser(old)
+	} else {
+		metrics.WSConnections.Inc()
+	}
+	if c.UserID != "" {
+		if h.users[c.UserID] == nil {
+			h.users[c.UserID] = make(map[string]*Conn)
+		}
+		h.users[c.UserID][c.DeviceID] = c
+	}
+	h.mu.Unlock()
+
+	if exists {
+		old.Close()
+		h.logger.Info("设备重复连接, 关闭旧连接",
+			zap.String("device_id", c.DeviceID),
+		)
+	}
+	h.logger.Debug("设备已连接",
+		zap.String("user_id", c.UserID),
+		zap.String("device_id", c.DeviceID),
+	)
+	return true
+}
+
+// Unregister 移除设备连接, 连接已被新连接替换时不做处理
+func (h *Hub) Unregister(c *Conn) {
+	h.mu.Lock()
+	if h.conns[c.DeviceID] == c {
+		delete(h.conns, c.DeviceID)
+		h.removeUser(c)
+		metrics.WSConnections.Dec()
+	}
+	h.mu.Unlock()
+
+	c.Close()
+	h.logger.Debug("设备已断开",
+		zap.String("user_id", c.UserID),
+		zap.String("device_id", c.DeviceID),
+	)
+}
+
+func (h *Hub) removeUser(c *Conn) {
+	if devices, ok := h.users[c.UserID]; ok && devices[c.DeviceID] == c {
+		delete(devices, c.DeviceID)
+		if len(devices) == 0 {
+			delete(h.users, c.UserID)
+		}
+	}
+}
+
+// SendToDevice 向在线设备发送消息, 设备不在线或发送队列已满时返回false
+func (h *Hub) SendToDevice(ctx context.Context, deviceID string, payload []byte) bool {
+	_, span := tracing.Start(ctx, "ws.deliver", trace.WithAttributes(
+		attribute.String("device_id", deviceID),
+		attribute.Int("payload_size", len(payload)),
+	))
+	defer span.End()
+
+	h.mu.RLock()
+	c, ok := h.conns[deviceID]
+	h.mu.RUnlock()
+	sent := ok && c.Send(payload)
+	span.SetAttributes(attribute.Bool("delivered", sent))
+	return sent
+}
+
+// SendToUser 向用户的所有在线设备发送消息, 返回成功投递的设备数
+func (h *Hub) SendToUser(ctx context.Context, userID string, payload []byte) int {
+	_, span := tracing.Start(ctx, "ws.fanout", trace.WithAttributes(
+		attribute.String("user_id", userID),
+		attribute.Int("payload_size", len(payload)),
+	))
+	defer span.End()
+
+	h.mu.RLock()
+	conns := make([]*Conn, 0, len(h.users[userID]))
+	for _, c := range h.users[userID] {
+		conns = append(conns, c)
+	}
+	h.mu.RUnlock()
+
+	sent := 0
+	for _, c := range conns {
+		if c.Send(payload) {
+			sent++
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("devices", len(conns)),
+		attribute.Int("delivered", sent),
+	)
+	return sent
+}
+
+// Online 设备是否在本实例上在线
+func (h *Hub) Online(deviceID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.conns[deviceID]
+	return ok
+}
+
+// Users 本实例上有在线设备的用户
+func (h *Hub) Users() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	users := make([]string, 0, len(h.users))
+	for userID := range h.users {
+		users = append(users, userID)
+	}
+	return users
+}
+
+// Devices 用户在本实例上的在线设备
+func (h *Hub) Devices(userID string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	devices := make([]string, 0, len(h.users[userID]))
+	for deviceID := range h.users[userID] {
+		devices = append(devices, deviceID)
+	}
+	return devices
+}
+
+// Count 当前连接数
+func (h *Hub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.conns)
+}
+
+// Shutdown 通知所有客户端重连其他实例, 在截止时间前等待发送队列写完, 然后关闭连接
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	conns := make([]*Conn, 0, len(h.conns))
+	for _, c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+
+	h.logger.Info("通知客户端重连", zap.Int("connections", len(conns)))
+	for _, c := range conns {
+		c.Send(reconnectNotice)
+	}
+
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+
+	var err error
+wait:
+	for {
+		pending := 0
+		for _, c := range conns {
+			pending += c.Pending()
+		}
+		if pending == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			h.logger.Warn("等待消息发送超时, 强制关闭连接", zap.Int("pending", pending))
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	for _, c := range conns {
+		c.GoAway("server shutdown")
+	}
+	return err
+}
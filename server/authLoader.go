@@ -0,0 +1,38 @@
+package server
+
+import (
+	"github.com/bytedance/sonic"
+	"go.uber.org/zap"
+	"msgcenter/platform/auth"
+	"msgcenter/platform/consul"
+	consulconfig "msgcenter/platform/consul/config"
+)
+
+// loadAuth 读取consul中的令牌密钥, 并在密钥轮换时替换签名器.
+// 未配置密钥时需要鉴权的接口一律拒绝
+func (s *Server) loadAuth() {
+	if cfg, err := s.Consul.GetAuth(); err != nil {
+		s.Logger.Warn("未配置令牌密钥, 需要鉴权的接口将拒绝访问", zap.Error(err))
+	} else {
+		s.applyAuth(cfg)
+	}
+
+	s.Consul.RegisterCallback(consul.Auth, func(oldData, newData []byte) {
+		var cfg consulconfig.Auth
+		if err := sonic.Unmarshal(newData, &cfg); err != nil {
+			s.Logger.Error("解析鉴权配置失败", zap.Error(err))
+			return
+		}
+		s.applyAuth(cfg)
+	})
+}
+
+func (s *Server) applyAuth(cfg consulconfig.Auth) {
+	signer, err := auth.NewSigner(cfg.Secret)
+	if err != nil {
+		s.Logger.Error("应用鉴权配置失败", zap.Error(err))
+		return
+	}
+	s.Service.SetAuth(signer)
+	s.Logger.Info("令牌密钥已更新")
+}
@@ -1,16 +1,26 @@
 package server
 
 import (
+	"go.uber.org/zap"
+	"msgcenter/platform/consul/config"
 	"msgcenter/utils/banner"
 	"os"
 )
 
 func (s *Server) loadBanner() {
-	bannerCfg := s.Consul.GetBanner()
+	bannerCfg := config.Banner{AppName: s.Config().Consul.Service.Name}
+	if s.Consul != nil {
+		cfg, err := s.Consul.GetBanner()
+		if err != nil {
+			s.Logger.Warn("获取Banner失败, 使用默认Banner", zap.Error(err))
+		} else {
+			bannerCfg = cfg
+		}
+	}
 	routes := s.App.GetRoutes()
 	routeUrls := make([]string, 0)
 	for _, route := range routes {
 		routeUrls = append(routeUrls, route.Path+"-"+route.Method+"("+route.Name+")")
 	}
-	banner.Show(bannerCfg.AppName, bannerCfg.Flag, s.LocalConfig.IP, os.Getpid(), routeUrls, s.Logger)
+	banner.Show(bannerCfg.AppName, bannerCfg.Flag, s.Config().IP, os.Getpid(), routeUrls, s.Logger)
 }
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"msgcenter/platform/lifecycle"
+	"msgcenter/platform/ws"
+	"msgcenter/utils/logger"
+)
+
+// 内置组件名称, 可通过 WithComponent 注册同名组件替换
+const (
+	ComponentTracing   = "tracing"
+	ComponentConsul    = "consul"
+	ComponentRedis     = "redis"
+	ComponentPostgres  = "postgres"
+	ComponentWS        = "ws"
+	ComponentHTTP      = "http"
+	ComponentPurge     = "purge"
+	ComponentNode      = "node"
+	ComponentMQ        = "mq"
+	ComponentOutbox    = "outbox"
+	ComponentDelivery  = "delivery"
+	ComponentSchedule  = "schedule"
+	ComponentRetention = "retention"
+)
+
+// components 返回内置组件, 启动顺序由依赖关系决定
+func (s *Server) components() []lifecycle.Component {
+	return []lifecycle.Component{
+		&lifecycle.Func{
+			ComponentName: ComponentTracing,
+			OnStart:       s.tracingLoader,
+			OnStop:        s.ShutdownTracing,
+		},
+		&lifecycle.Func{
+			ComponentName: ComponentConsul,
+			OnStart:       s.consulLoader,
+			OnStop:        s.closeConsul,
+			OnHealth: func(ctx context.Context) error {
+				return s.Consul.Ping(ctx)
+			},
+		},
+		&lifecycle.Func{
+			ComponentName: ComponentNode,
+			Deps:          []string{ComponentConsul},
+			OnStart:       s.nodeLoader,
+			OnStop:        s.releaseNode,
+		},
+		&lifecycle.Func{
+			ComponentName: ComponentRedis,
+			Deps:          []string{ComponentConsul},
+			OnStart: func(ctx context.Context) error {
+				if err := s.redisLoader(ctx); err != nil {
+					return err
+				}
+				s.registerRedisClientUpdate()
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				return s.CloseRedis()
+			},
+			OnHealth: func(ctx context.Context) error {
+				if s.RedisClient == nil {
+					return errors.New("redis未初始化")
+				}
+				return s.RedisClient.Ping(ctx).Err()
+			},
+		},
+		&lifecycle.Func{
+			ComponentName: ComponentPostgres,
+			Deps:          []string{ComponentConsul},
+			OnStart: func(ctx context.Context) error {
+				if err := s.dbLoader(ctx); err != nil {
+					return err
+				}
+				s.registerDbClientUpdate()
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				return s.CloseDb()
+			},
+			OnHealth: func(ctx context.Context) error {
+				if s.sqlDB == nil {
+					return errors.New("数据库未初始化")
+				}
+				return s.sqlDB.PingContext(ctx)
+			},
+		},
+		&lifecycle.Func{
+			ComponentName: ComponentMQ,
+			Deps:          []string{ComponentConsul},
+			OnStart:       s.mqLoader,
+			OnStop: func(context.Context) error {
+				return s.closeMQ()
+			},
+		},
+		&lifecycle.Func{
+			ComponentName: ComponentWS,
+			OnStart: func(context.Context) error {
+				s.Hub = ws.NewHub(logger.Named(logger.WS))
+				s.Service.SetHub(s.Hub)
+				return nil
+			},
+			// 通知客户端重连并排空待投递消息
+			OnStop: func(ctx context.Context) error {
+				return s.Hub.Shutdown(ctx)
+			},
+		},
+		&lifecycle.Func{
+			ComponentName: ComponentPurge,
+			Deps:          []string{ComponentPostgres},
+			OnStart:       s.purgeLoader,
+			OnStop:        s.stopPurge,
+		},
+		&lifecycle.Func{
+			ComponentName: ComponentOutbox,
+			Deps:          []string{ComponentConsul, ComponentPostgres, ComponentMQ},
+			OnStart:       s.outboxLoader,
+			OnStop:        s.stopOutbox,
+		},
+		&lifecycle.Func{
+			ComponentName: ComponentDelivery,
+			Deps:          []string{ComponentRedis, ComponentPostgres, ComponentMQ, ComponentWS},
+			OnStart:       s.deliveryLoader,
+			OnStop:        s.stopDelivery,
+		},
+		&lifecycle.Func{
+			ComponentName: ComponentSchedule,
+			Deps:          []string{ComponentConsul, ComponentRedis, ComponentPostgres, ComponentMQ},
+			OnStart:       s.scheduleLoader,
+			OnStop:        s.stopSchedule,
+		},
+		&lifecycle.Func{
+			ComponentName: ComponentRetention,
+			Deps:          []string{ComponentConsul, ComponentPostgres},
+			OnStart:       s.retentionLoader,
+			OnStop:        s.stopRetention,
+		},
+		&lifecycle.Func{
+			ComponentName: ComponentHTTP,
+			Deps:          []string{ComponentConsul, ComponentNode, ComponentRedis, ComponentPostgres, ComponentMQ, ComponentWS},
+			OnStart:       s.httpLoader,
+			OnStop:        s.closeHTTP,
+		},
+	}
+}
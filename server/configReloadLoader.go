@@ -0,0 +1,43 @@
+package server
+
+import (
+	"go.uber.org/zap"
+	"msgcenter/config"
+	"msgcenter/platform/consul"
+	"reflect"
+)
+
+// registerConfigReload 将本地配置热加载接入各子系统
+func (s *Server) registerConfigReload() {
+	config.RegisterReloadCallback(func(old, new *config.Config) {
+		changes := config.Diff(old, new)
+		if len(changes) == 0 {
+			return
+		}
+		s.Logger.Info("本地配置已更新", zap.Strings("changes", changes))
+
+		s.localConfig.Store(new)
+		s.Service.SetConfig(new)
+
+		if !reflect.DeepEqual(old.Log, new.Log) {
+			s.reloadLogger(old.Log, new.Log)
+		}
+
+		// consul组件未启动或已被替换时跳过
+		if s.Consul == nil {
+			return
+		}
+
+		if !reflect.DeepEqual(old.Consul.Services, new.Consul.Services) ||
+			!reflect.DeepEqual(old.Consul.Keys, new.Consul.Keys) {
+			s.Consul.StartDynamicWatch(consul.WatchConfig{
+				Services: new.Consul.Services,
+				Keys:     new.Consul.Keys,
+			})
+		}
+
+		if !reflect.DeepEqual(old.Consul.Service, new.Consul.Service) || old.IP != new.IP {
+			s.reRegisterConsul(old, new)
+		}
+	})
+}
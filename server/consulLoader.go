@@ -1,29 +1,57 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	consulapi "github.com/hashicorp/consul/api"
-	"log/slog"
+	"go.uber.org/zap"
+	"msgcenter/config"
 	"msgcenter/platform/consul"
+	"msgcenter/utils/logger"
 )
 
-func (s *Server) consulLoader() {
-	client, err := consul.NewClient(s.LocalConfig.Consul.Host, s.Logger)
+func (s *Server) consulLoader(ctx context.Context) error {
+	cfg := s.Config()
+	client, err := consul.NewClient(cfg.Consul.Host, logger.Named(logger.Consul))
 	if err != nil {
-		slog.Error("初始化consul失败", err)
-		panic(err)
+		return fmt.Errorf("初始化consul失败: %w", err)
+	}
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("连接consul失败: %w", err)
+	}
+
+	err = client.RegisterService(s.serviceRegistration(cfg))
+	if err != nil {
+		return fmt.Errorf("注册consul服务失败: %w", err)
 	}
 	s.Consul = client
+	s.Service.SetConsul(client)
 
-	err = s.Consul.RegisterService(&consulapi.AgentServiceRegistration{
-		ID:   s.LocalConfig.Consul.Service.ID,
-		Name: s.LocalConfig.Consul.Service.Name,
-		Port: s.LocalConfig.Consul.Service.Port,
-		Tags: s.LocalConfig.Consul.Service.Tags,
+	s.Logger.Info("注册consul服务成功", zap.Any("service", cfg.Consul.Service))
+
+	s.Consul.StartDynamicWatch(consul.WatchConfig{
+		Services: cfg.Consul.Services,
+		Keys:     cfg.Consul.Keys,
+	})
+
+	s.Logger.Info("开始监听consul服务", zap.Strings("services", cfg.Consul.Services))
+
+	s.loadLogLevel()
+	s.loadAuth()
+	return nil
+}
+
+func (s *Server) serviceRegistration(cfg *config.Config) *consulapi.AgentServiceRegistration {
+	return &consulapi.AgentServiceRegistration{
+		ID:   cfg.Consul.Service.ID,
+		Name: cfg.Consul.Service.Name,
+		Port: cfg.Consul.Service.Port,
+		Tags: cfg.Consul.Service.Tags,
 		Check: &consulapi.AgentServiceCheck{
-			HTTP:     "http://" + s.LocalConfig.IP + "/health", // 健康检查端点
-			Method:   "GET",                                    // 请求方法
-			Interval: "10s",                                    // 检查间隔
-			Timeout:  "3s",                                     // 超时时间
+			HTTP:     "http://" + cfg.IP + "/readyz", // 就绪检查端点, 依赖不可用或下线排空时返回503
+			Method:   "GET",                          // 请求方法
+			Interval: "10s",                          // 检查间隔
+			Timeout:  "3s",                           // 超时时间
 			Header: map[string][]string{ // 自定义请求头
 				"X-Consul-Check": {"true"},
 			},
@@ -32,27 +60,48 @@ func (s *Server) consulLoader() {
 			FailuresBeforeCritical: 3,                       // 连续失败次数标记为故障
 			Status:                 consulapi.HealthPassing, // 初始状态
 		},
-	})
-	if err != nil {
-		slog.Error("注册consul服务失败", err)
-		panic(err)
 	}
+}
 
-	slog.Info("注册consul服务成功", slog.Any("service", s.LocalConfig.Consul.Service))
-
-	s.Consul.StartDynamicWatch(consul.WatchConfig{
-		Services: s.LocalConfig.Consul.Services,
-		Keys:     s.LocalConfig.Consul.Keys,
-	})
+// reRegisterConsul 服务注册信息变化后重新注册
+func (s *Server) reRegisterConsul(old, new *config.Config) {
+	if old.Consul.Service.ID != new.Consul.Service.ID {
+		if err := s.Consul.DeregisterService(old.Consul.Service.ID); err != nil {
+			s.Logger.Warn("注销旧consul服务失败",
+				zap.String("serviceID", old.Consul.Service.ID),
+				zap.Error(err),
+			)
+		}
+	}
 
-	slog.Info("开始监听consul服务", slog.Any("services", s.LocalConfig.Consul.Services))
+	if err := s.Consul.RegisterService(s.serviceRegistration(new)); err != nil {
+		s.Logger.Error("重新注册consul服务失败",
+			zap.String("serviceID", new.Consul.Service.ID),
+			zap.Error(err),
+		)
+		return
+	}
+	s.Logger.Info("consul服务已重新注册", zap.Any("service", new.Consul.Service))
 }
 
+// DeregisterConsul 从consul注销本实例, 失败时只记录日志, 由consul健康检查摘除
 func (s *Server) DeregisterConsul() {
+	if s.Consul == nil {
+		return
+	}
+	serviceID := s.Config().Consul.Service.ID
+	if err := s.Consul.DeregisterService(serviceID); err != nil {
+		s.Logger.Warn("注销consul服务失败",
+			zap.String("serviceID", serviceID),
+			zap.Error(err),
+		)
+	}
+}
+
+// closeConsul 停止监控并注销仍在注册中的服务
+func (s *Server) closeConsul(context.Context) error {
 	if s.Consul != nil {
-		err := s.Consul.DeregisterService(s.LocalConfig.Consul.Service.ID)
-		if err != nil {
-			return
-		}
+		s.Consul.Close()
 	}
+	return nil
 }
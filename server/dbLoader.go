@@ -1,22 +1,28 @@
 package server
 
 import (
+	"context"
 	"entgo.io/ent/dialect/sql"
+	"fmt"
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 	"msgcenter/platform/consul"
+	consulconfig "msgcenter/platform/consul/config"
+	"msgcenter/platform/ent/driver"
 	"msgcenter/platform/ent/gen"
+	_ "msgcenter/platform/ent/gen/runtime" // 注册默认值、校验、hook和拦截器
+	"msgcenter/utils/logger"
 	"time"
 )
 
-func (s *Server) dbLoader() {
-	cfg := s.Consul.GetSqldb()
-	drv, err := sql.Open("postgres", cfg.Dsn())
+func (s *Server) dbLoader(ctx context.Context) error {
+	cfg, err := s.Consul.GetSqldb()
 	if err != nil {
-		s.Logger.Error("数据库连接失败",
-			zap.Error(err),
-		)
-		panic(err)
+		return err
+	}
+	drv, err := s.openDb(cfg)
+	if err != nil {
+		return fmt.Errorf("数据库连接失败: %w", err)
 	}
 
 	db := drv.DB()
@@ -40,27 +46,54 @@ func (s *Server) dbLoader() {
 	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
 	db.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime) * time.Second)
 
+	if err := db.PingContext(ctx); err != nil {
+		_ = drv.Close()
+		return fmt.Errorf("数据库连接失败: %w", err)
+	}
+	if s.Config().Migrate.Auto {
+		if err := s.autoMigrate(ctx, db, drv.Dialect()); err != nil {
+			_ = drv.Close()
+			return err
+		}
+	}
+	s.sqlDB = db
+
 	// 3. 创建 Ent 客户端
-	s.DbClient = gen.NewClient(gen.Driver(drv))
+	s.DbClient = gen.NewClient(gen.Driver(driver.New(drv, logger.Named(logger.SQL))))
+	s.Service.SetDbClient(s.DbClient)
+	return nil
 }
 
-func (s *Server) CloseDb() {
-	if s.DbClient != nil {
-		err := s.DbClient.Close()
-		if err != nil {
-			s.Logger.Error("关闭数据库连接失败",
-				zap.Error(err),
-			)
-			panic(err)
-		}
+// DbOpener 根据consul中的数据库配置创建连接, 默认连接postgres, 测试时可替换为SQLite
+type DbOpener func(cfg consulconfig.SqlDb) (*sql.Driver, error)
+
+func (s *Server) openDb(cfg consulconfig.SqlDb) (*sql.Driver, error) {
+	if s.dbOpener != nil {
+		return s.dbOpener(cfg)
 	}
+	return openPostgres(cfg)
+}
+
+func openPostgres(cfg consulconfig.SqlDb) (*sql.Driver, error) {
+	return sql.Open("postgres", cfg.Dsn())
+}
+
+func (s *Server) CloseDb() error {
+	if s.DbClient == nil {
+		return nil
+	}
+	return s.DbClient.Close()
 }
 
 func (s *Server) updateDbClient() {
-	if s.DbClient != nil {
-		s.CloseDb()
+	if err := s.CloseDb(); err != nil {
+		s.Logger.Error("关闭数据库连接失败",
+			zap.Error(err),
+		)
+	}
+	if err := s.dbLoader(context.Background()); err != nil {
+		s.Logger.Error("重新加载数据库失败", zap.Error(err))
 	}
-	s.dbLoader()
 }
 
 func (s *Server) registerDbClientUpdate() {
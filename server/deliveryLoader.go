@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"msgcenter/platform/dlq"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/mq"
+	"msgcenter/platform/ws"
+	"msgcenter/service/conversation"
+	"msgcenter/service/delivery"
+	"msgcenter/service/message"
+	"msgcenter/service/signal"
+	"msgcenter/service/topic"
+	"msgcenter/utils/logger"
+)
+
+// deliveryRetry 消费退出后重新订阅的间隔
+const deliveryRetry = 5 * time.Second
+
+// deliveryLoader 启动消息投递: 所有实例共享的收件箱写扩散、未读计数和主题广播扩散,
+// 以及每个实例独立的消息、已读回执和临时信号在线推送
+func (s *Server) deliveryLoader(context.Context) error {
+	log := logger.Named(logger.Delivery)
+	client := func() *gen.Client { return s.Service.DbClient() }
+	broker := func() mq.Broker { return s.Service.Broker() }
+	retry := mq.RetryPolicy{
+		MaxAttempts: 10,
+		Backoff:     time.Second,
+		MaxBackoff:  time.Minute,
+	}
+	deadLetter := dlq.NewStore(client, broker)
+
+	inbox := &mq.Worker{
+		Broker: s.Broker,
+		Subscription: mq.SubscribeOptions{
+			Topic:        message.TopicCreated,
+			Subscription: delivery.SubscriptionInbox,
+			Type:         mq.Shared,
+		},
+		Handler:    delivery.NewInbox(client, s.Config().Conversation.FanoutThreshold, log).Handle,
+		Retry:      retry,
+		DeadLetter: deadLetter,
+		Logger:     log,
+	}
+
+	unreadWorker := &mq.Worker{
+		Broker: s.Broker,
+		Subscription: mq.SubscribeOptions{
+			Topic:        message.TopicCreated,
+			Subscription: delivery.SubscriptionUnread,
+			Type:         mq.Shared,
+		},
+		Handler:    delivery.NewUnread(client, s.Service.RedisClient, log).Handle,
+		Retry:      retry,
+		DeadLetter: deadLetter,
+		Logger:     log,
+	}
+
+	topicCfg := s.Config().Topic
+	broadcaster := &mq.Worker{
+		Broker: s.Broker,
+		Subscription: mq.SubscribeOptions{
+			Topic:        topic.TopicBroadcast,
+			Subscription: delivery.SubscriptionBroadcast,
+			Type:         mq.Shared,
+		},
+		Handler:    delivery.NewBroadcaster(client, broker, s.Service.RedisClient, topicCfg.FanoutRate, topicCfg.BatchSize, log).Handle,
+		Retry:      retry,
+		DeadLetter: deadLetter,
+		Logger:     log,
+	}
+
+	instance := s.Config().Consul.Service.ID
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	hub := func() *ws.Hub { return s.Hub }
+	push := delivery.NewPush(client, hub, log)
+	receipts := delivery.NewReceipts(client, hub, s.Config().Conversation.FanoutThreshold, log)
+	signals := delivery.NewSignals(client, hub, log)
+	pushOpts := func(topic string) mq.SubscribeOptions {
+		return mq.SubscribeOptions{
+			Topic:        topic,
+			Subscription: delivery.PushSubscription(instance),
+			Type:         mq.Failover,
+			NonDurable:   true,
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	run := func(name string, fn func(ctx context.Context) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if err := fn(ctx); err != nil && ctx.Err() == nil {
+					log.Warn("消息投递消费退出, 稍后重新订阅", zap.String("consumer", name), zap.Error(err))
+				}
+				select {
+				case <-time.After(deliveryRetry):
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	run(delivery.SubscriptionInbox, inbox.Run)
+	run(delivery.SubscriptionUnread, unreadWorker.Run)
+	run(delivery.SubscriptionBroadcast, broadcaster.Run)
+	consume := func(opts mq.SubscribeOptions, handler mq.Handler) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			c, err := s.Broker.Subscribe(ctx, opts)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			return mq.Consume(ctx, c, log, handler)
+		}
+	}
+	run(delivery.PushSubscription(instance), consume(pushOpts(message.TopicCreated), push.Handle))
+	run(delivery.PushSubscription(instance)+"/"+conversation.TopicRead, consume(pushOpts(conversation.TopicRead), receipts.Handle))
+	run(signal.Channel, func(ctx context.Context) error {
+		return signals.Run(ctx, s.Service.RedisClient())
+	})
+
+	s.deliveryStop = func(stopCtx context.Context) error {
+		cancel()
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	}
+	s.Logger.Info("消息投递已启动", zap.String("push_subscription", delivery.PushSubscription(instance)))
+	return nil
+}
+
+func (s *Server) stopDelivery(ctx context.Context) error {
+	if s.deliveryStop == nil {
+		return nil
+	}
+	return s.deliveryStop(ctx)
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultLeaderTTL = 15 * time.Second
+
+// leaderKey 选举键, 同一服务的所有实例竞选同一个键
+func (s *Server) leaderKey(name string) string {
+	return fmt.Sprintf("service/%s/leader/%s", s.Config().Consul.Service.Name, name)
+}
+
+// runElected 在后台竞选 leader, 当选期间执行 fn; 返回的函数停止竞选并等待 fn 退出
+func (s *Server) runElected(name string, ttl time.Duration, fn func(ctx context.Context)) func(context.Context) error {
+	if ttl <= 0 {
+		ttl = defaultLeaderTTL
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Consul.Elect(ctx, s.leaderKey(name), ttl, fn)
+	}()
+
+	return func(stopCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	}
+}
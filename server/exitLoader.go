@@ -1,31 +1,44 @@
 package server
 
 import (
-	"github.com/tebeka/atexit"
-	"os"
-	"os/signal"
-	"syscall"
+	"context"
+	"go.uber.org/zap"
+	"time"
 )
 
-func ExitLoader() {
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+const (
+	defaultShutdownTimeout = 30 * time.Second
+	defaultDrainDelay      = 5 * time.Second
+)
 
-	go func() {
-		<-sigCh
-		atexit.Exit(1) // 触发钩子
-	}()
-	atexit.Register(cleanUp)
-}
+// Shutdown 按顺序下线: 标记未就绪 -> consul注销 -> 等待流量切走 -> 按启动相反顺序停止组件
+// (停止接收连接 -> 通知长连接客户端重连并排空待投递消息 -> 关闭数据库和redis -> 刷新链路追踪) -> 刷新日志
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.Health != nil {
+		s.Health.SetDraining(true)
+	}
+	s.Logger.Info("已标记为未就绪")
 
-func cleanUp() {
-	s := GetServer()
-	s.Logger.Info("服务已退出")
 	s.DeregisterConsul()
 	s.Logger.Info("服务已从consul注销")
-	s.CloseDb()
-	s.Logger.Info("数据库已关闭")
-	s.CloseRedis()
-	s.Logger.Info("redis已关闭")
+
+	// 等待consul及负载均衡感知实例下线, 期间仍正常处理请求
+	drainDelay := s.Config().Shutdown.DrainDelay
+	if drainDelay == 0 {
+		drainDelay = defaultDrainDelay
+	}
+	if drainDelay > 0 {
+		select {
+		case <-time.After(drainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	var err error
+	if s.Registry != nil {
+		err = s.Registry.Stop(ctx)
+	}
+	s.Logger.Info("服务已退出", zap.Error(err))
 	_ = s.Logger.Sync()
+	return err
 }
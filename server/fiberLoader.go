@@ -1,21 +1,55 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"github.com/bytedance/sonic"
 	"github.com/gofiber/fiber/v2"
 	"msgcenter/api"
+	"net"
 )
 
 func (s *Server) fiberLoader() {
 	s.App = fiber.New(fiber.Config{
 		AppName:               "msgcenter",
 		DisableStartupMessage: true,
+		JSONEncoder:           sonic.Marshal,
+		JSONDecoder:           sonic.Unmarshal,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			// 处理错误
-			return c.JSON(fiber.Map{
-				"code": 500,
+			// 处理错误, fiber.Error 保留其状态码
+			code := fiber.StatusInternalServerError
+			var e *fiber.Error
+			if errors.As(err, &e) {
+				code = e.Code
+			}
+			return c.Status(code).JSON(fiber.Map{
+				"code": code,
 				"msg":  err.Error(),
 			})
 		},
 	})
-	api.InitRouter(s.App)
+	api.InitRouter(s.App, s.Service)
+}
+
+// httpLoader 创建路由并开始监听, 监听端口失败时直接返回错误, 之后的异常通过 listenErr 通知
+func (s *Server) httpLoader(context.Context) error {
+	s.fiberLoader()
+
+	ln, err := net.Listen("tcp", s.Config().IP)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.App.Listener(ln); err != nil {
+			s.listenErr <- err
+		}
+	}()
+	return nil
+}
+
+func (s *Server) closeHTTP(ctx context.Context) error {
+	if s.App == nil {
+		return nil
+	}
+	return s.App.ShutdownWithContext(ctx)
 }
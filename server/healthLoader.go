@@ -0,0 +1,18 @@
+package server
+
+import (
+	"context"
+	"msgcenter/platform/health"
+	"time"
+)
+
+// healthLoader 为每个已注册组件注册就绪检查, 检查时读取当前连接, 配置热更新后无需重新注册
+func (s *Server) healthLoader() {
+	s.Health = health.NewChecker(2 * time.Second)
+
+	for _, name := range s.Registry.Names() {
+		s.Health.Register(name, func(ctx context.Context) error {
+			return s.Registry.Health(ctx, name)
+		})
+	}
+}
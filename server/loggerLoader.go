@@ -2,37 +2,67 @@ package server
 
 import (
 	"fmt"
+	"github.com/bytedance/sonic"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"log"
 	"log/slog"
+	"msgcenter/config"
+	"msgcenter/platform/consul"
+	consulconfig "msgcenter/platform/consul/config"
+	"msgcenter/utils/logger"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
 )
 
-func (s *Server) loadLogger() *Server {
+// rotateWriter 支持热更新轮转参数的日志写入器
+type rotateWriter struct {
+	mu     sync.Mutex
+	logger *lumberjack.Logger
+}
+
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.logger.Write(p)
+}
+
+func (w *rotateWriter) Sync() error {
+	return nil
+}
+
+// swap 替换底层的轮转写入器并关闭旧文件
+func (w *rotateWriter) swap(logger *lumberjack.Logger) {
+	w.mu.Lock()
+	old := w.logger
+	w.logger = logger
+	w.mu.Unlock()
+	_ = old.Close()
+}
+
+func (s *Server) loadLogger() error {
+	cfg := s.Config()
 	// 确保日志目录存在
-	if s.LocalConfig.Log.Dir != "" {
-		if err := os.MkdirAll(s.LocalConfig.Log.Dir, 0755); err != nil {
-			slog.Error("创建日志目录失败", "error", err, "dir", s.LocalConfig.Log.Dir)
-			panic(err)
+	if cfg.Log.Dir != "" {
+		if err := os.MkdirAll(cfg.Log.Dir, 0755); err != nil {
+			slog.Error("创建日志目录失败", "error", err, "dir", cfg.Log.Dir)
+			return fmt.Errorf("创建日志目录失败: %w", err)
 		}
 	}
 
 	// 配置日志轮转和归档
-	logFile := filepath.Join(s.LocalConfig.Log.Dir, "app.log")
+	logFile := filepath.Join(cfg.Log.Dir, "app.log")
 	logWriter, err := s.createLogWriter(logFile)
 	if err != nil {
 		slog.Error("创建日志写入器失败", "error", err)
-		panic(err)
+		return err
 	}
 
 	// 日志级别配置
-	logLevel := zap.InfoLevel
-	if s.LocalConfig.Log.Debug {
-		logLevel = zap.DebugLevel
-	}
+	logLevel := logLevelOf(cfg.Log)
 
 	// 编码器配置
 	encoderConfig := zapcore.EncoderConfig{
@@ -51,67 +81,146 @@ func (s *Server) loadLogger() *Server {
 	}
 
 	var encoder zapcore.Encoder
-	if s.LocalConfig.Env == "development" {
+	if cfg.Env == "development" {
 		encoderConfig.EncodeLevel = zapcore.LowercaseColorLevelEncoder
 		encoder = zapcore.NewConsoleEncoder(encoderConfig)
 	} else {
 		encoder = zapcore.NewJSONEncoder(encoderConfig)
 	}
 
-	// 创建核心
-	core := zapcore.NewCore(
+	// 创建日志管理器, 各子系统日志共用编码器和输出, 级别独立
+	s.LogManager = logger.New(
 		encoder,
 		zapcore.NewMultiWriteSyncer(
 			zapcore.AddSync(os.Stdout),
-			zapcore.AddSync(logWriter),
+			logWriter,
 		),
-		zap.NewAtomicLevelAt(logLevel),
-	)
-
-	// 构建Logger
-	logger := zap.New(
-		core,
+		logLevel,
+		samplingOf(cfg.Log),
 		zap.AddCaller(),
-		zap.AddCallerSkip(1),
 		zap.AddStacktrace(zap.ErrorLevel),
 	)
+	if err := s.LogManager.ApplyLevels(logger.SourceFile, logLevel.String(), cfg.Log.Modules); err != nil {
+		slog.Error("子系统日志级别配置错误", "error", err)
+		return err
+	}
+	logger.SetDefault(s.LogManager)
 
 	// 设置全局Logger
-	zap.ReplaceGlobals(logger)
-	s.Logger = logger
+	zapLogger := s.LogManager.Logger()
+	zap.ReplaceGlobals(zapLogger)
+	s.Logger = zapLogger
 
 	// 兼容标准库log
-	zap.RedirectStdLog(logger)
+	zap.RedirectStdLog(zapLogger)
 	log.SetFlags(0)
 
-	logger.Info("日志系统初始化完成",
-		zap.String("env", s.LocalConfig.Env),
+	zapLogger.Info("日志系统初始化完成",
+		zap.String("env", cfg.Env),
 		zap.String("log_level", logLevel.String()),
 		zap.String("log_file", logFile),
-		zap.Int("max_size", s.LocalConfig.Log.MaxSize),
-		zap.Int("max_backups", s.LocalConfig.Log.MaxBackups),
-		zap.Int("max_age", s.LocalConfig.Log.MaxAge),
-		zap.Bool("compress", s.LocalConfig.Log.Compress),
+		zap.Int("max_size", cfg.Log.MaxSize),
+		zap.Int("max_backups", cfg.Log.MaxBackups),
+		zap.Int("max_age", cfg.Log.MaxAge),
+		zap.Bool("compress", cfg.Log.Compress),
+		zap.Any("modules", cfg.Log.Modules),
 	)
 
-	return s
+	return nil
 }
 
 func (s *Server) createLogWriter(logFile string) (zapcore.WriteSyncer, error) {
+	rotateLogger, err := newRotateLogger(logFile, s.Config().Log)
+	if err != nil {
+		return nil, err
+	}
+	s.logWriter = &rotateWriter{logger: rotateLogger}
+	return s.logWriter, nil
+}
+
+func newRotateLogger(logFile string, cfg config.LogConfig) (*lumberjack.Logger, error) {
 	// 确保日志文件所在目录存在
 	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
 		return nil, fmt.Errorf("创建日志目录失败: %w", err)
 	}
 
 	// 配置日志轮转
-	rotateWriter := &lumberjack.Logger{
+	return &lumberjack.Logger{
 		Filename:   logFile,
-		MaxSize:    s.LocalConfig.Log.MaxSize,    // 单个日志文件最大大小(MB)
-		MaxBackups: s.LocalConfig.Log.MaxBackups, // 保留的旧日志文件最大数量
-		MaxAge:     s.LocalConfig.Log.MaxAge,     // 保留旧日志文件的最大天数
-		Compress:   s.LocalConfig.Log.Compress,   // 是否压缩归档日志
-		LocalTime:  true,                         // 使用本地时间
+		MaxSize:    cfg.MaxSize,    // 单个日志文件最大大小(MB)
+		MaxBackups: cfg.MaxBackups, // 保留的旧日志文件最大数量
+		MaxAge:     cfg.MaxAge,     // 保留旧日志文件的最大天数
+		Compress:   cfg.Compress,   // 是否压缩归档日志
+		LocalTime:  true,           // 使用本地时间
+	}, nil
+}
+
+func logLevelOf(cfg config.LogConfig) zapcore.Level {
+	if cfg.Debug {
+		return zap.DebugLevel
 	}
+	return zap.InfoLevel
+}
 
-	return zapcore.AddSync(rotateWriter), nil
+func samplingOf(cfg config.LogConfig) logger.SamplingConfig {
+	return logger.SamplingConfig{
+		Tick:       cfg.Sampling.Tick,
+		First:      cfg.Sampling.First,
+		Thereafter: cfg.Sampling.Thereafter,
+	}
+}
+
+// reloadLogger 热更新日志级别与轮转参数
+func (s *Server) reloadLogger(old, new config.LogConfig) {
+	if old.Debug != new.Debug || !reflect.DeepEqual(old.Modules, new.Modules) {
+		if err := s.LogManager.ApplyLevels(logger.SourceFile, logLevelOf(new).String(), new.Modules); err != nil {
+			s.Logger.Error("更新日志级别失败", zap.Error(err))
+		} else {
+			s.Logger.Info("日志级别已更新", zap.Any("levels", s.LogManager.Levels()))
+		}
+	}
+
+	if old.Dir == new.Dir && old.MaxSize == new.MaxSize && old.MaxBackups == new.MaxBackups &&
+		old.MaxAge == new.MaxAge && old.Compress == new.Compress {
+		return
+	}
+
+	logFile := filepath.Join(new.Dir, "app.log")
+	rotateLogger, err := newRotateLogger(logFile, new)
+	if err != nil {
+		s.Logger.Error("更新日志轮转配置失败", zap.Error(err))
+		return
+	}
+	s.logWriter.swap(rotateLogger)
+	s.Logger.Info("日志轮转配置已更新",
+		zap.String("log_file", logFile),
+		zap.Int("max_size", new.MaxSize),
+		zap.Int("max_backups", new.MaxBackups),
+		zap.Int("max_age", new.MaxAge),
+		zap.Bool("compress", new.Compress),
+	)
+}
+
+// loadLogLevel 应用consul中的运行时日志级别, 并监听其变化
+func (s *Server) loadLogLevel() {
+	if cfg, err := s.Consul.GetLog(); err == nil {
+		s.applyLogLevel(cfg)
+	}
+
+	s.Consul.RegisterCallback(consul.Log, func(oldData, newData []byte) {
+		var cfg consulconfig.Log
+		if err := sonic.Unmarshal(newData, &cfg); err != nil {
+			s.Logger.Error("解析日志级别配置失败", zap.Error(err))
+			return
+		}
+		s.applyLogLevel(cfg)
+	})
+}
+
+func (s *Server) applyLogLevel(cfg consulconfig.Log) {
+	if err := s.LogManager.ApplyLevels(logger.SourceConsul, cfg.Level, cfg.Modules); err != nil {
+		s.Logger.Error("应用日志级别配置失败", zap.Error(err))
+		return
+	}
+	s.Logger.Info("日志级别已更新", zap.Any("levels", s.LogManager.Levels()))
 }
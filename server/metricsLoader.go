@@ -0,0 +1,27 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"msgcenter/platform/metrics"
+)
+
+// metricsLoader 注册连接池指标, 采集时读取当前连接, 配置热更新后无需重新注册
+func (s *Server) metricsLoader() error {
+	collectors := []prometheus.Collector{
+		metrics.NewDBStatsCollector(func() *sql.DB { return s.sqlDB }),
+		metrics.NewRedisPoolCollector(func() *redis.Client { return s.RedisClient }),
+	}
+	for _, collector := range collectors {
+		if err := prometheus.Register(collector); err != nil {
+			// 同一进程内多次创建Server(如测试)时沿用已注册的指标
+			var are prometheus.AlreadyRegisteredError
+			if !errors.As(err, &are) {
+				return err
+			}
+		}
+	}
+	return nil
+}
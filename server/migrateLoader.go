@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"go.uber.org/zap"
+	"msgcenter/platform/consul"
+	"msgcenter/platform/migrate"
+	"msgcenter/utils/logger"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = `用法: msgcenter migrate <命令> [参数]
+
+命令:
+  up [n]               执行未执行的迁移, 不指定 n 时全部执行
+  down [n]             回滚已执行的迁移, 默认回滚一个版本
+  status               查看各版本的执行状态
+  force <version>      将 version 及之前的版本标记为已执行, 用于接管已有数据库
+  diff --dev-url <url> <name>
+                       根据 ent schema 生成新的迁移文件
+  hash                 手工修改迁移文件后重新计算 atlas.sum
+`
+
+// autoMigrate 启动时执行未执行的迁移, 多实例同时启动时由迁移锁保证只执行一次
+func (s *Server) autoMigrate(ctx context.Context, db *sql.DB, dialect string) error {
+	migrations, err := migrate.Migrations()
+	if err != nil {
+		return err
+	}
+	done, err := migrate.NewRunner(db, dialect, migrations, logger.Named(logger.SQL)).Up(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("自动迁移失败: %w", err)
+	}
+	s.Logger.Info("自动迁移完成", zap.Int("applied", len(done)))
+	return nil
+}
+
+// Migrate 执行 migrate 子命令, 数据库配置与服务启动时一样从consul读取
+func (s *Server) Migrate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.Usage = func() { _, _ = fmt.Fprint(fs.Output(), migrateUsage) }
+	dir := fs.String("dir", migrate.Dir, "迁移文件目录, 用于 diff/hash")
+	devURL := fs.String("dev-url", "", "diff 使用的开发库地址")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("缺少迁移命令")
+	}
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+
+	// diff/hash 只处理迁移文件, 不需要连接数据库
+	switch cmd {
+	case "diff":
+		if *devURL == "" || len(rest) != 1 {
+			fs.Usage()
+			return errors.New("diff 需要 --dev-url 和迁移名称")
+		}
+		return migrate.Diff(ctx, *dir, *devURL, rest[0])
+	case "hash":
+		return migrate.Hash(*dir)
+	}
+
+	runner, closeDb, err := s.migrateRunner(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDb()
+
+	switch cmd {
+	case "up":
+		n, err := stepsArg(rest)
+		if err != nil {
+			return err
+		}
+		done, err := runner.Up(ctx, n)
+		s.Logger.Info("迁移执行完成", zap.Int("applied", len(done)))
+		return err
+	case "down":
+		n, err := stepsArg(rest)
+		if err != nil {
+			return err
+		}
+		done, err := runner.Down(ctx, n)
+		s.Logger.Info("迁移回滚完成", zap.Int("reverted", len(done)))
+		return err
+	case "force":
+		if len(rest) != 1 {
+			return errors.New("force 需要版本号")
+		}
+		version, err := strconv.ParseUint(rest[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("无效的版本号 %q: %w", rest[0], err)
+		}
+		return runner.Force(ctx, version)
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, st := range statuses {
+			appliedAt := "pending"
+			if st.Applied {
+				appliedAt = st.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			_, _ = fmt.Fprintf(w, "%06d\t%s\t%s\n", st.Version, st.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		fs.Usage()
+		return fmt.Errorf("未知的迁移命令: %s", cmd)
+	}
+}
+
+// migrateRunner 加载配置并连接数据库, 不注册consul服务
+func (s *Server) migrateRunner(ctx context.Context) (*migrate.Runner, func(), error) {
+	if err := s.staticConfigLoader(); err != nil {
+		return nil, nil, err
+	}
+	if err := s.loadLogger(); err != nil {
+		return nil, nil, err
+	}
+
+	client, err := consul.NewClient(s.Config().Consul.Host, logger.Named(logger.Consul))
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := client.GetSqldb()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	drv, err := s.openDb(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("数据库连接失败: %w", err)
+	}
+	if err := drv.DB().PingContext(ctx); err != nil {
+		_ = drv.Close()
+		return nil, nil, fmt.Errorf("数据库连接失败: %w", err)
+	}
+
+	migrations, err := migrate.Migrations()
+	if err != nil {
+		_ = drv.Close()
+		return nil, nil, err
+	}
+	runner := migrate.NewRunner(drv.DB(), drv.Dialect(), migrations, logger.Named(logger.SQL))
+	return runner, func() { _ = drv.Close() }, nil
+}
+
+func stepsArg(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("无效的步数 %q", args[0])
+	}
+	return n, nil
+}
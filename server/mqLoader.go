@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"go.uber.org/zap"
+	consulconfig "msgcenter/platform/consul/config"
+	"msgcenter/platform/mq"
+	"msgcenter/utils/logger"
+	"time"
+)
+
+// BrokerOpener 根据consul中的消息队列配置创建 Broker, 默认连接Pulsar, 测试时可替换为内存实现
+type BrokerOpener func(cfg consulconfig.Pulsar) (mq.Broker, error)
+
+func (s *Server) mqLoader(context.Context) error {
+	cfg, err := s.Consul.GetPulsar()
+	if err != nil {
+		return err
+	}
+	broker, err := s.openBroker(cfg)
+	if err != nil {
+		return err
+	}
+	s.Broker = broker
+	s.Service.SetBroker(broker)
+	s.Logger.Info("消息队列已加载", zap.String("url", cfg.URL))
+	return nil
+}
+
+func (s *Server) openBroker(cfg consulconfig.Pulsar) (mq.Broker, error) {
+	if s.brokerOpener != nil {
+		return s.brokerOpener(cfg)
+	}
+	return mq.NewPulsar(mq.PulsarOptions{
+		URL:               cfg.URL,
+		Tenant:            cfg.Tenant,
+		Namespace:         cfg.Namespace,
+		OperationTimeout:  time.Duration(cfg.OperationTimeout) * time.Second,
+		ConnectionTimeout: time.Duration(cfg.ConnectionTimeout) * time.Second,
+	}, logger.Named(logger.MQ))
+}
+
+func (s *Server) closeMQ() error {
+	if s.Broker == nil {
+		return nil
+	}
+	return s.Broker.Close()
+}
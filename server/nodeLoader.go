@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"msgcenter/utils/objectid"
+	"msgcenter/utils/snowflake"
+)
+
+const defaultNodeLeaseTTL = 30 * time.Second
+
+// nodeLoader 设置 objectid 和 snowflake 的节点ID, 未配置固定ID时从 consul 租约分配
+func (s *Server) nodeLoader(ctx context.Context) error {
+	cfg := s.Config().Node
+	if !cfg.LayoutCutover.IsZero() {
+		objectid.LayoutCutover = cfg.LayoutCutover
+	}
+	node := 0
+	if cfg.ID != nil {
+		node = *cfg.ID
+	} else {
+		ttl := cfg.LeaseTTL
+		if ttl <= 0 {
+			ttl = defaultNodeLeaseTTL
+		}
+		prefix := fmt.Sprintf("service/%s/nodes/", s.Config().Consul.Service.Name)
+		// 租约失效后原ID被其他实例占用时, 切换到新占用的ID
+		lease, err := s.Consul.AcquireNode(ctx, prefix, snowflake.MaxNode, ttl, s.setNode)
+		if err != nil {
+			return err
+		}
+		s.nodeLease = lease
+		node = lease.ID()
+	}
+
+	if err := s.setNode(node); err != nil {
+		return err
+	}
+	s.Logger.Info("节点ID已设置", zap.Int("node", node), zap.Bool("lease", s.nodeLease != nil),
+		zap.Time("layoutCutover", objectid.LayoutCutover))
+	return nil
+}
+
+func (s *Server) setNode(node int) error {
+	if err := snowflake.SetNode(int64(node)); err != nil {
+		return err
+	}
+	return objectid.SetNode(uint32(node))
+}
+
+func (s *Server) releaseNode(ctx context.Context) error {
+	if s.nodeLease == nil {
+		return nil
+	}
+	return s.nodeLease.Release(ctx)
+}
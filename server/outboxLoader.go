@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/mq"
+	"msgcenter/platform/outbox"
+	"msgcenter/utils/logger"
+)
+
+// outboxLoader 竞选发件箱发布的 leader, 只有 leader 实例发布事件
+func (s *Server) outboxLoader(context.Context) error {
+	cfg := s.Config().Outbox
+	relay := outbox.NewRelay(
+		func() *gen.Client { return s.Service.DbClient() },
+		func() mq.Broker { return s.Service.Broker() },
+		outbox.Options{
+			Interval:    cfg.Interval,
+			BatchSize:   cfg.BatchSize,
+			Retention:   cfg.Retention,
+			MaxAttempts: cfg.MaxAttempts,
+			CommitGrace: cfg.CommitGrace,
+		},
+		logger.Named(logger.MQ),
+	)
+	s.outboxStop = s.runElected(ComponentOutbox, cfg.LeaderTTL, relay.Run)
+	s.Logger.Info("发件箱发布已加载", zap.String("election", s.leaderKey(ComponentOutbox)))
+	return nil
+}
+
+func (s *Server) stopOutbox(ctx context.Context) error {
+	if s.outboxStop == nil {
+		return nil
+	}
+	return s.outboxStop(ctx)
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/softdelete/purge"
+	"msgcenter/utils/logger"
+	"time"
+)
+
+const defaultPurgeInterval = time.Hour
+
+// purgeLoader 启动软删除记录清理, 未配置保留时间时不清理
+func (s *Server) purgeLoader(context.Context) error {
+	cfg := s.Config().SoftDelete
+	if cfg.Retention <= 0 {
+		s.Logger.Info("未配置软删除保留时间, 不清理软删除记录")
+		return nil
+	}
+	interval := cfg.PurgeInterval
+	if interval <= 0 {
+		interval = defaultPurgeInterval
+	}
+
+	s.purger = purge.New(
+		func() *gen.Client { return s.Service.DbClient() },
+		cfg.Retention,
+		interval,
+		logger.Named(logger.SQL),
+	)
+	s.purger.Start()
+	s.Logger.Info("软删除记录清理已启动",
+		zap.Duration("retention", cfg.Retention),
+		zap.Duration("interval", interval),
+	)
+	return nil
+}
+
+func (s *Server) stopPurge(ctx context.Context) error {
+	if s.purger == nil {
+		return nil
+	}
+	return s.purger.Stop(ctx)
+}
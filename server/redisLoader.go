@@ -1,14 +1,21 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"github.com/redis/go-redis/v9"
-	"log/slog"
+	"go.uber.org/zap"
 	"msgcenter/platform/consul"
+	"msgcenter/platform/redisx"
+	"msgcenter/utils/logger"
 )
 
-func (s *Server) redisLoader() {
-	cfg := s.Consul.GetRedis()
-	s.RedisClient = redis.NewClient(&redis.Options{
+func (s *Server) redisLoader(ctx context.Context) error {
+	cfg, err := s.Consul.GetRedis()
+	if err != nil {
+		return err
+	}
+	client := redis.NewClient(&redis.Options{
 		Addr:         cfg.Addr,
 		DB:           cfg.DB,
 		Password:     cfg.Password,
@@ -17,26 +24,39 @@ func (s *Server) redisLoader() {
 		MaxIdleConns: cfg.MaxIdleConns,
 		PoolTimeout:  cfg.PoolTimeout,
 	})
-	slog.Info("redis已加载", slog.Any("cfg", cfg))
+	client.AddHook(redisx.NewTracingHook())
+	client.AddHook(redisx.NewLogHook(logger.Named(logger.Redis)))
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return fmt.Errorf("连接redis失败: %w", err)
+	}
+	s.RedisClient = client
+	s.Service.SetRedisClient(client)
+	s.Logger.Info("redis已加载", zap.String("addr", cfg.Addr), zap.Int("db", cfg.DB))
+	return nil
 }
 
-func (s *Server) CloseRedis() {
-	err := s.RedisClient.Close()
-	if err != nil {
-		slog.Error("redis已关闭", err.Error())
-		panic(err)
+func (s *Server) CloseRedis() error {
+	if s.RedisClient == nil {
+		return nil
 	}
+	return s.RedisClient.Close()
 }
 
 func (s *Server) updateRedisClient() {
-	s.CloseRedis()
-	s.redisLoader()
+	if err := s.CloseRedis(); err != nil {
+		s.Logger.Error("关闭redis失败", zap.Error(err))
+	}
+	if err := s.redisLoader(context.Background()); err != nil {
+		s.Logger.Error("重新加载redis失败", zap.Error(err))
+	}
 }
 
 func (s *Server) registerRedisClientUpdate() {
 	s.Consul.RegisterCallback(consul.Redis, func(oldData, newData []byte) {
-		slog.Info("redis数据库配置更新", "old", string(oldData), "new", string(newData))
+		s.Logger.Info("redis数据库配置更新")
 		s.updateRedisClient()
-		slog.Info("redis数据库配置更新完成")
+		s.Logger.Info("redis数据库配置更新完成")
 	})
 }
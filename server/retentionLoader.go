@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"msgcenter/platform/consul"
+	consulconfig "msgcenter/platform/consul/config"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/service/retention"
+	"msgcenter/utils/logger"
+)
+
+// retentionLoader 竞选消息清理的 leader, 只有 leader 实例清理和归档消息
+func (s *Server) retentionLoader(context.Context) error {
+	job := retention.NewJob(
+		func() *gen.Client { return s.Service.DbClient() },
+		s.retentionConfig,
+		logger.Named(logger.Retention),
+	)
+	s.retentionStop = s.runElected(ComponentRetention, s.Config().Retention.LeaderTTL, job.Run)
+	s.Logger.Info("消息清理已加载", zap.String("election", s.leaderKey(ComponentRetention)))
+	return nil
+}
+
+// retentionConfig 读取 consul 中的保留策略, 未配置时只删除已过有效期的消息
+func (s *Server) retentionConfig() (retention.Config, error) {
+	if s.Consul.GetConfigValue(consul.Retention) == nil {
+		return retention.Parse(consulconfig.Retention{})
+	}
+	cfg, err := s.Consul.GetRetention()
+	if err != nil {
+		return retention.Config{}, err
+	}
+	return retention.Parse(cfg)
+}
+
+func (s *Server) stopRetention(ctx context.Context) error {
+	if s.retentionStop == nil {
+		return nil
+	}
+	return s.retentionStop(ctx)
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/service/schedule"
+	"msgcenter/utils/logger"
+)
+
+// scheduleLoader 竞选定时消息调度的 leader, 只有 leader 实例发送到期消息
+func (s *Server) scheduleLoader(context.Context) error {
+	cfg := s.Config().Schedule
+	scheduler := schedule.NewScheduler(
+		func() *gen.Client { return s.Service.DbClient() },
+		func() *redis.Client { return s.Service.RedisClient() },
+		schedule.Options{
+			Interval:     cfg.Interval,
+			BatchSize:    cfg.BatchSize,
+			SyncInterval: cfg.SyncInterval,
+			Horizon:      cfg.Horizon,
+			MaxAttempts:  cfg.MaxAttempts,
+			Backoff:      cfg.Backoff,
+			MaxBackoff:   cfg.MaxBackoff,
+		},
+		logger.Named(logger.Delivery),
+	)
+	s.scheduleStop = s.runElected(ComponentSchedule, cfg.LeaderTTL, scheduler.Run)
+	s.Logger.Info("定时消息调度已加载", zap.String("election", s.leaderKey(ComponentSchedule)))
+	return nil
+}
+
+func (s *Server) stopSchedule(ctx context.Context) error {
+	if s.scheduleStop == nil {
+		return nil
+	}
+	return s.scheduleStop(ctx)
+}
@@ -1,15 +1,25 @@
 package server
 
 import (
+	"context"
+	"database/sql"
 	"github.com/gofiber/fiber/v2"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
-	"log/slog"
 	"msgcenter/app"
 	"msgcenter/config"
 	"msgcenter/platform/consul"
 	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/softdelete/purge"
+	"msgcenter/platform/health"
+	"msgcenter/platform/lifecycle"
+	"msgcenter/platform/mq"
+	"msgcenter/platform/ws"
+	"msgcenter/utils/logger"
+	"os/signal"
 	"sync"
+	"sync/atomic"
+	"syscall"
 )
 
 var (
@@ -19,43 +29,169 @@ var (
 
 type Server struct {
 	App         *fiber.App
-	LocalConfig *config.Config
 	Consul      *consul.Client
 	DbClient    *gen.Client
 	RedisClient *redis.Client
 	Service     *app.ServiceApp
 	Logger      *zap.Logger
+	LogManager  *logger.Manager
+	Hub         *ws.Hub
+	Health      *health.Checker
+	Registry    *lifecycle.Registry
+	Broker      mq.Broker
+
+	// localConfig 本地配置, 热加载时整体替换, 读取方通过 Config 获取当前快照
+	localConfig   atomic.Pointer[config.Config]
+	logWriter     *rotateWriter
+	sqlDB         *sql.DB
+	traceShutdown func(context.Context) error
+	listenErr     chan error
+	dbOpener      DbOpener
+	brokerOpener  BrokerOpener
+	purger        *purge.Purger
+	nodeLease     *consul.NodeLease
+	outboxStop    func(context.Context) error
+	deliveryStop  func(context.Context) error
+	scheduleStop  func(context.Context) error
+	retentionStop func(context.Context) error
+	overrides     []lifecycle.Component
+}
+
+// Option 创建 Server 时的可选配置
+type Option func(*Server)
+
+// WithConfig 使用给定配置, 不再读取配置文件
+func WithConfig(cfg *config.Config) Option {
+	return func(s *Server) {
+		s.localConfig.Store(cfg)
+	}
+}
+
+// WithComponent 注册额外组件, 与内置组件同名时替换内置组件
+func WithComponent(c lifecycle.Component) Option {
+	return func(s *Server) {
+		s.overrides = append(s.overrides, c)
+	}
+}
+
+// WithDbOpener 替换数据库连接的创建方式
+func WithDbOpener(open DbOpener) Option {
+	return func(s *Server) {
+		s.dbOpener = open
+	}
+}
+
+// WithBrokerOpener 替换消息队列的创建方式
+func WithBrokerOpener(open BrokerOpener) Option {
+	return func(s *Server) {
+		s.brokerOpener = open
+	}
+}
+
+func New(opts ...Option) *Server {
+	s := &Server{
+		listenErr: make(chan error, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Config 当前生效的本地配置
+func (s *Server) Config() *config.Config {
+	return s.localConfig.Load()
 }
 
 func GetServer() *Server {
 	Once.Do(func() {
-		Instance = &Server{}
+		Instance = New()
 	})
 	return Instance
 }
 
-func (s *Server) init() *Server {
-	s.staticConfigLoader()
-	s.loadLogger()
-	s.consulLoader()
-	s.redisLoader()
-	s.registerRedisClientUpdate()
+func (s *Server) init() error {
+	if err := s.staticConfigLoader(); err != nil {
+		return err
+	}
+	if err := s.loadLogger(); err != nil {
+		return err
+	}
+	s.registerConfigReload()
+	if err := s.metricsLoader(); err != nil {
+		return err
+	}
+
+	startup := s.Config().Startup
+	s.Registry = lifecycle.NewRegistry(lifecycle.StartOptions{
+		Timeout:    startup.Timeout,
+		Retries:    startup.Retries,
+		Backoff:    startup.Backoff,
+		MaxBackoff: startup.MaxBackoff,
+	}, logger.Named(logger.Root))
+	for _, c := range s.components() {
+		s.Registry.Register(c)
+	}
+	for _, c := range s.overrides {
+		s.Registry.Register(c)
+	}
 
-	s.dbLoader()
-	s.registerDbClientUpdate()
+	s.healthLoader()
 	s.serviceLoader()
-	s.fiberLoader()
-	s.loadBanner()
-	return s
+	return nil
+}
+
+// Boot 加载配置并按依赖顺序启动所有组件, 失败时已启动的组件会被停止
+func (s *Server) Boot(ctx context.Context) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+	if err := s.Registry.Start(ctx); err != nil {
+		s.Logger.Error("启动服务失败", zap.Error(err))
+		return err
+	}
+	s.Logger.Info("启动服务成功", zap.String("ip", s.Config().IP))
+	if s.App != nil {
+		s.loadBanner()
+	}
+	return nil
+}
+
+// Run 阻塞直到 ctx 结束或HTTP服务异常退出, 然后优雅下线
+func (s *Server) Run(ctx context.Context) error {
+	var runErr error
+	select {
+	case <-ctx.Done():
+		s.Logger.Info("收到退出信号, 开始优雅下线")
+	case runErr = <-s.listenErr:
+		s.Logger.Error("HTTP服务异常退出", zap.Error(runErr))
+	}
+
+	timeout := s.Config().Shutdown.Timeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		s.Logger.Warn("优雅下线未完成", zap.Error(err))
+	}
+	return runErr
 }
 
-func (s *Server) Start() {
-	ExitLoader()
-	s.init()
-	err := s.App.Listen(s.LocalConfig.IP)
-	if err != nil {
-		slog.Error("启动服务失败", err)
-		panic(err)
+// Start 启动服务并阻塞至收到退出信号, 下线过程中再次收到信号则立即退出
+func (s *Server) Start() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := s.Boot(ctx); err != nil {
+		return err
 	}
-	slog.Info("启动服务成功", slog.Any("ip", s.LocalConfig.IP))
+
+	go func() {
+		<-ctx.Done()
+		// 恢复默认信号处理, 再次收到信号时进程直接退出
+		stop()
+	}()
+	return s.Run(ctx)
 }
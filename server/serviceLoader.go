@@ -2,6 +2,10 @@ package server
 
 import "msgcenter/app"
 
+// serviceLoader 创建业务依赖容器, 各组件启动或重建连接后写入其中
 func (s *Server) serviceLoader() {
-	s.Service = app.GetService(s.LocalConfig, s.DbClient, s.Consul, s.RedisClient)
+	s.Service = app.New(
+		app.WithConfig(s.Config()),
+		app.WithHealth(s.Health),
+	)
 }
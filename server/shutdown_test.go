@@ -0,0 +1,47 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+
+	"msgcenter/platform/lifecycle"
+	"msgcenter/server"
+	"msgcenter/testkit"
+)
+
+func TestShutdownDrainsBeforeStoppingComponents(t *testing.T) {
+	env := testkit.NewEnv(t)
+	var s *server.Server
+
+	// 依赖 http 的组件最先停止, 停止时实例应已标记未就绪并从consul注销
+	var draining, registered bool
+	probe := &lifecycle.Func{
+		ComponentName: "probe",
+		Deps:          []string{server.ComponentHTTP},
+		OnStop: func(context.Context) error {
+			draining = s.Health.Draining()
+			_, registered = env.Consul.Service(env.Config.Consul.Service.ID)
+			return nil
+		},
+	}
+	s = server.New(env.ServerOptions(server.WithComponent(probe))...)
+	if err := s.Boot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := env.Consul.Service(env.Config.Consul.Service.ID); !ok {
+		t.Fatal("启动后应注册到consul")
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !draining {
+		t.Fatal("停止组件前应标记为未就绪")
+	}
+	if registered {
+		t.Fatal("停止组件前应从consul注销")
+	}
+	if err := s.Registry.Health(context.Background(), server.ComponentPostgres); err == nil {
+		t.Fatal("下线后组件应已停止")
+	}
+}
@@ -5,11 +5,17 @@ import (
 	"msgcenter/config"
 )
 
-func (s *Server) staticConfigLoader() {
+func (s *Server) staticConfigLoader() error {
+	// 通过 WithConfig 注入配置时不读取配置文件
+	if s.Config() != nil {
+		return nil
+	}
+
 	err := config.Init()
 	if err != nil {
-		slog.Error("初始化配置失败", err)
-		panic(err)
+		slog.Error("初始化配置失败", "error", err)
+		return err
 	}
-	s.LocalConfig = config.GlobalConfig
+	s.localConfig.Store(config.GlobalConfig)
+	return nil
 }
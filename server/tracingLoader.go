@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"msgcenter/platform/tracing"
+)
+
+func (s *Server) tracingLoader(context.Context) error {
+	cfg := s.Config()
+	shutdown, err := tracing.Init(cfg.Trace, cfg.Consul.Service.Name, cfg.Consul.Service.ID)
+	if err != nil {
+		return fmt.Errorf("初始化链路追踪失败: %w", err)
+	}
+	s.traceShutdown = shutdown
+	s.Logger.Info("链路追踪已初始化",
+		zap.String("exporter", cfg.Trace.Exporter),
+		zap.Float64p("sample_ratio", cfg.Trace.SampleRatio),
+	)
+	return nil
+}
+
+// ShutdownTracing 刷新尚未导出的span
+func (s *Server) ShutdownTracing(ctx context.Context) error {
+	if s.traceShutdown == nil {
+		return nil
+	}
+	return s.traceShutdown(ctx)
+}
@@ -0,0 +1,346 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+
+	"msgcenter/platform/ent"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/conversation"
+	"msgcenter/platform/ent/gen/conversationmember"
+	"msgcenter/platform/ent/gen/predicate"
+	"msgcenter/platform/ent/gen/user"
+	"msgcenter/utils/objectid"
+)
+
+var (
+	ErrNotFound       = errors.New("会话不存在")
+	ErrUserNotFound   = errors.New("用户不存在")
+	ErrNotMember      = errors.New("不是会话成员")
+	ErrAlreadyMember  = errors.New("已是会话成员")
+	ErrFull           = errors.New("会话成员已满")
+	ErrForbidden      = errors.New("没有权限执行该操作")
+	ErrOwnerLeave     = errors.New("群主需先转让群主才能退出")
+	ErrDirect         = errors.New("单聊不支持该操作")
+	ErrDirectMembers  = errors.New("单聊必须是两名不同的用户")
+	ErrMuted          = errors.New("已被禁言")
+	ErrInvalidRole    = errors.New("角色无效")
+	ErrMissingOwnerID = errors.New("群聊必须指定群主")
+)
+
+// 成员角色
+const (
+	RoleOwner  = conversationmember.RoleOwner
+	RoleAdmin  = conversationmember.RoleAdmin
+	RoleMember = conversationmember.RoleMember
+)
+
+// rank 角色等级, 只能管理等级更低的成员
+var rank = map[conversationmember.Role]int{
+	RoleOwner:  3,
+	RoleAdmin:  2,
+	RoleMember: 1,
+}
+
+// CreateInput 创建会话的参数
+type CreateInput struct {
+	Type        conversation.Type
+	Name        string
+	OwnerID     objectid.ID   // 群主, 单聊时为其中一方
+	MemberIDs   []objectid.ID // 其他成员, 单聊时为另一方
+	MemberLimit int           // 群成员上限, 单聊固定为 2
+}
+
+// Create 创建会话. 单聊按双方用户查找已有会话, 存在时直接返回, 并发创建由 direct_key 唯一约束去重
+func Create(ctx context.Context, client *gen.Client, in CreateInput) (*gen.Conversation, error) {
+	if in.OwnerID.IsZero() {
+		return nil, ErrMissingOwnerID
+	}
+	userIDs := objectid.Compact(append([]objectid.ID{in.OwnerID}, in.MemberIDs...))
+
+	var directKey string
+	switch in.Type {
+	case conversation.TypeDirect:
+		if len(userIDs) != 2 {
+			return nil, ErrDirectMembers
+		}
+		in.MemberLimit = 2
+		directKey = userIDs[0].String() + ":" + userIDs[1].String()
+		existing, err := client.Conversation.Query().
+			Where(conversation.DirectKey(directKey)).
+			Only(ctx)
+		if err == nil {
+			return existing, nil
+		}
+		if !gen.IsNotFound(err) {
+			return nil, err
+		}
+	case conversation.TypeGroup:
+		if len(userIDs) > in.MemberLimit {
+			return nil, ErrFull
+		}
+	default:
+		return nil, conversation.TypeValidator(in.Type)
+	}
+
+	n, err := client.User.Query().Where(user.IDIn(userIDs...)).Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(userIDs) {
+		return nil, ErrUserNotFound
+	}
+
+	var conv *gen.Conversation
+	err = ent.WithTx(ctx, client, func(tx *gen.Tx) error {
+		create := tx.Conversation.Create().
+			SetType(in.Type).
+			SetMemberLimit(in.MemberLimit).
+			SetMemberCount(len(userIDs))
+		if in.Name != "" {
+			create.SetName(in.Name)
+		}
+		if directKey != "" {
+			create.SetDirectKey(directKey)
+		}
+		var err error
+		if conv, err = create.Save(ctx); err != nil {
+			return err
+		}
+
+		members := make([]*gen.ConversationMemberCreate, len(userIDs))
+		for i, id := range userIDs {
+			role := RoleMember
+			if in.Type == conversation.TypeGroup && id == in.OwnerID {
+				role = RoleOwner
+			}
+			members[i] = tx.ConversationMember.Create().
+				SetConversationID(conv.ID).
+				SetUserID(id).
+				SetRole(role)
+		}
+		return tx.ConversationMember.CreateBulk(members...).Exec(ctx)
+	})
+	// 并发创建同一单聊时, 唯一约束冲突的一方返回已创建的会话
+	if directKey != "" && gen.IsConstraintError(err) {
+		return client.Conversation.Query().
+			Where(conversation.DirectKey(directKey)).
+			Only(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return conv.Unwrap(), nil
+}
+
+// Get 查询会话
+func Get(ctx context.Context, client *gen.Client, id objectid.ID) (*gen.Conversation, error) {
+	conv, err := client.Conversation.Get(ctx, id)
+	if gen.IsNotFound(err) {
+		return nil, ErrNotFound
+	}
+	return conv, err
+}
+
+// Members 按加入时间分页查询成员
+func Members(ctx context.Context, client *gen.Client, id objectid.ID, limit, offset int) ([]*gen.ConversationMember, error) {
+	return client.ConversationMember.Query().
+		Where(conversationmember.ConversationID(id)).
+		Order(gen.Asc(conversationmember.FieldJoinTime), gen.Asc(conversationmember.FieldID)).
+		Limit(limit).
+		Offset(offset).
+		All(ctx)
+}
+
+// Member 查询成员, 不是成员时返回 ErrNotMember
+func Member(ctx context.Context, client *gen.Client, id, userID objectid.ID) (*gen.ConversationMember, error) {
+	m, err := client.ConversationMember.Query().
+		Where(
+			conversationmember.ConversationID(id),
+			conversationmember.UserID(userID),
+		).
+		Only(ctx)
+	if gen.IsNotFound(err) {
+		return nil, ErrNotMember
+	}
+	return m, err
+}
+
+// CheckSend 检查用户能否在会话中发言, userID 为空表示系统消息, 只检查会话是否存在
+func CheckSend(ctx context.Context, client *gen.Client, id, userID objectid.ID) error {
+	if _, err := Get(ctx, client, id); err != nil {
+		return err
+	}
+	if userID.IsZero() {
+		return nil
+	}
+	m, err := Member(ctx, client, id, userID)
+	if err != nil {
+		return err
+	}
+	if Muted(m) {
+		return ErrMuted
+	}
+	return nil
+}
+
+// Muted 成员是否处于禁言中
+func Muted(m *gen.ConversationMember) bool {
+	return m.MutedUntil != nil && m.MutedUntil.After(time.Now())
+}
+
+// Join 用户加入群聊, 成员数达到上限时返回 ErrFull
+func Join(ctx context.Context, client *gen.Client, id, userID objectid.ID) (*gen.ConversationMember, error) {
+	conv, err := group(ctx, client, id)
+	if err != nil {
+		return nil, err
+	}
+	exist, err := client.User.Query().Where(user.ID(userID)).Exist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, ErrUserNotFound
+	}
+
+	var member *gen.ConversationMember
+	err = ent.WithTx(ctx, client, func(tx *gen.Tx) error {
+		// 条件更新保证并发加入时不超过上限
+		n, err := tx.Conversation.Update().
+			Where(
+				conversation.ID(conv.ID),
+				predicate.Conversation(sql.FieldsLT(conversation.FieldMemberCount, conversation.FieldMemberLimit)),
+			).
+			AddMemberCount(1).
+			Save(ctx)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrFull
+		}
+		member, err = tx.ConversationMember.Create().
+			SetConversationID(conv.ID).
+			SetUserID(userID).
+			SetRole(RoleMember).
+			Save(ctx)
+		if gen.IsConstraintError(err) {
+			return ErrAlreadyMember
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return member.Unwrap(), nil
+}
+
+// Leave 成员退出群聊, 群主需先转让
+func Leave(ctx context.Context, client *gen.Client, id, userID objectid.ID) error {
+	if _, err := group(ctx, client, id); err != nil {
+		return err
+	}
+	m, err := Member(ctx, client, id, userID)
+	if err != nil {
+		return err
+	}
+	if m.Role == RoleOwner {
+		return ErrOwnerLeave
+	}
+	return remove(ctx, client, m)
+}
+
+// Kick 将成员移出群聊, 只能移出角色低于自己的成员
+func Kick(ctx context.Context, client *gen.Client, id, operatorID, userID objectid.ID) error {
+	_, target, err := manage(ctx, client, id, operatorID, userID)
+	if err != nil {
+		return err
+	}
+	return remove(ctx, client, target)
+}
+
+// Mute 禁言成员到 until, until 为零值时解除禁言; 只能禁言角色低于自己的成员
+func Mute(ctx context.Context, client *gen.Client, id, operatorID, userID objectid.ID, until time.Time) (*gen.ConversationMember, error) {
+	_, target, err := manage(ctx, client, id, operatorID, userID)
+	if err != nil {
+		return nil, err
+	}
+	update := client.ConversationMember.UpdateOne(target)
+	if until.IsZero() {
+		update.ClearMutedUntil()
+	} else {
+		update.SetMutedUntil(until)
+	}
+	return update.Save(ctx)
+}
+
+// SetRole 群主设置成员角色; 设置为 Owner 时转让群主, 原群主成为 Admin
+func SetRole(ctx context.Context, client *gen.Client, id, operatorID, userID objectid.ID, role conversationmember.Role) error {
+	if conversationmember.RoleValidator(role) != nil {
+		return ErrInvalidRole
+	}
+	operator, target, err := manage(ctx, client, id, operatorID, userID)
+	if err != nil {
+		return err
+	}
+	if operator.Role != RoleOwner {
+		return ErrForbidden
+	}
+	if role != RoleOwner {
+		return client.ConversationMember.UpdateOne(target).SetRole(role).Exec(ctx)
+	}
+	return ent.WithTx(ctx, client, func(tx *gen.Tx) error {
+		if err := tx.ConversationMember.UpdateOneID(operator.ID).SetRole(RoleAdmin).Exec(ctx); err != nil {
+			return err
+		}
+		return tx.ConversationMember.UpdateOneID(target.ID).SetRole(RoleOwner).Exec(ctx)
+	})
+}
+
+// group 查询群聊, 单聊返回 ErrDirect
+func group(ctx context.Context, client *gen.Client, id objectid.ID) (*gen.Conversation, error) {
+	conv, err := Get(ctx, client, id)
+	if err != nil {
+		return nil, err
+	}
+	if conv.Type != conversation.TypeGroup {
+		return nil, ErrDirect
+	}
+	return conv, nil
+}
+
+// manage 查询操作者和目标成员, 操作者角色必须高于目标
+func manage(ctx context.Context, client *gen.Client, id, operatorID, userID objectid.ID) (operator, target *gen.ConversationMember, err error) {
+	if _, err = group(ctx, client, id); err != nil {
+		return nil, nil, err
+	}
+	if operatorID == userID {
+		return nil, nil, ErrForbidden
+	}
+	if operator, err = Member(ctx, client, id, operatorID); err != nil {
+		if errors.Is(err, ErrNotMember) {
+			return nil, nil, ErrForbidden
+		}
+		return nil, nil, err
+	}
+	if target, err = Member(ctx, client, id, userID); err != nil {
+		return nil, nil, err
+	}
+	if rank[operator.Role] <= rank[target.Role] {
+		return nil, nil, ErrForbidden
+	}
+	return operator, target, nil
+}
+
+// remove 删除成员并减少成员数
+func remove(ctx context.Context, client *gen.Client, m *gen.ConversationMember) error {
+	return ent.WithTx(ctx, client, func(tx *gen.Tx) error {
+		if err := tx.ConversationMember.DeleteOneID(m.ID).Exec(ctx); err != nil {
+			return err
+		}
+		return tx.Conversation.UpdateOneID(m.ConversationID).AddMemberCount(-1).Exec(ctx)
+	})
+}
@@ -0,0 +1,140 @@
+package conversation_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"entgo.io/ent"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/conversation"
+	conversationsvc "msgcenter/service/conversation"
+	"msgcenter/testkit"
+	"msgcenter/utils/objectid"
+)
+
+func newUsers(t *testing.T, client *gen.Client, n int) []objectid.ID {
+	t.Helper()
+	ids := make([]objectid.ID, n)
+	for i := range ids {
+		ids[i] = client.User.Create().SetName("u").SaveX(context.Background()).ID
+	}
+	return ids
+}
+
+func TestCreateDirectReturnsExisting(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	users := newUsers(t, client, 2)
+
+	first, err := conversationsvc.Create(ctx, client, conversationsvc.CreateInput{
+		Type: conversation.TypeDirect, OwnerID: users[0], MemberIDs: users[1:],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 对方发起的单聊返回同一会话
+	again, err := conversationsvc.Create(ctx, client, conversationsvc.CreateInput{
+		Type: conversation.TypeDirect, OwnerID: users[1], MemberIDs: users[:1],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID || first.DirectKey == nil {
+		t.Fatalf("会话 = %s, want %s, direct_key = %v", again.ID, first.ID, first.DirectKey)
+	}
+}
+
+func TestCreateDirectConcurrent(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	users := newUsers(t, client, 2)
+
+	var wg sync.WaitGroup
+	ids := make([]objectid.ID, 8)
+	errs := make([]error, len(ids))
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conv, err := conversationsvc.Create(ctx, client, conversationsvc.CreateInput{
+				Type: conversation.TypeDirect, OwnerID: users[i%2], MemberIDs: users[1-i%2 : 2-i%2],
+			})
+			if errs[i] = err; err == nil {
+				ids[i] = conv.ID
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range ids {
+		if errs[i] != nil {
+			t.Fatalf("第 %d 次创建失败: %v", i, errs[i])
+		}
+		if ids[i] != ids[0] {
+			t.Fatalf("并发创建返回不同的会话: %s, %s", ids[i], ids[0])
+		}
+	}
+	if n := client.Conversation.Query().CountX(ctx); n != 1 {
+		t.Fatalf("会话数 = %d, want 1", n)
+	}
+}
+
+// 查询已有单聊之后、写入之前被其他请求抢先创建, 唯一约束冲突时返回对方创建的会话
+func TestCreateDirectLosesRace(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	users := newUsers(t, client, 2)
+	key := objectid.Compact(users)[0].String() + ":" + objectid.Compact(users)[1].String()
+
+	var winner *gen.Conversation
+	var raced atomic.Bool
+	client.Conversation.Use(func(next ent.Mutator) ent.Mutator {
+		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+			if raced.CompareAndSwap(false, true) {
+				winner = client.Conversation.Create().
+					SetType(conversation.TypeDirect).
+					SetDirectKey(key).
+					SetMemberLimit(2).
+					SaveX(context.Background())
+			}
+			return next.Mutate(ctx, m)
+		})
+	})
+
+	conv, err := conversationsvc.Create(ctx, client, conversationsvc.CreateInput{
+		Type: conversation.TypeDirect, OwnerID: users[0], MemberIDs: users[1:],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.ID != winner.ID {
+		t.Fatalf("会话 = %s, want %s", conv.ID, winner.ID)
+	}
+	if n := client.Conversation.Query().CountX(ctx); n != 1 {
+		t.Fatalf("会话数 = %d, want 1", n)
+	}
+}
+
+func TestCreateGroupHasNoDirectKey(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	users := newUsers(t, client, 2)
+
+	for range 2 {
+		conv, err := conversationsvc.Create(ctx, client, conversationsvc.CreateInput{
+			Type: conversation.TypeGroup, OwnerID: users[0], MemberIDs: users[1:], MemberLimit: 10,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conv.DirectKey != nil {
+			t.Fatalf("群聊 direct_key = %q", *conv.DirectKey)
+		}
+	}
+	if n := client.Conversation.Query().CountX(ctx); n != 2 {
+		t.Fatalf("相同成员的群聊可以重复创建, 会话数 = %d", n)
+	}
+}
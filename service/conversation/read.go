@@ -0,0 +1,118 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+	"github.com/bytedance/sonic"
+
+	"msgcenter/platform/ent"
+	"msgcenter/platform/ent/audit"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/conversationmember"
+	"msgcenter/platform/ent/gen/message"
+	"msgcenter/platform/outbox"
+	"msgcenter/utils/objectid"
+)
+
+// TopicRead 已读回执事件的主题, 事件体为 Receipt 的 JSON
+const TopicRead = "read-receipt"
+
+var ErrMessageNotFound = errors.New("消息不存在或不属于该会话")
+
+// Receipt 已读回执
+type Receipt struct {
+	ConversationID objectid.ID `json:"conversation_id"`
+	UserID         objectid.ID `json:"user_id"`
+	MessageID      int64       `json:"message_id"`
+	ReadTime       time.Time   `json:"read_time"`
+}
+
+// MarkRead 将成员的已读游标推进到 messageID, messageID 为 0 表示会话最新的消息.
+// 游标只前进不后退, 推进成功时在同一事务中写入已读回执事件; 返回的 bool 表示游标是否前进.
+// 已读游标变化频繁, 不写审计日志
+func MarkRead(ctx context.Context, client *gen.Client, id, userID objectid.ID, messageID int64) (*gen.ConversationMember, bool, error) {
+	member, err := Member(ctx, client, id, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if messageID == 0 {
+		messageID, err = client.Message.Query().
+			Where(message.ConversationID(id)).
+			Order(message.ByID(sql.OrderDesc())).
+			FirstID(ctx)
+		if gen.IsNotFound(err) {
+			return member, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		exist, err := client.Message.Query().
+			Where(message.ID(messageID), message.ConversationID(id)).
+			Exist(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		if !exist {
+			return nil, false, ErrMessageNotFound
+		}
+	}
+	if messageID <= member.ReadCursor {
+		return member, false, nil
+	}
+
+	now := time.Now()
+	advanced := false
+	ctx = audit.WithoutAudit(ctx)
+	err = ent.WithTx(ctx, client, func(tx *gen.Tx) error {
+		n, err := tx.ConversationMember.Update().
+			Where(
+				conversationmember.ID(member.ID),
+				conversationmember.ReadCursorLT(messageID),
+			).
+			SetReadCursor(messageID).
+			SetReadTime(now).
+			Save(ctx)
+		if err != nil || n == 0 {
+			return err
+		}
+		advanced = true
+
+		body, err := sonic.Marshal(Receipt{
+			ConversationID: id,
+			UserID:         userID,
+			MessageID:      messageID,
+			ReadTime:       now,
+		})
+		if err != nil {
+			return err
+		}
+		return outbox.Add(ctx, tx.Client(), outbox.Event{
+			Topic:       TopicRead,
+			Key:         id.String(),
+			Payload:     body,
+			Aggregate:   gen.TypeConversationMember,
+			AggregateID: member.ID.String(),
+		})
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	member, err = client.ConversationMember.Get(ctx, member.ID)
+	return member, advanced, err
+}
+
+// Unread 成员在已读游标之后收到的消息数, 不含自己发送的和已过期的消息
+func Unread(ctx context.Context, client *gen.Client, member *gen.ConversationMember) (int, error) {
+	return client.Message.Query().
+		Where(
+			message.ConversationID(member.ConversationID),
+			message.IDGT(member.ReadCursor),
+			message.Or(message.SenderIDIsNil(), message.SenderIDNEQ(member.UserID)),
+			message.Or(message.ExpireTimeIsNil(), message.ExpireTimeGT(time.Now())),
+		).
+		Count(ctx)
+}
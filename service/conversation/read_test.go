@@ -0,0 +1,125 @@
+package conversation_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/conversation"
+	"msgcenter/platform/ent/gen/outboxevent"
+	conversationsvc "msgcenter/service/conversation"
+	"msgcenter/testkit"
+	"msgcenter/utils/objectid"
+)
+
+// newGroup 创建群聊, users[0] 为群主
+func newGroup(t *testing.T, client *gen.Client, users []objectid.ID) *gen.Conversation {
+	t.Helper()
+	conv, err := conversationsvc.Create(context.Background(), client, conversationsvc.CreateInput{
+		Type: conversation.TypeGroup, OwnerID: users[0], MemberIDs: users[1:], MemberLimit: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conv
+}
+
+func send(t *testing.T, client *gen.Client, conv *gen.Conversation, sender objectid.ID) *gen.Message {
+	t.Helper()
+	return client.Message.Create().
+		SetConversationID(conv.ID).
+		SetSenderID(sender).
+		SetContentType("text").
+		SetPayload(json.RawMessage(`"hi"`)).
+		SaveX(context.Background())
+}
+
+func receipts(t *testing.T, client *gen.Client) int {
+	t.Helper()
+	return client.OutboxEvent.Query().Where(outboxevent.Topic(conversationsvc.TopicRead)).CountX(context.Background())
+}
+
+func TestMarkReadOnlyAdvances(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	users := newUsers(t, client, 2)
+	conv := newGroup(t, client, users)
+	m1 := send(t, client, conv, users[0])
+	m2 := send(t, client, conv, users[0])
+
+	member, advanced, err := conversationsvc.MarkRead(ctx, client, conv.ID, users[1], m2.ID)
+	if err != nil || !advanced || member.ReadCursor != m2.ID || member.ReadTime == nil {
+		t.Fatalf("MarkRead = %+v, %v, %v", member, advanced, err)
+	}
+	// 游标不后退, 重复标记不产生回执
+	for _, id := range []int64{m1.ID, m2.ID} {
+		member, advanced, err = conversationsvc.MarkRead(ctx, client, conv.ID, users[1], id)
+		if err != nil || advanced || member.ReadCursor != m2.ID {
+			t.Fatalf("MarkRead(%d) cursor = %d, advanced = %v, err = %v", id, member.ReadCursor, advanced, err)
+		}
+	}
+	if n := receipts(t, client); n != 1 {
+		t.Fatalf("已读回执事件数 = %d, want 1", n)
+	}
+
+	// 0 表示会话最新的消息
+	m3 := send(t, client, conv, users[0])
+	if member, advanced, _ = conversationsvc.MarkRead(ctx, client, conv.ID, users[1], 0); !advanced || member.ReadCursor != m3.ID {
+		t.Fatalf("标记最新消息后 cursor = %d, want %d", member.ReadCursor, m3.ID)
+	}
+}
+
+func TestMarkReadRejectsForeignMessage(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	users := newUsers(t, client, 3)
+	conv := newGroup(t, client, users[:2])
+	other := newGroup(t, client, []objectid.ID{users[0], users[2]})
+	foreign := send(t, client, other, users[0])
+
+	if _, _, err := conversationsvc.MarkRead(ctx, client, conv.ID, users[1], foreign.ID); !errors.Is(err, conversationsvc.ErrMessageNotFound) {
+		t.Fatalf("其他会话的消息 err = %v, want ErrMessageNotFound", err)
+	}
+	if _, _, err := conversationsvc.MarkRead(ctx, client, conv.ID, users[2], 0); !errors.Is(err, conversationsvc.ErrNotMember) {
+		t.Fatalf("非成员 err = %v, want ErrNotMember", err)
+	}
+	// 空会话没有可标记的消息
+	if _, advanced, err := conversationsvc.MarkRead(ctx, client, conv.ID, users[1], 0); err != nil || advanced {
+		t.Fatalf("空会话 MarkRead advanced = %v, err = %v", advanced, err)
+	}
+}
+
+func TestUnreadExcludesOwnAndExpired(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	users := newUsers(t, client, 2)
+	conv := newGroup(t, client, users)
+
+	read := send(t, client, conv, users[0])
+	send(t, client, conv, users[0])
+	send(t, client, conv, users[1])
+	client.Message.Create().
+		SetConversationID(conv.ID).
+		SetSenderID(users[0]).
+		SetContentType("text").
+		SetPayload(json.RawMessage(`"expired"`)).
+		SetExpireTime(time.Now().Add(-time.Second)).
+		ExecX(ctx)
+	client.Message.Create().
+		SetConversationID(conv.ID).
+		SetContentType("text").
+		SetPayload(json.RawMessage(`"system"`)).
+		ExecX(ctx)
+
+	member, _, err := conversationsvc.MarkRead(ctx, client, conv.ID, users[1], read.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 已读之后: 群主的一条和系统消息; 自己发送的和已过期的不计
+	if n, err := conversationsvc.Unread(ctx, client, member); err != nil || n != 2 {
+		t.Fatalf("Unread = %d, %v, want 2", n, err)
+	}
+}
@@ -0,0 +1,231 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/broadcast"
+	"msgcenter/platform/ent/gen/device"
+	"msgcenter/platform/ent/gen/predicate"
+	"msgcenter/platform/ent/gen/topicsubscription"
+	"msgcenter/platform/ent/gen/userdevicerelation"
+	"msgcenter/platform/metrics"
+	"msgcenter/platform/mq"
+	"msgcenter/platform/redisx"
+	"msgcenter/service/message"
+	"msgcenter/service/topic"
+	"msgcenter/utils/logger"
+	"msgcenter/utils/objectid"
+)
+
+// SubscriptionBroadcast 广播扩散的订阅, 所有实例共享
+const SubscriptionBroadcast = "broadcast"
+
+// fanoutLimitKey 广播扩散限速的 redis 键, 所有实例的所有广播共享同一速率
+const fanoutLimitKey = "broadcast:fanout:limit"
+
+// Broadcaster 主题广播扩散: 按订阅主键分批展开到设备, 以指定设备的消息创建事件重新发布,
+// 由收件箱和在线推送按普通消息投递. 每批完成后记录进度, 重试时从上次的位置继续
+type Broadcaster struct {
+	client    func() *gen.Client
+	broker    func() mq.Broker
+	limiter   *redisx.Limiter // 每秒扩散的设备数, 所有实例共享
+	batchSize int
+	logger    *zap.Logger
+}
+
+// NewBroadcaster rate 为所有实例合计每秒扩散的设备数, 0 表示不限速
+func NewBroadcaster(client func() *gen.Client, broker func() mq.Broker, rdb func() *redis.Client,
+	rate, batch int, logger *zap.Logger,
+) *Broadcaster {
+	if batch <= 0 {
+		batch = batchSize
+	}
+	return &Broadcaster{
+		client:    client,
+		broker:    broker,
+		limiter:   redisx.NewLimiter(rdb, fanoutLimitKey, rate),
+		batchSize: batch,
+		logger:    logger,
+	}
+}
+
+// Handle 处理广播创建事件, 可重复消费
+func (b *Broadcaster) Handle(ctx context.Context, msg *mq.Message) error {
+	var ev gen.Broadcast
+	if err := sonic.Unmarshal(msg.Payload, &ev); err != nil {
+		return mq.Permanent(fmt.Errorf("解析广播事件失败: %w", err))
+	}
+	client := b.client()
+	bc, err := client.Broadcast.Get(ctx, ev.ID)
+	if gen.IsNotFound(err) {
+		return mq.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	if bc.Status == broadcast.StatusDone || bc.Status == broadcast.StatusFailed {
+		return nil
+	}
+
+	target, err := topic.ParseTarget(bc.Target)
+	if err != nil {
+		return b.fail(ctx, client, bc, err)
+	}
+	m, err := client.Message.Get(ctx, bc.MessageID)
+	if gen.IsNotFound(err) {
+		return b.fail(ctx, client, bc, err)
+	}
+	if err != nil {
+		return err
+	}
+	if bc.Status == broadcast.StatusPending {
+		if err := client.Broadcast.UpdateOne(bc).SetStatus(broadcast.StatusRunning).Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	cursor, total := bc.Cursor, bc.DeviceCount
+	for {
+		// 过期后停止扩散, 已发布的部分由收件箱和推送跳过
+		if expired(m) {
+			metrics.DeliveryExpired.WithLabelValues("broadcast").Inc()
+			break
+		}
+		subs, err := client.TopicSubscription.Query().
+			Where(topicsubscription.TopicID(bc.TopicID), topicsubscription.IDGT(cursor)).
+			Order(topicsubscription.ByID()).
+			Limit(b.batchSize).
+			All(ctx)
+		if err != nil {
+			return err
+		}
+		if len(subs) == 0 {
+			break
+		}
+
+		devices, err := b.devices(ctx, client, subs, bc.Target == "", target)
+		if err != nil {
+			return err
+		}
+		for chunk := range slices.Chunk(devices, batchSize) {
+			if err := b.publish(ctx, m, chunk); err != nil {
+				return err
+			}
+			if err := b.limiter.Wait(ctx, len(chunk)); err != nil {
+				return err
+			}
+		}
+
+		cursor = subs[len(subs)-1].ID
+		total += len(devices)
+		err = client.Broadcast.UpdateOneID(bc.ID).
+			SetCursor(cursor).
+			SetDeviceCount(total).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		metrics.DeliveryBroadcastDevices.Add(float64(len(devices)))
+	}
+
+	err = client.Broadcast.UpdateOneID(bc.ID).
+		SetStatus(broadcast.StatusDone).
+		SetFinishTime(time.Now()).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	metrics.DeliveryBroadcasts.WithLabelValues(string(broadcast.StatusDone)).Inc()
+	logger.With(ctx, b.logger).Info("主题广播已完成",
+		zap.Stringer("id", bc.ID),
+		zap.Int64("message_id", bc.MessageID),
+		zap.Int("devices", total),
+	)
+	return nil
+}
+
+// devices 一批订阅展开后的设备. 用户订阅通过用户设备关联展开并按定向表达式过滤;
+// 设备订阅在没有定向条件时只排除已删除的设备, 有定向条件时按该设备的关联判断
+func (b *Broadcaster) devices(ctx context.Context, client *gen.Client, subs []*gen.TopicSubscription,
+	all bool, target predicate.UserDeviceRelation,
+) ([]objectid.ID, error) {
+	var users, devices []objectid.ID
+	for _, s := range subs {
+		if s.SubscriberType == topicsubscription.SubscriberTypeUser {
+			users = append(users, s.SubscriberID)
+		} else {
+			devices = append(devices, s.SubscriberID)
+		}
+	}
+
+	var subscribers []predicate.UserDeviceRelation
+	if len(users) > 0 {
+		subscribers = append(subscribers, userdevicerelation.UserIDIn(users...))
+	}
+	var result []objectid.ID
+	if all && len(devices) > 0 {
+		ids, err := client.Device.Query().
+			Where(device.IDIn(devices...), device.DeleteFlag(false)).
+			IDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result = ids
+	} else if len(devices) > 0 {
+		subscribers = append(subscribers, userdevicerelation.DeviceIDIn(devices...))
+	}
+	if len(subscribers) > 0 {
+		rels, err := client.UserDeviceRelation.Query().
+			Where(userdevicerelation.Or(subscribers...), target).
+			Select(userdevicerelation.FieldDeviceID).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range rels {
+			result = append(result, rel.DeviceID)
+		}
+	}
+	return objectid.Compact(result), nil
+}
+
+// publish 以指定设备的消息创建事件重新发布广播消息
+func (b *Broadcaster) publish(ctx context.Context, m *gen.Message, devices []objectid.ID) error {
+	copied := *m
+	copied.UserIds = nil
+	copied.DeviceIds = devices
+	body, err := sonic.Marshal(&copied)
+	if err != nil {
+		return err
+	}
+	_, err = b.broker().Publish(ctx, &mq.Message{
+		Topic:   message.TopicCreated,
+		Key:     strconv.FormatInt(m.ID, 10),
+		Payload: body,
+	})
+	return err
+}
+
+// fail 标记广播失败, 不再重试
+func (b *Broadcaster) fail(ctx context.Context, client *gen.Client, bc *gen.Broadcast, cause error) error {
+	err := client.Broadcast.UpdateOneID(bc.ID).
+		SetStatus(broadcast.StatusFailed).
+		SetError(cause.Error()).
+		SetFinishTime(time.Now()).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	metrics.DeliveryBroadcasts.WithLabelValues(string(broadcast.StatusFailed)).Inc()
+	logger.With(ctx, b.logger).Warn("主题广播失败", zap.Stringer("id", bc.ID), zap.Error(cause))
+	return mq.Permanent(cause)
+}
@@ -0,0 +1,136 @@
+package delivery_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/broadcast"
+	"msgcenter/platform/ent/gen/device"
+	"msgcenter/platform/mq"
+	"msgcenter/service/delivery"
+	"msgcenter/service/message"
+	"msgcenter/service/topic"
+	"msgcenter/testkit"
+	"msgcenter/utils/objectid"
+)
+
+type broadcastEnv struct {
+	client   *gen.Client
+	broker   *mq.Memory
+	consumer mq.Consumer
+	topic    *gen.Topic
+}
+
+func newBroadcastEnv(t *testing.T) *broadcastEnv {
+	t.Helper()
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	broker := mq.NewMemory()
+	t.Cleanup(func() { _ = broker.Close() })
+	consumer, err := broker.Subscribe(ctx, mq.SubscribeOptions{Topic: message.TopicCreated, Subscription: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, err := topic.Create(ctx, client, "news", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &broadcastEnv{client: client, broker: broker, consumer: consumer, topic: tp}
+}
+
+func (e *broadcastEnv) device(t *testing.T, name string, typ device.DeviceType) *gen.Device {
+	t.Helper()
+	return e.client.Device.Create().SetClientDeviceID(name).SetDeviceType(typ).SaveX(context.Background())
+}
+
+func (e *broadcastEnv) subscribe(t *testing.T, sub topic.Subscriber) {
+	t.Helper()
+	if _, err := topic.Subscribe(context.Background(), e.client, "news", sub); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// run 发布广播并处理广播创建事件, 返回扩散到的设备
+func (e *broadcastEnv) run(t *testing.T, target string) []objectid.ID {
+	t.Helper()
+	ctx := context.Background()
+	bc, err := topic.Publish(ctx, e.client, "news", topic.PublishInput{
+		ContentType: "text",
+		Payload:     json.RawMessage(`"hi"`),
+		Target:      target,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := sonic.Marshal(bc)
+	b := delivery.NewBroadcaster(
+		func() *gen.Client { return e.client },
+		func() mq.Broker { return e.broker },
+		func() *redis.Client { return nil },
+		0, 1, zap.NewNop(),
+	)
+	if err := b.Handle(ctx, &mq.Message{Payload: body}); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.client.Broadcast.GetX(ctx, bc.ID); got.Status != broadcast.StatusDone {
+		t.Fatalf("广播状态 = %s, want Done", got.Status)
+	}
+
+	var devices []objectid.ID
+	for {
+		rctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		msg, err := e.consumer.Receive(rctx)
+		cancel()
+		if err != nil {
+			break
+		}
+		_ = e.consumer.Ack(msg)
+		var m gen.Message
+		if err := sonic.Unmarshal(msg.Payload, &m); err != nil {
+			t.Fatal(err)
+		}
+		devices = append(devices, m.DeviceIds...)
+	}
+	return objectid.Compact(devices)
+}
+
+func TestBroadcastSkipsDeletedDevices(t *testing.T) {
+	ctx := context.Background()
+	env := newBroadcastEnv(t)
+	live := env.device(t, "live", device.DeviceTypeMobile)
+	deleted := env.device(t, "deleted", device.DeviceTypeMobile)
+	env.subscribe(t, topic.Subscriber{DeviceID: live.ID})
+	env.subscribe(t, topic.Subscriber{DeviceID: deleted.ID})
+	env.client.Device.DeleteOneID(deleted.ID).ExecX(ctx)
+
+	if got := env.run(t, ""); !slices.Equal(got, []objectid.ID{live.ID}) {
+		t.Fatalf("扩散到的设备 = %v, want [%s]", got, live.ID)
+	}
+}
+
+func TestBroadcastTargetsUserDevices(t *testing.T) {
+	ctx := context.Background()
+	env := newBroadcastEnv(t)
+	u := env.client.User.Create().SetName("u").SaveX(ctx)
+	mobile := env.device(t, "mobile", device.DeviceTypeMobile)
+	desktop := env.device(t, "desktop", device.DeviceTypeDesktop)
+	for _, d := range []*gen.Device{mobile, desktop} {
+		env.client.UserDeviceRelation.Create().SetUserID(u.ID).SetDeviceID(d.ID).ExecX(ctx)
+	}
+	env.subscribe(t, topic.Subscriber{UserID: u.ID})
+
+	if got := env.run(t, "device_type == 'Desktop'"); !slices.Equal(got, []objectid.ID{desktop.ID}) {
+		t.Fatalf("扩散到的设备 = %v, want [%s]", got, desktop.ID)
+	}
+	if got := env.run(t, ""); len(got) != 2 {
+		t.Fatalf("扩散到的设备 = %v, want 2 台", got)
+	}
+}
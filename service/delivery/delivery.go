@@ -0,0 +1,100 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/conversationmember"
+	"msgcenter/platform/ent/gen/inboxentry"
+	"msgcenter/platform/ent/gen/userdevicerelation"
+	"msgcenter/platform/mq"
+	"msgcenter/utils/objectid"
+)
+
+// 消息投递订阅
+const (
+	SubscriptionInbox = "inbox"
+	subscriptionPush  = "push-"
+)
+
+// batchSize IN 查询和批量写入的单批数量
+const batchSize = 500
+
+// DefaultFanoutThreshold 未配置时的写扩散成员数阈值
+const DefaultFanoutThreshold = 200
+
+// PushSubscription 实例的推送订阅名, 每个实例独立订阅以收到全部消息
+func PushSubscription(instance string) string {
+	return subscriptionPush + instance
+}
+
+// decode 解析消息创建事件
+func decode(msg *mq.Message) (*gen.Message, error) {
+	var m gen.Message
+	if err := sonic.Unmarshal(msg.Payload, &m); err != nil {
+		return nil, mq.Permanent(fmt.Errorf("解析消息事件失败: %w", err))
+	}
+	return &m, nil
+}
+
+// expired 消息已过有效期, 不再投递
+func expired(m *gen.Message) bool {
+	return m.ExpireTime != nil && !m.ExpireTime.After(time.Now())
+}
+
+// memberUserIDs 会话全部成员
+func memberUserIDs(ctx context.Context, client *gen.Client, conversationID objectid.ID) ([]objectid.ID, error) {
+	members, err := client.ConversationMember.Query().
+		Where(conversationmember.ConversationID(conversationID)).
+		Select(conversationmember.FieldUserID).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]objectid.ID, len(members))
+	for i, m := range members {
+		ids[i] = m.UserID
+	}
+	return ids, nil
+}
+
+// userDevices 通过用户设备关联查询用户的全部设备
+func userDevices(ctx context.Context, client *gen.Client, userIDs []objectid.ID) ([]objectid.ID, error) {
+	var devices []objectid.ID
+	for batch := range slices.Chunk(userIDs, batchSize) {
+		rels, err := client.UserDeviceRelation.Query().
+			Where(userdevicerelation.UserIDIn(batch...)).
+			Select(userdevicerelation.FieldDeviceID).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range rels {
+			devices = append(devices, rel.DeviceID)
+		}
+	}
+	return devices, nil
+}
+
+// existingEntries 已写入收件箱的设备, 重复消费时跳过
+func existingEntries(ctx context.Context, client *gen.Client, messageID int64, devices []objectid.ID) (map[objectid.ID]bool, error) {
+	existing := make(map[objectid.ID]bool)
+	for batch := range slices.Chunk(devices, batchSize) {
+		entries, err := client.InboxEntry.Query().
+			Where(inboxentry.MessageID(messageID), inboxentry.DeviceIDIn(batch...)).
+			Select(inboxentry.FieldDeviceID).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			existing[e.DeviceID] = true
+		}
+	}
+	return existing, nil
+}
@@ -0,0 +1,102 @@
+package delivery
+
+import (
+	"context"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/metrics"
+	"msgcenter/platform/mq"
+	"msgcenter/utils/logger"
+	"msgcenter/utils/objectid"
+)
+
+// Inbox 写扩散: 将消息写入接收设备的收件箱, 设备离线期间的消息由收件箱补发.
+// 直接指定的用户和成员数不超过阈值的会话, 通过用户设备关联展开到每台设备;
+// 超过阈值的大群只保存消息本身, 由设备按会话拉取(读扩散)
+type Inbox struct {
+	client    func() *gen.Client
+	threshold int
+	logger    *zap.Logger
+}
+
+func NewInbox(client func() *gen.Client, threshold int, logger *zap.Logger) *Inbox {
+	if threshold <= 0 {
+		threshold = DefaultFanoutThreshold
+	}
+	return &Inbox{client: client, threshold: threshold, logger: logger}
+}
+
+// Handle 处理消息创建事件, 可重复消费
+func (box *Inbox) Handle(ctx context.Context, msg *mq.Message) error {
+	m, err := decode(msg)
+	if err != nil {
+		return err
+	}
+	if expired(m) {
+		metrics.DeliveryExpired.WithLabelValues("inbox").Inc()
+		return nil
+	}
+	client := box.client()
+
+	users := slices.Clone(m.UserIds)
+	kind := "direct"
+	if !m.ConversationID.IsZero() {
+		conv, err := client.Conversation.Get(ctx, m.ConversationID)
+		if gen.IsNotFound(err) {
+			return mq.Permanent(err)
+		}
+		if err != nil {
+			return err
+		}
+		if conv.MemberCount > box.threshold {
+			metrics.DeliveryReadFanout.Inc()
+		} else {
+			members, err := memberUserIDs(ctx, client, conv.ID)
+			if err != nil {
+				return err
+			}
+			users = append(users, members...)
+			kind = "conversation"
+		}
+	}
+
+	devices, err := userDevices(ctx, client, objectid.Compact(users))
+	if err != nil {
+		return err
+	}
+	devices = objectid.Compact(append(devices, m.DeviceIds...))
+	if len(devices) == 0 {
+		return nil
+	}
+
+	existing, err := existingEntries(ctx, client, m.ID, devices)
+	if err != nil {
+		return err
+	}
+	devices = slices.DeleteFunc(devices, func(id objectid.ID) bool { return existing[id] })
+
+	for batch := range slices.Chunk(devices, batchSize) {
+		builders := make([]*gen.InboxEntryCreate, len(batch))
+		for i, deviceID := range batch {
+			create := client.InboxEntry.Create().
+				SetDeviceID(deviceID).
+				SetMessageID(m.ID)
+			if !m.ConversationID.IsZero() {
+				create.SetConversationID(m.ConversationID)
+			}
+			builders[i] = create
+		}
+		if err := client.InboxEntry.CreateBulk(builders...).Exec(ctx); err != nil {
+			return err
+		}
+		metrics.DeliveryInboxWritten.WithLabelValues(kind).Add(float64(len(batch)))
+	}
+	logger.With(ctx, box.logger).Debug("消息已写入收件箱",
+		zap.Int64("message_id", m.ID),
+		zap.Int("devices", len(devices)),
+	)
+	return nil
+}
@@ -0,0 +1,159 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/bytedance/sonic"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/conversationmember"
+	"msgcenter/platform/metrics"
+	"msgcenter/platform/mq"
+	"msgcenter/platform/ws"
+	"msgcenter/utils/logger"
+	"msgcenter/utils/objectid"
+)
+
+// 推送帧类型
+const (
+	FrameMessage = "message"
+	FrameRead    = "read"
+	FrameSignal  = "signal"
+)
+
+// Frame 推送给设备的 WebSocket 帧, 按 Type 填充对应的字段
+type Frame struct {
+	Type    string          `json:"type"`
+	Message json.RawMessage `json:"message,omitempty"`
+	Receipt json.RawMessage `json:"receipt,omitempty"`
+	Signal  json.RawMessage `json:"signal,omitempty"`
+}
+
+// Push 将消息推送到本实例上在线的接收设备. 每个实例独立订阅消息创建事件,
+// 推送失败不重试, 设备重连后从收件箱或会话拉取
+type Push struct {
+	client func() *gen.Client
+	hub    func() *ws.Hub
+	logger *zap.Logger
+}
+
+func NewPush(client func() *gen.Client, hub func() *ws.Hub, logger *zap.Logger) *Push {
+	return &Push{client: client, hub: hub, logger: logger}
+}
+
+// Handle 处理消息创建事件
+func (p *Push) Handle(ctx context.Context, msg *mq.Message) error {
+	hub := p.hub()
+	if hub == nil || hub.Count() == 0 {
+		return nil
+	}
+	m, err := decode(msg)
+	if err != nil {
+		return err
+	}
+	if expired(m) {
+		metrics.DeliveryExpired.WithLabelValues("push").Inc()
+		return nil
+	}
+
+	targets := make(map[string]struct{})
+	for _, id := range m.DeviceIds {
+		if deviceID := id.String(); hub.Online(deviceID) {
+			targets[deviceID] = struct{}{}
+		}
+	}
+	users := make([]string, 0, len(m.UserIds))
+	for _, id := range m.UserIds {
+		users = append(users, id.String())
+	}
+	if !m.ConversationID.IsZero() {
+		conv, err := p.client().Conversation.Get(ctx, m.ConversationID)
+		if err != nil && !gen.IsNotFound(err) {
+			return err
+		}
+		if conv != nil {
+			members, err := onlineMembers(ctx, p.client(), hub, conv)
+			if err != nil {
+				return err
+			}
+			users = append(users, members...)
+		}
+	}
+	for _, userID := range users {
+		for _, deviceID := range hub.Devices(userID) {
+			targets[deviceID] = struct{}{}
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	frame, err := sonic.Marshal(Frame{Type: FrameMessage, Message: msg.Payload})
+	if err != nil {
+		return err
+	}
+	sent := send(ctx, hub, targets, frame)
+	logger.With(ctx, p.logger).Debug("消息已推送",
+		zap.Int64("message_id", m.ID),
+		zap.Int("devices", len(targets)),
+		zap.Int("sent", sent),
+	)
+	return nil
+}
+
+// send 向设备发送帧, 返回成功进入发送队列的数量
+func send(ctx context.Context, hub *ws.Hub, targets map[string]struct{}, frame []byte) int {
+	sent := 0
+	for deviceID := range targets {
+		if hub.SendToDevice(ctx, deviceID, frame) {
+			sent++
+		}
+	}
+	metrics.DeliveryPushed.WithLabelValues("sent").Add(float64(sent))
+	metrics.DeliveryPushed.WithLabelValues("dropped").Add(float64(len(targets) - sent))
+	return sent
+}
+
+// onlineMembers 会话成员中在本实例在线的用户. 成员少于在线用户时查询全部成员,
+// 否则按在线用户查询, 使大群消息的查询量不超过本实例的在线用户数
+func onlineMembers(ctx context.Context, client *gen.Client, hub *ws.Hub, conv *gen.Conversation) ([]string, error) {
+	online := hub.Users()
+	if conv.MemberCount <= len(online) {
+		members, err := memberUserIDs(ctx, client, conv.ID)
+		if err != nil {
+			return nil, err
+		}
+		users := make([]string, len(members))
+		for i, id := range members {
+			users[i] = id.String()
+		}
+		return users, nil
+	}
+
+	ids := make([]objectid.ID, 0, len(online))
+	for _, userID := range online {
+		if id, err := objectid.Parse(userID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	var users []string
+	for batch := range slices.Chunk(ids, batchSize) {
+		members, err := client.ConversationMember.Query().
+			Where(
+				conversationmember.ConversationID(conv.ID),
+				conversationmember.UserIDIn(batch...),
+			).
+			Select(conversationmember.FieldUserID).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			users = append(users, m.UserID.String())
+		}
+	}
+	return users, nil
+}
@@ -0,0 +1,82 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bytedance/sonic"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/mq"
+	"msgcenter/platform/ws"
+	"msgcenter/service/conversation"
+	"msgcenter/utils/logger"
+)
+
+// Receipts 将已读回执推送到本实例上在线的会话成员, 与消息推送一样每个实例独立订阅、不重试.
+// 读者自己的其他设备也会收到, 用于多端同步角标; 成员数超过阈值的大群只推送给读者自己
+type Receipts struct {
+	client    func() *gen.Client
+	hub       func() *ws.Hub
+	threshold int
+	logger    *zap.Logger
+}
+
+func NewReceipts(client func() *gen.Client, hub func() *ws.Hub, threshold int, logger *zap.Logger) *Receipts {
+	if threshold <= 0 {
+		threshold = DefaultFanoutThreshold
+	}
+	return &Receipts{client: client, hub: hub, threshold: threshold, logger: logger}
+}
+
+// Handle 处理已读回执事件
+func (r *Receipts) Handle(ctx context.Context, msg *mq.Message) error {
+	hub := r.hub()
+	if hub == nil || hub.Count() == 0 {
+		return nil
+	}
+	var receipt conversation.Receipt
+	if err := sonic.Unmarshal(msg.Payload, &receipt); err != nil {
+		return mq.Permanent(fmt.Errorf("解析已读回执失败: %w", err))
+	}
+
+	users := []string{receipt.UserID.String()}
+	client := r.client()
+	conv, err := client.Conversation.Get(ctx, receipt.ConversationID)
+	if gen.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if conv.MemberCount <= r.threshold {
+		members, err := onlineMembers(ctx, client, hub, conv)
+		if err != nil {
+			return err
+		}
+		users = append(users, members...)
+	}
+
+	targets := make(map[string]struct{})
+	for _, userID := range users {
+		for _, deviceID := range hub.Devices(userID) {
+			targets[deviceID] = struct{}{}
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	frame, err := sonic.Marshal(Frame{Type: FrameRead, Receipt: msg.Payload})
+	if err != nil {
+		return err
+	}
+	sent := send(ctx, hub, targets, frame)
+	logger.With(ctx, r.logger).Debug("已读回执已推送",
+		zap.Stringer("conversation_id", receipt.ConversationID),
+		zap.Stringer("user_id", receipt.UserID),
+		zap.Int("devices", len(targets)),
+		zap.Int("sent", sent),
+	)
+	return nil
+}
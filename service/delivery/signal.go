@@ -0,0 +1,110 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/metrics"
+	"msgcenter/platform/ws"
+	"msgcenter/service/signal"
+	"msgcenter/utils/logger"
+)
+
+// Signals 将临时信号推送到本实例上在线的会话成员, 不包括发送者自己的设备.
+// 信号经 redis 发布订阅在实例间转发, 不持久化也不重试
+type Signals struct {
+	client func() *gen.Client
+	hub    func() *ws.Hub
+	logger *zap.Logger
+}
+
+func NewSignals(client func() *gen.Client, hub func() *ws.Hub, logger *zap.Logger) *Signals {
+	return &Signals{client: client, hub: hub, logger: logger}
+}
+
+// Run 订阅信号频道直到 ctx 结束或订阅断开
+func (s *Signals) Run(ctx context.Context, rdb *redis.Client) error {
+	if rdb == nil {
+		return errors.New("redis 未就绪")
+	}
+	sub := rdb.Subscribe(ctx, signal.Channel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := sub.Channel()
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return errors.New("信号订阅已断开")
+			}
+			if err := s.Handle(ctx, []byte(msg.Payload)); err != nil {
+				s.logger.Warn("信号推送失败", zap.Error(err))
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Handle 推送一条信号, 已过期的信号直接丢弃
+func (s *Signals) Handle(ctx context.Context, payload []byte) error {
+	hub := s.hub()
+	if hub == nil || hub.Count() == 0 {
+		return nil
+	}
+	var sig signal.Signal
+	if err := sonic.Unmarshal(payload, &sig); err != nil {
+		return fmt.Errorf("解析信号失败: %w", err)
+	}
+	if time.Now().After(sig.ExpireTime) {
+		metrics.Signals.WithLabelValues("expired").Inc()
+		return nil
+	}
+
+	client := s.client()
+	conv, err := client.Conversation.Get(ctx, sig.ConversationID)
+	if gen.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	members, err := onlineMembers(ctx, client, hub, conv)
+	if err != nil {
+		return err
+	}
+	sender := sig.UserID.String()
+	targets := make(map[string]struct{})
+	for _, userID := range members {
+		if userID == sender {
+			continue
+		}
+		for _, deviceID := range hub.Devices(userID) {
+			targets[deviceID] = struct{}{}
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	frame, err := sonic.Marshal(Frame{Type: FrameSignal, Signal: payload})
+	if err != nil {
+		return err
+	}
+	sent := send(ctx, hub, targets, frame)
+	logger.With(ctx, s.logger).Debug("信号已推送",
+		zap.String("kind", string(sig.Kind)),
+		zap.Stringer("conversation_id", sig.ConversationID),
+		zap.Int("devices", len(targets)),
+		zap.Int("sent", sent),
+	)
+	return nil
+}
@@ -0,0 +1,70 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/conversationmember"
+	"msgcenter/platform/mq"
+	"msgcenter/service/unread"
+	"msgcenter/utils/logger"
+	"msgcenter/utils/objectid"
+)
+
+// SubscriptionUnread 未读数计数的订阅, 所有实例共享
+const SubscriptionUnread = "unread"
+
+// Unread 会话消息计入发送者以外成员的未读数
+type Unread struct {
+	client func() *gen.Client
+	redis  func() *redis.Client
+	logger *zap.Logger
+}
+
+func NewUnread(client func() *gen.Client, redis func() *redis.Client, logger *zap.Logger) *Unread {
+	return &Unread{client: client, redis: redis, logger: logger}
+}
+
+// Handle 处理消息创建事件, 可重复消费
+func (u *Unread) Handle(ctx context.Context, msg *mq.Message) error {
+	m, err := decode(msg)
+	if err != nil {
+		return err
+	}
+	if m.ConversationID.IsZero() || expired(m) {
+		return nil
+	}
+	rdb := u.redis()
+	if rdb == nil {
+		return errors.New("redis 未就绪")
+	}
+	// 已读游标已越过该消息的成员(先标记了已读)不再计数
+	rows, err := u.client().ConversationMember.Query().
+		Where(
+			conversationmember.ConversationID(m.ConversationID),
+			conversationmember.ReadCursorLT(m.ID),
+		).
+		Select(conversationmember.FieldUserID).
+		All(ctx)
+	if err != nil {
+		return err
+	}
+	members := make([]objectid.ID, 0, len(rows))
+	for _, row := range rows {
+		if row.UserID != m.SenderID {
+			members = append(members, row.UserID)
+		}
+	}
+	if err := unread.Incr(ctx, rdb, m.ID, m.ConversationID, members); err != nil {
+		return err
+	}
+	logger.With(ctx, u.logger).Debug("未读数已更新",
+		zap.Int64("message_id", m.ID),
+		zap.Int("users", len(members)),
+	)
+	return nil
+}
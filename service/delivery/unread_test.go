@@ -0,0 +1,64 @@
+package delivery_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/conversation"
+	"msgcenter/platform/mq"
+	conversationsvc "msgcenter/service/conversation"
+	"msgcenter/service/delivery"
+	"msgcenter/testkit"
+	"msgcenter/utils/objectid"
+)
+
+func TestUnreadSkipsSenderAndReadMembers(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	rdb, _ := testkit.NewRedisClient(t)
+	users := make([]objectid.ID, 3)
+	for i := range users {
+		users[i] = client.User.Create().SetName("u").SaveX(ctx).ID
+	}
+	conv, err := conversationsvc.Create(ctx, client, conversationsvc.CreateInput{
+		Type: conversation.TypeGroup, OwnerID: users[0], MemberIDs: users[1:], MemberLimit: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := client.Message.Create().
+		SetConversationID(conv.ID).
+		SetSenderID(users[0]).
+		SetContentType("text").
+		SetPayload(json.RawMessage(`"hi"`)).
+		SaveX(ctx)
+	// users[2] 在消息事件到达前已标记已读
+	if _, _, err := conversationsvc.MarkRead(ctx, client, conv.ID, users[2], m.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	u := delivery.NewUnread(
+		func() *gen.Client { return client },
+		func() *redis.Client { return rdb },
+		zap.NewNop(),
+	)
+	body, _ := sonic.Marshal(m)
+	for range 2 {
+		if err := u.Handle(ctx, &mq.Message{Payload: body}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[objectid.ID]string{users[0]: "", users[1]: "1", users[2]: ""}
+	for id, n := range want {
+		if got := rdb.HGet(ctx, "unread:"+id.String(), conv.ID.String()).Val(); got != n {
+			t.Fatalf("用户 %s 的未读数 = %q, want %q", id, got, n)
+		}
+	}
+}
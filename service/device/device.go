@@ -0,0 +1,32 @@
+package device
+
+import (
+	"context"
+	"errors"
+
+	"msgcenter/platform/ent/gen"
+	entdevice "msgcenter/platform/ent/gen/device"
+	"msgcenter/platform/ent/gen/userdevicerelation"
+	"msgcenter/utils/objectid"
+)
+
+var ErrNotBound = errors.New("设备未绑定到该用户")
+
+// CheckBound 校验设备存在且未删除, 并已通过用户设备关联绑定到用户.
+// 建立长连接前调用, 防止持有令牌的用户接收其他用户设备的消息
+func CheckBound(ctx context.Context, client *gen.Client, userID, deviceID objectid.ID) error {
+	ok, err := client.UserDeviceRelation.Query().
+		Where(
+			userdevicerelation.UserID(userID),
+			userdevicerelation.DeviceID(deviceID),
+			userdevicerelation.HasDeviceWith(entdevice.DeleteFlag(false)),
+		).
+		Exist(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotBound
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package message
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"msgcenter/platform/ent"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/device"
+	"msgcenter/platform/ent/gen/user"
+	"msgcenter/platform/outbox"
+	"msgcenter/service/conversation"
+	"msgcenter/utils/objectid"
+)
+
+// TopicCreated 消息创建事件的主题, 事件体为消息的 JSON
+const TopicCreated = "message-created"
+
+var (
+	ErrNoRecipient    = errors.New("消息至少需要一个接收方")
+	ErrSenderNotFound = errors.New("发送者不存在")
+	ErrUserNotFound   = errors.New("接收用户不存在")
+	ErrDeviceNotFound = errors.New("接收设备不存在")
+)
+
+// Input 创建消息的参数
+type Input struct {
+	SenderID       objectid.ID
+	ConversationID objectid.ID
+	UserIDs        []objectid.ID
+	DeviceIDs      []objectid.ID
+	ContentType    string
+	Payload        json.RawMessage
+	TTL            time.Duration // 有效期, 0 表示不过期
+}
+
+// Create 校验发送者和接收方后保存消息, 并在同一事务中写入消息创建事件, 由发件箱发布到消息队列
+func Create(ctx context.Context, client *gen.Client, in Input) (*gen.Message, error) {
+	in, err := Prepare(ctx, client, in)
+	if err != nil {
+		return nil, err
+	}
+	var msg *gen.Message
+	err = ent.WithTx(ctx, client, func(tx *gen.Tx) error {
+		msg, err = Save(ctx, tx, in)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg.Unwrap(), nil
+}
+
+// Prepare 去重接收方并校验发送者和接收方, 返回可交给 Save 的参数
+func Prepare(ctx context.Context, client *gen.Client, in Input) (Input, error) {
+	in.UserIDs = objectid.Compact(in.UserIDs)
+	in.DeviceIDs = objectid.Compact(in.DeviceIDs)
+	if in.ConversationID.IsZero() && len(in.UserIDs) == 0 && len(in.DeviceIDs) == 0 {
+		return in, ErrNoRecipient
+	}
+	return in, validate(ctx, client, in)
+}
+
+// Save 在事务中保存已校验的消息并写入消息创建事件
+func Save(ctx context.Context, tx *gen.Tx, in Input) (*gen.Message, error) {
+	create := tx.Message.Create().
+		SetContentType(in.ContentType).
+		SetPayload(in.Payload)
+	if !in.SenderID.IsZero() {
+		create.SetSenderID(in.SenderID)
+	}
+	if !in.ConversationID.IsZero() {
+		create.SetConversationID(in.ConversationID)
+	}
+	if len(in.UserIDs) > 0 {
+		create.SetUserIds(in.UserIDs)
+	}
+	if len(in.DeviceIDs) > 0 {
+		create.SetDeviceIds(in.DeviceIDs)
+	}
+	if in.TTL > 0 {
+		create.SetExpireTime(time.Now().Add(in.TTL))
+	}
+	msg, err := create.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := sonic.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	id := strconv.FormatInt(msg.ID, 10)
+	err = outbox.Add(ctx, tx.Client(), outbox.Event{
+		Topic:       TopicCreated,
+		Key:         id,
+		Payload:     body,
+		Aggregate:   gen.TypeMessage,
+		AggregateID: id,
+	})
+	return msg, err
+}
+
+// validate 检查发送者和直接指定的接收用户、设备是否存在, 已软删除的视为不存在;
+// 发送到会话时发送者必须是未被禁言的成员
+func validate(ctx context.Context, client *gen.Client, in Input) error {
+	if !in.SenderID.IsZero() {
+		exist, err := client.User.Query().Where(user.ID(in.SenderID)).Exist(ctx)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return ErrSenderNotFound
+		}
+	}
+	if !in.ConversationID.IsZero() {
+		if err := conversation.CheckSend(ctx, client, in.ConversationID, in.SenderID); err != nil {
+			return err
+		}
+	}
+	if len(in.UserIDs) > 0 {
+		n, err := client.User.Query().Where(user.IDIn(in.UserIDs...)).Count(ctx)
+		if err != nil {
+			return err
+		}
+		if n != len(in.UserIDs) {
+			return ErrUserNotFound
+		}
+	}
+	if len(in.DeviceIDs) > 0 {
+		n, err := client.Device.Query().Where(device.IDIn(in.DeviceIDs...)).Count(ctx)
+		if err != nil {
+			return err
+		}
+		if n != len(in.DeviceIDs) {
+			return ErrDeviceNotFound
+		}
+	}
+	return nil
+}
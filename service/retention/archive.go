@@ -0,0 +1,66 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bytedance/sonic"
+
+	"msgcenter/platform/ent/gen"
+)
+
+// archive 将一批消息写入 gzip 压缩的 JSONL 文件, 每行一条消息.
+// 文件按批次中最早消息的创建日期分目录, 以消息ID范围命名, 删除失败后重新归档同一批消息时覆盖原文件;
+// 先写临时文件再重命名, 不会留下不完整的归档
+func archive(dir, reason string, msgs []*gen.Message) (string, error) {
+	dir = filepath.Join(dir, msgs[0].CreateTime.Format("20060102"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("messages-%s-%d-%d.jsonl.gz", reason, msgs[0].ID, msgs[len(msgs)-1].ID)
+	path := filepath.Join(dir, name)
+
+	f, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if err := write(f, msgs); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp, path)
+}
+
+func write(f *os.File, msgs []*gen.Message) error {
+	zw := gzip.NewWriter(f)
+	w := bufio.NewWriter(zw)
+	for _, m := range msgs {
+		line, err := sonic.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,72 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"msgcenter/platform/ent/gen"
+)
+
+func readArchive(t *testing.T, path string) []int64 {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	sc := bufio.NewScanner(zr)
+	for sc.Scan() {
+		var m gen.Message
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, m.ID)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	created := time.Date(2026, 1, 2, 10, 0, 0, 0, time.Local)
+	msgs := []*gen.Message{
+		{ID: 1, ContentType: "text", Payload: json.RawMessage(`"a"`), CreateTime: created},
+		{ID: 2, ContentType: "text", Payload: json.RawMessage(`"b"`), CreateTime: created.Add(48 * time.Hour)},
+	}
+
+	path, err := archive(dir, reasonRetention, msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 目录按最早消息的创建日期, 与归档执行的日期无关
+	want := filepath.Join(dir, "20260102", "messages-retention-1-2.jsonl.gz")
+	if path != want {
+		t.Fatalf("归档文件 = %s, want %s", path, want)
+	}
+	if ids := readArchive(t, path); len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("归档内容 = %v, want [1 2]", ids)
+	}
+
+	// 删除失败后重新归档同一批消息, 覆盖原文件而不是在新日期下重复归档
+	again, err := archive(dir, reasonRetention, msgs)
+	if err != nil || again != path {
+		t.Fatalf("重新归档 = %s, %v, want %s", again, err, path)
+	}
+	entries, _ := os.ReadDir(dir)
+	files, _ := os.ReadDir(filepath.Join(dir, "20260102"))
+	if len(entries) != 1 || len(files) != 1 {
+		t.Fatalf("归档目录 = %d 个, 文件 = %d 个, 不应有重复或临时文件", len(entries), len(files))
+	}
+}
@@ -0,0 +1,249 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	consulconfig "msgcenter/platform/consul/config"
+	"msgcenter/platform/ent"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/inboxentry"
+	"msgcenter/platform/ent/gen/message"
+	"msgcenter/platform/ent/gen/predicate"
+	"msgcenter/platform/metrics"
+	"msgcenter/utils/objectid"
+	"msgcenter/utils/snowflake"
+)
+
+// 清理方式
+const (
+	ActionDelete  = "delete"
+	ActionArchive = "archive"
+)
+
+// 清理原因, 用于指标和归档文件名
+const (
+	reasonExpired   = "expired"
+	reasonRetention = "retention"
+)
+
+const (
+	DefaultInterval  = time.Hour
+	DefaultBatchSize = 1000
+)
+
+// Policy 超过 MaxAge 的消息按 Action 清理, MaxAge 为 0 表示永久保留
+type Policy struct {
+	MaxAge time.Duration
+	Action string
+}
+
+// Config 解析后的保留策略
+type Config struct {
+	Interval      time.Duration
+	BatchSize     int
+	ArchiveDir    string
+	Default       Policy
+	Conversations map[objectid.ID]Policy
+}
+
+// Parse 校验 consul 中的保留配置并补齐默认值. 未配置 action 时删除
+func Parse(c consulconfig.Retention) (Config, error) {
+	cfg := Config{
+		Interval:      time.Duration(c.Interval) * time.Second,
+		BatchSize:     c.BatchSize,
+		ArchiveDir:    c.ArchiveDir,
+		Conversations: make(map[objectid.ID]Policy, len(c.Conversations)),
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+
+	var err error
+	if cfg.Default, err = parsePolicy(c.Default, cfg.ArchiveDir); err != nil {
+		return cfg, fmt.Errorf("default: %w", err)
+	}
+	for key, p := range c.Conversations {
+		id, err := objectid.Parse(key)
+		if err != nil {
+			return cfg, fmt.Errorf("conversations: %s 不是有效的会话ID", key)
+		}
+		if cfg.Conversations[id], err = parsePolicy(p, cfg.ArchiveDir); err != nil {
+			return cfg, fmt.Errorf("conversations.%s: %w", key, err)
+		}
+	}
+	return cfg, nil
+}
+
+func parsePolicy(p consulconfig.RetentionPolicy, archiveDir string) (Policy, error) {
+	if p.MaxAge < 0 {
+		return Policy{}, fmt.Errorf("max_age 不能为负数")
+	}
+	action := p.Action
+	switch action {
+	case "":
+		action = ActionDelete
+	case ActionDelete:
+	case ActionArchive:
+		if archiveDir == "" {
+			return Policy{}, fmt.Errorf("action 为 archive 时必须配置 archive_dir")
+		}
+	default:
+		return Policy{}, fmt.Errorf("action 只能为 delete 或 archive")
+	}
+	return Policy{MaxAge: time.Duration(p.MaxAge) * time.Second, Action: action}, nil
+}
+
+// Job 按保留策略清理消息, 多实例部署时只能由 leader 运行.
+// 每轮读取最新配置, 修改 consul 后下一轮生效; 清理后 redis 中的未读数由定期对账修正
+type Job struct {
+	client func() *gen.Client
+	config func() (Config, error)
+	logger *zap.Logger
+}
+
+func NewJob(client func() *gen.Client, config func() (Config, error), logger *zap.Logger) *Job {
+	return &Job{client: client, config: config, logger: logger}
+}
+
+// Run 循环清理直到 ctx 取消, 配置错误时跳过本轮
+func (j *Job) Run(ctx context.Context) {
+	j.logger.Info("消息清理已启动")
+	defer j.logger.Info("消息清理已停止")
+
+	for {
+		interval := DefaultInterval
+		cfg, err := j.config()
+		if err != nil {
+			j.logger.Error("消息保留配置错误, 跳过本轮清理", zap.Error(err))
+		} else {
+			interval = cfg.Interval
+			if _, err := j.RunOnce(ctx, cfg); err != nil && ctx.Err() == nil {
+				metrics.RetentionErrors.Inc()
+				j.logger.Warn("清理消息失败", zap.Error(err))
+			}
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunOnce 清理已过期和超过保留时间的消息, 返回清理的数量
+func (j *Job) RunOnce(ctx context.Context, cfg Config) (int, error) {
+	client := j.client()
+	if client == nil {
+		return 0, fmt.Errorf("数据库未初始化")
+	}
+	now := time.Now()
+	total := 0
+
+	// 已过有效期的消息
+	n, err := j.sweep(ctx, client, cfg, reasonExpired, cfg.Default.Action,
+		message.ExpireTimeLTE(now))
+	total += n
+	if err != nil {
+		return total, err
+	}
+
+	// 单独配置了策略的会话
+	overrides := make([]objectid.ID, 0, len(cfg.Conversations))
+	for id, p := range cfg.Conversations {
+		overrides = append(overrides, id)
+		if p.MaxAge <= 0 {
+			continue
+		}
+		n, err := j.sweep(ctx, client, cfg, reasonRetention, p.Action,
+			message.ConversationID(id),
+			message.IDLT(snowflake.MinID(now.Add(-p.MaxAge))),
+		)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	// 其余会话和直接发送的消息
+	if cfg.Default.MaxAge > 0 {
+		preds := []predicate.Message{message.IDLT(snowflake.MinID(now.Add(-cfg.Default.MaxAge)))}
+		if len(overrides) > 0 {
+			preds = append(preds, message.Or(
+				message.ConversationIDIsNil(),
+				message.ConversationIDNotIn(overrides...),
+			))
+		}
+		n, err := j.sweep(ctx, client, cfg, reasonRetention, cfg.Default.Action, preds...)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	if total > 0 {
+		j.logger.Info("消息清理完成", zap.Int("count", total))
+	}
+	return total, nil
+}
+
+// sweep 按主键顺序分批清理满足条件的消息
+func (j *Job) sweep(ctx context.Context, client *gen.Client, cfg Config, reason, action string, preds ...predicate.Message) (int, error) {
+	total := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		msgs, err := client.Message.Query().
+			Where(preds...).
+			Order(message.ByID()).
+			Limit(cfg.BatchSize).
+			All(ctx)
+		if err != nil {
+			return total, err
+		}
+		if len(msgs) == 0 {
+			return total, nil
+		}
+		if action == ActionArchive {
+			path, err := archive(cfg.ArchiveDir, reason, msgs)
+			if err != nil {
+				return total, fmt.Errorf("归档消息失败: %w", err)
+			}
+			j.logger.Debug("消息已归档", zap.String("file", path), zap.Int("count", len(msgs)))
+		}
+		if err := remove(ctx, client, msgs); err != nil {
+			return total, err
+		}
+		total += len(msgs)
+		metrics.RetentionMessages.WithLabelValues(reason, action).Add(float64(len(msgs)))
+		if len(msgs) < cfg.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// remove 删除消息及其收件箱条目. 归档后删除失败时下一轮重新归档, 文件按ID范围命名会被覆盖
+func remove(ctx context.Context, client *gen.Client, msgs []*gen.Message) error {
+	ids := make([]int64, len(msgs))
+	for i, m := range msgs {
+		ids[i] = m.ID
+	}
+	return ent.WithTx(ctx, client, func(tx *gen.Tx) error {
+		for batch := range slices.Chunk(ids, 500) {
+			if _, err := tx.InboxEntry.Delete().Where(inboxentry.MessageIDIn(batch...)).Exec(ctx); err != nil {
+				return err
+			}
+			if _, err := tx.Message.Delete().Where(message.IDIn(batch...)).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,60 @@
+package retention_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/service/retention"
+	"msgcenter/testkit"
+	"msgcenter/utils/snowflake"
+)
+
+func TestRunOnceArchivesOldMessages(t *testing.T) {
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	dir := t.TempDir()
+
+	old := time.Now().Add(-72 * time.Hour)
+	for i := range 3 {
+		client.Message.Create().
+			SetID(snowflake.MinID(old) + int64(i)).
+			SetContentType("text").
+			SetPayload(json.RawMessage(`"old"`)).
+			SetCreateTime(old).
+			ExecX(ctx)
+	}
+	recent := client.Message.Create().
+		SetContentType("text").
+		SetPayload(json.RawMessage(`"new"`)).
+		SaveX(ctx)
+
+	cfg := retention.Config{
+		BatchSize:  2,
+		ArchiveDir: dir,
+		Default:    retention.Policy{MaxAge: 24 * time.Hour, Action: retention.ActionArchive},
+	}
+	job := retention.NewJob(func() *gen.Client { return client }, nil, zap.NewNop())
+	n, err := job.RunOnce(ctx, cfg)
+	if err != nil || n != 3 {
+		t.Fatalf("RunOnce = %d, %v, want 3", n, err)
+	}
+	if ids := client.Message.Query().IDsX(ctx); len(ids) != 1 || ids[0] != recent.ID {
+		t.Fatalf("剩余消息 = %v, want [%d]", ids, recent.ID)
+	}
+
+	// 两批归档都在消息创建日期的目录下
+	files, err := filepath.Glob(filepath.Join(dir, old.Format("20060102"), "messages-retention-*.jsonl.gz"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("归档文件 = %v, %v, want 2 个", files, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("归档目录数 = %d, want 1", len(entries))
+	}
+}
@@ -0,0 +1,218 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/predicate"
+	"msgcenter/platform/ent/gen/scheduledmessage"
+	"msgcenter/service/message"
+	"msgcenter/service/topic"
+	"msgcenter/utils/objectid"
+)
+
+// dueKey 到期索引, member 为定时消息ID, score 为计划发送时间的毫秒时间戳.
+// 索引可能缺失或过时, 以数据库中的状态和时间为准
+const dueKey = "schedule:due"
+
+var (
+	ErrNotFound   = errors.New("定时消息不存在")
+	ErrNotPending = errors.New("定时消息已发送、已取消或已失败")
+	ErrPastTime   = errors.New("deliver_time 必须晚于当前时间")
+	ErrTooFar     = errors.New("deliver_time 超过最大定时范围")
+	ErrRecipient  = errors.New("topic 不能与会话、用户或设备同时指定")
+)
+
+// Input 创建定时消息的参数. 指定 Topic 时按主题广播发送, 否则按普通消息发送
+type Input struct {
+	message.Input
+	Topic       string
+	Target      string
+	DeliverTime time.Time
+}
+
+func (in Input) publish() topic.PublishInput {
+	return topic.PublishInput{
+		SenderID:    in.SenderID,
+		ContentType: in.ContentType,
+		Payload:     in.Payload,
+		Target:      in.Target,
+		TTL:         in.TTL,
+	}
+}
+
+// Create 校验接收方后保存定时消息并加入到期索引. 写入索引失败不影响创建, 由定期重建补齐
+func Create(ctx context.Context, client *gen.Client, rdb *redis.Client, maxDelay time.Duration, in Input) (*gen.ScheduledMessage, error) {
+	if err := checkTime(in.DeliverTime, maxDelay); err != nil {
+		return nil, err
+	}
+	if in.Topic != "" {
+		if !in.ConversationID.IsZero() || len(in.UserIDs) > 0 || len(in.DeviceIDs) > 0 {
+			return nil, ErrRecipient
+		}
+		if _, err := topic.Prepare(ctx, client, in.Topic, in.publish()); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if in.Input, err = message.Prepare(ctx, client, in.Input); err != nil {
+			return nil, err
+		}
+	}
+
+	create := client.ScheduledMessage.Create().
+		SetContentType(in.ContentType).
+		SetPayload(in.Payload).
+		SetTTL(int(in.TTL / time.Second)).
+		SetDeliverTime(in.DeliverTime)
+	if !in.SenderID.IsZero() {
+		create.SetSenderID(in.SenderID)
+	}
+	if !in.ConversationID.IsZero() {
+		create.SetConversationID(in.ConversationID)
+	}
+	if len(in.UserIDs) > 0 {
+		create.SetUserIds(in.UserIDs)
+	}
+	if len(in.DeviceIDs) > 0 {
+		create.SetDeviceIds(in.DeviceIDs)
+	}
+	if in.Topic != "" {
+		create.SetTopic(in.Topic)
+	}
+	if in.Target != "" {
+		create.SetTarget(in.Target)
+	}
+	sm, err := create.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_ = index(ctx, rdb, sm)
+	return sm, nil
+}
+
+// Get 查询定时消息. sender 非零值时只能查询该用户创建的定时消息, 其他用户的按不存在处理
+func Get(ctx context.Context, client *gen.Client, sender, id objectid.ID) (*gen.ScheduledMessage, error) {
+	query := client.ScheduledMessage.Query().Where(scheduledmessage.ID(id))
+	if !sender.IsZero() {
+		query.Where(scheduledmessage.SenderID(sender))
+	}
+	sm, err := query.Only(ctx)
+	if gen.IsNotFound(err) {
+		return nil, ErrNotFound
+	}
+	return sm, err
+}
+
+// Filter 定时消息查询条件, 零值字段不过滤
+type Filter struct {
+	SenderID objectid.ID
+	Status   scheduledmessage.Status
+	Limit    int
+	Offset   int
+}
+
+// List 按计划发送时间查询定时消息
+func List(ctx context.Context, client *gen.Client, f Filter) ([]*gen.ScheduledMessage, error) {
+	var preds []predicate.ScheduledMessage
+	if !f.SenderID.IsZero() {
+		preds = append(preds, scheduledmessage.SenderID(f.SenderID))
+	}
+	if f.Status != "" {
+		preds = append(preds, scheduledmessage.StatusEQ(f.Status))
+	}
+	return client.ScheduledMessage.Query().
+		Where(preds...).
+		Order(scheduledmessage.ByDeliverTime(), scheduledmessage.ByID()).
+		Limit(f.Limit).
+		Offset(f.Offset).
+		All(ctx)
+}
+
+// Cancel 取消未发送的定时消息, sender 与 Get 相同
+func Cancel(ctx context.Context, client *gen.Client, rdb *redis.Client, sender, id objectid.ID) (*gen.ScheduledMessage, error) {
+	sm, err := transition(ctx, client, sender, id, func(u *gen.ScheduledMessageUpdate) {
+		u.SetStatus(scheduledmessage.StatusCanceled)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rdb != nil {
+		// 删除失败时到期后按数据库状态跳过
+		_ = rdb.ZRem(ctx, dueKey, id.String()).Err()
+	}
+	return sm, nil
+}
+
+// Reschedule 修改未发送的定时消息的发送时间, sender 与 Get 相同
+func Reschedule(ctx context.Context, client *gen.Client, rdb *redis.Client, maxDelay time.Duration, sender, id objectid.ID, deliverTime time.Time) (*gen.ScheduledMessage, error) {
+	if err := checkTime(deliverTime, maxDelay); err != nil {
+		return nil, err
+	}
+	// 改期后重新计算失败次数
+	sm, err := transition(ctx, client, sender, id, func(u *gen.ScheduledMessageUpdate) {
+		u.SetDeliverTime(deliverTime).SetAttempts(0).ClearRetryTime()
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 写入失败时旧的分数到期后按数据库中的时间重新入队
+	_ = index(ctx, rdb, sm)
+	return sm, nil
+}
+
+// transition 只更新仍为 Pending 的定时消息, 与到期发送互斥
+func transition(ctx context.Context, client *gen.Client, sender, id objectid.ID, set func(*gen.ScheduledMessageUpdate)) (*gen.ScheduledMessage, error) {
+	update := client.ScheduledMessage.Update().
+		Where(scheduledmessage.ID(id), scheduledmessage.StatusEQ(scheduledmessage.StatusPending))
+	if !sender.IsZero() {
+		update.Where(scheduledmessage.SenderID(sender))
+	}
+	set(update)
+	n, err := update.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		if _, err := Get(ctx, client, sender, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotPending
+	}
+	return Get(ctx, client, sender, id)
+}
+
+func checkTime(t time.Time, maxDelay time.Duration) error {
+	now := time.Now()
+	if !t.After(now) {
+		return ErrPastTime
+	}
+	if maxDelay > 0 && t.After(now.Add(maxDelay)) {
+		return ErrTooFar
+	}
+	return nil
+}
+
+// due 定时消息下次可以发送的时间, 失败后为计划时间和重试时间中较晚的一个
+func due(sm *gen.ScheduledMessage) time.Time {
+	if sm.RetryTime != nil && sm.RetryTime.After(sm.DeliverTime) {
+		return *sm.RetryTime
+	}
+	return sm.DeliverTime
+}
+
+// index 将定时消息按下次发送时间加入到期索引, redis 不可用时跳过
+func index(ctx context.Context, rdb *redis.Client, sms ...*gen.ScheduledMessage) error {
+	if rdb == nil || len(sms) == 0 {
+		return nil
+	}
+	members := make([]redis.Z, len(sms))
+	for i, sm := range sms {
+		members[i] = redis.Z{Score: float64(due(sm).UnixMilli()), Member: sm.ID.String()}
+	}
+	return rdb.ZAdd(ctx, dueKey, members...).Err()
+}
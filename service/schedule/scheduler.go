@@ -0,0 +1,387 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/scheduledmessage"
+	"msgcenter/platform/metrics"
+	"msgcenter/service/conversation"
+	"msgcenter/service/message"
+	"msgcenter/service/topic"
+	"msgcenter/utils/objectid"
+)
+
+// Options 调度配置
+type Options struct {
+	Interval     time.Duration // 检查到期消息的间隔
+	BatchSize    int           // 每次发送的到期消息数
+	SyncInterval time.Duration // 从数据库重建到期索引的间隔
+	Horizon      time.Duration // 重建索引时加载的未来时间窗口
+	MaxAttempts  int           // 单条消息的最大发送次数, 超过后标记失败
+	Backoff      time.Duration // 首次失败后的重试间隔, 之后按指数增长
+	MaxBackoff   time.Duration // 重试间隔上限
+}
+
+// Scheduler 发送到期的定时消息, 多实例部署时只能由 leader 运行.
+// 当选后先从数据库重建到期索引, 之后定期重建以补齐写入索引失败或 redis 数据丢失的消息;
+// redis 不可用时直接按数据库查询到期消息
+type Scheduler struct {
+	client func() *gen.Client
+	redis  func() *redis.Client
+	opts   Options
+	logger *zap.Logger
+}
+
+func NewScheduler(client func() *gen.Client, redis func() *redis.Client, opts Options, logger *zap.Logger) *Scheduler {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Minute
+	}
+	if opts.Horizon < opts.SyncInterval {
+		opts.Horizon = 2 * opts.SyncInterval
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 10
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 5 * time.Second
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = max(10*time.Minute, opts.Backoff)
+	}
+	return &Scheduler{client: client, redis: redis, opts: opts, logger: logger}
+}
+
+// Run 循环发送到期消息直到 ctx 取消
+func (s *Scheduler) Run(ctx context.Context) {
+	s.logger.Info("定时消息调度已启动")
+	defer s.logger.Info("定时消息调度已停止")
+
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	var lastSync time.Time
+
+	for {
+		rdb := s.redis()
+		if rdb != nil && time.Since(lastSync) >= s.opts.SyncInterval {
+			if err := s.sync(ctx, rdb); err != nil {
+				if ctx.Err() == nil {
+					s.logger.Warn("重建定时消息索引失败", zap.Error(err))
+				}
+			} else {
+				lastSync = time.Now()
+			}
+		}
+
+		n, err := s.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Warn("发送定时消息失败", zap.Error(err))
+		}
+		// 整批处理完说明可能还有到期消息, 立即继续
+		if err == nil && n == s.opts.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunOnce 处理一批到期消息, 返回处理的数量
+func (s *Scheduler) RunOnce(ctx context.Context) (int, error) {
+	client := s.client()
+	if client == nil {
+		return 0, fmt.Errorf("数据库未初始化")
+	}
+	rdb := s.redis()
+	ids, err := s.due(ctx, client, rdb)
+	if err != nil {
+		return 0, err
+	}
+	// 单条失败不阻塞其他到期消息, 按退避时间推迟重试
+	var errs []error
+	for _, id := range ids {
+		if err := s.deliver(ctx, client, rdb, id); err != nil {
+			errs = append(errs, fmt.Errorf("发送定时消息 %s 失败: %w", id, err))
+		}
+	}
+	return len(ids), errors.Join(errs...)
+}
+
+// due 到期的定时消息ID
+func (s *Scheduler) due(ctx context.Context, client *gen.Client, rdb *redis.Client) ([]objectid.ID, error) {
+	now := time.Now()
+	if rdb == nil {
+		return client.ScheduledMessage.Query().
+			Where(
+				scheduledmessage.StatusEQ(scheduledmessage.StatusPending),
+				scheduledmessage.DeliverTimeLTE(now),
+				scheduledmessage.Or(scheduledmessage.RetryTimeIsNil(), scheduledmessage.RetryTimeLTE(now)),
+			).
+			Order(scheduledmessage.ByDeliverTime()).
+			Limit(s.opts.BatchSize).
+			IDs(ctx)
+	}
+
+	members, err := rdb.ZRangeByScore(ctx, dueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: int64(s.opts.BatchSize),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]objectid.ID, 0, len(members))
+	for _, m := range members {
+		id, err := objectid.Parse(m)
+		if err != nil {
+			rdb.ZRem(ctx, dueKey, m)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// deliver 发送一条到期消息. 标记已发送与保存消息在同一事务中, 重复调用不会重复发送;
+// 接收方已失效时标记为失败, 其他错误推迟重试
+func (s *Scheduler) deliver(ctx context.Context, client *gen.Client, rdb *redis.Client, id objectid.ID) error {
+	sm, err := client.ScheduledMessage.Get(ctx, id)
+	if gen.IsNotFound(err) {
+		return s.unindex(ctx, rdb, id)
+	}
+	if err != nil {
+		return err
+	}
+	if sm.Status != scheduledmessage.StatusPending {
+		return s.unindex(ctx, rdb, id)
+	}
+	if due(sm).After(time.Now()) {
+		// 已改期或等待重试, 按新的时间重新入队
+		return index(ctx, rdb, sm)
+	}
+
+	send, err := s.prepare(ctx, client, sm)
+	if permanent(err) {
+		return s.fail(ctx, client, rdb, sm, err)
+	}
+	if err != nil {
+		return s.retry(ctx, client, rdb, sm, err)
+	}
+	now := time.Now()
+	err = ent.WithTx(ctx, client, func(tx *gen.Tx) error {
+		update := tx.ScheduledMessage.Update().
+			Where(
+				scheduledmessage.ID(sm.ID),
+				scheduledmessage.StatusEQ(scheduledmessage.StatusPending),
+				scheduledmessage.DeliverTimeLTE(now),
+			).
+			SetStatus(scheduledmessage.StatusSent).
+			SetSentTime(now)
+		if err := send(tx, update); err != nil {
+			return err
+		}
+		n, err := update.Save(ctx)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			// 已被取消或改期, 回滚已保存的消息
+			return errClaimed
+		}
+		return nil
+	})
+	if errors.Is(err, errClaimed) {
+		return nil
+	}
+	if err != nil {
+		return s.retry(ctx, client, rdb, sm, err)
+	}
+	metrics.ScheduledSent.WithLabelValues(string(scheduledmessage.StatusSent)).Inc()
+	metrics.ScheduledDelay.Observe(now.Sub(sm.DeliverTime).Seconds())
+	s.logger.Info("定时消息已发送", zap.Stringer("id", sm.ID), zap.Time("deliver_time", sm.DeliverTime))
+	return s.unindex(ctx, rdb, id)
+}
+
+var errClaimed = errors.New("定时消息已被取消或改期")
+
+// permanent 接收方或主题已失效等重试也无法成功的错误
+func permanent(err error) bool {
+	for _, target := range []error{
+		message.ErrNoRecipient,
+		message.ErrSenderNotFound,
+		message.ErrUserNotFound,
+		message.ErrDeviceNotFound,
+		conversation.ErrNotFound,
+		conversation.ErrNotMember,
+		conversation.ErrMuted,
+		topic.ErrNotFound,
+		topic.ErrSenderNotFound,
+		topic.ErrInvalidTarget,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// prepare 重新校验接收方, 返回在事务中保存消息并记录结果的函数
+func (s *Scheduler) prepare(ctx context.Context, client *gen.Client, sm *gen.ScheduledMessage) (func(*gen.Tx, *gen.ScheduledMessageUpdate) error, error) {
+	ttl := time.Duration(sm.TTL) * time.Second
+	if sm.Topic != "" {
+		in := topic.PublishInput{
+			SenderID:    sm.SenderID,
+			ContentType: sm.ContentType,
+			Payload:     sm.Payload,
+			Target:      sm.Target,
+			TTL:         ttl,
+		}
+		t, err := topic.Prepare(ctx, client, sm.Topic, in)
+		if err != nil {
+			return nil, err
+		}
+		return func(tx *gen.Tx, update *gen.ScheduledMessageUpdate) error {
+			b, err := topic.Save(ctx, tx, t, in)
+			if err != nil {
+				return err
+			}
+			update.SetMessageID(b.MessageID).SetBroadcastID(b.ID)
+			return nil
+		}, nil
+	}
+
+	in, err := message.Prepare(ctx, client, message.Input{
+		SenderID:       sm.SenderID,
+		ConversationID: sm.ConversationID,
+		UserIDs:        sm.UserIds,
+		DeviceIDs:      sm.DeviceIds,
+		ContentType:    sm.ContentType,
+		Payload:        sm.Payload,
+		TTL:            ttl,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(tx *gen.Tx, update *gen.ScheduledMessageUpdate) error {
+		msg, err := message.Save(ctx, tx, in)
+		if err != nil {
+			return err
+		}
+		update.SetMessageID(msg.ID)
+		return nil
+	}, nil
+}
+
+// retry 记录失败并按退避时间推迟重试, 避免一直失败的消息占据到期索引的头部;
+// 达到最大次数后标记为失败. 返回原错误供调用方记录
+func (s *Scheduler) retry(ctx context.Context, client *gen.Client, rdb *redis.Client, sm *gen.ScheduledMessage, cause error) error {
+	sm.Attempts++
+	if sm.Attempts >= s.opts.MaxAttempts {
+		if err := s.fail(ctx, client, rdb, sm, fmt.Errorf("发送 %d 次均失败: %w", sm.Attempts, cause)); err != nil {
+			return err
+		}
+		return cause
+	}
+	retryTime := time.Now().Add(s.backoff(sm.Attempts))
+	sm.RetryTime = &retryTime
+	err := client.ScheduledMessage.Update().
+		Where(scheduledmessage.ID(sm.ID), scheduledmessage.StatusEQ(scheduledmessage.StatusPending)).
+		SetAttempts(sm.Attempts).
+		SetRetryTime(retryTime).
+		SetError(cause.Error()).
+		Exec(ctx)
+	if err != nil {
+		return errors.Join(cause, err)
+	}
+	if err := index(ctx, rdb, sm); err != nil {
+		return errors.Join(cause, err)
+	}
+	return cause
+}
+
+// backoff 第 attempts 次失败后的重试间隔
+func (s *Scheduler) backoff(attempts int) time.Duration {
+	d := s.opts.Backoff
+	for i := 1; i < attempts && d < s.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, s.opts.MaxBackoff)
+}
+
+// fail 接收方校验失败或重试次数用尽, 标记为失败且不再重试
+func (s *Scheduler) fail(ctx context.Context, client *gen.Client, rdb *redis.Client, sm *gen.ScheduledMessage, cause error) error {
+	err := client.ScheduledMessage.Update().
+		Where(scheduledmessage.ID(sm.ID), scheduledmessage.StatusEQ(scheduledmessage.StatusPending)).
+		SetStatus(scheduledmessage.StatusFailed).
+		SetAttempts(sm.Attempts).
+		SetError(cause.Error()).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	metrics.ScheduledSent.WithLabelValues(string(scheduledmessage.StatusFailed)).Inc()
+	s.logger.Warn("定时消息发送失败", zap.Stringer("id", sm.ID), zap.Error(cause))
+	return s.unindex(ctx, rdb, sm.ID)
+}
+
+func (s *Scheduler) unindex(ctx context.Context, rdb *redis.Client, id objectid.ID) error {
+	if rdb == nil {
+		return nil
+	}
+	return rdb.ZRem(ctx, dueKey, id.String()).Err()
+}
+
+// sync 将时间窗口内未发送的定时消息写入到期索引, 包括已过期未发送的
+func (s *Scheduler) sync(ctx context.Context, rdb *redis.Client) error {
+	client := s.client()
+	if client == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	until := time.Now().Add(s.opts.Horizon)
+	var cursor objectid.ID
+	total := 0
+	for {
+		sms, err := client.ScheduledMessage.Query().
+			Where(
+				scheduledmessage.StatusEQ(scheduledmessage.StatusPending),
+				scheduledmessage.DeliverTimeLTE(until),
+				scheduledmessage.IDGT(cursor),
+			).
+			Order(scheduledmessage.ByID()).
+			Limit(s.opts.BatchSize).
+			All(ctx)
+		if err != nil {
+			return err
+		}
+		if len(sms) == 0 {
+			break
+		}
+		if err := index(ctx, rdb, sms...); err != nil {
+			return err
+		}
+		total += len(sms)
+		cursor = sms[len(sms)-1].ID
+	}
+	s.logger.Debug("定时消息索引已重建", zap.Int("count", total), zap.Time("until", until))
+	return nil
+}
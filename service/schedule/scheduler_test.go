@@ -0,0 +1,247 @@
+package schedule_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"entgo.io/ent"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/hook"
+	"msgcenter/platform/ent/gen/scheduledmessage"
+	"msgcenter/service/message"
+	"msgcenter/service/schedule"
+	"msgcenter/testkit"
+	"msgcenter/utils/objectid"
+)
+
+type scheduleEnv struct {
+	client *gen.Client
+	rdb    *redis.Client
+	user   objectid.ID
+	// beforeSave 非空时在定时消息发送的事务保存消息前调用
+	beforeSave func(*gen.MessageMutation) error
+}
+
+// newScheduleEnv useRedis 为 false 时调度器直接查询数据库
+func newScheduleEnv(t *testing.T, useRedis bool) *scheduleEnv {
+	t.Helper()
+	env := &scheduleEnv{client: testkit.NewEntClient(t)}
+	if useRedis {
+		env.rdb, _ = testkit.NewRedisClient(t)
+	}
+	env.user = env.client.User.Create().SetName("u").SaveX(context.Background()).ID
+	env.client.Message.Use(func(next ent.Mutator) ent.Mutator {
+		return hook.MessageFunc(func(ctx context.Context, m *gen.MessageMutation) (ent.Value, error) {
+			if ct, _ := m.ContentType(); ct == "bad" {
+				return nil, errors.New("写入消息失败")
+			}
+			if env.beforeSave != nil {
+				if err := env.beforeSave(m); err != nil {
+					return nil, err
+				}
+			}
+			return next.Mutate(ctx, m)
+		})
+	})
+	return env
+}
+
+func (e *scheduleEnv) scheduler(opts schedule.Options) *schedule.Scheduler {
+	opts.BatchSize = max(opts.BatchSize, 1)
+	return schedule.NewScheduler(
+		func() *gen.Client { return e.client },
+		func() *redis.Client { return e.rdb },
+		opts,
+		zap.NewNop(),
+	)
+}
+
+// create 创建 after 后到期的定时消息, contentType 为 bad 时发送失败
+func (e *scheduleEnv) create(t *testing.T, contentType string, after time.Duration) *gen.ScheduledMessage {
+	t.Helper()
+	sm, err := schedule.Create(context.Background(), e.client, e.rdb, 0, schedule.Input{
+		Input: message.Input{
+			UserIDs:     []objectid.ID{e.user},
+			ContentType: contentType,
+			Payload:     json.RawMessage(`"hi"`),
+		},
+		DeliverTime: time.Now().Add(after),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sm
+}
+
+func (e *scheduleEnv) get(t *testing.T, id objectid.ID) *gen.ScheduledMessage {
+	t.Helper()
+	return e.client.ScheduledMessage.GetX(context.Background(), id)
+}
+
+func TestFailingMessageDoesNotBlockOthers(t *testing.T) {
+	for _, useRedis := range []bool{true, false} {
+		name := "database"
+		if useRedis {
+			name = "redis"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			env := newScheduleEnv(t, useRedis)
+			bad := env.create(t, "bad", 20*time.Millisecond)
+			good := env.create(t, "text", 30*time.Millisecond)
+			time.Sleep(50 * time.Millisecond)
+
+			s := env.scheduler(schedule.Options{BatchSize: 1, Backoff: time.Hour})
+			if _, err := s.RunOnce(ctx); err == nil {
+				t.Fatal("发送失败应返回错误")
+			}
+			got := env.get(t, bad.ID)
+			if got.Status != scheduledmessage.StatusPending || got.Attempts != 1 || got.RetryTime == nil || got.Error == "" {
+				t.Fatalf("失败后 status = %s, attempts = %d, retry_time = %v", got.Status, got.Attempts, got.RetryTime)
+			}
+			if useRedis {
+				score := env.rdb.ZScore(ctx, "schedule:due", bad.ID.String()).Val()
+				if int64(score) != got.RetryTime.UnixMilli() {
+					t.Fatalf("到期索引分数 = %v, want 重试时间 %d", score, got.RetryTime.UnixMilli())
+				}
+			}
+
+			// 失败的消息推迟后不再占据头部, 后面的消息正常发送
+			if n, err := s.RunOnce(ctx); err != nil || n != 1 {
+				t.Fatalf("RunOnce = %d, %v, want 1", n, err)
+			}
+			if got := env.get(t, good.ID); got.Status != scheduledmessage.StatusSent {
+				t.Fatalf("后续消息 status = %s, want Sent", got.Status)
+			}
+			if n, err := s.RunOnce(ctx); err != nil || n != 0 {
+				t.Fatalf("重试时间前 RunOnce = %d, %v, want 0", n, err)
+			}
+		})
+	}
+}
+
+func TestFailedAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	env := newScheduleEnv(t, true)
+	bad := env.create(t, "bad", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	s := env.scheduler(schedule.Options{MaxAttempts: 3, Backoff: 10 * time.Millisecond})
+	for range 3 {
+		_, _ = s.RunOnce(ctx)
+		time.Sleep(30 * time.Millisecond)
+	}
+	got := env.get(t, bad.ID)
+	if got.Status != scheduledmessage.StatusFailed || got.Attempts != 3 {
+		t.Fatalf("status = %s, attempts = %d, want Failed, 3", got.Status, got.Attempts)
+	}
+	if env.rdb.ZScore(ctx, "schedule:due", bad.ID.String()).Err() != redis.Nil {
+		t.Fatal("标记失败后应移出到期索引")
+	}
+	if n, err := s.RunOnce(ctx); err != nil || n != 0 {
+		t.Fatalf("RunOnce = %d, %v, want 0", n, err)
+	}
+}
+
+// 发送事务进行中被取消, 保存的消息随事务回滚
+func TestCancelWhileDelivering(t *testing.T) {
+	ctx := context.Background()
+	env := newScheduleEnv(t, true)
+	sm := env.create(t, "text", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	env.beforeSave = func(*gen.MessageMutation) error {
+		env.beforeSave = nil
+		_, err := schedule.Cancel(ctx, env.client, env.rdb, objectid.ID{}, sm.ID)
+		return err
+	}
+	if _, err := env.scheduler(schedule.Options{}).RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := env.get(t, sm.ID); got.Status != scheduledmessage.StatusCanceled || got.MessageID != nil {
+		t.Fatalf("status = %s, message_id = %v, want Canceled", got.Status, got.MessageID)
+	}
+	if n := env.client.Message.Query().CountX(ctx); n != 0 {
+		t.Fatalf("取消后不应保存消息, 消息数 = %d", n)
+	}
+}
+
+// 发送事务进行中被改期, 本次不发送, 到新的时间后只发送一次
+func TestRescheduleWhileDelivering(t *testing.T) {
+	ctx := context.Background()
+	env := newScheduleEnv(t, true)
+	sm := env.create(t, "text", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	env.beforeSave = func(*gen.MessageMutation) error {
+		env.beforeSave = nil
+		_, err := schedule.Reschedule(ctx, env.client, env.rdb, 0, objectid.ID{}, sm.ID, time.Now().Add(50*time.Millisecond))
+		return err
+	}
+	s := env.scheduler(schedule.Options{})
+	if _, err := s.RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := env.get(t, sm.ID); got.Status != scheduledmessage.StatusPending {
+		t.Fatalf("改期后 status = %s, want Pending", got.Status)
+	}
+	if n, _ := s.RunOnce(ctx); n != 0 {
+		t.Fatalf("新的时间之前不应发送: %d", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	for range 2 {
+		if _, err := s.RunOnce(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := env.get(t, sm.ID); got.Status != scheduledmessage.StatusSent || got.MessageID == nil {
+		t.Fatalf("到期后 status = %s, want Sent", got.Status)
+	}
+	if n := env.client.Message.Query().CountX(ctx); n != 1 {
+		t.Fatalf("消息数 = %d, want 1", n)
+	}
+}
+
+// 改期清除失败次数和重试时间
+func TestRescheduleResetsRetry(t *testing.T) {
+	ctx := context.Background()
+	env := newScheduleEnv(t, true)
+	bad := env.create(t, "bad", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	_, _ = env.scheduler(schedule.Options{Backoff: time.Hour}).RunOnce(ctx)
+
+	deliverTime := time.Now().Add(time.Minute)
+	got, err := schedule.Reschedule(ctx, env.client, env.rdb, 0, objectid.ID{}, bad.ID, deliverTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Attempts != 0 || got.RetryTime != nil {
+		t.Fatalf("改期后 attempts = %d, retry_time = %v", got.Attempts, got.RetryTime)
+	}
+	if score := env.rdb.ZScore(ctx, "schedule:due", bad.ID.String()).Val(); int64(score) != deliverTime.UnixMilli() {
+		t.Fatalf("到期索引分数 = %v, want %d", score, deliverTime.UnixMilli())
+	}
+}
+
+func TestCancelAfterSent(t *testing.T) {
+	ctx := context.Background()
+	env := newScheduleEnv(t, true)
+	sm := env.create(t, "text", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, err := env.scheduler(schedule.Options{}).RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schedule.Cancel(ctx, env.client, env.rdb, objectid.ID{}, sm.ID); !errors.Is(err, schedule.ErrNotPending) {
+		t.Fatalf("已发送后取消 err = %v, want ErrNotPending", err)
+	}
+	if _, err := schedule.Reschedule(ctx, env.client, env.rdb, 0, objectid.ID{}, sm.ID, time.Now().Add(time.Minute)); !errors.Is(err, schedule.ErrNotPending) {
+		t.Fatalf("已发送后改期 err = %v, want ErrNotPending", err)
+	}
+}
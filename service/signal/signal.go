@@ -0,0 +1,116 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/metrics"
+	"msgcenter/service/conversation"
+	"msgcenter/utils/objectid"
+)
+
+// Channel 信号的 redis 发布订阅频道. 信号不落库、不进入消息队列和收件箱,
+// 每个实例订阅该频道, 只推送给本实例上在线的会话成员, 离线设备不会补发
+const Channel = "signals"
+
+// Kind 信号类型
+type Kind string
+
+const (
+	KindTyping    Kind = "typing"
+	KindRecording Kind = "recording"
+	KindViewing   Kind = "viewing"
+)
+
+func (k Kind) Valid() bool {
+	switch k {
+	case KindTyping, KindRecording, KindViewing:
+		return true
+	}
+	return false
+}
+
+// 未配置时的节流间隔和有效期
+const (
+	DefaultThrottle = 3 * time.Second
+	DefaultTTL      = 6 * time.Second
+)
+
+const throttlePrefix = "signal:throttle:"
+
+var ErrInvalidKind = errors.New("信号类型只能是 typing、recording 或 viewing")
+
+// Signal 会话中的临时状态信号
+type Signal struct {
+	Kind           Kind        `json:"kind"`
+	ConversationID objectid.ID `json:"conversation_id"`
+	UserID         objectid.ID `json:"user_id"`
+	DeviceID       string      `json:"device_id,omitempty"`
+	Active         bool        `json:"active"`      // false 表示状态结束, 如停止输入
+	ExpireTime     time.Time   `json:"expire_time"` // 过期后接收端应自动清除状态, 服务端不再转发
+}
+
+// Options 信号发送选项
+type Options struct {
+	Throttle   time.Duration // 同一用户在同一会话的同类信号的最小间隔, 结束信号不受限
+	TTL        time.Duration // 信号有效期, 客户端需在有效期内重复发送以保持状态
+	MaxMembers int           // 成员数超过该值的群不转发信号
+}
+
+// Send 校验成员身份后发布信号. 会话成员数超过上限或被节流时丢弃, 返回 false
+func Send(ctx context.Context, client *gen.Client, rdb *redis.Client, opts Options, sig Signal) (bool, error) {
+	if !sig.Kind.Valid() {
+		return false, ErrInvalidKind
+	}
+	if opts.Throttle <= 0 {
+		opts.Throttle = DefaultThrottle
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+
+	conv, err := conversation.Get(ctx, client, sig.ConversationID)
+	if err != nil {
+		return false, err
+	}
+	if _, err := conversation.Member(ctx, client, sig.ConversationID, sig.UserID); err != nil {
+		return false, err
+	}
+	if opts.MaxMembers > 0 && conv.MemberCount > opts.MaxMembers {
+		metrics.Signals.WithLabelValues("dropped").Inc()
+		return false, nil
+	}
+
+	key := throttlePrefix + sig.ConversationID.String() + ":" + sig.UserID.String() + ":" + string(sig.Kind)
+	if sig.Active {
+		ok, err := rdb.SetNX(ctx, key, 1, opts.Throttle).Result()
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			metrics.Signals.WithLabelValues("throttled").Inc()
+			return false, nil
+		}
+	} else {
+		// 结束信号不节流, 并清除节流标记使之后可以立即重新开始
+		if err := rdb.Del(ctx, key).Err(); err != nil {
+			return false, err
+		}
+	}
+
+	sig.ExpireTime = time.Now().Add(opts.TTL)
+	body, err := sonic.Marshal(sig)
+	if err != nil {
+		return false, err
+	}
+	if err := rdb.Publish(ctx, Channel, body).Err(); err != nil {
+		return false, err
+	}
+	metrics.Signals.WithLabelValues("published").Inc()
+	return true, nil
+}
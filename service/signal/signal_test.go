@@ -0,0 +1,133 @@
+package signal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/redis/go-redis/v9"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/conversation"
+	conversationsvc "msgcenter/service/conversation"
+	"msgcenter/service/signal"
+	"msgcenter/testkit"
+	"msgcenter/utils/objectid"
+)
+
+type signalEnv struct {
+	client *gen.Client
+	rdb    *redis.Client
+	ch     <-chan *redis.Message
+	conv   *gen.Conversation
+	users  []objectid.ID
+}
+
+func newSignalEnv(t *testing.T) *signalEnv {
+	t.Helper()
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	rdb, _ := testkit.NewRedisClient(t)
+	users := make([]objectid.ID, 3)
+	for i := range users {
+		users[i] = client.User.Create().SetName("u").SaveX(ctx).ID
+	}
+	conv, err := conversationsvc.Create(ctx, client, conversationsvc.CreateInput{
+		Type: conversation.TypeGroup, OwnerID: users[0], MemberIDs: users[1:2], MemberLimit: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := rdb.Subscribe(ctx, signal.Channel)
+	if _, err := sub.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sub.Close() })
+	return &signalEnv{client: client, rdb: rdb, ch: sub.Channel(), conv: conv, users: users}
+}
+
+func (e *signalEnv) send(t *testing.T, opts signal.Options, user objectid.ID, kind signal.Kind, active bool) (bool, error) {
+	t.Helper()
+	return signal.Send(context.Background(), e.client, e.rdb, opts, signal.Signal{
+		Kind:           kind,
+		ConversationID: e.conv.ID,
+		UserID:         user,
+		Active:         active,
+	})
+}
+
+// published 读取频道上已发布的信号
+func (e *signalEnv) published(t *testing.T) []signal.Signal {
+	t.Helper()
+	var got []signal.Signal
+	for {
+		var msg *redis.Message
+		select {
+		case msg = <-e.ch:
+		case <-time.After(100 * time.Millisecond):
+			return got
+		}
+		var sig signal.Signal
+		if err := sonic.UnmarshalString(msg.Payload, &sig); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, sig)
+	}
+}
+
+func TestSendThrottles(t *testing.T) {
+	env := newSignalEnv(t)
+	opts := signal.Options{Throttle: time.Minute, TTL: 5 * time.Second}
+
+	sent, err := env.send(t, opts, env.users[0], signal.KindTyping, true)
+	if err != nil || !sent {
+		t.Fatalf("Send = %v, %v", sent, err)
+	}
+	// 节流间隔内重复的开始信号被丢弃, 其他类型和其他用户不受影响
+	if sent, _ := env.send(t, opts, env.users[0], signal.KindTyping, true); sent {
+		t.Fatal("节流间隔内的重复信号不应发布")
+	}
+	if sent, _ := env.send(t, opts, env.users[0], signal.KindRecording, true); !sent {
+		t.Fatal("其他类型的信号不应被节流")
+	}
+	if sent, _ := env.send(t, opts, env.users[1], signal.KindTyping, true); !sent {
+		t.Fatal("其他用户的信号不应被节流")
+	}
+	// 结束信号不节流, 之后可以立即重新开始
+	if sent, _ := env.send(t, opts, env.users[0], signal.KindTyping, false); !sent {
+		t.Fatal("结束信号不应被节流")
+	}
+	if sent, _ := env.send(t, opts, env.users[0], signal.KindTyping, true); !sent {
+		t.Fatal("结束后重新开始的信号不应被节流")
+	}
+
+	got := env.published(t)
+	if len(got) != 5 {
+		t.Fatalf("已发布 %d 条信号, want 5", len(got))
+	}
+	if got[0].ConversationID != env.conv.ID || got[0].UserID != env.users[0] || !got[0].Active ||
+		time.Until(got[0].ExpireTime) <= 0 || time.Until(got[0].ExpireTime) > opts.TTL {
+		t.Fatalf("信号 = %+v", got[0])
+	}
+}
+
+func TestSendRejects(t *testing.T) {
+	env := newSignalEnv(t)
+
+	if _, err := env.send(t, signal.Options{}, env.users[0], "shouting", true); !errors.Is(err, signal.ErrInvalidKind) {
+		t.Fatalf("无效类型 err = %v, want ErrInvalidKind", err)
+	}
+	if _, err := env.send(t, signal.Options{}, env.users[2], signal.KindTyping, true); !errors.Is(err, conversationsvc.ErrNotMember) {
+		t.Fatalf("非成员 err = %v, want ErrNotMember", err)
+	}
+	// 超过成员数上限的群丢弃信号
+	sent, err := env.send(t, signal.Options{MaxMembers: 1}, env.users[0], signal.KindTyping, true)
+	if err != nil || sent {
+		t.Fatalf("大群 Send = %v, %v, want 丢弃", sent, err)
+	}
+	if got := env.published(t); len(got) != 0 {
+		t.Fatalf("不应发布信号: %+v", got)
+	}
+}
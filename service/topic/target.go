@@ -0,0 +1,259 @@
+package topic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"msgcenter/platform/ent/gen/device"
+	"msgcenter/platform/ent/gen/predicate"
+	"msgcenter/platform/ent/gen/userdevicerelation"
+)
+
+// ErrInvalidTarget 定向表达式无效
+var ErrInvalidTarget = errors.New("定向表达式无效")
+
+// maxTargetLength 定向表达式的最大长度
+const maxTargetLength = 1024
+
+// ParseTarget 将定向表达式编译为用户设备关联的查询条件, 空表达式匹配全部未删除的设备.
+//
+// 表达式由比较和逻辑运算组成, 字符串使用单引号:
+//
+//	device_type == 'Mobile'
+//	tag in ['beta', 'internal'] && device_type != 'Desktop'
+//	!(tag == 'beta') || (device_type == 'Other')
+//
+// 字段 device_type 对应 Device.device_type, tag 对应 UserDeviceRelation.tag;
+// 运算符优先级从高到低为 !、&&、||
+func ParseTarget(expr string) (predicate.UserDeviceRelation, error) {
+	base := userdevicerelation.HasDeviceWith(device.DeleteFlag(false))
+	if strings.TrimSpace(expr) == "" {
+		return base, nil
+	}
+	if len(expr) > maxTargetLength {
+		return nil, fmt.Errorf("%w: 长度不能超过 %d", ErrInvalidTarget, maxTargetLength)
+	}
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	pred, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "多余的 %q", t.text)
+	}
+	return userdevicerelation.And(base, pred), nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenOp // == != && || ! ( ) [ ] ,
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lex(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'':
+			end := strings.IndexByte(expr[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: 位置 %d 的字符串未闭合", ErrInvalidTarget, i)
+			}
+			tokens = append(tokens, token{tokenString, expr[i+1 : i+1+end], i})
+			i += end + 2
+		case isIdentByte(c):
+			start := i
+			for i < len(expr) && (isIdentByte(expr[i]) || expr[i] >= '0' && expr[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, expr[start:i], start})
+		case strings.HasPrefix(expr[i:], "==") || strings.HasPrefix(expr[i:], "!=") ||
+			strings.HasPrefix(expr[i:], "&&") || strings.HasPrefix(expr[i:], "||"):
+			tokens = append(tokens, token{tokenOp, expr[i : i+2], i})
+			i += 2
+		case strings.IndexByte("!()[],", c) >= 0:
+			tokens = append(tokens, token{tokenOp, expr[i : i+1], i})
+			i++
+		default:
+			return nil, fmt.Errorf("%w: 位置 %d 的字符 %q 无法识别", ErrInvalidTarget, i, c)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(expr)}), nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parser 递归下降解析:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | "(" or ")" | compare
+//	compare = ident ( "==" | "!=" ) string | ident "in" "[" string { "," string } "]"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokenOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return p.errorf(t, "期望 %q", op)
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("%w: 位置 %d %s", ErrInvalidTarget, t.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) or() (predicate.UserDeviceRelation, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = userdevicerelation.Or(left, right)
+	}
+	return left, nil
+}
+
+func (p *parser) and() (predicate.UserDeviceRelation, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = userdevicerelation.And(left, right)
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (predicate.UserDeviceRelation, error) {
+	if p.accept("!") {
+		pred, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return userdevicerelation.Not(pred), nil
+	}
+	if p.accept("(") {
+		pred, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return pred, nil
+	}
+	return p.compare()
+}
+
+func (p *parser) compare() (predicate.UserDeviceRelation, error) {
+	ident := p.next()
+	if ident.kind != tokenIdent {
+		return nil, p.errorf(ident, "期望字段名")
+	}
+	if ident.text != "device_type" && ident.text != "tag" {
+		return nil, p.errorf(ident, "不支持的字段 %q, 只能是 device_type 或 tag", ident.text)
+	}
+
+	var (
+		values []string
+		negate bool
+	)
+	switch op := p.next(); {
+	case op.kind == tokenOp && (op.text == "==" || op.text == "!="):
+		value := p.next()
+		if value.kind != tokenString {
+			return nil, p.errorf(value, "期望字符串")
+		}
+		values, negate = []string{value.text}, op.text == "!="
+	case op.kind == tokenIdent && op.text == "in":
+		if err := p.expect("["); err != nil {
+			return nil, err
+		}
+		for {
+			value := p.next()
+			if value.kind != tokenString {
+				return nil, p.errorf(value, "期望字符串")
+			}
+			values = append(values, value.text)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, p.errorf(op, "期望 ==、!= 或 in")
+	}
+
+	var pred predicate.UserDeviceRelation
+	switch ident.text {
+	case "device_type":
+		types := make([]device.DeviceType, len(values))
+		for i, v := range values {
+			types[i] = device.DeviceType(v)
+			if device.DeviceTypeValidator(types[i]) != nil {
+				return nil, p.errorf(ident, "device_type 不能是 %q", v)
+			}
+		}
+		pred = userdevicerelation.HasDeviceWith(device.DeviceTypeIn(types...))
+	case "tag":
+		// tag 为 NULL 时比较结果也是 NULL, 取反后仍不匹配; 先排除 NULL 使 != 和 ! 包含无标签的关联
+		pred = userdevicerelation.And(userdevicerelation.TagNotNil(), userdevicerelation.TagIn(values...))
+	}
+	if negate {
+		pred = userdevicerelation.Not(pred)
+	}
+	return pred, nil
+}
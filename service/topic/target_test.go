@@ -0,0 +1,103 @@
+package topic_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/device"
+	"msgcenter/service/topic"
+	"msgcenter/testkit"
+)
+
+// targetFixture 一名用户的四台设备, 按名称查找; deleted 已软删除
+func targetFixture(t *testing.T) (*gen.Client, map[string]string) {
+	t.Helper()
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	u := client.User.Create().SetName("u").SaveX(ctx)
+
+	devices := []struct {
+		name string
+		typ  device.DeviceType
+		tag  string
+	}{
+		{"mobile-beta", device.DeviceTypeMobile, "beta"},
+		{"desktop", device.DeviceTypeDesktop, ""},
+		{"other-internal", device.DeviceTypeOther, "internal"},
+		{"deleted", device.DeviceTypeMobile, "beta"},
+	}
+	names := map[string]string{}
+	for _, d := range devices {
+		dev := client.Device.Create().SetClientDeviceID(d.name).SetDeviceType(d.typ).SaveX(ctx)
+		rel := client.UserDeviceRelation.Create().SetUserID(u.ID).SetDeviceID(dev.ID)
+		if d.tag != "" {
+			rel.SetTag(d.tag)
+		}
+		rel.SaveX(ctx)
+		names[dev.ID.String()] = d.name
+	}
+	client.Device.Delete().Where(device.ClientDeviceID("deleted")).ExecX(ctx)
+	return client, names
+}
+
+func TestParseTarget(t *testing.T) {
+	client, names := targetFixture(t)
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"", []string{"desktop", "mobile-beta", "other-internal"}},
+		{"device_type == 'Mobile'", []string{"mobile-beta"}},
+		{"device_type != 'Mobile'", []string{"desktop", "other-internal"}},
+		{"tag == 'beta'", []string{"mobile-beta"}},
+		// 无标签的关联满足 != 和取反
+		{"tag != 'beta'", []string{"desktop", "other-internal"}},
+		{"!(tag in ['beta', 'internal'])", []string{"desktop"}},
+		{"tag in ['beta', 'internal'] && device_type != 'Other'", []string{"mobile-beta"}},
+		// && 优先于 ||
+		{"device_type == 'Desktop' || tag == 'beta' && device_type == 'Other'", []string{"desktop"}},
+		{"(device_type == 'Desktop' || tag == 'beta') && device_type == 'Mobile'", []string{"mobile-beta"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			pred, err := topic.ParseTarget(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rels := client.UserDeviceRelation.Query().Where(pred).AllX(context.Background())
+			var got []string
+			for _, rel := range rels {
+				got = append(got, names[rel.DeviceID.String()])
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("匹配的设备 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTargetInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"device_type == 'Phone'",
+		"user_id == 'x'",
+		"tag == beta",
+		"tag == 'beta",
+		"tag in []",
+		"tag in ['a' 'b']",
+		"(tag == 'a'",
+		"tag == 'a')",
+		"tag == 'a' &&",
+		"tag > 'a'",
+		"tag == 'a' # x",
+		"tag == '" + string(make([]byte, 1100)) + "'",
+	} {
+		if _, err := topic.ParseTarget(expr); !errors.Is(err, topic.ErrInvalidTarget) {
+			t.Errorf("ParseTarget(%q) err = %v, want ErrInvalidTarget", expr, err)
+		}
+	}
+}
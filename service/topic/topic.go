@@ -0,0 +1,241 @@
+package topic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+
+	"msgcenter/platform/ent"
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/device"
+	"msgcenter/platform/ent/gen/topic"
+	"msgcenter/platform/ent/gen/topicsubscription"
+	"msgcenter/platform/ent/gen/user"
+	"msgcenter/platform/outbox"
+	"msgcenter/utils/objectid"
+)
+
+// TopicBroadcast 广播创建事件的主题, 事件体为广播任务的 JSON
+const TopicBroadcast = "broadcast-created"
+
+var (
+	ErrNotFound          = errors.New("主题不存在")
+	ErrExists            = errors.New("主题已存在")
+	ErrSubscriber        = errors.New("必须且只能指定 user_id 或 device_id 之一")
+	ErrUserNotFound      = errors.New("用户不存在")
+	ErrDeviceNotFound    = errors.New("设备不存在")
+	ErrSenderNotFound    = errors.New("发送者不存在")
+	ErrAlreadySubscribed = errors.New("已订阅该主题")
+	ErrNotSubscribed     = errors.New("未订阅该主题")
+	ErrBroadcastNotFound = errors.New("广播不存在")
+)
+
+// 订阅者类型
+const (
+	SubscriberUser   = topicsubscription.SubscriberTypeUser
+	SubscriberDevice = topicsubscription.SubscriberTypeDevice
+)
+
+// Subscriber 订阅者, 用户订阅时其全部设备都会收到广播
+type Subscriber struct {
+	UserID   objectid.ID
+	DeviceID objectid.ID
+}
+
+// resolve 订阅者类型和ID
+func (s Subscriber) resolve() (topicsubscription.SubscriberType, objectid.ID, error) {
+	switch {
+	case s.UserID.IsZero() == s.DeviceID.IsZero():
+		return "", objectid.ID{}, ErrSubscriber
+	case !s.UserID.IsZero():
+		return SubscriberUser, s.UserID, nil
+	default:
+		return SubscriberDevice, s.DeviceID, nil
+	}
+}
+
+// Create 创建主题, 名称唯一
+func Create(ctx context.Context, client *gen.Client, name, description string) (*gen.Topic, error) {
+	create := client.Topic.Create().SetName(name)
+	if description != "" {
+		create.SetDescription(description)
+	}
+	t, err := create.Save(ctx)
+	if gen.IsConstraintError(err) {
+		return nil, ErrExists
+	}
+	return t, err
+}
+
+// Get 按名称查询主题
+func Get(ctx context.Context, client *gen.Client, name string) (*gen.Topic, error) {
+	t, err := client.Topic.Query().Where(topic.Name(name)).Only(ctx)
+	if gen.IsNotFound(err) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+// Subscribe 订阅主题
+func Subscribe(ctx context.Context, client *gen.Client, name string, sub Subscriber) (*gen.TopicSubscription, error) {
+	typ, id, err := sub.resolve()
+	if err != nil {
+		return nil, err
+	}
+	t, err := Get(ctx, client, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var exist bool
+	if typ == SubscriberUser {
+		exist, err = client.User.Query().Where(user.ID(id)).Exist(ctx)
+	} else {
+		exist, err = client.Device.Query().Where(device.ID(id)).Exist(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		if typ == SubscriberUser {
+			return nil, ErrUserNotFound
+		}
+		return nil, ErrDeviceNotFound
+	}
+
+	s, err := client.TopicSubscription.Create().
+		SetTopicID(t.ID).
+		SetSubscriberType(typ).
+		SetSubscriberID(id).
+		Save(ctx)
+	if gen.IsConstraintError(err) {
+		return nil, ErrAlreadySubscribed
+	}
+	return s, err
+}
+
+// Unsubscribe 取消订阅, 进行中的广播若尚未处理到该订阅则不再投递
+func Unsubscribe(ctx context.Context, client *gen.Client, name string, sub Subscriber) error {
+	typ, id, err := sub.resolve()
+	if err != nil {
+		return err
+	}
+	t, err := Get(ctx, client, name)
+	if err != nil {
+		return err
+	}
+	n, err := client.TopicSubscription.Delete().
+		Where(
+			topicsubscription.TopicID(t.ID),
+			topicsubscription.SubscriberTypeEQ(typ),
+			topicsubscription.SubscriberID(id),
+		).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotSubscribed
+	}
+	return nil
+}
+
+// PublishInput 发布广播的参数
+type PublishInput struct {
+	SenderID    objectid.ID // 为空表示系统消息
+	ContentType string
+	Payload     json.RawMessage
+	Target      string        // 定向表达式, 见 ParseTarget
+	TTL         time.Duration // 有效期, 过期后停止扩散, 0 表示不过期
+}
+
+// Publish 保存广播消息和广播任务, 并在同一事务中写入广播创建事件, 由投递服务按订阅分批扩散
+func Publish(ctx context.Context, client *gen.Client, name string, in PublishInput) (*gen.Broadcast, error) {
+	t, err := Prepare(ctx, client, name, in)
+	if err != nil {
+		return nil, err
+	}
+	var b *gen.Broadcast
+	err = ent.WithTx(ctx, client, func(tx *gen.Tx) error {
+		b, err = Save(ctx, tx, t, in)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.Unwrap(), nil
+}
+
+// Prepare 校验定向表达式、主题和发送者, 返回要发布到的主题
+func Prepare(ctx context.Context, client *gen.Client, name string, in PublishInput) (*gen.Topic, error) {
+	if _, err := ParseTarget(in.Target); err != nil {
+		return nil, err
+	}
+	t, err := Get(ctx, client, name)
+	if err != nil {
+		return nil, err
+	}
+	if !in.SenderID.IsZero() {
+		exist, err := client.User.Query().Where(user.ID(in.SenderID)).Exist(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !exist {
+			return nil, ErrSenderNotFound
+		}
+	}
+	return t, nil
+}
+
+// Save 在事务中保存已校验的广播消息和广播任务, 并写入广播创建事件
+func Save(ctx context.Context, tx *gen.Tx, t *gen.Topic, in PublishInput) (*gen.Broadcast, error) {
+	create := tx.Message.Create().
+		SetContentType(in.ContentType).
+		SetPayload(in.Payload)
+	if !in.SenderID.IsZero() {
+		create.SetSenderID(in.SenderID)
+	}
+	if in.TTL > 0 {
+		create.SetExpireTime(time.Now().Add(in.TTL))
+	}
+	msg, err := create.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bc := tx.Broadcast.Create().
+		SetTopicID(t.ID).
+		SetMessageID(msg.ID)
+	if in.Target != "" {
+		bc.SetTarget(in.Target)
+	}
+	b, err := bc.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := sonic.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	err = outbox.Add(ctx, tx.Client(), outbox.Event{
+		Topic:       TopicBroadcast,
+		Key:         b.ID.String(),
+		Payload:     body,
+		Aggregate:   gen.TypeBroadcast,
+		AggregateID: b.ID.String(),
+	})
+	return b, err
+}
+
+// GetBroadcast 查询广播任务
+func GetBroadcast(ctx context.Context, client *gen.Client, id objectid.ID) (*gen.Broadcast, error) {
+	b, err := client.Broadcast.Get(ctx, id)
+	if gen.IsNotFound(err) {
+		return nil, ErrBroadcastNotFound
+	}
+	return b, err
+}
@@ -0,0 +1,150 @@
+package unread
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/conversationmember"
+	"msgcenter/service/conversation"
+	"msgcenter/utils/objectid"
+)
+
+// 未读数保存在每个用户一个 hash 中, field 为会话ID, value 为未读数.
+// 新消息由投递服务递增, 标记已读时以数据库重新计算的值覆盖;
+// 对账标记过期后下次查询以数据库为准整体重建, 修正重复消费等原因造成的偏差
+const (
+	keyPrefix     = "unread:"
+	syncedPrefix  = "unread:synced:"
+	countedPrefix = "unread:counted:"
+
+	// countedTTL 消息已计数标记的有效期, 覆盖消息队列的重试窗口
+	countedTTL = 24 * time.Hour
+	// DefaultSyncInterval 未配置时与数据库对账的间隔
+	DefaultSyncInterval = time.Hour
+)
+
+func key(userID objectid.ID) string {
+	return keyPrefix + userID.String()
+}
+
+// incrScript 消息未计数时为每个接收用户递增未读数, KEYS[1] 为计数标记, 其余为用户的 hash
+var incrScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], 1, "NX", "EX", ARGV[2]) == false then
+	return 0
+end
+for i = 2, #KEYS do
+	redis.call("HINCRBY", KEYS[i], ARGV[1], 1)
+end
+return 1`)
+
+// Incr 会话新消息计入接收成员的未读数, 同一消息重复调用只计一次
+func Incr(ctx context.Context, rdb *redis.Client, messageID int64, conversationID objectid.ID, userIDs []objectid.ID) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(userIDs)+1)
+	keys = append(keys, countedPrefix+strconv.FormatInt(messageID, 10))
+	for _, id := range userIDs {
+		keys = append(keys, key(id))
+	}
+	return incrScript.Run(ctx, rdb, keys, conversationID.String(), int(countedTTL/time.Second)).Err()
+}
+
+// Set 覆盖用户在会话中的未读数, 0 时删除
+func Set(ctx context.Context, rdb *redis.Client, userID, conversationID objectid.ID, n int) error {
+	if n <= 0 {
+		return rdb.HDel(ctx, key(userID), conversationID.String()).Err()
+	}
+	return rdb.HSet(ctx, key(userID), conversationID.String(), n).Err()
+}
+
+// Refresh 以数据库重新计算成员的未读数并写入 redis
+func Refresh(ctx context.Context, client *gen.Client, rdb *redis.Client, member *gen.ConversationMember) (int, error) {
+	n, err := conversation.Unread(ctx, client, member)
+	if err != nil {
+		return 0, err
+	}
+	if rdb == nil {
+		return n, nil
+	}
+	return n, Set(ctx, rdb, member.UserID, member.ConversationID, n)
+}
+
+// Counts 用户各会话的未读数, 只包含未读数大于 0 的会话. 对账标记过期时以数据库重建,
+// redis 不可用时直接查询数据库
+func Counts(ctx context.Context, client *gen.Client, rdb *redis.Client, userID objectid.ID, interval time.Duration) (map[objectid.ID]int, error) {
+	if rdb == nil {
+		return count(ctx, client, userID)
+	}
+	synced, err := rdb.Exists(ctx, syncedPrefix+userID.String()).Result()
+	if err != nil {
+		return nil, err
+	}
+	if synced == 0 {
+		return Reconcile(ctx, client, rdb, userID, interval)
+	}
+
+	values, err := rdb.HGetAll(ctx, key(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[objectid.ID]int, len(values))
+	for field, value := range values {
+		id, err := objectid.Parse(field)
+		if err != nil {
+			continue
+		}
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			counts[id] = n
+		}
+	}
+	return counts, nil
+}
+
+// Reconcile 以数据库重建用户的全部未读数, interval 内不再对账
+func Reconcile(ctx context.Context, client *gen.Client, rdb *redis.Client, userID objectid.ID, interval time.Duration) (map[objectid.ID]int, error) {
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+	counts, err := count(ctx, client, userID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, key(userID))
+		for id, n := range counts {
+			p.HSet(ctx, key(userID), id.String(), n)
+		}
+		p.Set(ctx, syncedPrefix+userID.String(), 1, interval)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// count 从数据库计算用户各会话的未读数
+func count(ctx context.Context, client *gen.Client, userID objectid.ID) (map[objectid.ID]int, error) {
+	members, err := client.ConversationMember.Query().
+		Where(conversationmember.UserID(userID)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[objectid.ID]int)
+	for _, m := range members {
+		n, err := conversation.Unread(ctx, client, m)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			counts[m.ConversationID] = n
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,129 @@
+package unread_test
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"testing"
+	"time"
+
+	"msgcenter/platform/ent/gen"
+	"msgcenter/platform/ent/gen/conversation"
+	conversationsvc "msgcenter/service/conversation"
+	"msgcenter/service/unread"
+	"msgcenter/testkit"
+	"msgcenter/utils/objectid"
+)
+
+type unreadEnv struct {
+	client *gen.Client
+	conv   *gen.Conversation
+	owner  objectid.ID
+	member objectid.ID
+}
+
+func newUnreadEnv(t *testing.T) *unreadEnv {
+	t.Helper()
+	ctx := context.Background()
+	client := testkit.NewEntClient(t)
+	owner := client.User.Create().SetName("owner").SaveX(ctx).ID
+	member := client.User.Create().SetName("member").SaveX(ctx).ID
+	conv, err := conversationsvc.Create(ctx, client, conversationsvc.CreateInput{
+		Type: conversation.TypeGroup, OwnerID: owner, MemberIDs: []objectid.ID{member}, MemberLimit: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &unreadEnv{client: client, conv: conv, owner: owner, member: member}
+}
+
+func (e *unreadEnv) send(t *testing.T) *gen.Message {
+	t.Helper()
+	return e.client.Message.Create().
+		SetConversationID(e.conv.ID).
+		SetSenderID(e.owner).
+		SetContentType("text").
+		SetPayload(json.RawMessage(`"hi"`)).
+		SaveX(context.Background())
+}
+
+func TestIncrCountsMessageOnce(t *testing.T) {
+	ctx := context.Background()
+	env := newUnreadEnv(t)
+	rdb, _ := testkit.NewRedisClient(t)
+	if _, err := unread.Reconcile(ctx, env.client, rdb, env.member, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	m := env.send(t)
+	// 同一消息重复消费只计一次
+	for range 2 {
+		if err := unread.Incr(ctx, rdb, m.ID, env.conv.ID, []objectid.ID{env.member}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = unread.Incr(ctx, rdb, env.send(t).ID, env.conv.ID, []objectid.ID{env.member})
+
+	counts, err := unread.Counts(ctx, env.client, rdb, env.member, time.Hour)
+	if err != nil || !maps.Equal(counts, map[objectid.ID]int{env.conv.ID: 2}) {
+		t.Fatalf("Counts = %v, %v, want 2", counts, err)
+	}
+
+	if err := unread.Set(ctx, rdb, env.member, env.conv.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if counts, _ := unread.Counts(ctx, env.client, rdb, env.member, time.Hour); len(counts) != 0 {
+		t.Fatalf("清零后 Counts = %v", counts)
+	}
+}
+
+func TestCountsReconcilesWithDatabase(t *testing.T) {
+	ctx := context.Background()
+	env := newUnreadEnv(t)
+	rdb, mr := testkit.NewRedisClient(t)
+	env.send(t)
+	env.send(t)
+
+	// 未对账时以数据库为准, 覆盖 redis 中偏差的计数
+	if err := unread.Set(ctx, rdb, env.member, env.conv.ID, 9); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := unread.Counts(ctx, env.client, rdb, env.member, time.Minute)
+	if err != nil || counts[env.conv.ID] != 2 {
+		t.Fatalf("Counts = %v, %v, want 2", counts, err)
+	}
+
+	// 对账有效期内读取 redis, 过期后重新对账
+	_ = unread.Set(ctx, rdb, env.member, env.conv.ID, 5)
+	if counts, _ := unread.Counts(ctx, env.client, rdb, env.member, time.Minute); counts[env.conv.ID] != 5 {
+		t.Fatalf("对账有效期内 Counts = %v, want 5", counts)
+	}
+	mr.FastForward(2 * time.Minute)
+	if counts, _ := unread.Counts(ctx, env.client, rdb, env.member, time.Minute); counts[env.conv.ID] != 2 {
+		t.Fatalf("对账过期后 Counts = %v, want 2", counts)
+	}
+
+	// 发送者自己的消息不计入
+	if counts, _ := unread.Counts(ctx, env.client, nil, env.owner, time.Minute); len(counts) != 0 {
+		t.Fatalf("发送者 Counts = %v", counts)
+	}
+}
+
+func TestRefreshAfterRead(t *testing.T) {
+	ctx := context.Background()
+	env := newUnreadEnv(t)
+	rdb, _ := testkit.NewRedisClient(t)
+	first := env.send(t)
+	env.send(t)
+
+	member, _, err := conversationsvc.MarkRead(ctx, env.client, env.conv.ID, env.member, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := unread.Refresh(ctx, env.client, rdb, member); err != nil || n != 1 {
+		t.Fatalf("Refresh = %d, %v, want 1", n, err)
+	}
+	if v := rdb.HGet(ctx, "unread:"+env.member.String(), env.conv.ID.String()).Val(); v != "1" {
+		t.Fatalf("redis 中的未读数 = %q, want 1", v)
+	}
+}
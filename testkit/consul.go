@@ -0,0 +1,458 @@
+package testkit
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/hashicorp/consul/api"
+)
+
+// Consul 基于 httptest 的 Consul agent 假实现, 支持 KV、服务注册、健康查询、session 锁和阻塞查询,
+// 覆盖 platform/consul 客户端用到的接口. session 不会按 TTL 过期, 需要时用 InvalidateSession 模拟
+type Consul struct {
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	index    uint64
+	changed  chan struct{}
+	closed   chan struct{}
+	kv       map[string]*api.KVPair
+	services map[string]*api.AgentServiceRegistration
+	sessions map[string]*api.SessionEntry
+}
+
+// NewConsul 启动假 Consul, 测试结束时自动关闭
+func NewConsul(t testing.TB) *Consul {
+	t.Helper()
+
+	c := &Consul{
+		index:    1,
+		changed:  make(chan struct{}),
+		closed:   make(chan struct{}),
+		kv:       make(map[string]*api.KVPair),
+		services: make(map[string]*api.AgentServiceRegistration),
+		sessions: make(map[string]*api.SessionEntry),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/kv/", c.handleKV)
+	mux.HandleFunc("/v1/agent/service/register", c.handleRegister)
+	mux.HandleFunc("/v1/agent/service/deregister/", c.handleDeregister)
+	mux.HandleFunc("/v1/agent/check/update/", c.handleOK)
+	mux.HandleFunc("/v1/health/service/", c.handleHealthService)
+	mux.HandleFunc("/v1/status/leader", c.handleLeader)
+	mux.HandleFunc("/v1/session/create", c.handleSessionCreate)
+	mux.HandleFunc("/v1/session/destroy/", c.handleSessionDestroy)
+	mux.HandleFunc("/v1/session/renew/", c.handleSessionRenew)
+	mux.HandleFunc("/v1/session/info/", c.handleSessionInfo)
+	c.srv = httptest.NewServer(mux)
+
+	t.Cleanup(c.Close)
+	return c
+}
+
+// Addr 返回 host:port, 可直接作为 consul.NewClient 的地址
+func (c *Consul) Addr() string {
+	return strings.TrimPrefix(c.srv.URL, "http://")
+}
+
+// Close 唤醒所有阻塞查询并关闭服务
+func (c *Consul) Close() {
+	c.mu.Lock()
+	select {
+	case <-c.closed:
+		c.mu.Unlock()
+		return
+	default:
+		close(c.closed)
+	}
+	c.mu.Unlock()
+	c.srv.Close()
+}
+
+// Put 写入配置键, 唤醒等待中的阻塞查询
+func (c *Consul) Put(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index := c.bump()
+	pair, ok := c.kv[key]
+	if !ok {
+		pair = &api.KVPair{Key: key, CreateIndex: index}
+		c.kv[key] = pair
+	}
+	pair.Value = append([]byte(nil), value...)
+	pair.ModifyIndex = index
+}
+
+// PutJSON 以 JSON 编码写入配置键
+func (c *Consul) PutJSON(key string, v any) error {
+	data, err := sonic.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.Put(key, data)
+	return nil
+}
+
+// Delete 删除配置键
+func (c *Consul) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.kv[key]; ok {
+		delete(c.kv, key)
+		c.bump()
+	}
+}
+
+// Get 读取配置键
+func (c *Consul) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pair, ok := c.kv[key]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), pair.Value...), true
+}
+
+// Service 返回已注册的服务
+func (c *Consul) Service(id string) (*api.AgentServiceRegistration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	svc, ok := c.services[id]
+	return svc, ok
+}
+
+// RegisterService 直接注册服务实例, 用于模拟其他节点
+func (c *Consul) RegisterService(svc *api.AgentServiceRegistration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.services[svc.ID] = svc
+	c.bump()
+}
+
+// InvalidateSession 使 session 失效并释放其持有的锁, 模拟 TTL 过期
+func (c *Consul) InvalidateSession(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.destroySession(id)
+}
+
+// LockHolder 返回持有键的 session, 未被持有时返回空字符串
+func (c *Consul) LockHolder(key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pair, ok := c.kv[key]; ok {
+		return pair.Session
+	}
+	return ""
+}
+
+// destroySession 删除 session 并释放锁, 调用方需持有锁
+func (c *Consul) destroySession(id string) {
+	if _, ok := c.sessions[id]; !ok {
+		return
+	}
+	delete(c.sessions, id)
+	index := c.bump()
+	for _, pair := range c.kv {
+		if pair.Session == id {
+			pair.Session = ""
+			pair.ModifyIndex = index
+		}
+	}
+}
+
+// bump 递增索引并通知阻塞查询, 调用方需持有锁
+func (c *Consul) bump() uint64 {
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return c.index
+}
+
+// wait 实现阻塞查询: 请求带 index 且索引未变化时, 等到数据变化、超时或请求取消
+func (c *Consul) wait(r *http.Request) {
+	q := r.URL.Query()
+	index, _ := strconv.ParseUint(q.Get("index"), 10, 64)
+	if index == 0 {
+		return
+	}
+	wait := 5 * time.Minute
+	if d, err := time.ParseDuration(q.Get("wait")); err == nil && d > 0 {
+		wait = d
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		c.mu.Lock()
+		current, changed := c.index, c.changed
+		c.mu.Unlock()
+		if current > index {
+			return
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return
+		case <-r.Context().Done():
+			return
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *Consul) writeJSON(w http.ResponseWriter, index uint64, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+	w.Header().Set("X-Consul-KnownLeader", "true")
+	w.Header().Set("X-Consul-LastContact", "0")
+	w.WriteHeader(status)
+	if v != nil {
+		data, _ := sonic.Marshal(v)
+		_, _ = w.Write(data)
+	}
+}
+
+func (c *Consul) handleKV(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+
+	switch r.Method {
+	case http.MethodGet:
+		c.wait(r)
+		c.mu.Lock()
+		index := c.index
+		pair, ok := c.kv[key]
+		var out []*api.KVPair
+		if ok {
+			cp := *pair
+			out = []*api.KVPair{&cp}
+		}
+		c.mu.Unlock()
+		if !ok {
+			c.writeJSON(w, index, http.StatusNotFound, nil)
+			return
+		}
+		c.writeJSON(w, index, http.StatusOK, out)
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q := r.URL.Query()
+		switch {
+		case q.Has("acquire"):
+			flags, _ := strconv.ParseUint(q.Get("flags"), 10, 64)
+			ok, err := c.acquire(key, q.Get("acquire"), flags, body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			c.writeJSON(w, c.currentIndex(), http.StatusOK, ok)
+		case q.Has("release"):
+			c.writeJSON(w, c.currentIndex(), http.StatusOK, c.release(key, q.Get("release"), body))
+		default:
+			c.Put(key, body)
+			c.writeJSON(w, c.currentIndex(), http.StatusOK, true)
+		}
+	case http.MethodDelete:
+		c.Delete(key)
+		c.writeJSON(w, c.currentIndex(), http.StatusOK, true)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// acquire 以 session 占用键, 已被其他 session 持有时返回 false
+func (c *Consul) acquire(key, session string, flags uint64, value []byte) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.sessions[session]; !ok {
+		return false, fmt.Errorf("invalid session %q", session)
+	}
+	pair, ok := c.kv[key]
+	if ok && pair.Session != "" && pair.Session != session {
+		return false, nil
+	}
+	index := c.bump()
+	if !ok {
+		pair = &api.KVPair{Key: key, CreateIndex: index}
+		c.kv[key] = pair
+	}
+	if pair.Session != session {
+		pair.LockIndex++
+	}
+	pair.Session = session
+	pair.Flags = flags
+	pair.Value = append([]byte(nil), value...)
+	pair.ModifyIndex = index
+	return true, nil
+}
+
+// release 释放 session 持有的键
+func (c *Consul) release(key, session string, value []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pair, ok := c.kv[key]
+	if !ok || pair.Session != session {
+		return false
+	}
+	pair.Session = ""
+	pair.Value = append([]byte(nil), value...)
+	pair.ModifyIndex = c.bump()
+	return true
+}
+
+func (c *Consul) handleSessionCreate(w http.ResponseWriter, r *http.Request) {
+	var entry api.SessionEntry
+	body, err := io.ReadAll(r.Body)
+	if err == nil && len(body) > 0 {
+		err = sonic.Unmarshal(body, &entry)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	index := c.bump()
+	entry.ID = fmt.Sprintf("00000000-0000-0000-0000-%012x", index)
+	entry.CreateIndex = index
+	c.sessions[entry.ID] = &entry
+	c.mu.Unlock()
+
+	c.writeJSON(w, index, http.StatusOK, map[string]string{"ID": entry.ID})
+}
+
+func (c *Consul) handleSessionDestroy(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/session/destroy/")
+	c.InvalidateSession(id)
+	c.writeJSON(w, c.currentIndex(), http.StatusOK, true)
+}
+
+func (c *Consul) handleSessionRenew(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/session/renew/")
+	c.mu.Lock()
+	entry, ok := c.sessions[id]
+	var out []*api.SessionEntry
+	if ok {
+		cp := *entry
+		out = []*api.SessionEntry{&cp}
+	}
+	index := c.index
+	c.mu.Unlock()
+
+	if !ok {
+		c.writeJSON(w, index, http.StatusNotFound, nil)
+		return
+	}
+	c.writeJSON(w, index, http.StatusOK, out)
+}
+
+func (c *Consul) handleSessionInfo(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/session/info/")
+	c.wait(r)
+	c.mu.Lock()
+	out := make([]*api.SessionEntry, 0, 1)
+	if entry, ok := c.sessions[id]; ok {
+		cp := *entry
+		out = append(out, &cp)
+	}
+	index := c.index
+	c.mu.Unlock()
+	c.writeJSON(w, index, http.StatusOK, out)
+}
+
+func (c *Consul) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var svc api.AgentServiceRegistration
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = sonic.Unmarshal(body, &svc)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if svc.ID == "" {
+		svc.ID = svc.Name
+	}
+	c.RegisterService(&svc)
+	c.writeJSON(w, c.currentIndex(), http.StatusOK, nil)
+}
+
+func (c *Consul) handleDeregister(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/")
+	c.mu.Lock()
+	if _, ok := c.services[id]; ok {
+		delete(c.services, id)
+		c.bump()
+	}
+	c.mu.Unlock()
+	c.writeJSON(w, c.currentIndex(), http.StatusOK, nil)
+}
+
+func (c *Consul) handleHealthService(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+	c.wait(r)
+
+	c.mu.Lock()
+	index := c.index
+	entries := make([]*api.ServiceEntry, 0)
+	for _, svc := range c.services {
+		if svc.Name != name {
+			continue
+		}
+		entries = append(entries, &api.ServiceEntry{
+			Node: &api.Node{Node: "testkit", Address: "127.0.0.1"},
+			Service: &api.AgentService{
+				ID:      svc.ID,
+				Service: svc.Name,
+				Tags:    svc.Tags,
+				Port:    svc.Port,
+				Address: svc.Address,
+			},
+			Checks: api.HealthChecks{{
+				Node:        "testkit",
+				CheckID:     "service:" + svc.ID,
+				Status:      api.HealthPassing,
+				ServiceID:   svc.ID,
+				ServiceName: svc.Name,
+			}},
+		})
+	}
+	c.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Service.ID < entries[j].Service.ID })
+	c.writeJSON(w, index, http.StatusOK, entries)
+}
+
+func (c *Consul) handleLeader(w http.ResponseWriter, r *http.Request) {
+	c.writeJSON(w, c.currentIndex(), http.StatusOK, "127.0.0.1:8300")
+}
+
+func (c *Consul) handleOK(w http.ResponseWriter, r *http.Request) {
+	c.writeJSON(w, c.currentIndex(), http.StatusOK, nil)
+}
+
+func (c *Consul) currentIndex() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.index
+}
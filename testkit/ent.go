@@ -0,0 +1,57 @@
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql"
+	_ "github.com/mattn/go-sqlite3"
+
+	consulconfig "msgcenter/platform/consul/config"
+	"msgcenter/platform/ent/gen"
+	_ "msgcenter/platform/ent/gen/runtime"
+)
+
+var dbSeq atomic.Int64
+
+// SQLiteDSN 返回独立的内存数据库DSN, 同一DSN的连接共享数据
+func SQLiteDSN(name string) string {
+	return fmt.Sprintf("file:%s_%d?mode=memory&cache=shared&_fk=1", name, dbSeq.Add(1))
+}
+
+// OpenSQLite 打开 SQLite 并创建表结构
+func OpenSQLite(ctx context.Context, dsn string) (*sql.Driver, error) {
+	drv, err := sql.Open(dialect.SQLite, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := gen.NewClient(gen.Driver(drv)).Schema.Create(ctx); err != nil {
+		_ = drv.Close()
+		return nil, fmt.Errorf("创建表结构失败: %w", err)
+	}
+	return drv, nil
+}
+
+// NewEntClient 返回基于内存 SQLite 的 ent 客户端, 表结构已创建, 测试结束时自动关闭
+func NewEntClient(t testing.TB) *gen.Client {
+	t.Helper()
+
+	drv, err := OpenSQLite(context.Background(), SQLiteDSN("ent"))
+	if err != nil {
+		t.Fatalf("打开SQLite失败: %v", err)
+	}
+	client := gen.NewClient(gen.Driver(drv))
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// SQLiteOpener 返回可用于 server.WithDbOpener 的连接函数, 忽略consul中的postgres配置.
+// 内存数据库在最后一个连接关闭后释放, 配置热更新重建连接后为空库
+func SQLiteOpener(dsn string) func(consulconfig.SqlDb) (*sql.Driver, error) {
+	return func(consulconfig.SqlDb) (*sql.Driver, error) {
+		return OpenSQLite(context.Background(), dsn)
+	}
+}
@@ -0,0 +1,119 @@
+package testkit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"msgcenter/config"
+	"msgcenter/platform/auth"
+	"msgcenter/platform/consul"
+	consulconfig "msgcenter/platform/consul/config"
+	"msgcenter/platform/mq"
+	"msgcenter/server"
+)
+
+// AuthSecret 测试环境的令牌密钥
+const AuthSecret = "msgcenter-test-secret"
+
+// Env 一套完整的假依赖: Consul、redis、SQLite 和内存消息队列, consul 中已写入指向它们的配置
+type Env struct {
+	Consul *Consul
+	Redis  *miniredis.Miniredis
+	DSN    string
+	Broker *mq.Memory
+	Config *config.Config
+}
+
+// NewEnv 启动假依赖并生成本地配置, HTTP 监听随机端口, 不导出链路追踪
+func NewEnv(t testing.TB) *Env {
+	t.Helper()
+
+	e := &Env{
+		Consul: NewConsul(t),
+		Redis:  NewRedis(t),
+		DSN:    SQLiteDSN("server"),
+		Broker: mq.NewMemory(),
+	}
+
+	seeds := map[string]any{
+		consul.Redis:  consulconfig.Redis{Addr: e.Redis.Addr()},
+		consul.Sqldb:  consulconfig.SqlDb{Database: e.DSN},
+		consul.Banner: consulconfig.Banner{AppName: "msgcenter-test"},
+		consul.Log:    consulconfig.Log{Level: "info"},
+		consul.Pulsar: consulconfig.Pulsar{URL: "memory://"},
+		consul.Auth:   consulconfig.Auth{Secret: AuthSecret},
+	}
+	for key, v := range seeds {
+		if err := e.Consul.PutJSON(key, v); err != nil {
+			t.Fatalf("写入consul配置失败: %v", err)
+		}
+	}
+
+	cfg := &config.Config{
+		IP:  "127.0.0.1:0",
+		Env: "test",
+		Log: config.LogConfig{Dir: t.TempDir()},
+	}
+	cfg.Consul.Host = e.Consul.Addr()
+	cfg.Consul.Service.Name = "msg-center"
+	cfg.Consul.Service.ID = "msg-center-test"
+	cfg.Consul.Keys = []string{consul.Sqldb, consul.Redis, consul.Log, consul.Pulsar, consul.Auth}
+	cfg.Startup.Timeout = 5 * time.Second
+	cfg.Shutdown.Timeout = 5 * time.Second
+	cfg.Shutdown.DrainDelay = -1 // 测试中没有负载均衡, 无需等待流量切走
+	e.Config = cfg
+	return e
+}
+
+// ServerOptions 返回使用本环境依赖创建 Server 的选项, 可追加选项替换组件
+func (e *Env) ServerOptions(opts ...server.Option) []server.Option {
+	return append([]server.Option{
+		server.WithConfig(e.Config),
+		server.WithDbOpener(SQLiteOpener(e.DSN)),
+		server.WithBrokerOpener(func(consulconfig.Pulsar) (mq.Broker, error) {
+			return e.Broker, nil
+		}),
+	}, opts...)
+}
+
+// NewServer 使用本环境依赖启动完整的 Server, 测试结束时优雅下线.
+// 可通过 Server.App.Test 发起请求
+func (e *Env) NewServer(t testing.TB, opts ...server.Option) *server.Server {
+	t.Helper()
+
+	s := server.New(e.ServerOptions(opts...)...)
+	if err := s.Boot(context.Background()); err != nil {
+		t.Fatalf("启动服务失败: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), e.Config.Shutdown.Timeout)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			t.Errorf("服务下线失败: %v", err)
+		}
+	})
+	return s
+}
+
+// Token 签发测试令牌, 有效期一小时
+func (e *Env) Token(t testing.TB, claims auth.Claims) string {
+	t.Helper()
+	signer, err := auth.NewSigner(AuthSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := signer.Sign(claims, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// AdminToken 签发管理员令牌
+func (e *Env) AdminToken(t testing.TB) string {
+	t.Helper()
+	return e.Token(t, auth.Claims{Admin: true})
+}
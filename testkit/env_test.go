@@ -0,0 +1,112 @@
+package testkit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"go.uber.org/zap"
+
+	"msgcenter/platform/consul"
+)
+
+func TestNewServerIsReady(t *testing.T) {
+	env := NewEnv(t)
+	s := env.NewServer(t)
+
+	resp, err := s.App.Test(httptest.NewRequest(http.MethodGet, "/readyz", nil), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("/readyz = %d: %s", resp.StatusCode, body)
+	}
+	for _, dep := range []string{"consul", "postgres", "redis"} {
+		if !strings.Contains(string(body), `"`+dep+`":{"status":"up"`) {
+			t.Errorf("依赖 %s 应为 up: %s", dep, body)
+		}
+	}
+}
+
+func TestConsulKVWatch(t *testing.T) {
+	fake := NewConsul(t)
+	client, err := consul.NewClient(fake.Addr(), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	fake.Put(consul.Log, []byte(`{"level":"info"}`))
+	changed := make(chan string, 1)
+	client.RegisterCallback(consul.Log, func(_, data []byte) { changed <- string(data) })
+	client.StartDynamicWatch(consul.WatchConfig{Keys: []string{consul.Log}})
+
+	// 首次读取后再修改, 阻塞查询应被唤醒并触发回调
+	deadline := time.After(5 * time.Second)
+	for string(client.GetConfigValue(consul.Log)) != `{"level":"info"}` {
+		select {
+		case <-deadline:
+			t.Fatal("读取配置超时")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	fake.Put(consul.Log, []byte(`{"level":"debug"}`))
+	for {
+		select {
+		case data := <-changed:
+			if data == `{"level":"debug"}` {
+				return
+			}
+		case <-deadline:
+			t.Fatal("配置变更后未触发回调")
+		}
+	}
+}
+
+func TestConsulSessionLock(t *testing.T) {
+	fake := NewConsul(t)
+	cfg := api.DefaultConfig()
+	cfg.Address = fake.Addr()
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSession := func() string {
+		id, _, err := client.Session().Create(&api.SessionEntry{TTL: "10s"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	acquire := func(session string) bool {
+		ok, _, err := client.KV().Acquire(&api.KVPair{Key: "lock", Session: session}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	a, b := newSession(), newSession()
+	if !acquire(a) {
+		t.Fatal("a 应获得锁")
+	}
+	if acquire(b) {
+		t.Fatal("锁被 a 持有时 b 不应获得")
+	}
+
+	// session 失效后锁被释放
+	fake.InvalidateSession(a)
+	if fake.LockHolder("lock") != "" {
+		t.Fatal("session 失效后锁应被释放")
+	}
+	if !acquire(b) {
+		t.Fatal("a 失效后 b 应获得锁")
+	}
+}
@@ -0,0 +1,24 @@
+package testkit
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// NewRedis 启动内存版 redis, 支持 tieredCache 用到的字符串、哈希、集合和发布订阅命令
+func NewRedis(t testing.TB) *miniredis.Miniredis {
+	t.Helper()
+	return miniredis.RunT(t)
+}
+
+// NewRedisClient 启动内存版 redis 并返回连接到它的客户端, 测试结束时自动关闭
+func NewRedisClient(t testing.TB) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := NewRedis(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return client, mr
+}
@@ -7,8 +7,12 @@ import (
 	"sync"
 	"time"
 
+	"msgcenter/platform/metrics"
+	"msgcenter/utils/logger"
+
 	"github.com/bytedance/sonic"
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
 // CacheEntry 定义缓存条目结构
@@ -66,6 +70,7 @@ type tieredCache struct {
 	statsMu    sync.Mutex
 	keyLocks   *keyLock
 	config     CacheConfig
+	logger     *zap.Logger
 }
 
 type keyLock struct {
@@ -97,6 +102,7 @@ func NewTieredCache(redisClient *redis.Client, config CacheConfig) Cache {
 		redis:    redisClient,
 		keyLocks: &keyLock{},
 		config:   config,
+		logger:   logger.Named(logger.Cache),
 	}
 }
 
@@ -104,17 +110,13 @@ func (c *tieredCache) Get(ctx context.Context, key string) (*CacheEntry, error)
 	if val, ok := c.localCache.Load(key); ok {
 		entry := val.(*CacheEntry)
 		if entry.Expiration.After(time.Now()) {
-			c.statsMu.Lock()
-			c.stats.LocalHits++
-			c.statsMu.Unlock()
+			c.record(&c.stats.LocalHits, "local", "hit")
 			return entry, nil
 		}
 		c.localCache.Delete(key)
 	}
 
-	c.statsMu.Lock()
-	c.stats.LocalMisses++
-	c.statsMu.Unlock()
+	c.record(&c.stats.LocalMisses, "local", "miss")
 
 	val, err := c.redis.Get(ctx, key).Result()
 	if err == nil {
@@ -131,9 +133,7 @@ func (c *tieredCache) Get(ctx context.Context, key string) (*CacheEntry, error)
 
 		c.localCache.Store(key, entry)
 
-		c.statsMu.Lock()
-		c.stats.RedisHits++
-		c.statsMu.Unlock()
+		c.record(&c.stats.RedisHits, "redis", "hit")
 
 		return entry, nil
 	}
@@ -142,9 +142,7 @@ func (c *tieredCache) Get(ctx context.Context, key string) (*CacheEntry, error)
 		return nil, fmt.Errorf("redis get error: %w", err)
 	}
 
-	c.statsMu.Lock()
-	c.stats.RedisMisses++
-	c.statsMu.Unlock()
+	c.record(&c.stats.RedisMisses, "redis", "miss")
 
 	return nil, nil
 }
@@ -190,9 +188,7 @@ func (c *tieredCache) GetOrSet(ctx context.Context, key string, fn func() (inter
 		return entry.Value, nil
 	}
 
-	c.statsMu.Lock()
-	c.stats.DBFetches++
-	c.statsMu.Unlock()
+	c.recordFetch()
 
 	result, err := fn()
 	if err != nil {
@@ -221,17 +217,13 @@ func (c *tieredCache) GetOrSetWithHash(ctx context.Context, hashKey, field strin
 	if val, ok := c.localCache.Load(hashKey); ok {
 		if hash, ok := val.(map[string]interface{}); ok {
 			if value, exists := hash[field]; exists {
-				c.statsMu.Lock()
-				c.stats.LocalHits++
-				c.statsMu.Unlock()
+				c.record(&c.stats.LocalHits, "local", "hit")
 				return value, nil
 			}
 		}
 	}
 
-	c.statsMu.Lock()
-	c.stats.LocalMisses++
-	c.statsMu.Unlock()
+	c.record(&c.stats.LocalMisses, "local", "miss")
 
 	value, err := c.redis.HGet(ctx, hashKey, field).Result()
 	if err == nil {
@@ -249,9 +241,7 @@ func (c *tieredCache) GetOrSetWithHash(ctx context.Context, hashKey, field strin
 			c.localCache.Store(hashKey, hash)
 		}
 
-		c.statsMu.Lock()
-		c.stats.RedisHits++
-		c.statsMu.Unlock()
+		c.record(&c.stats.RedisHits, "redis", "hit")
 
 		return result, nil
 	}
@@ -260,9 +250,7 @@ func (c *tieredCache) GetOrSetWithHash(ctx context.Context, hashKey, field strin
 		return nil, fmt.Errorf("redis hget error: %w", err)
 	}
 
-	c.statsMu.Lock()
-	c.stats.RedisMisses++
-	c.statsMu.Unlock()
+	c.record(&c.stats.RedisMisses, "redis", "miss")
 
 	unlock := c.keyLocks.Lock(hashKey + ":" + field)
 	defer unlock()
@@ -277,9 +265,7 @@ func (c *tieredCache) GetOrSetWithHash(ctx context.Context, hashKey, field strin
 		return nil, fmt.Errorf("redis hget error: %w", err)
 	}
 
-	c.statsMu.Lock()
-	c.stats.DBFetches++
-	c.statsMu.Unlock()
+	c.recordFetch()
 
 	result, err := fn()
 	if err != nil {
@@ -313,15 +299,11 @@ func (c *tieredCache) GetOrSetWithHash(ctx context.Context, hashKey, field strin
 
 func (c *tieredCache) GetOrSetWithSet(ctx context.Context, setKey string, fn func() ([]interface{}, error), ttl time.Duration) ([]interface{}, error) {
 	if val, ok := c.localCache.Load(setKey); ok {
-		c.statsMu.Lock()
-		c.stats.LocalHits++
-		c.statsMu.Unlock()
+		c.record(&c.stats.LocalHits, "local", "hit")
 		return val.([]interface{}), nil
 	}
 
-	c.statsMu.Lock()
-	c.stats.LocalMisses++
-	c.statsMu.Unlock()
+	c.record(&c.stats.LocalMisses, "local", "miss")
 
 	members, err := c.redis.SMembers(ctx, setKey).Result()
 	if err != nil {
@@ -340,16 +322,12 @@ func (c *tieredCache) GetOrSetWithSet(ctx context.Context, setKey string, fn fun
 
 		c.localCache.Store(setKey, result)
 
-		c.statsMu.Lock()
-		c.stats.RedisHits++
-		c.statsMu.Unlock()
+		c.record(&c.stats.RedisHits, "redis", "hit")
 
 		return result, nil
 	}
 
-	c.statsMu.Lock()
-	c.stats.RedisMisses++
-	c.statsMu.Unlock()
+	c.record(&c.stats.RedisMisses, "redis", "miss")
 
 	unlock := c.keyLocks.Lock(setKey)
 	defer unlock()
@@ -368,9 +346,7 @@ func (c *tieredCache) GetOrSetWithSet(ctx context.Context, setKey string, fn fun
 		return result, nil
 	}
 
-	c.statsMu.Lock()
-	c.stats.DBFetches++
-	c.statsMu.Unlock()
+	c.recordFetch()
 
 	result, err := fn()
 	if err != nil {
@@ -396,6 +372,22 @@ func (c *tieredCache) GetOrSetWithSet(ctx context.Context, setKey string, fn fun
 	return result, nil
 }
 
+// record 更新命中统计并上报监控指标
+func (c *tieredCache) record(stat *int64, tier, result string) {
+	c.statsMu.Lock()
+	*stat++
+	c.statsMu.Unlock()
+	metrics.CacheRequests.WithLabelValues(tier, result).Inc()
+}
+
+// recordFetch 更新回源统计并上报监控指标
+func (c *tieredCache) recordFetch() {
+	c.statsMu.Lock()
+	c.stats.DBFetches++
+	c.statsMu.Unlock()
+	metrics.CacheFetches.Inc()
+}
+
 func (c *tieredCache) Stats() *CacheStats {
 	c.statsMu.Lock()
 	defer c.statsMu.Unlock()
@@ -412,13 +404,20 @@ func (c *tieredCache) StartInvalidationListener(ctx context.Context) {
 	pubsub := c.redis.Subscribe(ctx, "cache_invalidation")
 	ch := pubsub.Channel()
 
+	c.logger.Info("缓存失效监听已启动")
 	go func() {
+		defer c.logger.Info("缓存失效监听已停止")
 		for msg := range ch {
 			c.localCache.Delete(msg.Payload)
+			c.logger.Debug("本地缓存已失效", zap.String("key", msg.Payload))
 		}
 	}()
 }
 
 func (c *tieredCache) PublishInvalidation(ctx context.Context, key string) error {
-	return c.redis.Publish(ctx, "cache_invalidation", key).Err()
+	if err := c.redis.Publish(ctx, "cache_invalidation", key).Err(); err != nil {
+		c.logger.Warn("发布缓存失效通知失败", zap.String("key", key), zap.Error(err))
+		return err
+	}
+	return nil
 }
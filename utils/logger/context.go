@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"context"
+	"msgcenter/utils/reqctx"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Ctx 返回附带请求ID、用户ID、设备ID的全局日志
+func Ctx(ctx context.Context) *zap.Logger {
+	return With(ctx, zap.L())
+}
+
+// With 为指定日志附加上下文中的请求信息
+func With(ctx context.Context, l *zap.Logger) *zap.Logger {
+	if ctx == nil {
+		return l
+	}
+	fields := make([]zap.Field, 0, 4)
+	if id := reqctx.RequestID(ctx); id != "" {
+		fields = append(fields, zap.String("request_id", id))
+	}
+	if id := reqctx.UserID(ctx); id != "" {
+		fields = append(fields, zap.String("user_id", id))
+	}
+	if id := reqctx.DeviceID(ctx); id != "" {
+		fields = append(fields, zap.String("device_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields, zap.String("trace_id", sc.TraceID().String()))
+	}
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields...)
+}
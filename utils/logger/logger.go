@@ -0,0 +1,290 @@
+package logger
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 子系统日志模块名
+const (
+	Root      = "root"
+	Consul    = "consul"
+	Cache     = "cache"
+	WS        = "ws"
+	Delivery  = "delivery"
+	HTTP      = "http"
+	SQL       = "sql"
+	Redis     = "redis"
+	MQ        = "mq"
+	Retention = "retention"
+)
+
+// SamplingConfig 热点日志采样配置, 每个 Tick 内同一条日志前 First 条全部输出, 之后每 Thereafter 条输出一条
+type SamplingConfig struct {
+	Tick       time.Duration
+	First      int
+	Thereafter int
+}
+
+// 日志级别的来源, 按优先级从低到高排列. 同一模块以优先级最高的来源为准,
+// 某个来源更新时只替换自己设置的级别, 不影响其他来源
+const (
+	SourceFile   = "file"   // 本地配置文件
+	SourceConsul = "consul" // consul 运行时配置
+	SourceAPI    = "api"    // 管理接口
+)
+
+var sources = []string{SourceFile, SourceConsul, SourceAPI}
+
+type module struct {
+	level   zap.AtomicLevel
+	logger  *zap.Logger
+	sampled *zap.Logger
+}
+
+// Manager 管理全局及各子系统的日志级别
+type Manager struct {
+	mu       sync.RWMutex
+	encoder  zapcore.Encoder
+	out      zapcore.WriteSyncer
+	opts     []zap.Option
+	sampling SamplingConfig
+	base     zapcore.Level
+	root     zap.AtomicLevel
+	logger   *zap.Logger
+	modules  map[string]*module
+	// 各来源设置的级别, 键为模块名, 全局级别以 Root 为键
+	levels map[string]map[string]zapcore.Level
+}
+
+var (
+	defaultManager *Manager
+	defaultMu      sync.RWMutex
+)
+
+// New 创建日志管理器, level 为各来源都未设置时的全局级别.
+// opts 同时用于全局和子系统日志, 日志都由调用方直接输出, 不应包含 AddCallerSkip
+func New(encoder zapcore.Encoder, out zapcore.WriteSyncer, level zapcore.Level, sampling SamplingConfig, opts ...zap.Option) *Manager {
+	m := &Manager{
+		encoder:  encoder,
+		out:      out,
+		opts:     opts,
+		sampling: sampling,
+		base:     level,
+		root:     zap.NewAtomicLevelAt(level),
+		modules:  make(map[string]*module),
+		levels:   make(map[string]map[string]zapcore.Level, len(sources)),
+	}
+	m.logger = zap.New(zapcore.NewCore(encoder, out, m.root), opts...)
+	return m
+}
+
+// SetDefault 设置全局默认的日志管理器
+func SetDefault(m *Manager) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultManager = m
+}
+
+// Default 返回全局默认的日志管理器, 未初始化时返回nil
+func Default() *Manager {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultManager
+}
+
+// Named 从默认管理器获取子系统日志, 未初始化时退化为 zap.L()
+func Named(name string) *zap.Logger {
+	if m := Default(); m != nil {
+		return m.Named(name)
+	}
+	return zap.L().Named(name)
+}
+
+// Sampled 从默认管理器获取带采样的子系统日志
+func Sampled(name string) *zap.Logger {
+	if m := Default(); m != nil {
+		return m.Sampled(name)
+	}
+	return zap.L().Named(name)
+}
+
+func (m *Manager) Logger() *zap.Logger {
+	return m.logger
+}
+
+// Named 获取子系统日志, 级别可独立配置, 未配置时跟随全局级别
+func (m *Manager) Named(name string) *zap.Logger {
+	return m.module(name).logger
+}
+
+// Sampled 获取带采样的子系统日志, 用于访问日志等高频场景
+func (m *Manager) Sampled(name string) *zap.Logger {
+	mod := m.module(name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mod.sampled == nil {
+		mod.sampled = mod.logger
+		if m.sampling.Tick > 0 {
+			mod.sampled = mod.logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+				return zapcore.NewSamplerWithOptions(core, m.sampling.Tick, m.sampling.First, m.sampling.Thereafter)
+			}))
+		}
+	}
+	return mod.sampled
+}
+
+func (m *Manager) module(name string) *module {
+	m.mu.RLock()
+	mod, ok := m.modules[name]
+	m.mu.RUnlock()
+	if ok {
+		return mod
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mod, ok = m.modules[name]; ok {
+		return mod
+	}
+	level := zap.NewAtomicLevelAt(m.effective(name))
+	mod = &module{
+		level:  level,
+		logger: zap.New(zapcore.NewCore(m.encoder, m.out, level), m.opts...).Named(name),
+	}
+	m.modules[name] = mod
+	return mod
+}
+
+// SetLevel 设置指定来源的日志级别, name 为空或 root 时设置全局级别
+func (m *Manager) SetLevel(source, name, level string) error {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	name = moduleName(name)
+	if name != Root {
+		m.module(name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.levels[source] == nil {
+		m.levels[source] = make(map[string]zapcore.Level)
+	}
+	m.levels[source][name] = lvl
+	m.resolve()
+	return nil
+}
+
+// ResetLevel 清除指定来源设置的级别, 恢复为其他来源的级别
+func (m *Manager) ResetLevel(source, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.levels[source], moduleName(name))
+	m.resolve()
+}
+
+// ApplyLevels 整体替换指定来源的级别配置, root 为空表示该来源不设置全局级别.
+// 配置有误时不做任何修改
+func (m *Manager) ApplyLevels(source, root string, modules map[string]string) error {
+	levels := make(map[string]zapcore.Level, len(modules)+1)
+	if root != "" {
+		lvl, err := parseLevel(root)
+		if err != nil {
+			return err
+		}
+		levels[Root] = lvl
+	}
+	for name, level := range modules {
+		lvl, err := parseLevel(level)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		levels[moduleName(name)] = lvl
+	}
+	for name := range levels {
+		if name != Root {
+			m.module(name)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.levels[source] = levels
+	m.resolve()
+	return nil
+}
+
+// resolve 按来源优先级重新计算全局及各子系统的级别, 调用方需持有锁
+func (m *Manager) resolve() {
+	m.root.SetLevel(m.effective(Root))
+	for name, mod := range m.modules {
+		mod.level.SetLevel(m.effective(name))
+	}
+}
+
+// effective 返回模块生效的级别: 优先级最高的来源为该模块设置的级别, 都未设置时跟随全局级别.
+// 调用方需持有锁
+func (m *Manager) effective(name string) zapcore.Level {
+	for _, source := range slices.Backward(sources) {
+		if lvl, ok := m.levels[source][name]; ok {
+			return lvl
+		}
+	}
+	if name == Root {
+		return m.base
+	}
+	return m.effective(Root)
+}
+
+func moduleName(name string) string {
+	if name == "" {
+		return Root
+	}
+	return name
+}
+
+func parseLevel(level string) (zapcore.Level, error) {
+	var lvl zapcore.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return lvl, fmt.Errorf("无效的日志级别 %q: %w", level, err)
+	}
+	return lvl, nil
+}
+
+// Levels 返回全局及各子系统当前的日志级别
+func (m *Manager) Levels() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	levels := map[string]string{Root: m.root.String()}
+	for name, mod := range m.modules {
+		levels[name] = mod.level.String()
+	}
+	return levels
+}
+
+// Overrides 返回各来源设置的级别, 用于排查级别为何没有生效
+func (m *Manager) Overrides() map[string]map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	overrides := make(map[string]map[string]string, len(m.levels))
+	for source, levels := range m.levels {
+		if len(levels) == 0 {
+			continue
+		}
+		overrides[source] = make(map[string]string, len(levels))
+		for name, lvl := range levels {
+			overrides[source][name] = lvl.String()
+		}
+	}
+	return overrides
+}
@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newTestManager(buf *bytes.Buffer) *Manager {
+	encoder := zapcore.NewConsoleEncoder(zapcore.EncoderConfig{
+		MessageKey:   "msg",
+		CallerKey:    "caller",
+		EncodeCaller: zapcore.ShortCallerEncoder,
+	})
+	return New(encoder, zapcore.AddSync(buf), zap.InfoLevel, SamplingConfig{}, zap.AddCaller())
+}
+
+func TestCallerPointsToCallSite(t *testing.T) {
+	var buf bytes.Buffer
+	m := newTestManager(&buf)
+
+	m.Logger().Info("root")
+	m.Named(SQL).Info("module")
+	m.Sampled(HTTP).Info("sampled")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("日志行数 = %d: %q", len(lines), buf.String())
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "logger/logger_test.go:") {
+			t.Errorf("caller 应为调用处: %q", line)
+		}
+	}
+}
+
+func TestLevelSourcesMerge(t *testing.T) {
+	m := newTestManager(&bytes.Buffer{})
+	levels := func() map[string]string { return m.Levels() }
+
+	if err := m.ApplyLevels(SourceFile, "info", map[string]string{SQL: "debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ApplyLevels(SourceConsul, "warn", map[string]string{Redis: "error"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetLevel(SourceAPI, Redis, "debug"); err != nil {
+		t.Fatal(err)
+	}
+	m.Named(MQ)
+
+	want := map[string]string{Root: "warn", SQL: "debug", Redis: "debug", MQ: "warn"}
+	for name, lvl := range want {
+		if got := levels()[name]; got != lvl {
+			t.Errorf("%s = %s, want %s", name, got, lvl)
+		}
+	}
+
+	// 配置文件重新加载不影响 consul 和接口设置的级别
+	if err := m.ApplyLevels(SourceFile, "debug", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := levels(); got[Root] != "warn" || got[Redis] != "debug" || got[SQL] != "warn" {
+		t.Fatalf("文件重载后 levels = %v", got)
+	}
+
+	// 清除接口设置后恢复 consul 的级别, 清除 consul 后恢复配置文件的级别
+	m.ResetLevel(SourceAPI, Redis)
+	if got := levels()[Redis]; got != "error" {
+		t.Fatalf("清除接口级别后 redis = %s, want error", got)
+	}
+	if err := m.ApplyLevels(SourceConsul, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := levels(); got[Root] != "debug" || got[Redis] != "debug" {
+		t.Fatalf("清除consul级别后 levels = %v", got)
+	}
+}
+
+func TestApplyLevelsInvalidKeepsLevels(t *testing.T) {
+	m := newTestManager(&bytes.Buffer{})
+	if err := m.ApplyLevels(SourceConsul, "warn", map[string]string{SQL: "debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ApplyLevels(SourceConsul, "info", map[string]string{SQL: "verbose"}); err == nil {
+		t.Fatal("无效级别应返回错误")
+	}
+	if got := m.Levels(); got[Root] != "warn" || got[SQL] != "debug" {
+		t.Fatalf("配置有误时不应修改级别, levels = %v", got)
+	}
+}
+
+func TestModuleLevelGates(t *testing.T) {
+	var buf bytes.Buffer
+	m := newTestManager(&buf)
+	if err := m.SetLevel(SourceAPI, SQL, "error"); err != nil {
+		t.Fatal(err)
+	}
+	m.Named(SQL).Info("hidden")
+	m.Named(Redis).Info("shown")
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "shown") {
+		t.Fatalf("输出 = %q", out)
+	}
+}
@@ -1,116 +1,215 @@
 package objectid
 
 import (
-	"crypto/md5"
+	"bytes"
+	"crypto/rand"
+	"database/sql/driver"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"math/rand"
-	"net"
-	"os"
+	"slices"
 	"sync"
 	"time"
 )
 
+// 12字节布局: 4字节秒级时间戳 | 2字节毫秒 | 3字节节点ID | 3字节自增序列.
+// 前4字节与标准ObjectId一致, 旧数据仍可按时间排序
 const (
-	// 定义各部分的字节长度
-	timestampLen = 4
-	machineLen   = 5
-	processLen   = 2
-	incLen       = 3
+	// MaxNode 节点ID上限(24位)
+	MaxNode    = 1<<24 - 1
+	maxCounter = 1<<24 - 1
 )
 
-var (
-	// 全局自增序列（需要加锁）
-	objectIDCounter uint32
-	mutex           sync.Mutex
+// LayoutCutover 毫秒布局的启用时间. 此前生成的ID为标准ObjectId布局,
+// 第5-6字节是随机值而非毫秒, Timestamp 对这些ID只取秒级时间戳.
+// 所有实例必须在该时间之前升级到毫秒布局, 否则旧版本之后生成的ID会被误读出毫秒;
+// 升级无法按期完成时通过配置 node.layout_cutover 推迟, 启动时由 server 覆盖
+var LayoutCutover = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
 
-	// 机器标识（启动时初始化）
-	machineID []byte
+var (
+	mutex sync.Mutex
+	// 节点ID, 未配置时启动随机生成, 多副本部署应通过 SetNode 指定唯一值
+	node uint32
+	// 上次生成使用的毫秒时间戳和该毫秒内的序列
+	lastMillis int64
+	counter    uint32
 )
 
 func init() {
-	// 初始化机器标识
-	initMachineID()
-	// 随机初始化计数器
-	objectIDCounter = rand.Uint32()
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	node = binary.BigEndian.Uint32(b[:]) & MaxNode
 }
 
-// 生成机器标识（5字节）
-func initMachineID() {
-	machineID = make([]byte, machineLen)
-
-	// 3字节：主机名哈希
-	hostname, err := os.Hostname()
-	if err != nil {
-		hostname = "localhost"
-	}
-	hash := md5.Sum([]byte(hostname))
-	copy(machineID, hash[:3])
-
-	// 2字节：进程ID + 网卡MAC哈希
-	pid := os.Getpid()
-	machineID[3] = byte(pid >> 8)
-	machineID[4] = byte(pid)
-
-	// 如果可能，加入网卡信息
-	if interfaces, err := net.Interfaces(); err == nil {
-		for _, i := range interfaces {
-			if len(i.HardwareAddr) > 0 {
-				hash := md5.Sum(i.HardwareAddr)
-				machineID[4] ^= hash[0] // 简单混合
-				break
-			}
-		}
+// SetNode 设置节点ID, 同一时刻运行的实例必须互不相同
+func SetNode(n uint32) error {
+	if n > MaxNode {
+		return fmt.Errorf("objectid: 节点ID %d 超出范围 [0, %d]", n, MaxNode)
 	}
+	mutex.Lock()
+	node = n
+	mutex.Unlock()
+	return nil
 }
 
-// 生成新的ObjectId
-func New() string {
-	var id [12]byte
+// Node 返回当前节点ID
+func Node() uint32 {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return node
+}
 
-	// 4字节时间戳（秒级）
-	timestamp := uint32(time.Now().Unix())
-	binary.BigEndian.PutUint32(id[:timestampLen], timestamp)
+// ID 12字节的ObjectId, 文本形式为24位小写十六进制
+type ID [12]byte
 
-	// 5字节机器标识
-	copy(id[timestampLen:timestampLen+machineLen], machineID)
+// Nil 零值ID
+var Nil ID
 
-	// 3字节自增序列（需要加锁）
+// ErrInvalid ObjectId格式错误
+var ErrInvalid = errors.New("invalid objectid")
+
+// 生成新的ObjectId, 同一节点生成的ID严格递增.
+// 时钟回拨时沿用上次的时间戳, 同一毫秒内序列耗尽时借用下一毫秒
+func New() ID {
 	mutex.Lock()
-	counter := objectIDCounter
-	objectIDCounter++
+	if now := time.Now().UnixMilli(); now > lastMillis {
+		lastMillis = now
+		counter = 0
+	} else if counter++; counter > maxCounter {
+		lastMillis++
+		counter = 0
+	}
+	millis, n, seq := lastMillis, node, counter
 	mutex.Unlock()
 
-	// 写入计数器（取低24位）
-	id[9] = byte(counter >> 16)
-	id[10] = byte(counter >> 8)
-	id[11] = byte(counter)
+	var id ID
+	binary.BigEndian.PutUint32(id[0:4], uint32(millis/1000))
+	binary.BigEndian.PutUint16(id[4:6], uint16(millis%1000))
+	id[6], id[7], id[8] = byte(n>>16), byte(n>>8), byte(n)
+	id[9], id[10], id[11] = byte(seq>>16), byte(seq>>8), byte(seq)
+	return id
+}
+
+// 解析ObjectId, 只接受24位十六进制字符串
+func Parse(s string) (ID, error) {
+	var id ID
+	if len(s) != hex.EncodedLen(len(id)) {
+		return Nil, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	if _, err := hex.Decode(id[:], []byte(s)); err != nil {
+		return Nil, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	return id, nil
+}
+
+// MustParse 解析失败时panic, 仅用于常量和测试
+func MustParse(s string) ID {
+	id, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// IsValid 判断字符串是否为合法的ObjectId
+func IsValid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
 
+func (id ID) String() string {
 	return hex.EncodeToString(id[:])
 }
 
-// 解析ObjectId
-func Parse(id string) (timestamp time.Time, machine string, counter uint32, err error) {
-	if len(id) != 24 {
-		err = fmt.Errorf("invalid objectid length")
-		return
+func (id ID) IsZero() bool {
+	return id == Nil
+}
+
+// Compact 返回排序去重并去掉零值后的新切片, 不修改 ids
+func Compact(ids []ID) []ID {
+	ids = slices.DeleteFunc(slices.Clone(ids), ID.IsZero)
+	slices.SortFunc(ids, ID.Compare)
+	return slices.Compact(ids)
+}
+
+// Compare 按字节比较, 即按生成时间排序, 可用于 slices.SortFunc
+func (id ID) Compare(other ID) int {
+	return bytes.Compare(id[:], other[:])
+}
+
+// Timestamp 生成时间, 精确到毫秒; LayoutCutover 之前的旧格式ID只精确到秒
+func (id ID) Timestamp() time.Time {
+	secs := int64(binary.BigEndian.Uint32(id[0:4]))
+	millis := int64(binary.BigEndian.Uint16(id[4:6]))
+	if secs < LayoutCutover.Unix() || millis >= 1000 {
+		return time.Unix(secs, 0)
 	}
+	return time.UnixMilli(secs*1000 + millis)
+}
+
+// Node 生成该ID的节点ID
+func (id ID) Node() uint32 {
+	return uint32(id[6])<<16 | uint32(id[7])<<8 | uint32(id[8])
+}
+
+// Counter 自增序列
+func (id ID) Counter() uint32 {
+	return uint32(id[9])<<16 | uint32(id[10])<<8 | uint32(id[11])
+}
 
-	bytes, err := hex.DecodeString(id)
+func (id ID) MarshalText() ([]byte, error) {
+	buf := make([]byte, hex.EncodedLen(len(id)))
+	hex.Encode(buf, id[:])
+	return buf, nil
+}
+
+func (id *ID) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
 	if err != nil {
-		return
+		return err
 	}
+	*id = parsed
+	return nil
+}
 
-	// 解析时间戳
-	secs := binary.BigEndian.Uint32(bytes[:timestampLen])
-	timestamp = time.Unix(int64(secs), 0)
+// MarshalJSON 零值编码为 null
+func (id ID) MarshalJSON() ([]byte, error) {
+	if id.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + id.String() + `"`), nil
+}
 
-	// 解析机器标识
-	machine = hex.EncodeToString(bytes[timestampLen : timestampLen+machineLen])
+// UnmarshalJSON 接受 null 和空字符串作为零值
+func (id *ID) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" || s == `""` {
+		*id = Nil
+		return nil
+	}
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return fmt.Errorf("%w: %s", ErrInvalid, s)
+	}
+	return id.UnmarshalText([]byte(s[1 : len(s)-1]))
+}
 
-	// 解析计数器
-	counter = uint32(bytes[9])<<16 | uint32(bytes[10])<<8 | uint32(bytes[11])
+// Scan 实现 sql.Scanner, 数据库中以24位字符串存储
+func (id *ID) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*id = Nil
+		return nil
+	case string:
+		return id.UnmarshalText([]byte(v))
+	case []byte:
+		return id.UnmarshalText(v)
+	default:
+		return fmt.Errorf("objectid: 不支持从 %T 读取", src)
+	}
+}
 
-	return
+// Value 实现 driver.Valuer
+func (id ID) Value() (driver.Value, error) {
+	return id.String(), nil
 }
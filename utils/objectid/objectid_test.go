@@ -0,0 +1,186 @@
+package objectid
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	const s = "65a1b2c3d4e5f60718293a4b"
+	id, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.String() != s {
+		t.Fatalf("String() = %s, want %s", id, s)
+	}
+
+	for _, bad := range []string{"", "65a1b2c3", "65a1b2c3d4e5f60718293a4", "zza1b2c3d4e5f60718293a4b", s + "00"} {
+		if _, err := Parse(bad); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q) err = %v, want ErrInvalid", bad, err)
+		}
+		if IsValid(bad) {
+			t.Errorf("IsValid(%q) = true", bad)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type body struct {
+		ID    ID  `json:"id"`
+		Owner ID  `json:"owner"`
+		Ref   *ID `json:"ref"`
+	}
+	id := New()
+	data, err := json.Marshal(body{ID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 零值编码为 null
+	want := `{"id":"` + id.String() + `","owner":null,"ref":null}`
+	if string(data) != want {
+		t.Fatalf("Marshal = %s, want %s", data, want)
+	}
+
+	var got body
+	if err := json.Unmarshal([]byte(`{"id":"`+id.String()+`","owner":""}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != id || !got.Owner.IsZero() {
+		t.Fatalf("Unmarshal = %+v", got)
+	}
+
+	for _, bad := range []string{`{"id":"bad"}`, `{"id":123}`} {
+		if err := json.Unmarshal([]byte(bad), &got); err == nil {
+			t.Errorf("Unmarshal(%s) 应返回错误", bad)
+		}
+	}
+}
+
+func TestScanValue(t *testing.T) {
+	id := New()
+	v, err := id.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != id.String() {
+		t.Fatalf("Value() = %v", v)
+	}
+
+	for _, src := range []any{id.String(), []byte(id.String())} {
+		var got ID
+		if err := got.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		if got != id {
+			t.Fatalf("Scan(%T) = %s, want %s", src, got, id)
+		}
+	}
+
+	got := id
+	if err := got.Scan(nil); err != nil || !got.IsZero() {
+		t.Fatalf("Scan(nil) = %s, %v", got, err)
+	}
+	if err := got.Scan(int64(1)); err == nil {
+		t.Fatal("Scan(int64) 应返回错误")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	a, b, c := New(), New(), New()
+	in := []ID{c, Nil, a, b, a, c}
+	got := Compact(in)
+	if !slices.Equal(got, []ID{a, b, c}) {
+		t.Fatalf("Compact = %v", got)
+	}
+	// 不修改入参
+	if in[0] != c || in[1] != Nil {
+		t.Fatalf("Compact 修改了入参: %v", in)
+	}
+}
+
+// setState 设置生成器状态, 测试结束后恢复
+func setState(t *testing.T, millis int64, seq uint32) {
+	t.Helper()
+	mutex.Lock()
+	oldMillis, oldCounter := lastMillis, counter
+	lastMillis, counter = millis, seq
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		lastMillis, counter = oldMillis, oldCounter
+		mutex.Unlock()
+	})
+}
+
+func TestNewMonotonic(t *testing.T) {
+	prev := New()
+	for range 10000 {
+		id := New()
+		if id.Compare(prev) <= 0 {
+			t.Fatalf("ID未严格递增: %s <= %s", id, prev)
+		}
+		prev = id
+	}
+	if prev.Node() != Node() {
+		t.Fatalf("Node() = %d, want %d", prev.Node(), Node())
+	}
+}
+
+func TestNewCounterOverflow(t *testing.T) {
+	// 时间戳设在未来, 模拟时钟回拨且当前毫秒序列已耗尽
+	future := time.Now().Add(time.Hour).UnixMilli()
+	setState(t, future, maxCounter)
+
+	id := New()
+	if got := id.Timestamp().UnixMilli(); got != future+1 {
+		t.Fatalf("序列耗尽后时间戳 = %d, want %d", got, future+1)
+	}
+	if id.Counter() != 0 {
+		t.Fatalf("序列耗尽后 Counter = %d, want 0", id.Counter())
+	}
+	next := New()
+	if next.Timestamp().UnixMilli() != future+1 || next.Counter() != 1 {
+		t.Fatalf("回拨期间应沿用上次时间戳: %v, %d", next.Timestamp(), next.Counter())
+	}
+}
+
+func TestSetNode(t *testing.T) {
+	old := Node()
+	t.Cleanup(func() { _ = SetNode(old) })
+
+	if err := SetNode(MaxNode + 1); err == nil {
+		t.Fatal("超出范围的节点ID应返回错误")
+	}
+	if err := SetNode(0xabcdef); err != nil {
+		t.Fatal(err)
+	}
+	if id := New(); id.Node() != 0xabcdef {
+		t.Fatalf("Node = %x", id.Node())
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	at := LayoutCutover.Add(90*24*time.Hour + 123*time.Millisecond)
+	var id ID
+	binary.BigEndian.PutUint32(id[0:4], uint32(at.Unix()))
+	binary.BigEndian.PutUint16(id[4:6], uint16(at.UnixMilli()%1000))
+	if got := id.Timestamp(); !got.Equal(at) {
+		t.Fatalf("Timestamp = %v, want %v", got, at)
+	}
+
+	// 切换前的标准ObjectId, 第5-6字节即使小于1000也不按毫秒解析
+	legacy := MustParse("5f5e1000" + "0064" + "a1b2c3d4e5f6")
+	if got := legacy.Timestamp(); !got.Equal(time.Unix(0x5f5e1000, 0)) {
+		t.Fatalf("旧格式 Timestamp = %v", got)
+	}
+
+	now := time.Now()
+	if d := New().Timestamp().Sub(now); d < -time.Second || d > time.Second {
+		t.Fatalf("新ID时间偏差 %v", d)
+	}
+}
@@ -0,0 +1,126 @@
+package reqctx
+
+import (
+	"context"
+	"msgcenter/utils/objectid"
+)
+
+// HeaderRequestID 请求ID的HTTP头及消息头名称
+const HeaderRequestID = "X-Request-ID"
+
+// 消息元数据中的键名
+const (
+	MetaRequestID = "request_id"
+	MetaUserID    = "user_id"
+	MetaDeviceID  = "device_id"
+)
+
+// maxRequestIDLen 外部传入请求ID的最大长度
+const maxRequestIDLen = 64
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	userIDKey
+	deviceIDKey
+	adminKey
+)
+
+// NewRequestID 生成新的请求ID
+func NewRequestID() string {
+	return objectid.New().String()
+}
+
+// ValidRequestID 校验外部传入的请求ID, 只允许字母数字及 -_.: 字符
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func WithUserID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userIDKey, id)
+}
+
+func UserID(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey).(string)
+	return id
+}
+
+func WithDeviceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, deviceIDKey, id)
+}
+
+func DeviceID(ctx context.Context) string {
+	id, _ := ctx.Value(deviceIDKey).(string)
+	return id
+}
+
+// WithAdmin 标记请求由管理员令牌发起
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminKey, true)
+}
+
+func Admin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey).(bool)
+	return admin
+}
+
+// WithClaims 写入已认证的用户和设备, 零值ID不写入
+func WithClaims(ctx context.Context, userID, deviceID objectid.ID) context.Context {
+	if !userID.IsZero() {
+		ctx = WithUserID(ctx, userID.String())
+	}
+	if !deviceID.IsZero() {
+		ctx = WithDeviceID(ctx, deviceID.String())
+	}
+	return ctx
+}
+
+// Metadata 导出上下文中的请求信息, 用于写入外发消息的消息头
+func Metadata(ctx context.Context) map[string]string {
+	md := make(map[string]string, 3)
+	if id := RequestID(ctx); id != "" {
+		md[MetaRequestID] = id
+	}
+	if id := UserID(ctx); id != "" {
+		md[MetaUserID] = id
+	}
+	if id := DeviceID(ctx); id != "" {
+		md[MetaDeviceID] = id
+	}
+	return md
+}
+
+// FromMetadata 从消息头恢复请求信息, 消费消息时继续沿用同一个请求ID
+func FromMetadata(ctx context.Context, md map[string]string) context.Context {
+	if id := md[MetaRequestID]; ValidRequestID(id) {
+		ctx = WithRequestID(ctx, id)
+	}
+	if id := md[MetaUserID]; id != "" {
+		ctx = WithUserID(ctx, id)
+	}
+	if id := md[MetaDeviceID]; id != "" {
+		ctx = WithDeviceID(ctx, id)
+	}
+	return ctx
+}
@@ -0,0 +1,41 @@
+package reqctx
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"msgcenter/utils/objectid"
+)
+
+func TestWithClaims(t *testing.T) {
+	user, device := objectid.New(), objectid.New()
+	ctx := WithClaims(context.Background(), user, device)
+	if UserID(ctx) != user.String() || DeviceID(ctx) != device.String() {
+		t.Fatalf("UserID = %q, DeviceID = %q", UserID(ctx), DeviceID(ctx))
+	}
+
+	// 管理员令牌可以不绑定设备, 零值不应写入
+	ctx = WithClaims(context.Background(), user, objectid.Nil)
+	if DeviceID(ctx) != "" {
+		t.Fatalf("零值设备不应写入, DeviceID = %q", DeviceID(ctx))
+	}
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = WithClaims(ctx, objectid.New(), objectid.New())
+
+	md := Metadata(ctx)
+	restored := FromMetadata(context.Background(), md)
+	if got := Metadata(restored); !maps.Equal(got, md) {
+		t.Fatalf("Metadata = %v, want %v", got, md)
+	}
+}
+
+func TestFromMetadataRejectsInvalidRequestID(t *testing.T) {
+	ctx := FromMetadata(context.Background(), map[string]string{MetaRequestID: "bad id\n"})
+	if RequestID(ctx) != "" {
+		t.Fatalf("不合法的请求ID不应恢复: %q", RequestID(ctx))
+	}
+}
@@ -0,0 +1,122 @@
+package snowflake
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 64位布局: 1位符号(恒为0) | 41位毫秒时间戳(相对 Epoch) | 10位节点ID | 12位序列
+const (
+	nodeBits = 10
+	seqBits  = 12
+
+	// MaxNode 节点ID上限
+	MaxNode = 1<<nodeBits - 1
+	maxSeq  = 1<<seqBits - 1
+)
+
+// Epoch 时间戳起点 2024-01-01 UTC, 41位毫秒可用约69年
+var Epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+// Generator 生成按时间递增的 int64 ID, 适用于需要按主键排序的表(如消息)
+type Generator struct {
+	mu         sync.Mutex
+	node       int64
+	lastMillis int64
+	seq        int64
+}
+
+func NewGenerator(node int64) (*Generator, error) {
+	if node < 0 || node > MaxNode {
+		return nil, fmt.Errorf("snowflake: 节点ID %d 超出范围 [0, %d]", node, MaxNode)
+	}
+	return &Generator{node: node}, nil
+}
+
+// Next 生成新ID, 同一生成器生成的ID严格递增.
+// 时钟回拨时沿用上次的时间戳, 同一毫秒内序列耗尽时借用下一毫秒
+func (g *Generator) Next() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now := time.Now().UnixMilli() - Epoch; now > g.lastMillis {
+		g.lastMillis = now
+		g.seq = 0
+	} else if g.seq++; g.seq > maxSeq {
+		g.lastMillis++
+		g.seq = 0
+	}
+	return g.lastMillis<<(nodeBits+seqBits) | g.node<<seqBits | g.seq
+}
+
+// Node 生成器的节点ID
+func (g *Generator) Node() int64 {
+	return g.node
+}
+
+var (
+	defaultMu sync.RWMutex
+	// 默认生成器, 未配置节点ID时使用随机节点, 多副本部署应通过 SetNode 指定唯一值
+	defaultGen *Generator
+)
+
+func init() {
+	var b [2]byte
+	_, _ = rand.Read(b[:])
+	defaultGen, _ = NewGenerator(int64(binary.BigEndian.Uint16(b[:])) & MaxNode)
+}
+
+// SetNode 替换默认生成器的节点ID
+func SetNode(node int64) error {
+	g, err := NewGenerator(node)
+	if err != nil {
+		return err
+	}
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	// 沿用旧生成器的时间戳, 避免切换节点后生成比之前更小的ID
+	g.lastMillis, g.seq = defaultGen.state()
+	defaultGen = g
+	return nil
+}
+
+// Next 使用默认生成器生成新ID
+func Next() int64 {
+	defaultMu.RLock()
+	g := defaultGen
+	defaultMu.RUnlock()
+	return g.Next()
+}
+
+func (g *Generator) state() (int64, int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lastMillis, g.seq
+}
+
+// Time ID 的生成时间
+func Time(id int64) time.Time {
+	return time.UnixMilli(id>>(nodeBits+seqBits) + Epoch)
+}
+
+// MinID 时间 t 之后生成的ID都不小于该值, 用于按生成时间范围查询主键
+func MinID(t time.Time) int64 {
+	ms := t.UnixMilli() - Epoch
+	if ms < 0 {
+		return 0
+	}
+	return ms << (nodeBits + seqBits)
+}
+
+// NodeOf ID 的节点ID
+func NodeOf(id int64) int64 {
+	return id >> seqBits & MaxNode
+}
+
+// Sequence ID 在所在毫秒内的序列
+func Sequence(id int64) int64 {
+	return id & maxSeq
+}
@@ -0,0 +1,94 @@
+package snowflake
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextLayout(t *testing.T) {
+	g, err := NewGenerator(MaxNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().Truncate(time.Millisecond)
+	id := g.Next()
+	if id <= 0 {
+		t.Fatalf("ID = %d, 应为正数", id)
+	}
+	if NodeOf(id) != MaxNode || Sequence(id) != 0 {
+		t.Fatalf("NodeOf = %d, Sequence = %d", NodeOf(id), Sequence(id))
+	}
+	if ts := Time(id); ts.Before(before) || ts.After(time.Now()) {
+		t.Fatalf("Time = %v, 不在生成时间范围内", ts)
+	}
+	if MinID(Time(id)) > id || MinID(Time(id).Add(time.Millisecond)) <= id {
+		t.Fatalf("MinID 与 ID %d 的时间不一致", id)
+	}
+
+	if _, err := NewGenerator(MaxNode + 1); err == nil {
+		t.Fatal("超出范围的节点ID应返回错误")
+	}
+}
+
+func TestNextSequenceOverflow(t *testing.T) {
+	g, _ := NewGenerator(1)
+	// 时间戳设在未来, 模拟时钟回拨且当前毫秒序列已耗尽
+	future := time.Now().Add(time.Hour).UnixMilli() - Epoch
+	g.lastMillis, g.seq = future, maxSeq
+
+	id := g.Next()
+	if Time(id).UnixMilli() != future+1+Epoch || Sequence(id) != 0 {
+		t.Fatalf("序列耗尽后 Time = %v, Sequence = %d", Time(id), Sequence(id))
+	}
+	next := g.Next()
+	if next <= id || Sequence(next) != 1 {
+		t.Fatalf("回拨期间应沿用上次时间戳: %d <= %d, Sequence = %d", next, id, Sequence(next))
+	}
+}
+
+func TestNextMonotonic(t *testing.T) {
+	g, _ := NewGenerator(3)
+	prev := g.Next()
+	// 超过单毫秒序列上限, 覆盖借用下一毫秒
+	for range 3 * (maxSeq + 1) {
+		id := g.Next()
+		if id <= prev {
+			t.Fatalf("ID未严格递增: %d <= %d", id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestSetNodeKeepsState(t *testing.T) {
+	defaultMu.RLock()
+	old := defaultGen
+	defaultMu.RUnlock()
+	t.Cleanup(func() {
+		defaultMu.Lock()
+		defaultGen = old
+		defaultMu.Unlock()
+	})
+
+	future := time.Now().Add(time.Hour).UnixMilli() - Epoch
+	old.mu.Lock()
+	savedMillis, savedSeq := old.lastMillis, old.seq
+	old.lastMillis, old.seq = future, 7
+	old.mu.Unlock()
+	t.Cleanup(func() {
+		old.mu.Lock()
+		old.lastMillis, old.seq = savedMillis, savedSeq
+		old.mu.Unlock()
+	})
+
+	if err := SetNode(5); err != nil {
+		t.Fatal(err)
+	}
+	// 切换节点后沿用旧生成器的时间戳, 不会生成比之前更小的ID
+	id := Next()
+	if NodeOf(id) != 5 || Time(id).UnixMilli() != future+Epoch || Sequence(id) != 8 {
+		t.Fatalf("切换节点后 Node = %d, Time = %v, Sequence = %d", NodeOf(id), Time(id), Sequence(id))
+	}
+	if err := SetNode(-1); err == nil {
+		t.Fatal("负数节点ID应返回错误")
+	}
+}